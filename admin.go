@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// adminUsage describes the admin subcommand
+const adminUsage = "usage: ride-sharing-app admin grant|revoke <email>"
+
+// runAdmin handles the "admin grant <email>" and "admin revoke <email>" subcommands.
+// Admins cannot register through the API, so this is the only way to create them.
+// Being an admin is kept apart from the user's role, which neither subcommand changes.
+func runAdmin(userRepo repository.UserRepository, args []string) error {
+	if len(args) != 2 || (args[0] != "grant" && args[0] != "revoke") {
+		return errors.New(adminUsage)
+	}
+	admin := args[0] == "grant"
+
+	user, err := userRepo.FindByEmail(args[1])
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("no user with email %s", args[1])
+	}
+	if user.IsAdmin == admin {
+		if admin {
+			return fmt.Errorf("%s is already an admin", user.Email)
+		}
+		return fmt.Errorf("%s is not an admin", user.Email)
+	}
+
+	user.IsAdmin = admin
+	if err := userRepo.Update(user); err != nil {
+		return err
+	}
+	if admin {
+		fmt.Printf("%s is now an admin; new tokens carry the flag\n", user.Email)
+	} else {
+		fmt.Printf("%s is no longer an admin; tokens issued before stay valid until they expire\n", user.Email)
+	}
+	return nil
+}
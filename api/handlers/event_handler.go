@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+)
+
+// eventKeepAlive is how often an idle Server-Sent Events stream sends a comment so that
+// proxies do not close it
+const eventKeepAlive = 25 * time.Second
+
+// EventHandler handles the real-time event streams of users
+type EventHandler struct {
+	broker *events.Broker
+}
+
+// NewEventHandler creates a new EventHandler
+func NewEventHandler(broker *events.Broker) *EventHandler {
+	return &EventHandler{
+		broker: broker,
+	}
+}
+
+// Stream handles streaming the authenticated user's ride events as they happen.
+// Clients asking for a WebSocket upgrade get one JSON event per message; other clients get
+// Server-Sent Events. A client that reconnects with the ID of the last event it saw, in the
+// Last-Event-ID header or the last_event_id query parameter, first receives the events it missed.
+func (h *EventHandler) Stream(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after uint64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+	}
+
+	subscription, err := h.broker.Subscribe(id, after)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	defer subscription.Close()
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		streamEventsOverWebSocket(c, subscription)
+		return
+	}
+	streamServerSentEvents(c, subscription)
+}
+
+// streamServerSentEvents writes a subscription's events as Server-Sent Events until the client
+// goes away or the subscription ends
+func streamServerSentEvents(c *gin.Context, subscription *events.Subscription) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	write := func(event events.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	for _, event := range subscription.Replay {
+		if write(event) != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			if write(event) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// streamEventsOverWebSocket upgrades the request and writes a subscription's events to it as JSON
+// messages until the client goes away or the subscription ends. Messages from the client are ignored.
+func streamEventsOverWebSocket(c *gin.Context, subscription *events.Subscription) {
+	conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	// Read from the client so that pongs and closing are handled, and notice when it is gone
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		conn.SetReadLimit(streamMaxMessageBytes)
+		conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(event events.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		return conn.WriteJSON(event)
+	}
+
+	for _, event := range subscription.Replay {
+		if write(event) != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(streamPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// The stream fell behind or the server is shutting down; the client should reconnect
+				closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "event stream ended")
+				conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(streamWriteWait))
+				return
+			}
+			if write(event) != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
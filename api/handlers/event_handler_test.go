@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/ride-sharing-app/api/middleware"
+	"github.com/yourusername/ride-sharing-app/infrastructure/auth"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+)
+
+// newEventServer serves the event stream of a user with a match proposed in their history
+func newEventServer(t *testing.T, userID uuid.UUID) (*httptest.Server, *auth.JWTService) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	broker := events.NewBroker(events.Config{HistorySize: 10, HistoryTTL: time.Hour, SubscriberBuffer: 10})
+	t.Cleanup(broker.Close)
+	broker.Publish(events.Event{Type: events.MatchProposed, UserID: userID, RideID: uuid.New()})
+
+	jwtService := auth.NewJWTService("test-secret", "test")
+	router := gin.New()
+	router.GET("/api/v1/events", middleware.StreamAuthMiddleware(jwtService), NewEventHandler(broker).Stream)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, jwtService
+}
+
+func TestStreamWithAStreamToken(t *testing.T) {
+	userID := uuid.New()
+	server, jwtService := newEventServer(t, userID)
+	token, err := jwtService.GenerateStreamToken(userID, "user@example.com", "passenger", false)
+	if err != nil {
+		t.Fatalf("GenerateStreamToken: %v", err)
+	}
+
+	t.Run("server-sent events", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/api/v1/events?last_event_id=1&access_token=" + token)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want %d", response.StatusCode, http.StatusOK)
+		}
+		reader := bufio.NewReader(response.Body)
+		reader.ReadString('\n') // id
+		line, err := reader.ReadString('\n')
+		if err != nil || line != "event: match_proposed\n" {
+			t.Fatalf("event line = %q, %v, want the missed event", line, err)
+		}
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		dialer := websocket.Dialer{Subprotocols: []string{middleware.StreamProtocol, token}}
+		conn, response, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/events?last_event_id=1", nil)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		defer conn.Close()
+		// Browsers drop the connection unless one of the offered protocols is picked
+		if protocol := response.Header.Get("Sec-WebSocket-Protocol"); protocol != middleware.StreamProtocol {
+			t.Errorf("protocol = %q, want %q", protocol, middleware.StreamProtocol)
+		}
+		var event events.Event
+		if err := conn.ReadJSON(&event); err != nil || event.Type != events.MatchProposed {
+			t.Fatalf("first message = %+v, %v, want the missed event", event, err)
+		}
+	})
+}
+
+func TestStreamRefusesOtherTokensOutsideTheHeader(t *testing.T) {
+	userID := uuid.New()
+	server, jwtService := newEventServer(t, userID)
+	login, err := jwtService.GenerateToken(userID, "user@example.com", "passenger", false)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	stream, err := jwtService.GenerateStreamToken(userID, "user@example.com", "passenger", false)
+	if err != nil {
+		t.Fatalf("GenerateStreamToken: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		query  string
+		header string
+	}{
+		{name: "no token"},
+		{name: "login token in the query", query: "?access_token=" + login},
+		{name: "stream token as a bearer token", header: "Bearer " + stream},
+		{name: "forged token", query: "?access_token=" + stream + "x"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/events"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			if tc.header != "" {
+				request.Header.Set("Authorization", tc.header)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", response.StatusCode, http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
 	"github.com/yourusername/ride-sharing-app/service"
 )
 
@@ -39,6 +42,13 @@ type CreateRideOfferRequest struct {
 	AvailableSeats  int       `json:"available_seats" binding:"required,min=1"`
 	PricePerSeat    float64   `json:"price_per_seat" binding:"required,min=0"`
 	AllowedDetourKm float64   `json:"allowed_detour_km" binding:"required,min=0"`
+
+	// Waypoints are the places the driver passes through, in driving order
+	Waypoints []struct {
+		Latitude  float64 `json:"lat" binding:"required"`
+		Longitude float64 `json:"lng" binding:"required"`
+		Address   string  `json:"address" binding:"required"`
+	} `json:"waypoints" binding:"omitempty,max=10,dive"`
 }
 
 // CreateRideRequestRequest represents the request format for creating a ride request
@@ -60,6 +70,88 @@ type CreateRideRequestRequest struct {
 	MaxPrice      float64   `json:"max_price" binding:"required,min=0"`
 }
 
+// LocationRequest represents a location in a request body
+type LocationRequest struct {
+	Latitude  float64 `json:"lat" binding:"required"`
+	Longitude float64 `json:"lng" binding:"required"`
+	Address   string  `json:"address" binding:"required"`
+}
+
+// location converts the request into a model location
+func (r *LocationRequest) location() *model.Location {
+	if r == nil {
+		return nil
+	}
+	return &model.Location{Latitude: r.Latitude, Longitude: r.Longitude, Address: r.Address}
+}
+
+// UpdateRideOfferRequest represents the request format for changing a ride offer; omitted fields are kept
+type UpdateRideOfferRequest struct {
+	StartLocation   *LocationRequest `json:"start_location"`
+	EndLocation     *LocationRequest `json:"end_location"`
+	DepartureTime   *time.Time       `json:"departure_time"`
+	AvailableSeats  *int             `json:"available_seats" binding:"omitempty,min=1"`
+	PricePerSeat    *float64         `json:"price_per_seat" binding:"omitempty,min=0"`
+	AllowedDetourKm *float64         `json:"allowed_detour_km" binding:"omitempty,min=0"`
+
+	// Waypoints replaces the offer's waypoints; an empty list removes them
+	Waypoints *[]LocationRequest `json:"waypoints" binding:"omitempty,max=10,dive"`
+}
+
+// UpdateRideRequestRequest represents the request format for changing a ride request; omitted fields are kept
+type UpdateRideRequestRequest struct {
+	StartLocation *LocationRequest `json:"start_location"`
+	EndLocation   *LocationRequest `json:"end_location"`
+	DepartureTime *time.Time       `json:"departure_time"`
+	NumPassengers *int             `json:"num_passengers" binding:"omitempty,min=1"`
+	MaxPrice      *float64         `json:"max_price" binding:"omitempty,min=0"`
+}
+
+// RejectMatchRequest represents the optional request body for rejecting a ride match
+type RejectMatchRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// SearchRidesRequest represents the query parameters for searching ride offers
+type SearchRidesRequest struct {
+	OriginLat      *float64  `form:"origin_lat" binding:"required,min=-90,max=90"`
+	OriginLng      *float64  `form:"origin_lng" binding:"required,min=-180,max=180"`
+	DestinationLat *float64  `form:"dest_lat" binding:"required,min=-90,max=90"`
+	DestinationLng *float64  `form:"dest_lng" binding:"required,min=-180,max=180"`
+	RadiusKm       float64   `form:"radius_km" binding:"omitempty,gt=0,max=50"`
+	From           time.Time `form:"from"`
+	To             time.Time `form:"to"`
+	Seats          int       `form:"seats" binding:"omitempty,min=1"`
+	MaxPrice       float64   `form:"max_price" binding:"omitempty,min=0"`
+	Sort           string    `form:"sort" binding:"omitempty,oneof=departure price distance"`
+	Cursor         string    `form:"cursor"`
+	Limit          int       `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// BookRideRequest represents the request format for booking seats on a ride offer
+type BookRideRequest struct {
+	RideOfferID   uuid.UUID       `json:"ride_offer_id" binding:"required"`
+	StartLocation LocationRequest `json:"start_location" binding:"required"`
+	EndLocation   LocationRequest `json:"end_location" binding:"required"`
+	NumPassengers int             `json:"num_passengers" binding:"required,min=1"`
+	MaxPrice      float64         `json:"max_price" binding:"required,min=0"`
+}
+
+// DriverAvailabilityRequest represents the request format for going online or offline for on-demand trips
+type DriverAvailabilityRequest struct {
+	Online *bool `json:"online" binding:"required"`
+	// Location is required to go online; drivers send it again as they move
+	Location *LocationRequest `json:"location"`
+}
+
+// RideNowRequest represents the request format for requesting an on-demand ride
+type RideNowRequest struct {
+	StartLocation LocationRequest `json:"start_location" binding:"required"`
+	EndLocation   LocationRequest `json:"end_location" binding:"required"`
+	NumPassengers int             `json:"num_passengers" binding:"required,min=1"`
+	MaxPrice      float64         `json:"max_price" binding:"required,min=0"`
+}
+
 // CreateRideOffer handles creating a new ride offer
 func (h *RideHandler) CreateRideOffer(c *gin.Context) {
 	userID, exists := c.Get("userID")
@@ -80,6 +172,15 @@ func (h *RideHandler) CreateRideOffer(c *gin.Context) {
 		return
 	}
 
+	waypoints := make([]model.Location, len(request.Waypoints))
+	for i, waypoint := range request.Waypoints {
+		waypoints[i] = model.Location{
+			Latitude:  waypoint.Latitude,
+			Longitude: waypoint.Longitude,
+			Address:   waypoint.Address,
+		}
+	}
+
 	offer, err := h.rideService.CreateRideOffer(
 		id,
 		request.StartLocation.Latitude,
@@ -92,6 +193,7 @@ func (h *RideHandler) CreateRideOffer(c *gin.Context) {
 		request.AvailableSeats,
 		request.PricePerSeat,
 		request.AllowedDetourKm,
+		waypoints,
 	)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -217,16 +319,735 @@ func (h *RideHandler) ConfirmMatch(c *gin.Context) {
 		return
 	}
 
-	role, _ := c.Get("role")
-	roleStr, _ := role.(string)
-	isDriver := roleStr == "driver" || roleStr == "both"
+	match, err := h.rideService.ConfirmMatch(matchID, id)
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Match confirmed successfully"
+	if match.Status != model.StatusConfirmed {
+		message = "Match accepted, waiting for the other party"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"match":   match,
+	})
+}
+
+// RejectMatch handles declining a proposed ride match
+func (h *RideHandler) RejectMatch(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	matchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+		return
+	}
+
+	// The request body is optional
+	var request RejectMatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	match, err := h.rideService.RejectMatch(matchID, id, request.Reason)
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Match rejected successfully",
+		"match":   match,
+	})
+}
+
+// StartRide handles starting a confirmed ride offer
+func (h *RideHandler) StartRide(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	if err := h.rideService.StartRide(offerID, id); err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ride started successfully",
+	})
+}
+
+// CompleteRide handles completing an in-progress ride offer
+func (h *RideHandler) CompleteRide(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	if err := h.rideService.CompleteRide(offerID, id); err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ride completed successfully",
+	})
+}
+
+// CancelRideOffer handles cancelling a ride offer by its driver
+func (h *RideHandler) CancelRideOffer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	if err := h.rideService.CancelRideOffer(offerID, id); err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ride offer cancelled successfully",
+	})
+}
+
+// CancelRideRequest handles cancelling a ride request by its passenger
+func (h *RideHandler) CancelRideRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride request ID"})
+		return
+	}
+
+	if err := h.rideService.CancelRideRequest(requestID, id); err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ride request cancelled successfully",
+	})
+}
+
+// UpdateRideOffer handles changing a ride offer by its driver
+func (h *RideHandler) UpdateRideOffer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	var request UpdateRideOfferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	changes := service.RideOfferChanges{
+		StartLocation:   request.StartLocation.location(),
+		EndLocation:     request.EndLocation.location(),
+		DepartureTime:   request.DepartureTime,
+		AvailableSeats:  request.AvailableSeats,
+		PricePerSeat:    request.PricePerSeat,
+		AllowedDetourKm: request.AllowedDetourKm,
+	}
+	if request.Waypoints != nil {
+		waypoints := make([]model.Location, len(*request.Waypoints))
+		for i := range *request.Waypoints {
+			waypoints[i] = *(*request.Waypoints)[i].location()
+		}
+		changes.Waypoints = &waypoints
+	}
+
+	offer, err := h.rideService.UpdateRideOffer(offerID, id, changes)
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Ride offer updated successfully",
+		"ride_offer": offer,
+	})
+}
+
+// DeleteRideOffer handles withdrawing a ride offer by its driver
+func (h *RideHandler) DeleteRideOffer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	if err := h.rideService.DeleteRideOffer(offerID, id); err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ride offer withdrawn successfully",
+	})
+}
+
+// UpdateRideRequest handles changing a ride request by its passenger
+func (h *RideHandler) UpdateRideRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride request ID"})
+		return
+	}
 
-	if err := h.rideService.ConfirmMatch(matchID, id, isDriver); err != nil {
+	var request UpdateRideRequestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	rideRequest, err := h.rideService.UpdateRideRequest(requestID, id, service.RideRequestChanges{
+		StartLocation: request.StartLocation.location(),
+		EndLocation:   request.EndLocation.location(),
+		DepartureTime: request.DepartureTime,
+		NumPassengers: request.NumPassengers,
+		MaxPrice:      request.MaxPrice,
+	})
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Match confirmed successfully",
+		"message":      "Ride request updated successfully",
+		"ride_request": rideRequest,
+	})
+}
+
+// DeleteRideRequest handles withdrawing a ride request by its passenger
+func (h *RideHandler) DeleteRideRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride request ID"})
+		return
+	}
+
+	if err := h.rideService.DeleteRideRequest(requestID, id); err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ride request withdrawn successfully",
+	})
+}
+
+// rideErrorStatus maps a ride service error to an HTTP status code.
+// State machine violations, seat shortages, repeated acceptances, edits to confirmed or on-demand
+// rides, answers to trips no longer offered or accepted mid-trip, and locations of rides not in progress are reported as
+// conflicts; location updates over the rate limit are reported as too many requests.
+func rideErrorStatus(err error) int {
+	if errors.Is(err, service.ErrRateLimited) {
+		return http.StatusTooManyRequests
+	}
+	if errors.Is(err, model.ErrInvalidTransition) ||
+		errors.Is(err, service.ErrNotEnoughSeats) ||
+		errors.Is(err, service.ErrAlreadyAccepted) ||
+		errors.Is(err, service.ErrBookingRejected) ||
+		errors.Is(err, service.ErrRideNotEditable) ||
+		errors.Is(err, service.ErrOnDemandNotEditable) ||
+		errors.Is(err, service.ErrDispatchClosed) ||
+		errors.Is(err, service.ErrDriverOnTrip) ||
+		errors.Is(err, service.ErrTripNotActive) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// GetRideOfferMatches handles listing the matches of one of the authenticated driver's ride offers
+func (h *RideHandler) GetRideOfferMatches(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	matches, err := h.rideService.GetMatchesForOffer(offerID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get matches"})
+		return
+	}
+	if matches == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ride offer not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"matches": matches,
+	})
+}
+
+// GetRideRequestMatches handles listing the matches of one of the authenticated passenger's ride requests
+func (h *RideHandler) GetRideRequestMatches(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride request ID"})
+		return
+	}
+
+	matches, err := h.rideService.GetMatchesForRequest(requestID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get matches"})
+		return
+	}
+	if matches == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ride request not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"matches": matches,
+	})
+}
+
+// GetMatch handles retrieving a single ride match for one of its parties
+func (h *RideHandler) GetMatch(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	matchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+		return
+	}
+
+	match, err := h.rideService.GetMatch(matchID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get match"})
+		return
+	}
+	if match == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Match not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
+
+// DryRunMatch handles scoring any ride offer and request pair for an admin, without proposing a match
+func (h *RideHandler) DryRunMatch(c *gin.Context) {
+	offerID, err := uuid.Parse(c.Query("offer_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	requestID, err := uuid.Parse(c.Query("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride request ID"})
+		return
+	}
+
+	result, err := h.rideService.DryRunMatch(offerID, requestID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to score match"})
+		return
+	}
+	if result == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ride offer or request not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SearchRides handles searching the ride offers that still take passengers between two points
+func (h *RideHandler) SearchRides(c *gin.Context) {
+	var request SearchRidesRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.rideService.SearchRideOffers(service.RideSearch{
+		Origin:      model.Location{Latitude: *request.OriginLat, Longitude: *request.OriginLng},
+		Destination: model.Location{Latitude: *request.DestinationLat, Longitude: *request.DestinationLng},
+		RadiusKm:    request.RadiusKm,
+		From:        request.From,
+		Until:       request.To,
+		Seats:       request.Seats,
+		MaxPrice:    request.MaxPrice,
+		Sort:        request.Sort,
+		Cursor:      request.Cursor,
+		Limit:       request.Limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// BookRide handles booking seats on a ride offer for the authenticated passenger
+func (h *RideHandler) BookRide(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var request BookRideRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rideRequest, match, err := h.rideService.BookRideOffer(
+		request.RideOfferID,
+		id,
+		*request.StartLocation.location(),
+		*request.EndLocation.location(),
+		request.NumPassengers,
+		request.MaxPrice,
+	)
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Ride booked successfully, waiting for the driver to accept",
+		"ride_request": rideRequest,
+		"match":        match,
+	})
+}
+
+// SetDriverAvailability handles the authenticated driver going online or offline for on-demand trips
+func (h *RideHandler) SetDriverAvailability(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var request DriverAvailabilityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	availability, err := h.rideService.SetDriverAvailability(id, *request.Online, request.Location.location())
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, availability)
+}
+
+// GetDriverAvailability handles retrieving the authenticated driver's availability for on-demand trips
+func (h *RideHandler) GetDriverAvailability(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	availability, err := h.rideService.GetDriverAvailability(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if availability == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Availability not set"})
+		return
+	}
+
+	c.JSON(http.StatusOK, availability)
+}
+
+// GetDispatches handles listing the on-demand trips waiting for the authenticated driver's answer
+func (h *RideHandler) GetDispatches(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	dispatches, err := h.rideService.GetOpenDispatches(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dispatches)
+}
+
+// AcceptDispatch handles the authenticated driver accepting an on-demand trip
+func (h *RideHandler) AcceptDispatch(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	dispatchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispatch ID"})
+		return
+	}
+
+	match, err := h.rideService.AcceptDispatch(dispatchID, id)
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trip accepted successfully",
+		"match":   match,
+	})
+}
+
+// DeclineDispatch handles the authenticated driver turning down an on-demand trip
+func (h *RideHandler) DeclineDispatch(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	dispatchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispatch ID"})
+		return
+	}
+
+	if err := h.rideService.DeclineDispatch(dispatchID, id); err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trip declined successfully",
+	})
+}
+
+// RequestRideNow handles requesting an on-demand ride for the authenticated passenger
+func (h *RideHandler) RequestRideNow(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var request RideNowRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rideRequest, err := h.rideService.RequestRideNow(
+		id,
+		*request.StartLocation.location(),
+		*request.EndLocation.location(),
+		request.NumPassengers,
+		request.MaxPrice,
+	)
+	if err != nil {
+		c.JSON(rideErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Looking for a driver near you",
+		"ride_request": rideRequest,
 	})
 }
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/api/middleware"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/auth"
+	"github.com/yourusername/ride-sharing-app/infrastructure/jobs"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+	"github.com/yourusername/ride-sharing-app/infrastructure/routing"
+	"github.com/yourusername/ride-sharing-app/repository/memory"
+	"github.com/yourusername/ride-sharing-app/service"
+)
+
+// rideAPI serves the ride routes the tests call over in-memory repositories
+type rideAPI struct {
+	router   *gin.Engine
+	jwt      *auth.JWTService
+	rideRepo repository.RideRepository
+}
+
+// newRideAPI sets the ride routes up behind the same middleware as the server
+func newRideAPI(t *testing.T) *rideAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rideRepo := memory.NewRideRepository()
+	queue := jobs.NewQueue(jobs.Config{Workers: 1, QueueSize: 100, MaxAttempts: 1, Backoff: time.Millisecond})
+	rideService := service.NewRideService(
+		rideRepo,
+		memory.NewUserRepository(),
+		routing.StraightLineEstimator{},
+		service.NewDefaultScorer(service.ScoreWeights{Price: 0.4, Time: 0.3, Location: 0.3}, 2*time.Hour),
+		service.MatchingOptions{Window: 2 * time.Hour, Threshold: 0.1},
+		service.ExpiryOptions{Grace: time.Hour},
+		service.DispatchOptions{RadiusKm: 5, AcceptWindow: time.Minute, MaxWait: 10 * time.Minute, LocationMaxAge: time.Minute, PricePerKm: 1},
+		service.TrackingOptions{MaxBatchSize: 100},
+		queue,
+		notify.LogNotifier{},
+		nil,
+	)
+	handler := NewRideHandler(rideService)
+	jwtService := auth.NewJWTService("test-secret", "test")
+
+	router := gin.New()
+	apiV1 := router.Group("/api/v1")
+	apiV1.Use(middleware.AuthMiddleware(jwtService))
+	apiV1.Group("/driver").Use(middleware.RoleMiddleware(model.RoleDriver, model.RoleBoth)).
+		GET("/rides", handler.GetMyRideOffers)
+	apiV1.Group("/admin").Use(middleware.AdminMiddleware()).
+		GET("/matches/dry-run", handler.DryRunMatch)
+	apiV1.GET("/rides/:id/location", handler.GetTripLocation)
+	apiV1.GET("/rides/:id/trace", handler.GetTripTrace)
+	router.GET("/api/v1/driver/rides/:id/locations/stream", middleware.StreamAuthMiddleware(jwtService),
+		middleware.RoleMiddleware(model.RoleDriver, model.RoleBoth), handler.StreamTripLocations)
+	return &rideAPI{router: router, jwt: jwtService, rideRepo: rideRepo}
+}
+
+// get calls a route as a user with a role, and as an admin if admin is set
+func (a *rideAPI) get(t *testing.T, path string, userID uuid.UUID, role model.UserRole, admin bool) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := a.jwt.GenerateToken(userID, "user@example.com", string(role), admin)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	a.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// createOffer saves a pending offer from the central station to station Zuid departing in an hour
+func (a *rideAPI) createOffer(t *testing.T) *model.RideOffer {
+	t.Helper()
+	offer := &model.RideOffer{
+		DriverID:        uuid.New(),
+		StartLocation:   model.Location{Latitude: 52.3791, Longitude: 4.9003, Address: "Centraal Station"},
+		EndLocation:     model.Location{Latitude: 52.3389, Longitude: 4.8730, Address: "Station Zuid"},
+		DepartureTime:   time.Now().Add(time.Hour),
+		AvailableSeats:  3,
+		PricePerSeat:    10,
+		AllowedDetourKm: 5,
+		Status:          model.StatusPending,
+	}
+	if err := a.rideRepo.CreateRideOffer(offer); err != nil {
+		t.Fatalf("CreateRideOffer: %v", err)
+	}
+	return offer
+}
+
+func TestDryRunMatch(t *testing.T) {
+	api := newRideAPI(t)
+	offer := api.createOffer(t)
+	request := &model.RideRequest{
+		PassengerID:   uuid.New(),
+		StartLocation: offer.StartLocation,
+		EndLocation:   model.Location{Latitude: 52.3579, Longitude: 4.8816, Address: "Museumplein"},
+		DepartureTime: offer.DepartureTime,
+		NumPassengers: 1,
+		MaxPrice:      50,
+		Status:        model.StatusPending,
+		Mode:          model.ModeScheduled,
+	}
+	if err := api.rideRepo.CreateRideRequest(request); err != nil {
+		t.Fatalf("CreateRideRequest: %v", err)
+	}
+	dryRun := "/api/v1/admin/matches/dry-run?offer_id=" + offer.ID.String() + "&request_id="
+
+	cases := []struct {
+		name       string
+		path       string
+		role       model.UserRole
+		admin      bool
+		wantStatus int
+	}{
+		{name: "admin", path: dryRun + request.ID.String(), role: model.RolePassenger, admin: true, wantStatus: http.StatusOK},
+		{name: "driver who is an admin", path: dryRun + request.ID.String(), role: model.RoleDriver, admin: true, wantStatus: http.StatusOK},
+		{name: "both roles without admin", path: dryRun + request.ID.String(), role: model.RoleBoth, wantStatus: http.StatusForbidden},
+		{name: "unknown request", path: dryRun + uuid.NewString(), role: model.RolePassenger, admin: true, wantStatus: http.StatusNotFound},
+		{name: "malformed request ID", path: dryRun + "42", role: model.RolePassenger, admin: true, wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			response := api.get(t, tc.path, uuid.New(), tc.role, tc.admin)
+			if response.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", response.Code, tc.wantStatus, response.Body)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var result service.MatchDryRun
+			if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+				t.Fatalf("decode dry run: %v", err)
+			}
+			if result.RideOfferID != offer.ID || result.RideRequestID != request.ID || !result.Matched {
+				t.Errorf("dry run = %+v, want the pair matched", result)
+			}
+		})
+	}
+
+	// Admins keep the routes of their role
+	if response := api.get(t, "/api/v1/driver/rides", uuid.New(), model.RoleDriver, true); response.Code != http.StatusOK {
+		t.Errorf("driver routes for a driver who is an admin: status = %d, want %d", response.Code, http.StatusOK)
+	}
+}
+
+func TestTripTrackingErrors(t *testing.T) {
+	api := newRideAPI(t)
+	offer := api.createOffer(t)
+	ride := "/api/v1/rides/" + offer.ID.String()
+	unknown := "/api/v1/rides/" + uuid.NewString()
+
+	cases := []struct {
+		name       string
+		path       string
+		userID     uuid.UUID
+		wantStatus int
+	}{
+		{name: "location for the driver", path: ride + "/location", userID: offer.DriverID, wantStatus: http.StatusOK},
+		{name: "trace for the driver", path: ride + "/trace", userID: offer.DriverID, wantStatus: http.StatusOK},
+		{name: "location of an unknown ride", path: unknown + "/location", userID: offer.DriverID, wantStatus: http.StatusNotFound},
+		{name: "trace of an unknown ride", path: unknown + "/trace", userID: offer.DriverID, wantStatus: http.StatusNotFound},
+		{name: "location for someone else", path: ride + "/location", userID: uuid.New(), wantStatus: http.StatusForbidden},
+		{name: "trace for someone else", path: ride + "/trace", userID: uuid.New(), wantStatus: http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if response := api.get(t, tc.path, tc.userID, model.RolePassenger, false); response.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d: %s", response.Code, tc.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+func TestStreamTripLocationsWithAStreamToken(t *testing.T) {
+	api := newRideAPI(t)
+	offer := api.createOffer(t)
+	token, err := api.jwt.GenerateStreamToken(offer.DriverID, "driver@example.com", string(model.RoleDriver), false)
+	if err != nil {
+		t.Fatalf("GenerateStreamToken: %v", err)
+	}
+
+	// The token gets the driver as far as the trip, which has not started yet
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/driver/rides/"+offer.ID.String()+"/locations/stream", nil)
+	request.Header.Set("Sec-WebSocket-Protocol", middleware.StreamProtocol+", "+token)
+	response := httptest.NewRecorder()
+	api.router.ServeHTTP(response, request)
+	if response.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d: %s", response.Code, http.StatusConflict, response.Body)
+	}
+}
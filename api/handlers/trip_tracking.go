@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/ride-sharing-app/api/middleware"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/service"
+)
+
+const (
+	// streamPongWait is how long a WebSocket stream stays open without hearing from the client
+	streamPongWait = 60 * time.Second
+	// streamPingPeriod is how often the client is pinged; it must be shorter than streamPongWait
+	streamPingPeriod = streamPongWait * 9 / 10
+	// streamWriteWait bounds each write to the client
+	streamWriteWait = 10 * time.Second
+	// streamMaxMessageBytes caps the size of one message from the client
+	streamMaxMessageBytes = 64 << 10
+)
+
+// streamUpgrader turns stream requests into WebSocket connections. Clients that authenticate
+// with a stream token in the subprotocols get the stream protocol back, as browsers require.
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Subprotocols:    []string{middleware.StreamProtocol},
+}
+
+// TripLocationRequest represents a GPS point reported by a driver
+type TripLocationRequest struct {
+	Latitude  *float64 `json:"lat" binding:"required,min=-90,max=90"`
+	Longitude *float64 `json:"lng" binding:"required,min=-180,max=180"`
+	Heading   *float64 `json:"heading" binding:"omitempty,min=0,max=360"`
+	SpeedKmh  *float64 `json:"speed_kmh" binding:"omitempty,min=0"`
+	AccuracyM *float64 `json:"accuracy_m" binding:"omitempty,min=0"`
+	// RecordedAt is when the device took the point; it defaults to when the server receives it
+	RecordedAt *time.Time `json:"recorded_at"`
+}
+
+// TripLocationsRequest represents the request format for reporting GPS points, over HTTP or as
+// one message of a location stream
+type TripLocationsRequest struct {
+	Locations []TripLocationRequest `json:"locations" binding:"required,min=1,dive"`
+}
+
+// TripTraceRequest represents the query parameters for fetching the trace of a trip
+type TripTraceRequest struct {
+	// After leaves out the points recorded up to this time, so clients can fetch only new points
+	After time.Time `form:"after"`
+}
+
+// tripLocations converts the request into model trip locations
+func (r *TripLocationsRequest) tripLocations() []model.TripLocation {
+	locations := make([]model.TripLocation, len(r.Locations))
+	for i, point := range r.Locations {
+		locations[i] = model.TripLocation{
+			Latitude:  *point.Latitude,
+			Longitude: *point.Longitude,
+			Heading:   point.Heading,
+			SpeedKmh:  point.SpeedKmh,
+			AccuracyM: point.AccuracyM,
+		}
+		if point.RecordedAt != nil {
+			locations[i].RecordedAt = *point.RecordedAt
+		}
+	}
+	return locations
+}
+
+// RecordTripLocations handles the authenticated driver reporting GPS points for a ride in progress
+func (h *RideHandler) RecordTripLocations(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	var request TripLocationsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	locations, err := h.rideService.RecordTripLocations(offerID, id, request.tripLocations())
+	if err != nil {
+		c.JSON(tripErrorStatus(err, rideErrorStatus(err)), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Locations recorded successfully",
+		"recorded": len(locations),
+	})
+}
+
+// StreamTripLocations handles the authenticated driver streaming GPS points for a ride in progress
+// over a WebSocket. Every message is a TripLocationsRequest and is answered with the number of points
+// recorded or an error; the server closes the stream once the ride is no longer in progress.
+func (h *RideHandler) StreamTripLocations(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	// Refuse the stream before upgrading, while errors can still be plain HTTP responses
+	if _, err := h.rideService.FindActiveTrip(offerID, id); err != nil {
+		c.JSON(tripErrorStatus(err, rideErrorStatus(err)), gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(streamMaxMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+
+	// Ping the device so that streams of drivers who dropped off are closed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(streamPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	reply := func(message gin.H) error {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		return conn.WriteJSON(message)
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(streamPongWait))
+
+		var request TripLocationsRequest
+		err = json.Unmarshal(message, &request)
+		if err == nil {
+			err = binding.Validator.ValidateStruct(&request)
+		}
+		if err != nil {
+			if reply(gin.H{"error": err.Error()}) != nil {
+				return
+			}
+			continue
+		}
+
+		locations, err := h.rideService.RecordTripLocations(offerID, id, request.tripLocations())
+		if errors.Is(err, service.ErrTripNotActive) {
+			closing := websocket.FormatCloseMessage(websocket.CloseNormalClosure, err.Error())
+			conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(streamWriteWait))
+			return
+		}
+		if err != nil {
+			if reply(gin.H{"error": err.Error()}) != nil {
+				return
+			}
+			continue
+		}
+		if reply(gin.H{"recorded": len(locations)}) != nil {
+			return
+		}
+	}
+}
+
+// GetTripLocation handles retrieving where the car of a ride is, for its driver or a passenger on it
+func (h *RideHandler) GetTripLocation(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	trace, err := h.rideService.GetTripLocation(offerID, id)
+	if err != nil {
+		c.JSON(tripErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
+
+// GetTripTrace handles retrieving the breadcrumb trail of a ride, for its driver or a passenger on it
+func (h *RideHandler) GetTripTrace(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride offer ID"})
+		return
+	}
+
+	var request TripTraceRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trace, err := h.rideService.GetTripTrace(offerID, id, request.After)
+	if err != nil {
+		c.JSON(tripErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
+
+// tripErrorStatus maps the errors of tracking a trip to HTTP status codes, and others to otherwise
+func tripErrorStatus(err error, otherwise int) int {
+	switch {
+	case errors.Is(err, service.ErrTripNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotTripDriver), errors.Is(err, service.ErrNotOnTrip):
+		return http.StatusForbidden
+	}
+	return otherwise
+}
@@ -48,6 +48,12 @@ type RegisterDriverRequest struct {
 	NumSeats   int    `json:"num_seats" binding:"required,min=2"`
 }
 
+// PassengerPreferencesRequest represents the request format for setting passenger preferences
+type PassengerPreferencesRequest struct {
+	MinDriverRating float32             `json:"min_driver_rating" binding:"min=0,max=5"`
+	Priority        model.MatchPriority `json:"priority" binding:"omitempty,oneof=price time location rating"`
+}
+
 // Register handles user registration
 func (h *UserHandler) Register(c *gin.Context) {
 	var request RegisterRequest
@@ -69,7 +75,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	token, err := h.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
+	token, err := h.jwtService.GenerateToken(user.ID, user.Email, string(user.Role), user.IsAdmin)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -96,17 +102,18 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.jwtService.GenerateToken(user.ID, user.Email, string(user.Role))
+	token, err := h.jwtService.GenerateToken(user.ID, user.Email, string(user.Role), user.IsAdmin)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"user_id": user.ID,
-		"token":   token,
-		"role":    user.Role,
+		"message":  "Login successful",
+		"user_id":  user.ID,
+		"token":    token,
+		"role":     user.Role,
+		"is_admin": user.IsAdmin,
 	})
 }
 
@@ -141,6 +148,45 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 		"email":      user.Email,
 		"phone":      user.Phone,
 		"role":       user.Role,
+		"is_admin":   user.IsAdmin,
+	})
+}
+
+// IssueStreamToken handles issuing a short-lived token that opens the authenticated user's event
+// or location streams from a browser, which cannot send the Authorization header there
+func (h *UserHandler) IssueStreamToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	// The token carries the user's current role, like a login would
+	user, err := h.userService.GetUserByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	token, err := h.jwtService.GenerateStreamToken(user.ID, user.Email, string(user.Role), user.IsAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"expires_in": int(auth.StreamTokenTTL.Seconds()),
 	})
 }
 
@@ -208,3 +254,58 @@ func (h *UserHandler) GetDriverProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, profile)
 }
+
+// GetPassengerPreferences handles retrieving passenger preferences
+func (h *UserHandler) GetPassengerPreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	preferences, err := h.userService.GetPassengerPreferences(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get passenger preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdatePassengerPreferences handles setting passenger preferences
+func (h *UserHandler) UpdatePassengerPreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var request PassengerPreferencesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preferences, err := h.userService.UpdatePassengerPreferences(id, request.MinDriverRating, request.Priority)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Passenger preferences updated successfully",
+		"preferences": preferences,
+	})
+}
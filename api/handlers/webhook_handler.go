@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/service"
+)
+
+// WebhookHandler handles the admin API for partner webhooks and their deliveries
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhookRequest represents the request format for subscribing a URL to domain events
+type CreateWebhookRequest struct {
+	Name       string                  `json:"name" binding:"required"`
+	URL        string                  `json:"url" binding:"required,url"`
+	EventTypes []model.DomainEventType `json:"event_types" binding:"required,min=1"`
+	// UserIDs limits the webhook to the rides of these users, such as a partner's employees
+	UserIDs []uuid.UUID `json:"user_ids"`
+	// Secret keys the delivery signatures; a random one is generated when it is omitted
+	Secret string `json:"secret"`
+}
+
+// UpdateWebhookRequest represents the request format for changing a webhook; omitted fields are kept
+type UpdateWebhookRequest struct {
+	Name       *string                  `json:"name"`
+	URL        *string                  `json:"url" binding:"omitempty,url"`
+	EventTypes *[]model.DomainEventType `json:"event_types" binding:"omitempty,min=1"`
+	UserIDs    *[]uuid.UUID             `json:"user_ids"`
+	Secret     *string                  `json:"secret"`
+	Active     *bool                    `json:"active"`
+}
+
+// ListWebhookDeliveriesRequest represents the query parameters for listing the deliveries of a webhook
+type ListWebhookDeliveriesRequest struct {
+	Status model.WebhookDeliveryStatus `form:"status" binding:"omitempty,oneof=pending delivered dead"`
+	Limit  int                         `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+// CreateWebhook handles subscribing a partner's URL to domain events
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var request CreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := h.webhookService.CreateWebhook(service.WebhookInput{
+		Name:       request.Name,
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		UserIDs:    request.UserIDs,
+		Secret:     request.Secret,
+	})
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// The secret is only ever shown here
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook created successfully",
+		"webhook": subscription,
+		"secret":  subscription.Secret,
+	})
+}
+
+// GetWebhooks handles listing every webhook
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	subscriptions, err := h.webhookService.GetWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GetWebhook handles getting a webhook by ID
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	subscription, err := h.webhookService.GetWebhook(webhookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		return
+	}
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// UpdateWebhook handles changing a webhook, including pausing and resuming it
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var request UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := h.webhookService.UpdateWebhook(webhookID, service.WebhookChanges{
+		Name:       request.Name,
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		UserIDs:    request.UserIDs,
+		Secret:     request.Secret,
+		Active:     request.Active,
+	})
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook updated successfully",
+		"webhook": subscription,
+	})
+}
+
+// DeleteWebhook handles removing a webhook along with its deliveries
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(webhookID); err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries handles listing the latest deliveries of a webhook, optionally by status
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var request ListWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveries, err := h.webhookService.GetWebhookDeliveries(webhookID, request.Status, request.Limit)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetWebhookDelivery handles getting a delivery with the log of its attempts
+func (h *WebhookHandler) GetWebhookDelivery(c *gin.Context) {
+	deliveryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	deliveryLog, err := h.webhookService.GetWebhookDelivery(deliveryID)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveryLog)
+}
+
+// ReplayWebhookDelivery handles sending a dead or delivered event to its webhook again
+func (h *WebhookHandler) ReplayWebhookDelivery(c *gin.Context) {
+	deliveryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayWebhookDelivery(deliveryID)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Webhook delivery queued for replay",
+		"delivery": delivery,
+	})
+}
+
+// webhookErrorStatus maps an error from the webhook service to an HTTP status
+func webhookErrorStatus(err error) int {
+	if errors.Is(err, service.ErrWebhookNotFound) || errors.Is(err, service.ErrDeliveryNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, service.ErrDeliveryPending) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -5,10 +5,15 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/yourusername/ride-sharing-app/domain/model"
 	"github.com/yourusername/ride-sharing-app/infrastructure/auth"
 )
 
+// StreamProtocol is the WebSocket subprotocol a client offers, followed by its stream token,
+// to authenticate a stream from a browser
+const StreamProtocol = "access_token"
+
 // AuthMiddleware handles authentication using JWT
 func AuthMiddleware(jwtService *auth.JWTService) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -17,28 +22,77 @@ func AuthMiddleware(jwtService *auth.JWTService) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			return
 		}
+		authenticateBearer(c, jwtService, authHeader)
+	}
+}
+
+// StreamAuthMiddleware handles authentication of the event and location streams. Browsers cannot
+// set the Authorization header on EventSource and WebSocket requests, so without one a stream token
+// is taken from the access_token query parameter, or from the WebSocket subprotocols offered as
+// StreamProtocol followed by the token.
+func StreamAuthMiddleware(jwtService *auth.JWTService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			authenticateBearer(c, jwtService, authHeader)
+			return
+		}
 
-		// Check if the Authorization header has the correct format
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format, use Bearer {token}"})
+		tokenString := c.Query("access_token")
+		if tokenString == "" {
+			tokenString = protocolToken(c.Request)
+		}
+		if tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header or stream token required"})
 			return
 		}
 
-		tokenString := parts[1]
-		claims, err := jwtService.ValidateToken(tokenString)
+		claims, err := jwtService.ValidateStreamToken(tokenString)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired stream token"})
 			return
 		}
+		authenticated(c, claims)
+	}
+}
 
-		// Store user information in context
-		c.Set("userID", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("role", claims.Role)
+// authenticateBearer authenticates a request by the token of its "Bearer {token}" Authorization header
+func authenticateBearer(c *gin.Context, jwtService *auth.JWTService, authHeader string) {
+	// Check if the Authorization header has the correct format
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format, use Bearer {token}"})
+		return
+	}
 
-		c.Next()
+	tokenString := parts[1]
+	claims, err := jwtService.ValidateToken(tokenString)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	authenticated(c, claims)
+}
+
+// authenticated stores the user information of a valid token in the context and carries on
+func authenticated(c *gin.Context, claims *auth.JWTClaim) {
+	c.Set("userID", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("role", claims.Role)
+	c.Set("admin", claims.Admin)
+
+	c.Next()
+}
+
+// protocolToken returns the token offered right after StreamProtocol among a WebSocket
+// request's subprotocols, or an empty string
+func protocolToken(request *http.Request) string {
+	protocols := websocket.Subprotocols(request)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == StreamProtocol {
+			return protocols[i+1]
+		}
 	}
+	return ""
 }
 
 // RoleMiddleware restricts access based on user role
@@ -58,10 +112,13 @@ func RoleMiddleware(roles ...model.UserRole) gin.HandlerFunc {
 
 		role := model.UserRole(roleStr)
 
-		// Check if the user has one of the required roles
+		// Check if the user has one of the required roles.
+		// Users with both roles count as drivers and passengers.
 		authorized := false
 		for _, allowedRole := range roles {
-			if role == allowedRole || role == model.RoleBoth {
+			bothCovers := role == model.RoleBoth &&
+				(allowedRole == model.RoleDriver || allowedRole == model.RolePassenger)
+			if role == allowedRole || bothCovers {
 				authorized = true
 				break
 			}
@@ -75,3 +132,15 @@ func RoleMiddleware(roles ...model.UserRole) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminMiddleware restricts access to admins, whatever their role
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("admin") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -13,6 +13,8 @@ func Setup(
 	router *gin.Engine,
 	userHandler *handlers.UserHandler,
 	rideHandler *handlers.RideHandler,
+	eventHandler *handlers.EventHandler,
+	webhookHandler *handlers.WebhookHandler,
 	jwtService *auth.JWTService,
 ) {
 	// Health check
@@ -25,6 +27,15 @@ func Setup(
 	// Public routes
 	router.POST("/api/v1/register", userHandler.Register)
 	router.POST("/api/v1/login", userHandler.Login)
+	router.GET("/api/v1/rides/search", rideHandler.SearchRides)
+
+	// Real-time ride events of the user, over Server-Sent Events or a WebSocket.
+	// Browsers open it with a stream token instead of the Authorization header.
+	router.GET("/api/v1/events", middleware.StreamAuthMiddleware(jwtService), eventHandler.Stream)
+
+	// The driver's GPS stream of a ride in progress, over a WebSocket opened the same way
+	router.GET("/api/v1/driver/rides/:id/locations/stream", middleware.StreamAuthMiddleware(jwtService),
+		middleware.RoleMiddleware(model.RoleDriver, model.RoleBoth), rideHandler.StreamTripLocations)
 
 	// API v1 routes group
 	apiV1 := router.Group("/api/v1")
@@ -32,6 +43,7 @@ func Setup(
 	{
 		// User routes
 		apiV1.GET("/profile", userHandler.GetProfile)
+		apiV1.POST("/stream-token", userHandler.IssueStreamToken)
 
 		// Driver routes
 		driverRoutes := apiV1.Group("/driver")
@@ -41,20 +53,63 @@ func Setup(
 			driverRoutes.GET("/profile", userHandler.GetDriverProfile)
 			driverRoutes.POST("/rides", rideHandler.CreateRideOffer)
 			driverRoutes.GET("/rides", rideHandler.GetMyRideOffers)
+			driverRoutes.PATCH("/rides/:id", rideHandler.UpdateRideOffer)
+			driverRoutes.DELETE("/rides/:id", rideHandler.DeleteRideOffer)
+			driverRoutes.GET("/rides/:id/matches", rideHandler.GetRideOfferMatches)
+			driverRoutes.POST("/rides/:id/start", rideHandler.StartRide)
+			driverRoutes.POST("/rides/:id/complete", rideHandler.CompleteRide)
+			driverRoutes.POST("/rides/:id/cancel", rideHandler.CancelRideOffer)
+			driverRoutes.POST("/rides/:id/locations", rideHandler.RecordTripLocations)
+			driverRoutes.PUT("/availability", rideHandler.SetDriverAvailability)
+			driverRoutes.GET("/availability", rideHandler.GetDriverAvailability)
+			driverRoutes.GET("/dispatches", rideHandler.GetDispatches)
+			driverRoutes.POST("/dispatches/:id/accept", rideHandler.AcceptDispatch)
+			driverRoutes.POST("/dispatches/:id/decline", rideHandler.DeclineDispatch)
 		}
 
 		// Passenger routes
 		passengerRoutes := apiV1.Group("/passenger")
 		passengerRoutes.Use(middleware.RoleMiddleware(model.RolePassenger, model.RoleBoth))
 		{
+			passengerRoutes.GET("/preferences", userHandler.GetPassengerPreferences)
+			passengerRoutes.PUT("/preferences", userHandler.UpdatePassengerPreferences)
 			passengerRoutes.POST("/rides", rideHandler.CreateRideRequest)
+			passengerRoutes.POST("/rides/now", rideHandler.RequestRideNow)
 			passengerRoutes.GET("/rides", rideHandler.GetMyRideRequests)
+			passengerRoutes.PATCH("/rides/:id", rideHandler.UpdateRideRequest)
+			passengerRoutes.DELETE("/rides/:id", rideHandler.DeleteRideRequest)
+			passengerRoutes.GET("/rides/:id/matches", rideHandler.GetRideRequestMatches)
+			passengerRoutes.POST("/rides/:id/cancel", rideHandler.CancelRideRequest)
+			passengerRoutes.POST("/bookings", rideHandler.BookRide)
 		}
 
+		// Trip tracking routes (available to the driver and the passengers of a ride)
+		apiV1.GET("/rides/:id/location", rideHandler.GetTripLocation)
+		apiV1.GET("/rides/:id/trace", rideHandler.GetTripTrace)
+
 		// Match routes (available to both drivers and passengers)
 		matchRoutes := apiV1.Group("/matches")
 		{
+			matchRoutes.GET("/:id", rideHandler.GetMatch)
 			matchRoutes.POST("/:id/confirm", rideHandler.ConfirmMatch)
+			matchRoutes.POST("/:id/reject", rideHandler.RejectMatch)
+		}
+
+		// Admin routes
+		adminRoutes := apiV1.Group("/admin")
+		adminRoutes.Use(middleware.AdminMiddleware())
+		{
+			adminRoutes.GET("/matches/dry-run", rideHandler.DryRunMatch)
+
+			// Partner webhooks, their deliveries and the log of delivery attempts
+			adminRoutes.POST("/webhooks", webhookHandler.CreateWebhook)
+			adminRoutes.GET("/webhooks", webhookHandler.GetWebhooks)
+			adminRoutes.GET("/webhooks/:id", webhookHandler.GetWebhook)
+			adminRoutes.PATCH("/webhooks/:id", webhookHandler.UpdateWebhook)
+			adminRoutes.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+			adminRoutes.GET("/webhooks/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+			adminRoutes.GET("/webhook-deliveries/:id", webhookHandler.GetWebhookDelivery)
+			adminRoutes.POST("/webhook-deliveries/:id/replay", webhookHandler.ReplayWebhookDelivery)
 		}
 	}
 }
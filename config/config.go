@@ -9,15 +9,29 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Routing  RoutingConfig
+	Matching MatchingConfig
+	Jobs     JobsConfig
+	Expiry   ExpiryConfig
+	Dispatch DispatchConfig
+	Tracking TrackingConfig
+	Events   EventsConfig
+	Outbox   OutboxConfig
+	Webhooks WebhooksConfig
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
 	Port string
+	// ShutdownTimeoutSeconds bounds how long shutdown waits for requests and background jobs
+	ShutdownTimeoutSeconds int
 }
 
 // DatabaseConfig holds database-related configuration
 type DatabaseConfig struct {
+	// Driver selects the storage backend: "postgres" or "sqlite"
+	Driver string
+	// URL is the connection string, or the database file path for sqlite
 	URL string
 }
 
@@ -27,11 +41,185 @@ type JWTConfig struct {
 	Issuer string
 }
 
+// RoutingConfig holds route estimation configuration
+type RoutingConfig struct {
+	// Provider selects the route estimator: "straight_line" or "osrm"
+	Provider string
+	// URL is the base URL of the OSRM-compatible routing service
+	URL string
+	// TimeoutSeconds bounds each request to the routing service
+	TimeoutSeconds int
+}
+
+// MatchingConfig holds match finding and scoring configuration
+type MatchingConfig struct {
+	// Scorer selects the match scorer: "default" or "rating"
+	Scorer string
+	// WindowMinutes is how far apart in time an offer and a request may depart
+	WindowMinutes int
+	// Threshold is the score a pairing must exceed to be proposed
+	Threshold float64
+	// Weights sets how much each factor counts towards a match score
+	Weights MatchWeightsConfig
+	// SweepIntervalSeconds is how often pending rides are matched again; 0 disables the sweep
+	SweepIntervalSeconds int
+}
+
+// MatchWeightsConfig holds the weight of each match score factor
+type MatchWeightsConfig struct {
+	Price    float64
+	Time     float64
+	Location float64
+	Rating   float64
+}
+
+// JobsConfig holds background job queue configuration
+type JobsConfig struct {
+	// Workers is the number of background jobs run at the same time
+	Workers int
+	// DeliveryWorkers is the number of event relay and webhook delivery jobs run at the same time.
+	// They have their own workers so that slow sinks or partners cannot hold up matching and dispatch.
+	DeliveryWorkers int
+	// QueueSize is the number of jobs that can wait for a worker
+	QueueSize int
+	// MaxAttempts is how many times a failing job is run before it is given up
+	MaxAttempts int
+	// BackoffMilliseconds is the delay before retrying a failed job; it doubles with every retry
+	BackoffMilliseconds int
+}
+
+// ExpiryConfig holds configuration for expiring stale rides and matches
+type ExpiryConfig struct {
+	// IntervalSeconds is how often stale rides and matches are expired; 0 disables expiry
+	IntervalSeconds int
+	// GraceMinutes is how long after departure an unconfirmed offer or request expires
+	GraceMinutes int
+	// MatchTTLMinutes is how long a proposed match waits for both parties to accept; 0 disables it
+	MatchTTLMinutes int
+}
+
+// DispatchConfig holds configuration for offering on-demand trips to drivers
+type DispatchConfig struct {
+	// IntervalSeconds is how often unanswered trips move on to the next driver; 0 disables the sweep
+	IntervalSeconds int
+	// RadiusKm is how far from the pickup drivers are offered a trip
+	RadiusKm float64
+	// AcceptSeconds is how long a driver has to accept a trip
+	AcceptSeconds int
+	// MaxWaitMinutes is how long a request looks for a driver before it expires
+	MaxWaitMinutes int
+	// LocationMaxAgeMinutes is how recently a driver must have reported their location to be offered trips
+	LocationMaxAgeMinutes int
+	// PricePerKm sets the fare of on-demand trips
+	PricePerKm float64
+}
+
+// TrackingConfig holds configuration for taking and keeping the GPS points of trips
+type TrackingConfig struct {
+	// MaxPointsPerMinute caps the points one ride records per minute; 0 disables the limit
+	MaxPointsPerMinute int
+	// MaxBatchSize caps the points in one upload
+	MaxBatchSize int
+	// RetentionDays is how long points are kept; 0 keeps them forever
+	RetentionDays int
+	// PurgeIntervalMinutes is how often points past retention are removed
+	PurgeIntervalMinutes int
+}
+
+// EventsConfig holds configuration for the real-time event streams of users
+type EventsConfig struct {
+	// HistorySize is how many recent events are kept per user for streams that reconnect
+	HistorySize int
+	// HistoryTTLMinutes is how long events are kept for streams that reconnect
+	HistoryTTLMinutes int
+	// SubscriberBuffer is how many events a stream can fall behind before it is disconnected
+	SubscriberBuffer int
+}
+
+// OutboxConfig holds configuration for relaying domain events from the outbox to sinks
+type OutboxConfig struct {
+	// RelayIntervalSeconds is how often waiting events are relayed; 0 disables the relay
+	RelayIntervalSeconds int
+	// BatchSize is how many events are read from the outbox at once
+	BatchSize int
+	// MaxAttempts is how many times an event is relayed before it is marked dead and skipped
+	MaxAttempts int
+	// RetentionDays is how long delivered events are kept; 0 keeps them forever
+	RetentionDays int
+	// PurgeIntervalMinutes is how often delivered events past retention are removed
+	PurgeIntervalMinutes int
+	// FilePath is the file the file sink appends events to; empty disables the file sink
+	FilePath string
+	// SubjectPrefix starts the subject of every event the file sink writes
+	SubjectPrefix string
+}
+
+// WebhooksConfig holds configuration for delivering domain events to partner webhooks
+type WebhooksConfig struct {
+	// DeliveryIntervalSeconds is how often due deliveries are posted; 0 disables delivery
+	DeliveryIntervalSeconds int
+	// TimeoutSeconds bounds each post to a webhook
+	TimeoutSeconds int
+	// BatchSize is how many due deliveries are read at once
+	BatchSize int
+	// MaxAttempts is how many times a delivery is tried before it is given up as dead
+	MaxAttempts int
+	// BackoffSeconds is the delay before the first retry of a delivery; it doubles with every retry
+	BackoffSeconds int
+	// MaxBackoffMinutes caps the delay between retries
+	MaxBackoffMinutes int
+}
+
 // LoadConfig loads the application configuration from environment variables or config file
 func LoadConfig() (*Config, error) {
 	// Set defaults
 	viper.SetDefault("server.port", "8080")
+	viper.SetDefault("server.shutdowntimeoutseconds", 15)
+	viper.SetDefault("database.driver", "postgres")
 	viper.SetDefault("jwt.issuer", "ride-sharing-app")
+	viper.SetDefault("routing.provider", "straight_line")
+	viper.SetDefault("routing.timeoutseconds", 2)
+	viper.SetDefault("matching.scorer", "default")
+	viper.SetDefault("matching.windowminutes", 30)
+	viper.SetDefault("matching.threshold", 0.6)
+	viper.SetDefault("matching.weights.price", 0.3)
+	viper.SetDefault("matching.weights.time", 0.3)
+	viper.SetDefault("matching.weights.location", 0.4)
+	viper.SetDefault("matching.weights.rating", 0.2)
+	viper.SetDefault("matching.sweepintervalseconds", 300)
+	viper.SetDefault("jobs.workers", 4)
+	viper.SetDefault("jobs.deliveryworkers", 2)
+	viper.SetDefault("jobs.queuesize", 100)
+	viper.SetDefault("jobs.maxattempts", 3)
+	viper.SetDefault("jobs.backoffmilliseconds", 500)
+	viper.SetDefault("expiry.intervalseconds", 60)
+	viper.SetDefault("expiry.graceminutes", 15)
+	viper.SetDefault("expiry.matchttlminutes", 120)
+	viper.SetDefault("dispatch.intervalseconds", 5)
+	viper.SetDefault("dispatch.radiuskm", 5)
+	viper.SetDefault("dispatch.acceptseconds", 30)
+	viper.SetDefault("dispatch.maxwaitminutes", 5)
+	viper.SetDefault("dispatch.locationmaxageminutes", 5)
+	viper.SetDefault("dispatch.priceperkm", 100)
+	viper.SetDefault("tracking.maxpointsperminute", 120)
+	viper.SetDefault("tracking.maxbatchsize", 100)
+	viper.SetDefault("tracking.retentiondays", 30)
+	viper.SetDefault("tracking.purgeintervalminutes", 60)
+	viper.SetDefault("events.historysize", 100)
+	viper.SetDefault("events.historyttlminutes", 60)
+	viper.SetDefault("events.subscriberbuffer", 64)
+	viper.SetDefault("outbox.relayintervalseconds", 2)
+	viper.SetDefault("outbox.batchsize", 100)
+	viper.SetDefault("outbox.maxattempts", 10)
+	viper.SetDefault("outbox.retentiondays", 7)
+	viper.SetDefault("outbox.purgeintervalminutes", 60)
+	viper.SetDefault("outbox.subjectprefix", "rides")
+	viper.SetDefault("webhooks.deliveryintervalseconds", 5)
+	viper.SetDefault("webhooks.timeoutseconds", 10)
+	viper.SetDefault("webhooks.batchsize", 50)
+	viper.SetDefault("webhooks.maxattempts", 8)
+	viper.SetDefault("webhooks.backoffseconds", 30)
+	viper.SetDefault("webhooks.maxbackoffminutes", 360)
 
 	// Look for config files
 	viper.SetConfigName("config")
@@ -45,9 +233,41 @@ func LoadConfig() (*Config, error) {
 
 	// Map environment variables
 	viper.BindEnv("server.port", "APP_SERVER_PORT")
+	viper.BindEnv("database.driver", "APP_DB_DRIVER")
 	viper.BindEnv("database.url", "APP_DB_URL")
 	viper.BindEnv("jwt.secret", "APP_JWT_SECRET")
 	viper.BindEnv("jwt.issuer", "APP_JWT_ISSUER")
+	viper.BindEnv("routing.provider", "APP_ROUTING_PROVIDER")
+	viper.BindEnv("routing.url", "APP_ROUTING_URL")
+	viper.BindEnv("matching.scorer", "APP_MATCHING_SCORER")
+	viper.BindEnv("matching.windowminutes", "APP_MATCHING_WINDOW_MINUTES")
+	viper.BindEnv("matching.threshold", "APP_MATCHING_THRESHOLD")
+	viper.BindEnv("matching.sweepintervalseconds", "APP_MATCHING_SWEEP_INTERVAL_SECONDS")
+	viper.BindEnv("jobs.workers", "APP_JOBS_WORKERS")
+	viper.BindEnv("jobs.deliveryworkers", "APP_JOBS_DELIVERY_WORKERS")
+	viper.BindEnv("expiry.intervalseconds", "APP_EXPIRY_INTERVAL_SECONDS")
+	viper.BindEnv("expiry.graceminutes", "APP_EXPIRY_GRACE_MINUTES")
+	viper.BindEnv("expiry.matchttlminutes", "APP_EXPIRY_MATCH_TTL_MINUTES")
+	viper.BindEnv("dispatch.intervalseconds", "APP_DISPATCH_INTERVAL_SECONDS")
+	viper.BindEnv("dispatch.radiuskm", "APP_DISPATCH_RADIUS_KM")
+	viper.BindEnv("dispatch.acceptseconds", "APP_DISPATCH_ACCEPT_SECONDS")
+	viper.BindEnv("dispatch.maxwaitminutes", "APP_DISPATCH_MAX_WAIT_MINUTES")
+	viper.BindEnv("dispatch.priceperkm", "APP_DISPATCH_PRICE_PER_KM")
+	viper.BindEnv("tracking.maxpointsperminute", "APP_TRACKING_MAX_POINTS_PER_MINUTE")
+	viper.BindEnv("tracking.maxbatchsize", "APP_TRACKING_MAX_BATCH_SIZE")
+	viper.BindEnv("tracking.retentiondays", "APP_TRACKING_RETENTION_DAYS")
+	viper.BindEnv("events.historysize", "APP_EVENTS_HISTORY_SIZE")
+	viper.BindEnv("events.historyttlminutes", "APP_EVENTS_HISTORY_TTL_MINUTES")
+	viper.BindEnv("events.subscriberbuffer", "APP_EVENTS_SUBSCRIBER_BUFFER")
+	viper.BindEnv("outbox.relayintervalseconds", "APP_OUTBOX_RELAY_INTERVAL_SECONDS")
+	viper.BindEnv("outbox.maxattempts", "APP_OUTBOX_MAX_ATTEMPTS")
+	viper.BindEnv("outbox.retentiondays", "APP_OUTBOX_RETENTION_DAYS")
+	viper.BindEnv("outbox.filepath", "APP_OUTBOX_FILE_PATH")
+	viper.BindEnv("outbox.subjectprefix", "APP_OUTBOX_SUBJECT_PREFIX")
+	viper.BindEnv("webhooks.deliveryintervalseconds", "APP_WEBHOOKS_DELIVERY_INTERVAL_SECONDS")
+	viper.BindEnv("webhooks.timeoutseconds", "APP_WEBHOOKS_TIMEOUT_SECONDS")
+	viper.BindEnv("webhooks.maxattempts", "APP_WEBHOOKS_MAX_ATTEMPTS")
+	viper.BindEnv("webhooks.backoffseconds", "APP_WEBHOOKS_BACKOFF_SECONDS")
 
 	// Read config file (if exists)
 	if err := viper.ReadInConfig(); err != nil {
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DriverAvailability tells whether a driver takes on-demand trips right now, and where they are
+type DriverAvailability struct {
+	DriverID  uuid.UUID `json:"driver_id" gorm:"primary_key;type:uuid"`
+	Online    bool      `json:"online" gorm:"not null;default:false"`
+	Location  Location  `json:"location" gorm:"embedded;embedded_prefix:location_"`
+	Geohash   string    `json:"-" gorm:"column:location_geohash;type:varchar(12)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	// UpdatedAt is when the driver last reported their availability or location
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	// CurrentTripID is the ride offer of the on-demand trip the driver accepted and has not
+	// finished yet. Drivers on a trip are not offered other trips.
+	CurrentTripID *uuid.UUID `json:"current_trip_id,omitempty" gorm:"type:uuid"`
+}
+
+// DispatchStatus defines where a trip offered to a driver stands
+type DispatchStatus string
+
+const (
+	// DispatchOffered indicates the driver has not answered yet
+	DispatchOffered DispatchStatus = "offered"
+	// DispatchAccepted indicates the driver took the trip
+	DispatchAccepted DispatchStatus = "accepted"
+	// DispatchDeclined indicates the driver turned the trip down
+	DispatchDeclined DispatchStatus = "declined"
+	// DispatchTimedOut indicates the driver did not answer in time
+	DispatchTimedOut DispatchStatus = "timed_out"
+	// DispatchCancelled indicates the passenger cancelled before the driver answered
+	DispatchCancelled DispatchStatus = "cancelled"
+)
+
+// Dispatch is an on-demand ride request offered to one driver, who has until ExpiresAt to accept it.
+// A request is offered to one driver at a time, nearest first, until one of them accepts.
+type Dispatch struct {
+	ID            uuid.UUID      `json:"id" gorm:"primary_key;type:uuid"`
+	RideRequestID uuid.UUID      `json:"ride_request_id" gorm:"type:uuid;not null"`
+	DriverID      uuid.UUID      `json:"driver_id" gorm:"type:uuid;not null"`
+	Status        DispatchStatus `json:"status" gorm:"type:varchar(20);not null"`
+	// DistanceKm is how far the driver was from the pickup when the trip was offered
+	DistanceKm  float64    `json:"distance_km" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	RespondedAt *time.Time `json:"responded_at"`
+	// RideMatchID is the match assigning the driver to the trip once accepted
+	RideMatchID *uuid.UUID `json:"ride_match_id,omitempty" gorm:"type:uuid"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// BeforeCreate generates a UUID for new dispatches before creating them
+func (d *Dispatch) BeforeCreate() error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave stores expiry times in UTC so that they compare correctly on every backend
+func (d *Dispatch) BeforeSave() error {
+	d.ExpiresAt = d.ExpiresAt.UTC()
+	return nil
+}
+
+// Open reports whether the dispatch still waits for the driver's answer
+func (d *Dispatch) Open() bool {
+	return d.Status == DispatchOffered
+}
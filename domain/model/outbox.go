@@ -0,0 +1,174 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DomainEventType identifies a change to a ride. Types are named after the entity that
+// changed and what happened to it.
+type DomainEventType string
+
+const (
+	// EventRideOfferCreated records a driver offering a ride
+	EventRideOfferCreated DomainEventType = "ride_offer.created"
+	// EventRideOfferUpdated records a driver changing their ride offer
+	EventRideOfferUpdated DomainEventType = "ride_offer.updated"
+	// EventRideOfferDeleted records a driver withdrawing their ride offer before anyone confirmed it
+	EventRideOfferDeleted DomainEventType = "ride_offer.deleted"
+	// EventRideOfferCancelled records a driver cancelling their ride offer
+	EventRideOfferCancelled DomainEventType = "ride_offer.cancelled"
+	// EventRideOfferExpired records a ride offer departing without being confirmed
+	EventRideOfferExpired DomainEventType = "ride_offer.expired"
+	// EventRideStarted records a driver setting off on a ride
+	EventRideStarted DomainEventType = "ride_offer.started"
+	// EventRideCompleted records a driver finishing a ride
+	EventRideCompleted DomainEventType = "ride_offer.completed"
+	// EventRideRequestCreated records a passenger asking for a ride
+	EventRideRequestCreated DomainEventType = "ride_request.created"
+	// EventRideRequestUpdated records a passenger changing their ride request
+	EventRideRequestUpdated DomainEventType = "ride_request.updated"
+	// EventRideRequestDeleted records a passenger withdrawing their ride request before it was confirmed
+	EventRideRequestDeleted DomainEventType = "ride_request.deleted"
+	// EventRideRequestCancelled records a passenger cancelling their ride request
+	EventRideRequestCancelled DomainEventType = "ride_request.cancelled"
+	// EventRideRequestExpired records a ride request departing without being confirmed
+	EventRideRequestExpired DomainEventType = "ride_request.expired"
+	// EventMatchCreated records a driver and a passenger being matched, by matching or a booking
+	EventMatchCreated DomainEventType = "ride_match.created"
+	// EventMatchConfirmed records both parties of a match accepting it
+	EventMatchConfirmed DomainEventType = "ride_match.confirmed"
+	// EventMatchRejected records a party of a match turning it down
+	EventMatchRejected DomainEventType = "ride_match.rejected"
+	// EventMatchCancelled records a match being called off because its ride was cancelled or left without it
+	EventMatchCancelled DomainEventType = "ride_match.cancelled"
+	// EventMatchExpired records a proposed match not being confirmed in time
+	EventMatchExpired DomainEventType = "ride_match.expired"
+	// EventMatchWithdrawn records a proposed match being dropped because a party changed or withdrew their ride
+	EventMatchWithdrawn DomainEventType = "ride_match.withdrawn"
+)
+
+// DomainEventTypes lists every type of domain event
+var DomainEventTypes = []DomainEventType{
+	EventRideOfferCreated,
+	EventRideOfferUpdated,
+	EventRideOfferDeleted,
+	EventRideOfferCancelled,
+	EventRideOfferExpired,
+	EventRideStarted,
+	EventRideCompleted,
+	EventRideRequestCreated,
+	EventRideRequestUpdated,
+	EventRideRequestDeleted,
+	EventRideRequestCancelled,
+	EventRideRequestExpired,
+	EventMatchCreated,
+	EventMatchConfirmed,
+	EventMatchRejected,
+	EventMatchCancelled,
+	EventMatchExpired,
+	EventMatchWithdrawn,
+}
+
+// IsDomainEventType reports whether eventType names a type of domain event
+func IsDomainEventType(eventType DomainEventType) bool {
+	for _, known := range DomainEventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// EventPayload is the JSON body of a domain event
+type EventPayload []byte
+
+// Value stores an event payload as JSON text
+func (p EventPayload) Value() (driver.Value, error) {
+	return string(p), nil
+}
+
+// Scan reads an event payload stored as JSON text
+func (p *EventPayload) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case []byte:
+		*p = append(EventPayload{}, data...)
+	case string:
+		*p = EventPayload(data)
+	default:
+		return fmt.Errorf("cannot scan %T into an event payload", value)
+	}
+	return nil
+}
+
+// MarshalJSON writes the payload as it is, rather than as base64
+func (p EventPayload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
+
+// UnmarshalJSON keeps the payload as it is
+func (p *EventPayload) UnmarshalJSON(data []byte) error {
+	*p = append(EventPayload{}, data...)
+	return nil
+}
+
+// OutboxEventStatus defines where the relay of an event to the sinks stands
+type OutboxEventStatus string
+
+const (
+	// OutboxPending indicates the event waits to be relayed
+	OutboxPending OutboxEventStatus = "pending"
+	// OutboxPublished indicates every sink took the event
+	OutboxPublished OutboxEventStatus = "published"
+	// OutboxDead indicates the relay gave up on the event after too many failed attempts
+	OutboxDead OutboxEventStatus = "dead"
+)
+
+// OutboxEvent is a domain event waiting in the outbox. It is written in the same transaction as
+// the change it records, so that a change is never saved without its event or the other way round,
+// and it stays in the outbox until the relay has handed it to every sink.
+type OutboxEvent struct {
+	ID uuid.UUID `json:"id" gorm:"primary_key;type:uuid"`
+	// Sequence numbers the events in the order they were written; the database assigns it
+	Sequence int64           `json:"sequence" gorm:"auto_increment"`
+	Type     DomainEventType `json:"type" gorm:"type:varchar(50);not null"`
+	// AggregateID is the ride offer, request or match that changed
+	AggregateID uuid.UUID `json:"aggregate_id" gorm:"type:uuid;not null"`
+	// Payload is the state of the aggregate after the change
+	Payload    EventPayload      `json:"payload" gorm:"type:jsonb;not null"`
+	OccurredAt time.Time         `json:"occurred_at" gorm:"not null"`
+	Status     OutboxEventStatus `json:"status" gorm:"type:varchar(20);not null"`
+	// PublishedAt is when the relay delivered the event; it is nil while the event waits
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	// Attempts counts the deliveries that failed
+	Attempts  int       `json:"attempts" gorm:"not null;default:0"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BeforeCreate generates a UUID for new outbox events and marks them pending before creating them
+func (e *OutboxEvent) BeforeCreate() error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.Status == "" {
+		e.Status = OutboxPending
+	}
+	return nil
+}
+
+// BeforeSave stores times in UTC so that they compare correctly on every backend
+func (e *OutboxEvent) BeforeSave() error {
+	e.OccurredAt = e.OccurredAt.UTC()
+	if e.PublishedAt != nil {
+		publishedAt := e.PublishedAt.UTC()
+		e.PublishedAt = &publishedAt
+	}
+	return nil
+}
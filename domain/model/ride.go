@@ -1,6 +1,9 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +25,20 @@ const (
 	StatusCompleted RideStatus = "completed"
 	// StatusCancelled indicates a ride has been cancelled
 	StatusCancelled RideStatus = "cancelled"
+	// StatusRejected indicates a proposed match has been declined by one of its parties
+	StatusRejected RideStatus = "rejected"
+	// StatusExpired indicates a ride departed without being confirmed, or a proposed match was not confirmed in time
+	StatusExpired RideStatus = "expired"
+)
+
+// RideMode tells how a ride request finds its driver
+type RideMode string
+
+const (
+	// ModeScheduled requests are matched with ride offers that drivers publish ahead of time
+	ModeScheduled RideMode = "scheduled"
+	// ModeOnDemand requests want to leave now and are dispatched to the nearest available driver
+	ModeOnDemand RideMode = "on_demand"
 )
 
 // Location represents a geographical point
@@ -33,18 +50,32 @@ type Location struct {
 
 // RideOffer represents a ride offered by a driver
 type RideOffer struct {
-	ID              uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid"`
-	DriverID        uuid.UUID  `json:"driver_id" gorm:"type:uuid;not null"`
-	Driver          User       `json:"-" gorm:"foreignKey:DriverID"`
-	StartLocation   Location   `json:"start_location" gorm:"embedded;embeddedPrefix:start_"`
-	EndLocation     Location   `json:"end_location" gorm:"embedded;embeddedPrefix:end_"`
-	DepartureTime   time.Time  `json:"departure_time" gorm:"not null"`
-	AvailableSeats  int        `json:"available_seats" gorm:"not null"`
-	Status          RideStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
-	PricePerSeat    float64    `json:"price_per_seat" gorm:"not null"`
-	AllowedDetourKm float64    `json:"allowed_detour_km" gorm:"default:5"`
-	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	ID              uuid.UUID      `json:"id" gorm:"primary_key;type:uuid"`
+	DriverID        uuid.UUID      `json:"driver_id" gorm:"type:uuid;not null"`
+	Driver          User           `json:"-" gorm:"foreignKey:DriverID"`
+	StartLocation   Location       `json:"start_location" gorm:"embedded;embedded_prefix:start_"`
+	EndLocation     Location       `json:"end_location" gorm:"embedded;embedded_prefix:end_"`
+	DepartureTime   time.Time      `json:"departure_time" gorm:"not null"`
+	AvailableSeats  int            `json:"available_seats" gorm:"not null"`
+	Status          RideStatus     `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	PricePerSeat    float64        `json:"price_per_seat" gorm:"not null"`
+	AllowedDetourKm float64        `json:"allowed_detour_km" gorm:"default:5"`
+	StartGeohash    string         `json:"-" gorm:"type:varchar(12)"`
+	EndGeohash      string         `json:"-" gorm:"type:varchar(12)"`
+	Corridor        CorridorBox    `json:"-" gorm:"embedded;embedded_prefix:corridor_"`
+	Waypoints       []RideWaypoint `json:"waypoints,omitempty" gorm:"-"`
+	Stops           []RideStop     `json:"stops,omitempty" gorm:"-"`
+	CreatedAt       time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// CorridorBox bounds the area in which a ride offer's passengers may board and leave.
+// Its edges are nil when the offer has no box, in which case it is checked exactly.
+type CorridorBox struct {
+	MinLatitude  *float64
+	MaxLatitude  *float64
+	MinLongitude *float64
+	MaxLongitude *float64
 }
 
 // BeforeCreate generates a UUID for new ride offers before creating them
@@ -55,17 +86,26 @@ func (r *RideOffer) BeforeCreate() error {
 	return nil
 }
 
+// BeforeSave stores departure times in UTC so that they compare correctly on every backend
+func (r *RideOffer) BeforeSave() error {
+	r.DepartureTime = r.DepartureTime.UTC()
+	return nil
+}
+
 // RideRequest represents a ride requested by a passenger
 type RideRequest struct {
-	ID            uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid"`
+	ID            uuid.UUID  `json:"id" gorm:"primary_key;type:uuid"`
 	PassengerID   uuid.UUID  `json:"passenger_id" gorm:"type:uuid;not null"`
 	Passenger     User       `json:"-" gorm:"foreignKey:PassengerID"`
-	StartLocation Location   `json:"start_location" gorm:"embedded;embeddedPrefix:start_"`
-	EndLocation   Location   `json:"end_location" gorm:"embedded;embeddedPrefix:end_"`
+	StartLocation Location   `json:"start_location" gorm:"embedded;embedded_prefix:start_"`
+	EndLocation   Location   `json:"end_location" gorm:"embedded;embedded_prefix:end_"`
 	DepartureTime time.Time  `json:"departure_time" gorm:"not null"`
 	NumPassengers int        `json:"num_passengers" gorm:"not null;default:1"`
 	Status        RideStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
 	MaxPrice      float64    `json:"max_price" gorm:"not null"`
+	Mode          RideMode   `json:"mode" gorm:"type:varchar(20);not null;default:'scheduled'"`
+	StartGeohash  string     `json:"-" gorm:"type:varchar(12)"`
+	EndGeohash    string     `json:"-" gorm:"type:varchar(12)"`
 	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 }
@@ -78,18 +118,40 @@ func (r *RideRequest) BeforeCreate() error {
 	return nil
 }
 
-// RideMatch represents a match between a ride offer and request
+// BeforeSave stores departure times in UTC so that they compare correctly on every backend
+func (r *RideRequest) BeforeSave() error {
+	r.DepartureTime = r.DepartureTime.UTC()
+	return nil
+}
+
+// RideMatch represents a match between a ride offer and request.
+// The driver and the passenger accept it independently; it is confirmed once both have.
 type RideMatch struct {
-	ID            uuid.UUID   `json:"id" gorm:"primaryKey;type:uuid"`
-	RideOfferID   uuid.UUID   `json:"ride_offer_id" gorm:"type:uuid;not null"`
-	RideOffer     RideOffer   `json:"-" gorm:"foreignKey:RideOfferID"`
-	RideRequestID uuid.UUID   `json:"ride_request_id" gorm:"type:uuid;not null"`
-	RideRequest   RideRequest `json:"-" gorm:"foreignKey:RideRequestID"`
-	Status        RideStatus  `json:"status" gorm:"type:varchar(20);default:'matched'"`
-	MatchScore    float64     `json:"match_score" gorm:"not null"`
-	Price         float64     `json:"price" gorm:"not null"`
-	CreatedAt     time.Time   `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time   `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                  uuid.UUID       `json:"id" gorm:"primary_key;type:uuid"`
+	RideOfferID         uuid.UUID       `json:"ride_offer_id" gorm:"type:uuid;not null"`
+	RideOffer           RideOffer       `json:"-" gorm:"foreignKey:RideOfferID"`
+	RideRequestID       uuid.UUID       `json:"ride_request_id" gorm:"type:uuid;not null"`
+	RideRequest         RideRequest     `json:"-" gorm:"foreignKey:RideRequestID"`
+	Status              RideStatus      `json:"status" gorm:"type:varchar(20);default:'matched'"`
+	MatchScore          float64         `json:"match_score" gorm:"not null"`
+	Price               float64         `json:"price" gorm:"not null"`
+	DriverAcceptedAt    *time.Time      `json:"driver_accepted_at"`
+	PassengerAcceptedAt *time.Time      `json:"passenger_accepted_at"`
+	RejectedBy          *uuid.UUID      `json:"rejected_by,omitempty" gorm:"type:uuid"`
+	RejectionReason     string          `json:"rejection_reason,omitempty"`
+	ScoreBreakdown      *MatchBreakdown `json:"score_breakdown,omitempty" gorm:"type:jsonb"`
+	CreatedAt           time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// DriverAccepted reports whether the driver has accepted the match
+func (r *RideMatch) DriverAccepted() bool {
+	return r.DriverAcceptedAt != nil
+}
+
+// PassengerAccepted reports whether the passenger has accepted the match
+func (r *RideMatch) PassengerAccepted() bool {
+	return r.PassengerAcceptedAt != nil
 }
 
 // BeforeCreate generates a UUID for new ride matches before creating them
@@ -99,3 +161,111 @@ func (r *RideMatch) BeforeCreate() error {
 	}
 	return nil
 }
+
+// MatchBreakdown explains a match score: the component scores (0-1) and the measurements behind them
+type MatchBreakdown struct {
+	Score         float64  `json:"score"`
+	PriceScore    float64  `json:"price_score"`
+	TimeScore     float64  `json:"time_score"`
+	LocationScore float64  `json:"location_score"`
+	RatingScore   *float64 `json:"rating_score,omitempty"`
+	// PickupDistanceKm is the straight-line distance from the driver's start to the pickup
+	PickupDistanceKm float64 `json:"pickup_distance_km"`
+	// DropoffDistanceKm is the straight-line distance from the drop-off to the driver's end
+	DropoffDistanceKm     float64 `json:"dropoff_distance_km"`
+	TimeDifferenceMinutes float64 `json:"time_difference_minutes"`
+	DetourKm              float64 `json:"detour_km"`
+	Fare                  float64 `json:"fare"`
+	// Rejection says why the pair was not matched; it is empty for matches
+	Rejection string `json:"rejection,omitempty"`
+}
+
+// Value stores a match breakdown as JSON
+func (b MatchBreakdown) Value() (driver.Value, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a match breakdown stored as JSON
+func (b *MatchBreakdown) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, b)
+	case string:
+		return json.Unmarshal([]byte(data), b)
+	default:
+		return fmt.Errorf("cannot scan %T into a match breakdown", value)
+	}
+}
+
+// StopType tells whether passengers board or leave the car at a stop
+type StopType string
+
+const (
+	// StopPickup is where a passenger boards
+	StopPickup StopType = "pickup"
+	// StopDropoff is where a passenger leaves
+	StopDropoff StopType = "dropoff"
+)
+
+// RideStop is a pickup or drop-off on a ride offer's route, in driving order
+type RideStop struct {
+	ID            uuid.UUID `json:"id" gorm:"primary_key;type:uuid"`
+	RideOfferID   uuid.UUID `json:"ride_offer_id" gorm:"type:uuid;not null"`
+	RideRequestID uuid.UUID `json:"ride_request_id" gorm:"type:uuid;not null"`
+	Sequence      int       `json:"sequence" gorm:"not null"`
+	// AfterWaypoint is the sequence of the waypoint the stop follows, 0 for the start
+	AfterWaypoint int       `json:"after_waypoint" gorm:"not null;default:0"`
+	Type          StopType  `json:"type" gorm:"type:varchar(10);not null"`
+	Location      Location  `json:"location" gorm:"embedded;embedded_prefix:location_"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BeforeCreate generates a UUID for new ride stops before creating them
+func (r *RideStop) BeforeCreate() error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// RideWaypoint is a place a ride offer passes through between its start and end.
+// Passengers can board or leave at any waypoint.
+type RideWaypoint struct {
+	ID          uuid.UUID `json:"id" gorm:"primary_key;type:uuid"`
+	RideOfferID uuid.UUID `json:"ride_offer_id" gorm:"type:uuid;not null"`
+	Sequence    int       `json:"sequence" gorm:"not null"`
+	Location    Location  `json:"location" gorm:"embedded;embedded_prefix:location_"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BeforeCreate generates a UUID for new ride waypoints before creating them
+func (r *RideWaypoint) BeforeCreate() error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// Fare returns the price for numPassengers travelling rideKm of the offer's routeKm long route.
+// Passengers pay the seat price in proportion to the distance they travel.
+func (r *RideOffer) Fare(numPassengers int, rideKm, routeKm float64) float64 {
+	share := 1.0
+	if routeKm > 0 && rideKm < routeKm {
+		share = rideKm / routeKm
+	}
+	return r.PricePerSeat * float64(numPassengers) * share
+}
+
+// Route returns the locations the offer's driver passes through, from start to end
+func (r *RideOffer) Route() []Location {
+	route := make([]Location, 0, len(r.Waypoints)+2)
+	route = append(route, r.StartLocation)
+	for _, waypoint := range r.Waypoints {
+		route = append(route, waypoint.Location)
+	}
+	return append(route, r.EndLocation)
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TripLocation is a GPS point the driver reported during a ride. The points of a ride offer,
+// in the order they were recorded, form the trace of the trip.
+type TripLocation struct {
+	ID          uuid.UUID `json:"id" gorm:"primary_key;type:uuid"`
+	RideOfferID uuid.UUID `json:"ride_offer_id" gorm:"type:uuid;not null"`
+	Latitude    float64   `json:"lat" gorm:"not null"`
+	Longitude   float64   `json:"lng" gorm:"not null"`
+	// Heading is the direction of travel in degrees clockwise from north, if the device reported it
+	Heading *float64 `json:"heading,omitempty"`
+	// SpeedKmh is the speed of the car, if the device reported it
+	SpeedKmh *float64 `json:"speed_kmh,omitempty"`
+	// AccuracyM is the radius in meters the point is accurate to, if the device reported it
+	AccuracyM *float64 `json:"accuracy_m,omitempty"`
+	// RecordedAt is when the device took the point, which may be well before it was uploaded
+	RecordedAt time.Time `json:"recorded_at" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BeforeCreate generates a UUID for new trip locations before creating them
+func (l *TripLocation) BeforeCreate() error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave stores recording times in UTC so that they compare correctly on every backend
+func (l *TripLocation) BeforeSave() error {
+	l.RecordedAt = l.RecordedAt.UTC()
+	return nil
+}
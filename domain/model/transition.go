@@ -0,0 +1,158 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition is the error wrapped by every rejected status change
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// TransitionError describes a status change that the state machine does not allow
+type TransitionError struct {
+	Entity string
+	From   RideStatus
+	To     RideStatus
+}
+
+// Error implements the error interface
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot move from %s to %s", e.Entity, e.From, e.To)
+}
+
+// Unwrap lets errors.Is match ErrInvalidTransition
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// rideOfferTransitions lists the allowed status changes for ride offers.
+// An offer may take further passengers, so it can stay matched; a confirmed offer taking
+// further passengers stays confirmed (see AcceptsMatches, MarkMatched and Confirm), and one
+// that loses its confirmed passengers goes back to matching through Reopen.
+var rideOfferTransitions = map[RideStatus][]RideStatus{
+	StatusPending:    {StatusMatched, StatusCancelled, StatusExpired},
+	StatusMatched:    {StatusMatched, StatusPending, StatusConfirmed, StatusCancelled, StatusExpired},
+	StatusConfirmed:  {StatusInProgress, StatusCancelled},
+	StatusInProgress: {StatusCompleted},
+}
+
+// rideRequestTransitions lists the allowed status changes for ride requests.
+// A request can be proposed to several offers but confirmed on only one. A request whose
+// confirmed seat is given up goes back to matching through Reopen.
+var rideRequestTransitions = map[RideStatus][]RideStatus{
+	StatusPending:    {StatusMatched, StatusCancelled, StatusExpired},
+	StatusMatched:    {StatusMatched, StatusPending, StatusConfirmed, StatusCancelled, StatusExpired},
+	StatusConfirmed:  {StatusInProgress, StatusCancelled},
+	StatusInProgress: {StatusCompleted},
+}
+
+// rideMatchTransitions lists the allowed status changes for ride matches
+var rideMatchTransitions = map[RideStatus][]RideStatus{
+	StatusMatched:    {StatusConfirmed, StatusRejected, StatusCancelled, StatusExpired},
+	StatusConfirmed:  {StatusInProgress, StatusCancelled},
+	StatusInProgress: {StatusCompleted},
+}
+
+// CanTransitionTo reports whether the ride offer may move to the given status
+func (r *RideOffer) CanTransitionTo(status RideStatus) bool {
+	return allowed(rideOfferTransitions, r.Status, status)
+}
+
+// TransitionTo moves the ride offer to the given status if the state machine allows it
+func (r *RideOffer) TransitionTo(status RideStatus) error {
+	if !r.CanTransitionTo(status) {
+		return &TransitionError{Entity: "ride offer", From: r.Status, To: status}
+	}
+	r.Status = status
+	return nil
+}
+
+// AcceptsMatches reports whether passengers can still be proposed to the ride offer.
+// Confirmed offers take further passengers as long as they have seats left.
+func (r *RideOffer) AcceptsMatches() bool {
+	return r.Status == StatusConfirmed || r.CanTransitionTo(StatusMatched)
+}
+
+// MarkMatched records that a passenger was proposed to the ride offer. A pending offer becomes
+// matched; a matched or confirmed one keeps its status.
+func (r *RideOffer) MarkMatched() error {
+	if !r.AcceptsMatches() {
+		return &TransitionError{Entity: "ride offer", From: r.Status, To: StatusMatched}
+	}
+	if r.Status == StatusPending {
+		r.Status = StatusMatched
+	}
+	return nil
+}
+
+// Confirm records that a passenger's seat on the ride offer was confirmed.
+// An offer that has a confirmed passenger already stays confirmed.
+func (r *RideOffer) Confirm() error {
+	if r.Status == StatusConfirmed {
+		return nil
+	}
+	return r.TransitionTo(StatusConfirmed)
+}
+
+// Reopen moves a confirmed ride offer back to matching once none of its seats are confirmed any
+// more: to matched if passengers are still proposed to it, to pending otherwise
+func (r *RideOffer) Reopen(status RideStatus) error {
+	if !reopenable(r.Status, status) {
+		return &TransitionError{Entity: "ride offer", From: r.Status, To: status}
+	}
+	r.Status = status
+	return nil
+}
+
+// CanTransitionTo reports whether the ride request may move to the given status
+func (r *RideRequest) CanTransitionTo(status RideStatus) bool {
+	return allowed(rideRequestTransitions, r.Status, status)
+}
+
+// TransitionTo moves the ride request to the given status if the state machine allows it
+func (r *RideRequest) TransitionTo(status RideStatus) error {
+	if !r.CanTransitionTo(status) {
+		return &TransitionError{Entity: "ride request", From: r.Status, To: status}
+	}
+	r.Status = status
+	return nil
+}
+
+// Reopen moves a confirmed ride request back to matching once its confirmed seat is given up:
+// to matched if it is still proposed to other offers, to pending otherwise
+func (r *RideRequest) Reopen(status RideStatus) error {
+	if !reopenable(r.Status, status) {
+		return &TransitionError{Entity: "ride request", From: r.Status, To: status}
+	}
+	r.Status = status
+	return nil
+}
+
+// CanTransitionTo reports whether the ride match may move to the given status
+func (r *RideMatch) CanTransitionTo(status RideStatus) bool {
+	return allowed(rideMatchTransitions, r.Status, status)
+}
+
+// TransitionTo moves the ride match to the given status if the state machine allows it
+func (r *RideMatch) TransitionTo(status RideStatus) error {
+	if !r.CanTransitionTo(status) {
+		return &TransitionError{Entity: "ride match", From: r.Status, To: status}
+	}
+	r.Status = status
+	return nil
+}
+
+// reopenable reports whether a confirmed offer or request may go back to the given matching status
+func reopenable(from, to RideStatus) bool {
+	return from == StatusConfirmed && (to == StatusMatched || to == StatusPending)
+}
+
+// allowed looks up a status change in a transition table
+func allowed(table map[RideStatus][]RideStatus, from, to RideStatus) bool {
+	for _, next := range table[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+// allStatuses lists every ride status, so that the tests also cover the moves the tables leave out
+var allStatuses = []RideStatus{
+	StatusPending,
+	StatusMatched,
+	StatusConfirmed,
+	StatusInProgress,
+	StatusCompleted,
+	StatusCancelled,
+	StatusRejected,
+	StatusExpired,
+}
+
+// transitionCase is a status change and whether the state machine must allow it
+type transitionCase struct {
+	from, to RideStatus
+	allowed  bool
+}
+
+// transitionCases checks every pair of statuses, allowing exactly the listed moves
+func transitionCases(allowedMoves map[RideStatus][]RideStatus) []transitionCase {
+	var cases []transitionCase
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			allowed := false
+			for _, next := range allowedMoves[from] {
+				if next == to {
+					allowed = true
+				}
+			}
+			cases = append(cases, transitionCase{from: from, to: to, allowed: allowed})
+		}
+	}
+	return cases
+}
+
+// checkTransition asserts the outcome of one TransitionTo call
+func checkTransition(t *testing.T, tc transitionCase, err error, status RideStatus) {
+	t.Helper()
+	if tc.allowed {
+		if err != nil || status != tc.to {
+			t.Errorf("%s -> %s = %v, status %s; want allowed", tc.from, tc.to, err, status)
+		}
+		return
+	}
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("%s -> %s = %v; want ErrInvalidTransition", tc.from, tc.to, err)
+	}
+	if status != tc.from {
+		t.Errorf("%s -> %s left the status at %s; want it unchanged", tc.from, tc.to, status)
+	}
+}
+
+func TestRideOfferTransitionTo(t *testing.T) {
+	cases := transitionCases(map[RideStatus][]RideStatus{
+		StatusPending:    {StatusMatched, StatusCancelled, StatusExpired},
+		StatusMatched:    {StatusMatched, StatusPending, StatusConfirmed, StatusCancelled, StatusExpired},
+		StatusConfirmed:  {StatusInProgress, StatusCancelled},
+		StatusInProgress: {StatusCompleted},
+	})
+	for _, tc := range cases {
+		offer := &RideOffer{Status: tc.from}
+		err := offer.TransitionTo(tc.to)
+		checkTransition(t, tc, err, offer.Status)
+	}
+}
+
+func TestRideRequestTransitionTo(t *testing.T) {
+	cases := transitionCases(map[RideStatus][]RideStatus{
+		StatusPending:    {StatusMatched, StatusCancelled, StatusExpired},
+		StatusMatched:    {StatusMatched, StatusPending, StatusConfirmed, StatusCancelled, StatusExpired},
+		StatusConfirmed:  {StatusInProgress, StatusCancelled},
+		StatusInProgress: {StatusCompleted},
+	})
+	for _, tc := range cases {
+		request := &RideRequest{Status: tc.from}
+		err := request.TransitionTo(tc.to)
+		checkTransition(t, tc, err, request.Status)
+	}
+}
+
+func TestConfirmedRideOfferOnlyReopensExplicitly(t *testing.T) {
+	offer := &RideOffer{Status: StatusConfirmed}
+	if err := offer.TransitionTo(StatusMatched); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("TransitionTo(matched) = %v, want ErrInvalidTransition", err)
+	}
+
+	// Proposing or confirming further passengers leaves it confirmed
+	if !offer.AcceptsMatches() {
+		t.Error("AcceptsMatches = false, want a confirmed offer to take further passengers")
+	}
+	if err := offer.MarkMatched(); err != nil || offer.Status != StatusConfirmed {
+		t.Errorf("MarkMatched = %v, status %s; want it to stay confirmed", err, offer.Status)
+	}
+	if err := offer.Confirm(); err != nil || offer.Status != StatusConfirmed {
+		t.Errorf("Confirm = %v, status %s; want it to stay confirmed", err, offer.Status)
+	}
+
+	if err := offer.Reopen(StatusMatched); err != nil || offer.Status != StatusMatched {
+		t.Fatalf("Reopen(matched) = %v, status %s; want matched", err, offer.Status)
+	}
+	if err := offer.Reopen(StatusPending); !errors.Is(err, ErrInvalidTransition) || offer.Status != StatusMatched {
+		t.Errorf("Reopen of a matched offer = %v, status %s; want ErrInvalidTransition", err, offer.Status)
+	}
+}
+
+func TestRideOfferMarkMatched(t *testing.T) {
+	for _, tc := range []struct {
+		from, want RideStatus
+		allowed    bool
+	}{
+		{from: StatusPending, want: StatusMatched, allowed: true},
+		{from: StatusMatched, want: StatusMatched, allowed: true},
+		{from: StatusConfirmed, want: StatusConfirmed, allowed: true},
+		{from: StatusInProgress, want: StatusInProgress},
+		{from: StatusCancelled, want: StatusCancelled},
+		{from: StatusExpired, want: StatusExpired},
+	} {
+		offer := &RideOffer{Status: tc.from}
+		err := offer.MarkMatched()
+		if tc.allowed != (err == nil) || offer.Status != tc.want {
+			t.Errorf("MarkMatched from %s = %v, status %s; want allowed %t, status %s", tc.from, err, offer.Status, tc.allowed, tc.want)
+		}
+	}
+}
+
+func TestRideRequestReopen(t *testing.T) {
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			request := &RideRequest{Status: from}
+			err := request.Reopen(to)
+			allowed := from == StatusConfirmed && (to == StatusMatched || to == StatusPending)
+			checkTransition(t, transitionCase{from: from, to: to, allowed: allowed}, err, request.Status)
+		}
+	}
+}
+
+func TestRideMatchTransitionTo(t *testing.T) {
+	cases := transitionCases(map[RideStatus][]RideStatus{
+		StatusMatched:    {StatusConfirmed, StatusRejected, StatusCancelled, StatusExpired},
+		StatusConfirmed:  {StatusInProgress, StatusCancelled},
+		StatusInProgress: {StatusCompleted},
+	})
+	for _, tc := range cases {
+		match := &RideMatch{Status: tc.from}
+		err := match.TransitionTo(tc.to)
+		checkTransition(t, tc, err, match.Status)
+	}
+}
+
+func TestTransitionErrorMessage(t *testing.T) {
+	match := &RideMatch{Status: StatusCompleted}
+	err := match.TransitionTo(StatusCancelled)
+
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("TransitionTo = %v, want a *TransitionError", err)
+	}
+	if got, want := err.Error(), "ride match cannot move from completed to cancelled"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -20,13 +20,15 @@ const (
 
 // User represents a user in the system
 type User struct {
-	ID        uuid.UUID `json:"id" gorm:"primaryKey;type:uuid"`
+	ID        uuid.UUID `json:"id" gorm:"primary_key;type:uuid"`
 	FirstName string    `json:"first_name" gorm:"not null"`
 	LastName  string    `json:"last_name" gorm:"not null"`
 	Email     string    `json:"email" gorm:"unique;not null"`
 	Password  string    `json:"-" gorm:"not null"`
 	Phone     string    `json:"phone" gorm:"not null"`
 	Role      UserRole  `json:"role" gorm:"type:varchar(20);not null"`
+	// IsAdmin lets the user inspect the matching of any ride, whatever their role
+	IsAdmin   bool      `json:"is_admin" gorm:"not null;default:false"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
@@ -41,7 +43,7 @@ func (u *User) BeforeCreate() error {
 
 // DriverProfile contains additional information for users with driver role
 type DriverProfile struct {
-	UserID        uuid.UUID `json:"-" gorm:"primaryKey;type:uuid"`
+	UserID        uuid.UUID `json:"-" gorm:"primary_key;type:uuid"`
 	User          User      `json:"-" gorm:"foreignKey:UserID"`
 	LicenseNo     string    `json:"license_no" gorm:"not null"`
 	CarModel      string    `json:"car_model" gorm:"not null"`
@@ -51,3 +53,27 @@ type DriverProfile struct {
 	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
+
+// MatchPriority names the match factor a passenger cares about most
+type MatchPriority string
+
+const (
+	// PriorityPrice favours rides well within the passenger's maximum price
+	PriorityPrice MatchPriority = "price"
+	// PriorityTime favours rides leaving close to the requested departure time
+	PriorityTime MatchPriority = "time"
+	// PriorityLocation favours rides that need only a short detour
+	PriorityLocation MatchPriority = "location"
+	// PriorityRating favours drivers with a high rating
+	PriorityRating MatchPriority = "rating"
+)
+
+// PassengerPreferences holds what a passenger asks of the rides they are matched with
+type PassengerPreferences struct {
+	UserID          uuid.UUID     `json:"-" gorm:"primary_key;type:uuid"`
+	User            User          `json:"-" gorm:"foreignKey:UserID"`
+	MinDriverRating float32       `json:"min_driver_rating" gorm:"not null;default:0"`
+	Priority        MatchPriority `json:"priority" gorm:"type:varchar(20)"`
+	CreatedAt       time.Time     `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+}
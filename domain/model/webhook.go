@@ -0,0 +1,176 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventTypeList is a list of domain event types stored as JSON
+type EventTypeList []DomainEventType
+
+// Value stores an event type list as JSON
+func (l EventTypeList) Value() (driver.Value, error) {
+	return jsonValue(l)
+}
+
+// Scan reads an event type list stored as JSON
+func (l *EventTypeList) Scan(value interface{}) error {
+	return scanJSON(value, l, "an event type list")
+}
+
+// UserIDList is a list of user IDs stored as JSON
+type UserIDList []uuid.UUID
+
+// Value stores a user ID list as JSON
+func (l UserIDList) Value() (driver.Value, error) {
+	return jsonValue(l)
+}
+
+// Scan reads a user ID list stored as JSON
+func (l *UserIDList) Scan(value interface{}) error {
+	return scanJSON(value, l, "a user ID list")
+}
+
+// WebhookSubscription asks for the domain events of some types to be posted to a partner's URL
+type WebhookSubscription struct {
+	ID uuid.UUID `json:"id" gorm:"primary_key;type:uuid"`
+	// Name tells who the webhook is for, such as the partner's company
+	Name string `json:"name" gorm:"not null"`
+	URL  string `json:"url" gorm:"not null"`
+	// Secret keys the signature of every delivery; it is only shown when the subscription is created
+	Secret     string        `json:"-" gorm:"not null"`
+	EventTypes EventTypeList `json:"event_types" gorm:"type:jsonb;not null"`
+	// UserIDs limits the webhook to the rides of these users, such as the partner's employees;
+	// when it is empty the webhook covers every ride
+	UserIDs UserIDList `json:"user_ids" gorm:"type:jsonb"`
+	// Active is false while deliveries to the webhook are paused
+	Active    bool      `json:"active" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// BeforeCreate generates a UUID for new webhook subscriptions before creating them
+func (s *WebhookSubscription) BeforeCreate() error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// Subscribes reports whether the subscription takes events of the given type
+func (s *WebhookSubscription) Subscribes(eventType DomainEventType) bool {
+	for _, subscribed := range s.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether the subscription takes events about a ride of any of the given users
+func (s *WebhookSubscription) Covers(userIDs []uuid.UUID) bool {
+	if len(s.UserIDs) == 0 {
+		return true
+	}
+	for _, covered := range s.UserIDs {
+		for _, userID := range userIDs {
+			if covered == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus defines where the delivery of an event to a webhook stands
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookPending indicates the delivery waits for its next attempt
+	WebhookPending WebhookDeliveryStatus = "pending"
+	// WebhookDelivered indicates the subscriber accepted the delivery
+	WebhookDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDead indicates the delivery was given up after its last attempt failed; it can be replayed
+	WebhookDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one domain event to be posted to one webhook.
+// A failed attempt is retried at NextAttemptAt, waiting longer after every failure.
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id" gorm:"primary_key;type:uuid"`
+	SubscriptionID uuid.UUID       `json:"subscription_id" gorm:"type:uuid;not null"`
+	EventID        uuid.UUID       `json:"event_id" gorm:"type:uuid;not null"`
+	EventType      DomainEventType `json:"event_type" gorm:"type:varchar(50);not null"`
+	// Body is the JSON document posted to the webhook
+	Body   EventPayload          `json:"body" gorm:"type:jsonb;not null"`
+	Status WebhookDeliveryStatus `json:"status" gorm:"type:varchar(20);not null"`
+	// Attempts counts the attempts since the delivery was created or last replayed
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null"`
+	LastError     string     `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// BeforeCreate generates a UUID for new webhook deliveries before creating them
+func (d *WebhookDelivery) BeforeCreate() error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeSave stores attempt times in UTC so that they compare correctly on every backend
+func (d *WebhookDelivery) BeforeSave() error {
+	d.NextAttemptAt = d.NextAttemptAt.UTC()
+	return nil
+}
+
+// WebhookAttempt records one attempt at posting a delivery and what the webhook answered
+type WebhookAttempt struct {
+	ID         uuid.UUID `json:"id" gorm:"primary_key;type:uuid"`
+	DeliveryID uuid.UUID `json:"delivery_id" gorm:"type:uuid;not null"`
+	// StatusCode is the HTTP status the webhook answered with; it is 0 if it did not answer
+	StatusCode int `json:"status_code"`
+	// ResponseBody is the start of the webhook's answer
+	ResponseBody string    `json:"response_body,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BeforeCreate generates a UUID for new webhook attempts before creating them
+func (a *WebhookAttempt) BeforeCreate() error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// jsonValue stores a value as JSON text
+func jsonValue(value interface{}) (driver.Value, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// scanJSON reads a value stored as JSON text into target
+func scanJSON(value interface{}, target interface{}, name string) error {
+	switch data := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(data, target)
+	case string:
+		return json.Unmarshal([]byte(data), target)
+	default:
+		return fmt.Errorf("cannot scan %T into %s", value, name)
+	}
+}
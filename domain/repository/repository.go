@@ -1,10 +1,70 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/yourusername/ride-sharing-app/domain/model"
 )
 
+// ErrDuplicateRideMatch is returned when a ride match is created for an offer and request pair that already has one
+var ErrDuplicateRideMatch = errors.New("a ride match already exists for this ride offer and request")
+
+// RideOfferSearch selects the ride offers a passenger could book a seat on
+type RideOfferSearch struct {
+	// Origin and Destination are where the passenger boards and leaves; the offer's route must
+	// pass within RadiusKm of both, in that order
+	Origin      model.Location
+	Destination model.Location
+	RadiusKm    float64
+	// DepartingFrom and DepartingUntil bound the departure time, inclusively
+	DepartingFrom  time.Time
+	DepartingUntil time.Time
+	// Seats is the number of seats the offer must still have available
+	Seats int
+	// MaxPricePerSeat excludes dearer offers; 0 means any price
+	MaxPricePerSeat float64
+	// OrderBy sorts the offers, ties broken by ID; empty sorts them by departure time
+	OrderBy RideOfferOrder
+	// After continues the search with the offers sorted after this one
+	After *RideOfferCursor
+	// Limit is the most offers returned; 0 returns them all
+	Limit int
+}
+
+// RideOfferOrder is an order in which ride offers are searched
+type RideOfferOrder string
+
+// Orders in which ride offers can be searched
+const (
+	OrderByDeparture RideOfferOrder = "departure_time"
+	OrderByPrice     RideOfferOrder = "price_per_seat"
+)
+
+// RideOfferCursor is the place of a ride offer in a sorted search
+type RideOfferCursor struct {
+	DepartureTime time.Time
+	PricePerSeat  float64
+	ID            uuid.UUID
+}
+
+// CursorAt returns the place of a ride offer in a sorted search
+func CursorAt(offer *model.RideOffer) *RideOfferCursor {
+	return &RideOfferCursor{DepartureTime: offer.DepartureTime, PricePerSeat: offer.PricePerSeat, ID: offer.ID}
+}
+
+// MatchCandidates selects the ride offers and requests that may be matched with each other
+type MatchCandidates struct {
+	// Window is how far apart the offer and request may depart, either way
+	Window time.Duration
+	// StraightLine leaves out pairs the driver cannot serve within the allowed detour and the
+	// passengers' maximum price when measured along straight lines. Roads can make a detour
+	// shorter than straight lines suggest, so it is only set when routes are measured as
+	// straight lines too; otherwise pairs are narrowed down by departure time and seats only.
+	StraightLine bool
+}
+
 // UserRepository defines the contract for user data operations
 type UserRepository interface {
 	Create(user *model.User) error
@@ -15,6 +75,36 @@ type UserRepository interface {
 	CreateDriverProfile(profile *model.DriverProfile) error
 	GetDriverProfile(userID uuid.UUID) (*model.DriverProfile, error)
 	UpdateDriverProfile(profile *model.DriverProfile) error
+	GetPassengerPreferences(userID uuid.UUID) (*model.PassengerPreferences, error)
+	UpdatePassengerPreferences(preferences *model.PassengerPreferences) error
+}
+
+// WebhookRepository defines the contract for webhook subscription and delivery operations
+type WebhookRepository interface {
+	// Subscription operations
+	CreateSubscription(subscription *model.WebhookSubscription) error
+	FindSubscriptionByID(id uuid.UUID) (*model.WebhookSubscription, error)
+	FindSubscriptions() ([]model.WebhookSubscription, error)
+	FindActiveSubscriptions() ([]model.WebhookSubscription, error)
+	UpdateSubscription(subscription *model.WebhookSubscription) error
+	// DeleteSubscription removes a subscription together with its deliveries and their attempts
+	DeleteSubscription(id uuid.UUID) error
+
+	// Delivery operations
+	CreateDelivery(delivery *model.WebhookDelivery) error
+	FindDeliveryByID(id uuid.UUID) (*model.WebhookDelivery, error)
+	FindDeliveryByEvent(subscriptionID, eventID uuid.UUID) (*model.WebhookDelivery, error)
+	// FindDeliveriesBySubscriptionID retrieves up to limit deliveries of a subscription, newest first;
+	// an empty status matches every delivery
+	FindDeliveriesBySubscriptionID(subscriptionID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]model.WebhookDelivery, error)
+	// FindDueDeliveries retrieves up to limit pending deliveries due by the given time, the longest due first.
+	// Deliveries to paused webhooks are left out.
+	FindDueDeliveries(dueBy time.Time, limit int) ([]model.WebhookDelivery, error)
+	UpdateDelivery(delivery *model.WebhookDelivery) error
+
+	// Delivery attempt operations
+	CreateAttempt(attempt *model.WebhookAttempt) error
+	FindAttemptsByDeliveryID(deliveryID uuid.UUID) ([]model.WebhookAttempt, error)
 }
 
 // RideRepository defines the contract for ride operations
@@ -23,6 +113,9 @@ type RideRepository interface {
 	CreateRideOffer(offer *model.RideOffer) error
 	FindRideOfferByID(id uuid.UUID) (*model.RideOffer, error)
 	FindRideOffersByDriverID(driverID uuid.UUID) ([]model.RideOffer, error)
+	FindPendingRideOffers(departingAfter time.Time) ([]model.RideOffer, error)
+	FindStaleRideOffers(departedBefore time.Time) ([]model.RideOffer, error)
+	SearchRideOffers(search RideOfferSearch) ([]model.RideOffer, error)
 	UpdateRideOffer(offer *model.RideOffer) error
 	DeleteRideOffer(id uuid.UUID) error
 
@@ -30,6 +123,8 @@ type RideRepository interface {
 	CreateRideRequest(request *model.RideRequest) error
 	FindRideRequestByID(id uuid.UUID) (*model.RideRequest, error)
 	FindRideRequestsByPassengerID(passengerID uuid.UUID) ([]model.RideRequest, error)
+	FindPendingRideRequests(mode model.RideMode, departingAfter time.Time) ([]model.RideRequest, error)
+	FindStaleRideRequests(departedBefore time.Time) ([]model.RideRequest, error)
 	UpdateRideRequest(request *model.RideRequest) error
 	DeleteRideRequest(id uuid.UUID) error
 
@@ -38,10 +133,57 @@ type RideRepository interface {
 	FindRideMatchByID(id uuid.UUID) (*model.RideMatch, error)
 	FindRideMatchesByOfferID(offerID uuid.UUID) ([]model.RideMatch, error)
 	FindRideMatchesByRequestID(requestID uuid.UUID) ([]model.RideMatch, error)
+	FindRideMatchByOfferAndRequest(offerID, requestID uuid.UUID) (*model.RideMatch, error)
+	FindStaleRideMatches(proposedBefore time.Time) ([]model.RideMatch, error)
 	UpdateRideMatch(match *model.RideMatch) error
 	DeleteRideMatch(id uuid.UUID) error
 
+	// Ride Waypoint operations
+	FindRideWaypointsByOfferID(offerID uuid.UUID) ([]model.RideWaypoint, error)
+	ReplaceRideWaypoints(offerID uuid.UUID, waypoints []model.RideWaypoint) error
+
+	// Ride Stop operations
+	FindRideStopsByOfferID(offerID uuid.UUID) ([]model.RideStop, error)
+	ReplaceRideStops(offerID uuid.UUID, stops []model.RideStop) error
+
+	// Driver availability operations
+	FindDriverAvailability(driverID uuid.UUID) (*model.DriverAvailability, error)
+	SaveDriverAvailability(availability *model.DriverAvailability) error
+	SetDriverCurrentTrip(driverID uuid.UUID, tripID *uuid.UUID) error
+	FindAvailableDrivers(near model.Location, radiusKm float64, seenSince time.Time) ([]model.DriverAvailability, error)
+
+	// Dispatch operations
+	CreateDispatch(dispatch *model.Dispatch) error
+	FindDispatchByID(id uuid.UUID) (*model.Dispatch, error)
+	FindDispatchesByRequestID(requestID uuid.UUID) ([]model.Dispatch, error)
+	FindOpenDispatchesByDriverID(driverID uuid.UUID) ([]model.Dispatch, error)
+	FindOverdueDispatches(expiredBefore time.Time) ([]model.Dispatch, error)
+	UpdateDispatch(dispatch *model.Dispatch) error
+
+	// Trip tracking operations
+	CreateTripLocations(locations []model.TripLocation) error
+	FindLatestTripLocation(offerID uuid.UUID) (*model.TripLocation, error)
+	FindTripLocations(offerID uuid.UUID, recordedAfter time.Time) ([]model.TripLocation, error)
+	DeleteTripLocationsBefore(recordedBefore time.Time) (int64, error)
+
+	// Outbox operations
+	CreateOutboxEvent(event *model.OutboxEvent) error
+	FindUnpublishedOutboxEvents(limit int) ([]model.OutboxEvent, error)
+	UpdateOutboxEvent(event *model.OutboxEvent) error
+	DeleteOutboxEventsPublishedBefore(publishedBefore time.Time) (int64, error)
+
 	// Match finding operations
-	FindPotentialMatches(offerID uuid.UUID) ([]model.RideRequest, error)
-	FindPotentialOffers(requestID uuid.UUID) ([]model.RideOffer, error)
+	FindPotentialMatches(offerID uuid.UUID, candidates MatchCandidates) ([]model.RideRequest, error)
+	FindPotentialOffers(requestID uuid.UUID, candidates MatchCandidates) ([]model.RideOffer, error)
+
+	// Locking reads, which hold the row until the surrounding transaction ends
+	LockRideOfferByID(id uuid.UUID) (*model.RideOffer, error)
+	LockRideRequestByID(id uuid.UUID) (*model.RideRequest, error)
+	LockRideMatchByID(id uuid.UUID) (*model.RideMatch, error)
+	LockDriverAvailability(driverID uuid.UUID) (*model.DriverAvailability, error)
+	LockDispatchByID(id uuid.UUID) (*model.Dispatch, error)
+
+	// WithTx runs fn as a single unit of work. The repository passed to fn
+	// is bound to the transaction, which is rolled back if fn returns an error.
+	WithTx(fn func(RideRepository) error) error
 }
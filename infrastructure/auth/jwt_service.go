@@ -19,6 +19,7 @@ type JWTClaim struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
 	Role   string    `json:"role"`
+	Admin  bool      `json:"admin,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -30,14 +31,37 @@ func NewJWTService(secretKey string, issuer string) *JWTService {
 	}
 }
 
+// StreamTokenTTL is how long a stream token can be used to open a stream
+const StreamTokenTTL = time.Minute
+
+// streamAudience marks stream tokens, which open event and location streams only
+const streamAudience = "stream"
+
+// ErrStreamToken is returned when a stream token is used outside a stream, or another token in its place
+var ErrStreamToken = errors.New("stream tokens only open streams")
+
 // GenerateToken generates a new JWT token
-func (s *JWTService) GenerateToken(userID uuid.UUID, email string, role string) (string, error) {
+func (s *JWTService) GenerateToken(userID uuid.UUID, email string, role string, admin bool) (string, error) {
+	return s.generate(userID, email, role, admin, "", 24*time.Hour) // Token expires in 24 hours
+}
+
+// GenerateStreamToken generates a short-lived token for opening a stream. Browsers cannot set
+// headers on EventSource and WebSocket requests, so it travels in the URL or the WebSocket
+// protocol instead, where it may be logged; it expires after StreamTokenTTL to limit the damage.
+func (s *JWTService) GenerateStreamToken(userID uuid.UUID, email string, role string, admin bool) (string, error) {
+	return s.generate(userID, email, role, admin, streamAudience, StreamTokenTTL)
+}
+
+// generate signs a token for a user that is valid for ttl
+func (s *JWTService) generate(userID uuid.UUID, email string, role string, admin bool, audience string, ttl time.Duration) (string, error) {
 	claims := &JWTClaim{
 		UserID: userID,
 		Email:  email,
 		Role:   role,
+		Admin:  admin,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
+			Audience:  audience,
+			ExpiresAt: time.Now().Add(ttl).Unix(),
 			Issuer:    s.issuer,
 			IssuedAt:  time.Now().Unix(),
 		},
@@ -52,8 +76,32 @@ func (s *JWTService) GenerateToken(userID uuid.UUID, email string, role string)
 	return tokenString, nil
 }
 
-// ValidateToken validates a JWT token
+// ValidateToken validates a JWT token. Stream tokens are refused.
 func (s *JWTService) ValidateToken(tokenString string) (*JWTClaim, error) {
+	claims, err := s.validate(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience == streamAudience {
+		return nil, ErrStreamToken
+	}
+	return claims, nil
+}
+
+// ValidateStreamToken validates a token made by GenerateStreamToken
+func (s *JWTService) ValidateStreamToken(tokenString string) (*JWTClaim, error) {
+	claims, err := s.validate(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience != streamAudience {
+		return nil, ErrStreamToken
+	}
+	return claims, nil
+}
+
+// validate checks a token's signature and expiry
+func (s *JWTService) validate(tokenString string) (*JWTClaim, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&JWTClaim{},
@@ -1,40 +1,61 @@
 package database
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres" // Import postgres dialect
-	"github.com/yourusername/ride-sharing-app/domain/model"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"   // Import sqlite dialect
+)
+
+// Supported values for the database driver setting
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
-// InitDB initializes the database connection
-func InitDB(dbURL string) (*gorm.DB, error) {
-	db, err := gorm.Open("postgres", dbURL)
+// InitDB initializes the database connection for the given driver.
+// The schema is managed by versioned migrations, see Migrator.
+func InitDB(driver string, dbURL string) (*gorm.DB, error) {
+	dialect, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialect, dbURL)
 	if err != nil {
 		return nil, err
 	}
 
+	// Record created and updated times in UTC so that they compare correctly on every backend
+	db.SetNowFuncOverride(func() time.Time {
+		return time.Now().UTC()
+	})
+
 	// Set connection pool parameters
-	db.DB().SetMaxIdleConns(10)
-	db.DB().SetMaxOpenConns(100)
+	if dialect == "sqlite3" {
+		// SQLite allows a single writer, so share one connection to avoid "database is locked" errors
+		db.DB().SetMaxOpenConns(1)
+	} else {
+		db.DB().SetMaxIdleConns(10)
+		db.DB().SetMaxOpenConns(100)
+	}
 
 	// Enable Logger
 	db.LogMode(true)
 
-	// Auto-migrate the schema
-	if err := migrateSchema(db); err != nil {
-		return nil, err
-	}
-
 	return db, nil
 }
 
-// migrateSchema migrates the DB schema
-func migrateSchema(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&model.User{},
-		&model.DriverProfile{},
-		&model.RideOffer{},
-		&model.RideRequest{},
-		&model.RideMatch{},
-	).Error
+// dialectFor maps a configured driver name to the gorm dialect name
+func dialectFor(driver string) (string, error) {
+	switch driver {
+	case DriverPostgres, "":
+		return "postgres", nil
+	case DriverSQLite:
+		return "sqlite3", nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q, expected %q or %q", driver, DriverPostgres, DriverSQLite)
+	}
 }
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+// openSQLite opens an empty SQLite database that is closed when the test ends
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := InitDB(DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	db.LogMode(false)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// migrateTo applies the migrations up to and including the given version
+func migrateTo(t *testing.T, db *gorm.DB, version int64) {
+	t.Helper()
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	var upTo []Migration
+	for _, migration := range migrator.migrations {
+		if migration.Version <= version {
+			upTo = append(upTo, migration)
+		}
+	}
+	migrator.migrations = upTo
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up to %04d: %v", version, err)
+	}
+}
+
+func TestUniqueRideMatchPairKeepsTheFurthestMatch(t *testing.T) {
+	db := openSQLite(t)
+	migrateTo(t, db, 6)
+
+	// Each pair was proposed several times; the first column is the match expected to survive
+	matches := []struct {
+		id, offer, request, status, createdAt string
+	}{
+		{"a-started", "offer-a", "request-a", "in_progress", "2024-01-03 10:00:00"},
+		{"a-rejected", "offer-a", "request-a", "rejected", "2024-01-01 10:00:00"},
+		{"a-confirmed", "offer-a", "request-a", "confirmed", "2024-01-02 10:00:00"},
+		{"b-confirmed", "offer-b", "request-b", "confirmed", "2024-01-02 10:00:00"},
+		{"b-cancelled", "offer-b", "request-b", "cancelled", "2024-01-01 10:00:00"},
+		{"b-matched", "offer-b", "request-b", "matched", "2024-01-01 11:00:00"},
+		{"c-older", "offer-c", "request-c", "matched", "2024-01-01 10:00:00"},
+		{"c-newer", "offer-c", "request-c", "matched", "2024-01-02 10:00:00"},
+		{"d-rejected", "offer-d", "request-d", "rejected", "2024-01-01 10:00:00"},
+	}
+	for _, match := range matches {
+		err := db.Exec(`INSERT INTO ride_matches (id, ride_offer_id, ride_request_id, status, match_score, price, created_at, updated_at)
+			VALUES (?, ?, ?, ?, 1, 10, ?, ?)`,
+			match.id, match.offer, match.request, match.status, match.createdAt, match.createdAt).Error
+		if err != nil {
+			t.Fatalf("insert match %s: %v", match.id, err)
+		}
+	}
+
+	migrateTo(t, db, 7)
+
+	var kept []string
+	if err := db.Table("ride_matches").Order("id").Pluck("id", &kept).Error; err != nil {
+		t.Fatalf("read matches: %v", err)
+	}
+	want := []string{"a-started", "b-confirmed", "c-older", "d-rejected"}
+	if len(kept) != len(want) {
+		t.Fatalf("kept matches %v, want %v", kept, want)
+	}
+	for i := range want {
+		if kept[i] != want[i] {
+			t.Fatalf("kept matches %v, want %v", kept, want)
+		}
+	}
+
+	// The pair is unique from now on
+	err := db.Exec(`INSERT INTO ride_matches (id, ride_offer_id, ride_request_id, status, match_score, price)
+		VALUES ('a-again', 'offer-a', 'request-a', 'matched', 1, 10)`).Error
+	if err == nil {
+		t.Error("a second match for the same pair was saved")
+	}
+}
+
+func TestUserAdminFlagReplacesTheAdminRole(t *testing.T) {
+	db := openSQLite(t)
+	migrateTo(t, db, 15)
+
+	for _, user := range []struct{ id, role string }{{"admin", "admin"}, {"driver", "driver"}} {
+		err := db.Exec(`INSERT INTO users (id, first_name, last_name, email, password, phone, role)
+			VALUES (?, 'Test', 'User', ?, 'hashed', '+94770000000', ?)`, user.id, user.id+"@example.com", user.role).Error
+		if err != nil {
+			t.Fatalf("insert user %s: %v", user.id, err)
+		}
+	}
+
+	migrateTo(t, db, 16)
+
+	var users []struct {
+		ID      string
+		Role    string
+		IsAdmin bool
+	}
+	if err := db.Table("users").Select("id, role, is_admin").Order("id").Scan(&users).Error; err != nil {
+		t.Fatalf("read users: %v", err)
+	}
+	if len(users) != 2 || users[0].Role != "passenger" || !users[0].IsAdmin || users[1].Role != "driver" || users[1].IsAdmin {
+		t.Fatalf("users = %+v, want the admin flagged as a passenger and the driver left alone", users)
+	}
+}
+
+func TestMigratorUpDownAndStatus(t *testing.T) {
+	db := openSQLite(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if err := CheckSchema(db); err == nil {
+		t.Fatal("CheckSchema passed on an empty database")
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(applied) != len(migrator.migrations) {
+		t.Fatalf("Up applied %d migrations, want all %d", len(applied), len(migrator.migrations))
+	}
+	if err := CheckSchema(db); err != nil {
+		t.Fatalf("CheckSchema after Up: %v", err)
+	}
+	if again, err := migrator.Up(); err != nil || len(again) != 0 {
+		t.Fatalf("second Up = %d migrations, %v, want none", len(again), err)
+	}
+
+	// Roll the newest migration back and check the status reports it pending
+	latest := migrator.migrations[len(migrator.migrations)-1]
+	rolledBack, err := migrator.Down()
+	if err != nil || rolledBack == nil || rolledBack.Version != latest.Version {
+		t.Fatalf("Down = %+v, %v, want %04d_%s", rolledBack, err, latest.Version, latest.Name)
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for i, status := range statuses {
+		wantApplied := i < len(statuses)-1
+		if status.Applied != wantApplied || (status.AppliedAt != nil) != wantApplied {
+			t.Errorf("status of %04d_%s = applied %v at %v, want applied %v", status.Version, status.Name, status.Applied, status.AppliedAt, wantApplied)
+		}
+	}
+	if err := CheckSchema(db); err == nil {
+		t.Error("CheckSchema passed with a migration rolled back")
+	}
+
+	// Every down script undoes its up script, down to an empty schema and back
+	for i := len(migrator.migrations) - 2; i >= 0; i-- {
+		rolledBack, err := migrator.Down()
+		if err != nil || rolledBack == nil || rolledBack.Version != migrator.migrations[i].Version {
+			t.Fatalf("Down = %+v, %v, want %04d", rolledBack, err, migrator.migrations[i].Version)
+		}
+	}
+	if rolledBack, err := migrator.Down(); err != nil || rolledBack != nil {
+		t.Fatalf("Down with nothing applied = %+v, %v, want nil", rolledBack, err)
+	}
+	for _, table := range []string{"users", "ride_offers", "ride_requests", "ride_matches"} {
+		if db.HasTable(table) {
+			t.Errorf("table %s is left after rolling every migration back", table)
+		}
+	}
+	if applied, err := migrator.Up(); err != nil || len(applied) != len(migrator.migrations) {
+		t.Fatalf("Up after rolling back = %d migrations, %v, want all", len(applied), err)
+	}
+}
+
+func TestMigratorStopsAtAFailingMigration(t *testing.T) {
+	db := openSQLite(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	latest := migrator.migrations[len(migrator.migrations)-1].Version
+	migrator.migrations = append(migrator.migrations,
+		Migration{Version: latest + 1, Name: "broken", Up: "CREATE TABLE broken (id INTEGER); SELECT * FROM missing", Down: "DROP TABLE broken"},
+		Migration{Version: latest + 2, Name: "after_broken", Up: "CREATE TABLE after_broken (id INTEGER)", Down: "DROP TABLE after_broken"},
+	)
+
+	applied, err := migrator.Up()
+	if err == nil {
+		t.Fatal("Up with a broken migration succeeded")
+	}
+	if len(applied) != len(migrator.migrations)-2 {
+		t.Errorf("Up applied %d migrations, want the %d before the broken one", len(applied), len(migrator.migrations)-2)
+	}
+
+	// The broken migration is rolled back whole and the ones after it are not tried
+	if db.HasTable("broken") || db.HasTable("after_broken") {
+		t.Error("tables of the broken migration or the one after it were created")
+	}
+	pending, err := migrator.Pending()
+	if err != nil || len(pending) != 2 || pending[0].Version != latest+1 {
+		t.Errorf("Pending = %+v, %v, want the broken migration and the one after it", pending, err)
+	}
+}
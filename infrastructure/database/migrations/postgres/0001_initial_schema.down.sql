@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS ride_matches;
+DROP TABLE IF EXISTS ride_requests;
+DROP TABLE IF EXISTS ride_offers;
+DROP TABLE IF EXISTS driver_profiles;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,87 @@
+-- Tables use IF NOT EXISTS so that databases created by the former
+-- AutoMigrate start-up step can adopt versioned migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         UUID PRIMARY KEY,
+    first_name TEXT NOT NULL,
+    last_name  TEXT NOT NULL,
+    email      TEXT NOT NULL UNIQUE,
+    password   TEXT NOT NULL,
+    phone      TEXT NOT NULL,
+    role       VARCHAR(20) NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS driver_profiles (
+    user_id        UUID PRIMARY KEY,
+    license_no     TEXT NOT NULL,
+    car_model      TEXT NOT NULL,
+    car_plate_no   TEXT NOT NULL,
+    num_seats      INTEGER NOT NULL,
+    average_rating REAL DEFAULT 0,
+    created_at     TIMESTAMPTZ,
+    updated_at     TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS ride_offers (
+    id                UUID PRIMARY KEY,
+    driver_id         UUID NOT NULL,
+    start_latitude    DOUBLE PRECISION NOT NULL,
+    start_longitude   DOUBLE PRECISION NOT NULL,
+    start_address     TEXT NOT NULL,
+    end_latitude      DOUBLE PRECISION NOT NULL,
+    end_longitude     DOUBLE PRECISION NOT NULL,
+    end_address       TEXT NOT NULL,
+    departure_time    TIMESTAMPTZ NOT NULL,
+    available_seats   INTEGER NOT NULL,
+    status            VARCHAR(20) DEFAULT 'pending',
+    price_per_seat    DOUBLE PRECISION NOT NULL,
+    allowed_detour_km DOUBLE PRECISION DEFAULT 5,
+    created_at        TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_offers_driver_id ON ride_offers (driver_id);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_status_departure_time ON ride_offers (status, departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_departure_time ON ride_offers (departure_time);
+
+CREATE TABLE IF NOT EXISTS ride_requests (
+    id              UUID PRIMARY KEY,
+    passenger_id    UUID NOT NULL,
+    start_latitude  DOUBLE PRECISION NOT NULL,
+    start_longitude DOUBLE PRECISION NOT NULL,
+    start_address   TEXT NOT NULL,
+    end_latitude    DOUBLE PRECISION NOT NULL,
+    end_longitude   DOUBLE PRECISION NOT NULL,
+    end_address     TEXT NOT NULL,
+    departure_time  TIMESTAMPTZ NOT NULL,
+    num_passengers  INTEGER NOT NULL DEFAULT 1,
+    status          VARCHAR(20) DEFAULT 'pending',
+    max_price       DOUBLE PRECISION NOT NULL,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_requests_passenger_id ON ride_requests (passenger_id);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_status_departure_time ON ride_requests (status, departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_departure_time ON ride_requests (departure_time);
+
+CREATE TABLE IF NOT EXISTS ride_matches (
+    id                    UUID PRIMARY KEY,
+    ride_offer_id         UUID NOT NULL,
+    ride_request_id       UUID NOT NULL,
+    status                VARCHAR(20) DEFAULT 'matched',
+    match_score           DOUBLE PRECISION NOT NULL,
+    price                 DOUBLE PRECISION NOT NULL,
+    driver_accepted_at    TIMESTAMPTZ,
+    passenger_accepted_at TIMESTAMPTZ,
+    rejected_by           UUID,
+    rejection_reason      TEXT,
+    created_at            TIMESTAMPTZ,
+    updated_at            TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_matches_ride_offer_id ON ride_matches (ride_offer_id);
+CREATE INDEX IF NOT EXISTS idx_ride_matches_ride_request_id ON ride_matches (ride_request_id);
+CREATE INDEX IF NOT EXISTS idx_ride_matches_status ON ride_matches (status);
@@ -0,0 +1,16 @@
+-- The PostGIS extension is left installed, other schemas may depend on it.
+
+DROP INDEX IF EXISTS idx_ride_requests_end_geography;
+DROP INDEX IF EXISTS idx_ride_requests_start_geography;
+DROP INDEX IF EXISTS idx_ride_offers_end_geography;
+DROP INDEX IF EXISTS idx_ride_offers_start_geography;
+
+DROP INDEX IF EXISTS idx_ride_requests_end_geohash;
+DROP INDEX IF EXISTS idx_ride_requests_start_geohash;
+DROP INDEX IF EXISTS idx_ride_offers_end_geohash;
+DROP INDEX IF EXISTS idx_ride_offers_start_geohash;
+
+ALTER TABLE ride_requests DROP COLUMN IF EXISTS end_geohash;
+ALTER TABLE ride_requests DROP COLUMN IF EXISTS start_geohash;
+ALTER TABLE ride_offers DROP COLUMN IF EXISTS end_geohash;
+ALTER TABLE ride_offers DROP COLUMN IF EXISTS start_geohash;
@@ -0,0 +1,35 @@
+-- Geohashes of the start and end locations let candidate searches use a
+-- prefix index instead of scanning every ride in the time window.
+
+ALTER TABLE ride_offers ADD COLUMN IF NOT EXISTS start_geohash VARCHAR(12);
+ALTER TABLE ride_offers ADD COLUMN IF NOT EXISTS end_geohash VARCHAR(12);
+ALTER TABLE ride_requests ADD COLUMN IF NOT EXISTS start_geohash VARCHAR(12);
+ALTER TABLE ride_requests ADD COLUMN IF NOT EXISTS end_geohash VARCHAR(12);
+
+CREATE INDEX IF NOT EXISTS idx_ride_offers_start_geohash ON ride_offers (start_geohash varchar_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_end_geohash ON ride_offers (end_geohash varchar_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_start_geohash ON ride_requests (start_geohash varchar_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_end_geohash ON ride_requests (end_geohash varchar_pattern_ops);
+
+-- When the server ships PostGIS, enable it and index the locations as
+-- geographies. The application detects the extension at start-up and
+-- falls back to the geohash columns without it.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'postgis') THEN
+        CREATE EXTENSION IF NOT EXISTS postgis;
+
+        CREATE INDEX IF NOT EXISTS idx_ride_offers_start_geography ON ride_offers
+            USING GIST ((ST_SetSRID(ST_MakePoint(start_longitude, start_latitude), 4326)::geography));
+        CREATE INDEX IF NOT EXISTS idx_ride_offers_end_geography ON ride_offers
+            USING GIST ((ST_SetSRID(ST_MakePoint(end_longitude, end_latitude), 4326)::geography));
+        CREATE INDEX IF NOT EXISTS idx_ride_requests_start_geography ON ride_requests
+            USING GIST ((ST_SetSRID(ST_MakePoint(start_longitude, start_latitude), 4326)::geography));
+        CREATE INDEX IF NOT EXISTS idx_ride_requests_end_geography ON ride_requests
+            USING GIST ((ST_SetSRID(ST_MakePoint(end_longitude, end_latitude), 4326)::geography));
+    END IF;
+EXCEPTION
+    WHEN insufficient_privilege THEN
+        RAISE NOTICE 'PostGIS is available but could not be enabled, using geohash search';
+END
+$$;
@@ -0,0 +1,3 @@
+ALTER TABLE ride_stops DROP COLUMN IF EXISTS after_waypoint;
+
+DROP TABLE IF EXISTS ride_waypoints;
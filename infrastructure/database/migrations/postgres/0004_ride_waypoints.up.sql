@@ -0,0 +1,16 @@
+-- Places a ride offer passes through between its start and end, and the
+-- position of each passenger stop relative to them.
+
+CREATE TABLE IF NOT EXISTS ride_waypoints (
+    id                 UUID PRIMARY KEY,
+    ride_offer_id      UUID NOT NULL,
+    sequence           INTEGER NOT NULL,
+    location_latitude  DOUBLE PRECISION NOT NULL,
+    location_longitude DOUBLE PRECISION NOT NULL,
+    location_address   TEXT NOT NULL,
+    created_at         TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ride_waypoints_ride_offer_id_sequence ON ride_waypoints (ride_offer_id, sequence);
+
+ALTER TABLE ride_stops ADD COLUMN IF NOT EXISTS after_waypoint INTEGER NOT NULL DEFAULT 0;
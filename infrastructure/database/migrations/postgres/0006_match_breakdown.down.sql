@@ -0,0 +1 @@
+ALTER TABLE ride_matches DROP COLUMN IF EXISTS score_breakdown;
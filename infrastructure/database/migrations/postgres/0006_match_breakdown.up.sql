@@ -0,0 +1,3 @@
+-- Component scores and measurements explaining each match score.
+
+ALTER TABLE ride_matches ADD COLUMN IF NOT EXISTS score_breakdown JSONB;
@@ -0,0 +1,26 @@
+-- An offer and request pair is proposed at most once. Duplicates left by
+-- earlier versions are removed first, keeping the match of each pair that
+-- went furthest: a ride that took place, then a confirmed seat, then a live
+-- proposal, then a closed one. Ties keep the oldest match.
+
+DELETE FROM ride_matches
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (
+            PARTITION BY ride_offer_id, ride_request_id
+            ORDER BY CASE status
+                         WHEN 'completed' THEN 0
+                         WHEN 'in_progress' THEN 0
+                         WHEN 'confirmed' THEN 1
+                         WHEN 'matched' THEN 2
+                         WHEN 'pending' THEN 2
+                         ELSE 3
+                     END,
+                     created_at, id
+        ) AS pair_rank
+        FROM ride_matches
+    ) ranked
+    WHERE pair_rank > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ride_matches_ride_offer_id_ride_request_id ON ride_matches (ride_offer_id, ride_request_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS dispatches;
+DROP TABLE IF EXISTS driver_availabilities;
+ALTER TABLE ride_requests DROP COLUMN IF EXISTS mode;
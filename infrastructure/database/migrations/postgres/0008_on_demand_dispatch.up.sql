@@ -0,0 +1,34 @@
+-- On-demand trips: immediate ride requests offered to one nearby online driver
+-- at a time, and the availability and live location of drivers.
+
+ALTER TABLE ride_requests ADD COLUMN IF NOT EXISTS mode VARCHAR(20) NOT NULL DEFAULT 'scheduled';
+
+CREATE TABLE IF NOT EXISTS driver_availabilities (
+    driver_id          UUID PRIMARY KEY,
+    online             BOOLEAN NOT NULL DEFAULT FALSE,
+    location_latitude  DOUBLE PRECISION NOT NULL,
+    location_longitude DOUBLE PRECISION NOT NULL,
+    location_address   TEXT NOT NULL,
+    location_geohash   VARCHAR(12),
+    created_at         TIMESTAMPTZ,
+    updated_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_availabilities_location_geohash ON driver_availabilities (location_geohash);
+
+CREATE TABLE IF NOT EXISTS dispatches (
+    id              UUID PRIMARY KEY,
+    ride_request_id UUID NOT NULL,
+    driver_id       UUID NOT NULL,
+    status          VARCHAR(20) NOT NULL,
+    distance_km     DOUBLE PRECISION NOT NULL,
+    expires_at      TIMESTAMPTZ NOT NULL,
+    responded_at    TIMESTAMPTZ,
+    ride_match_id   UUID,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispatches_ride_request_id ON dispatches (ride_request_id);
+CREATE INDEX IF NOT EXISTS idx_dispatches_driver_id ON dispatches (driver_id);
+CREATE INDEX IF NOT EXISTS idx_dispatches_status_expires_at ON dispatches (status, expires_at);
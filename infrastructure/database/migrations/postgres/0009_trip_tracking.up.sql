@@ -0,0 +1,16 @@
+-- GPS points drivers report while a ride is in progress.
+
+CREATE TABLE IF NOT EXISTS trip_locations (
+    id            UUID PRIMARY KEY,
+    ride_offer_id UUID NOT NULL,
+    latitude      DOUBLE PRECISION NOT NULL,
+    longitude     DOUBLE PRECISION NOT NULL,
+    heading       DOUBLE PRECISION,
+    speed_kmh     DOUBLE PRECISION,
+    accuracy_m    DOUBLE PRECISION,
+    recorded_at   TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_trip_locations_ride_offer_id_recorded_at ON trip_locations (ride_offer_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_trip_locations_recorded_at ON trip_locations (recorded_at);
@@ -0,0 +1,16 @@
+-- Domain events written with the changes they record, waiting to be relayed to sinks.
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id           UUID PRIMARY KEY,
+    type         VARCHAR(50) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload      JSONB NOT NULL,
+    occurred_at  TIMESTAMPTZ NOT NULL,
+    published_at TIMESTAMPTZ,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    created_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (created_at) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at);
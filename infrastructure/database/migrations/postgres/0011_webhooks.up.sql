@@ -0,0 +1,45 @@
+-- Partner webhooks, the domain events to be posted to them and every attempt at posting one.
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id          UUID PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    url         TEXT NOT NULL,
+    secret      TEXT NOT NULL,
+    event_types JSONB NOT NULL,
+    user_ids    JSONB,
+    active      BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL,
+    event_id        UUID NOT NULL,
+    event_type      VARCHAR(50) NOT NULL,
+    body            JSONB NOT NULL,
+    status          VARCHAR(20) NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT,
+    delivered_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ
+);
+
+-- An event is delivered to a webhook once, however often the relay hands it over
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id_event_id ON webhook_deliveries (subscription_id, event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id_created_at ON webhook_deliveries (subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+    id            UUID PRIMARY KEY,
+    delivery_id   UUID NOT NULL,
+    status_code   INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT,
+    error         TEXT,
+    duration_ms   BIGINT NOT NULL,
+    created_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_attempts_delivery_id_created_at ON webhook_attempts (delivery_id, created_at);
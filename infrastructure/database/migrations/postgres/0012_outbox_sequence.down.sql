@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+DROP INDEX IF EXISTS idx_outbox_events_sequence;
+
+-- Dead events go back to waiting, as the relay no longer gives up on them
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS status;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS sequence;
+DROP SEQUENCE IF EXISTS outbox_events_sequence_seq;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (created_at) WHERE published_at IS NULL;
@@ -0,0 +1,23 @@
+-- Outbox events are relayed in the order of a sequence number rather than by
+-- creation time, and the relay gives up on an event after too many failed
+-- attempts by marking it dead. Existing events are numbered in the order they
+-- were created.
+
+CREATE SEQUENCE IF NOT EXISTS outbox_events_sequence_seq;
+
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS sequence BIGINT;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+UPDATE outbox_events SET sequence = numbered.sequence
+FROM (SELECT id, row_number() OVER (ORDER BY created_at, id) AS sequence FROM outbox_events) AS numbered
+WHERE outbox_events.id = numbered.id;
+UPDATE outbox_events SET status = 'published' WHERE published_at IS NOT NULL;
+
+SELECT setval('outbox_events_sequence_seq', COALESCE((SELECT MAX(sequence) FROM outbox_events), 0) + 1, false);
+ALTER TABLE outbox_events ALTER COLUMN sequence SET DEFAULT nextval('outbox_events_sequence_seq');
+ALTER TABLE outbox_events ALTER COLUMN sequence SET NOT NULL;
+ALTER SEQUENCE outbox_events_sequence_seq OWNED BY outbox_events.sequence;
+
+DROP INDEX IF EXISTS idx_outbox_events_unpublished;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_events_sequence ON outbox_events (sequence);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (sequence) WHERE status = 'pending';
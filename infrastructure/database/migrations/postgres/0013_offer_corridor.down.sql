@@ -0,0 +1,4 @@
+ALTER TABLE ride_offers DROP COLUMN IF EXISTS corridor_max_longitude;
+ALTER TABLE ride_offers DROP COLUMN IF EXISTS corridor_min_longitude;
+ALTER TABLE ride_offers DROP COLUMN IF EXISTS corridor_max_latitude;
+ALTER TABLE ride_offers DROP COLUMN IF EXISTS corridor_min_latitude;
@@ -0,0 +1,8 @@
+-- The box around the corridor in which an offer's passengers may board and
+-- leave lets the search for offers matching a request skip the ones passing
+-- nowhere near it. Offers saved before have no box and are checked exactly.
+
+ALTER TABLE ride_offers ADD COLUMN IF NOT EXISTS corridor_min_latitude DOUBLE PRECISION;
+ALTER TABLE ride_offers ADD COLUMN IF NOT EXISTS corridor_max_latitude DOUBLE PRECISION;
+ALTER TABLE ride_offers ADD COLUMN IF NOT EXISTS corridor_min_longitude DOUBLE PRECISION;
+ALTER TABLE ride_offers ADD COLUMN IF NOT EXISTS corridor_max_longitude DOUBLE PRECISION;
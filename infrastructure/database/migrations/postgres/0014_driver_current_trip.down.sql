@@ -0,0 +1 @@
+ALTER TABLE driver_availabilities DROP COLUMN IF EXISTS current_trip_id;
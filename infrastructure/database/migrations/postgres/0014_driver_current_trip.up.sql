@@ -0,0 +1,4 @@
+-- The on-demand trip a driver accepted and has not finished yet. Drivers on
+-- a trip are not offered other trips until it is completed or cancelled.
+
+ALTER TABLE driver_availabilities ADD COLUMN IF NOT EXISTS current_trip_id UUID;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_ride_offers_corridor;
@@ -0,0 +1,4 @@
+-- Matching a request looks for offers whose corridor box contains its pickup
+-- and drop-off, so the box is indexed like the start and end geohashes.
+
+CREATE INDEX IF NOT EXISTS idx_ride_offers_corridor ON ride_offers (corridor_min_latitude, corridor_max_latitude, corridor_min_longitude, corridor_max_longitude);
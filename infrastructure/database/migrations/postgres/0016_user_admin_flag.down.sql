@@ -0,0 +1,3 @@
+UPDATE users SET role = 'admin' WHERE is_admin;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
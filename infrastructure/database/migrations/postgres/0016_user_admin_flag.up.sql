@@ -0,0 +1,7 @@
+-- Admin is a flag next to the user's role rather than a role of its own, so
+-- drivers and passengers keep their rides when they are granted admin.
+-- Admins granted before this kept no other role and become passengers.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET is_admin = TRUE, role = 'passenger' WHERE role = 'admin';
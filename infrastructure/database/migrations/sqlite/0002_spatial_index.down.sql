@@ -0,0 +1,57 @@
+-- SQLite before 3.35 cannot drop columns, so the ride tables are rebuilt
+-- without the geohash columns and their indexes are recreated.
+
+CREATE TABLE ride_offers_rebuild (
+    id                TEXT PRIMARY KEY,
+    driver_id         TEXT NOT NULL,
+    start_latitude    REAL NOT NULL,
+    start_longitude   REAL NOT NULL,
+    start_address     TEXT NOT NULL,
+    end_latitude      REAL NOT NULL,
+    end_longitude     REAL NOT NULL,
+    end_address       TEXT NOT NULL,
+    departure_time    DATETIME NOT NULL,
+    available_seats   INTEGER NOT NULL,
+    status            VARCHAR(20) DEFAULT 'pending',
+    price_per_seat    REAL NOT NULL,
+    allowed_detour_km REAL DEFAULT 5,
+    created_at        DATETIME,
+    updated_at        DATETIME
+);
+
+INSERT INTO ride_offers_rebuild (id, driver_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, available_seats, status, price_per_seat, allowed_detour_km, created_at, updated_at)
+SELECT id, driver_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, available_seats, status, price_per_seat, allowed_detour_km, created_at, updated_at FROM ride_offers;
+
+DROP TABLE ride_offers;
+ALTER TABLE ride_offers_rebuild RENAME TO ride_offers;
+
+CREATE INDEX IF NOT EXISTS idx_ride_offers_driver_id ON ride_offers (driver_id);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_status_departure_time ON ride_offers (status, departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_departure_time ON ride_offers (departure_time);
+
+CREATE TABLE ride_requests_rebuild (
+    id              TEXT PRIMARY KEY,
+    passenger_id    TEXT NOT NULL,
+    start_latitude  REAL NOT NULL,
+    start_longitude REAL NOT NULL,
+    start_address   TEXT NOT NULL,
+    end_latitude    REAL NOT NULL,
+    end_longitude   REAL NOT NULL,
+    end_address     TEXT NOT NULL,
+    departure_time  DATETIME NOT NULL,
+    num_passengers  INTEGER NOT NULL DEFAULT 1,
+    status          VARCHAR(20) DEFAULT 'pending',
+    max_price       REAL NOT NULL,
+    created_at      DATETIME,
+    updated_at      DATETIME
+);
+
+INSERT INTO ride_requests_rebuild (id, passenger_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, num_passengers, status, max_price, created_at, updated_at)
+SELECT id, passenger_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, num_passengers, status, max_price, created_at, updated_at FROM ride_requests;
+
+DROP TABLE ride_requests;
+ALTER TABLE ride_requests_rebuild RENAME TO ride_requests;
+
+CREATE INDEX IF NOT EXISTS idx_ride_requests_passenger_id ON ride_requests (passenger_id);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_status_departure_time ON ride_requests (status, departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_departure_time ON ride_requests (departure_time);
@@ -0,0 +1,12 @@
+-- Geohashes of the start and end locations let candidate searches use a
+-- prefix index instead of scanning every ride in the time window.
+
+ALTER TABLE ride_offers ADD COLUMN start_geohash VARCHAR(12);
+ALTER TABLE ride_offers ADD COLUMN end_geohash VARCHAR(12);
+ALTER TABLE ride_requests ADD COLUMN start_geohash VARCHAR(12);
+ALTER TABLE ride_requests ADD COLUMN end_geohash VARCHAR(12);
+
+CREATE INDEX IF NOT EXISTS idx_ride_offers_start_geohash ON ride_offers (start_geohash);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_end_geohash ON ride_offers (end_geohash);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_start_geohash ON ride_requests (start_geohash);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_end_geohash ON ride_requests (end_geohash);
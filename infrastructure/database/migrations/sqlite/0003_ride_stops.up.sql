@@ -0,0 +1,15 @@
+-- Ordered pickups and drop-offs of the passengers pooled into a ride offer.
+
+CREATE TABLE IF NOT EXISTS ride_stops (
+    id                 TEXT PRIMARY KEY,
+    ride_offer_id      TEXT NOT NULL,
+    ride_request_id    TEXT NOT NULL,
+    sequence           INTEGER NOT NULL,
+    type               VARCHAR(10) NOT NULL,
+    location_latitude  REAL NOT NULL,
+    location_longitude REAL NOT NULL,
+    location_address   TEXT NOT NULL,
+    created_at         DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ride_stops_ride_offer_id_sequence ON ride_stops (ride_offer_id, sequence);
@@ -0,0 +1,24 @@
+-- SQLite before 3.35 cannot drop columns, so ride_stops is rebuilt
+-- without after_waypoint and its index is recreated.
+
+CREATE TABLE ride_stops_rebuild (
+    id                 TEXT PRIMARY KEY,
+    ride_offer_id      TEXT NOT NULL,
+    ride_request_id    TEXT NOT NULL,
+    sequence           INTEGER NOT NULL,
+    type               VARCHAR(10) NOT NULL,
+    location_latitude  REAL NOT NULL,
+    location_longitude REAL NOT NULL,
+    location_address   TEXT NOT NULL,
+    created_at         DATETIME
+);
+
+INSERT INTO ride_stops_rebuild (id, ride_offer_id, ride_request_id, sequence, type, location_latitude, location_longitude, location_address, created_at)
+SELECT id, ride_offer_id, ride_request_id, sequence, type, location_latitude, location_longitude, location_address, created_at FROM ride_stops;
+
+DROP TABLE ride_stops;
+ALTER TABLE ride_stops_rebuild RENAME TO ride_stops;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ride_stops_ride_offer_id_sequence ON ride_stops (ride_offer_id, sequence);
+
+DROP TABLE IF EXISTS ride_waypoints;
@@ -0,0 +1,9 @@
+-- What passengers ask of the rides they are matched with.
+
+CREATE TABLE IF NOT EXISTS passenger_preferences (
+    user_id           TEXT PRIMARY KEY,
+    min_driver_rating REAL NOT NULL DEFAULT 0,
+    priority          VARCHAR(20),
+    created_at        DATETIME,
+    updated_at        DATETIME
+);
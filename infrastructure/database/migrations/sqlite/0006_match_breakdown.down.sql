@@ -0,0 +1,27 @@
+-- SQLite before 3.35 cannot drop columns, so ride_matches is rebuilt
+-- without score_breakdown and its indexes are recreated.
+
+CREATE TABLE ride_matches_rebuild (
+    id                    TEXT PRIMARY KEY,
+    ride_offer_id         TEXT NOT NULL,
+    ride_request_id       TEXT NOT NULL,
+    status                VARCHAR(20) DEFAULT 'matched',
+    match_score           REAL NOT NULL,
+    price                 REAL NOT NULL,
+    driver_accepted_at    DATETIME,
+    passenger_accepted_at DATETIME,
+    rejected_by           TEXT,
+    rejection_reason      TEXT,
+    created_at            DATETIME,
+    updated_at            DATETIME
+);
+
+INSERT INTO ride_matches_rebuild (id, ride_offer_id, ride_request_id, status, match_score, price, driver_accepted_at, passenger_accepted_at, rejected_by, rejection_reason, created_at, updated_at)
+SELECT id, ride_offer_id, ride_request_id, status, match_score, price, driver_accepted_at, passenger_accepted_at, rejected_by, rejection_reason, created_at, updated_at FROM ride_matches;
+
+DROP TABLE ride_matches;
+ALTER TABLE ride_matches_rebuild RENAME TO ride_matches;
+
+CREATE INDEX IF NOT EXISTS idx_ride_matches_ride_offer_id ON ride_matches (ride_offer_id);
+CREATE INDEX IF NOT EXISTS idx_ride_matches_ride_request_id ON ride_matches (ride_request_id);
+CREATE INDEX IF NOT EXISTS idx_ride_matches_status ON ride_matches (status);
@@ -0,0 +1,3 @@
+-- Component scores and measurements explaining each match score, as JSON.
+
+ALTER TABLE ride_matches ADD COLUMN score_breakdown TEXT;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_ride_matches_ride_offer_id_ride_request_id;
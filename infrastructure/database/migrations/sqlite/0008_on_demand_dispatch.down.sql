@@ -0,0 +1,36 @@
+DROP TABLE IF EXISTS dispatches;
+DROP TABLE IF EXISTS driver_availabilities;
+
+-- SQLite before 3.35 cannot drop columns, so ride_requests is rebuilt
+-- without mode and its indexes are recreated.
+
+CREATE TABLE ride_requests_rebuild (
+    id              TEXT PRIMARY KEY,
+    passenger_id    TEXT NOT NULL,
+    start_latitude  REAL NOT NULL,
+    start_longitude REAL NOT NULL,
+    start_address   TEXT NOT NULL,
+    end_latitude    REAL NOT NULL,
+    end_longitude   REAL NOT NULL,
+    end_address     TEXT NOT NULL,
+    departure_time  DATETIME NOT NULL,
+    num_passengers  INTEGER NOT NULL DEFAULT 1,
+    status          VARCHAR(20) DEFAULT 'pending',
+    max_price       REAL NOT NULL,
+    created_at      DATETIME,
+    updated_at      DATETIME,
+    start_geohash   VARCHAR(12),
+    end_geohash     VARCHAR(12)
+);
+
+INSERT INTO ride_requests_rebuild (id, passenger_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, num_passengers, status, max_price, created_at, updated_at, start_geohash, end_geohash)
+SELECT id, passenger_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, num_passengers, status, max_price, created_at, updated_at, start_geohash, end_geohash FROM ride_requests;
+
+DROP TABLE ride_requests;
+ALTER TABLE ride_requests_rebuild RENAME TO ride_requests;
+
+CREATE INDEX IF NOT EXISTS idx_ride_requests_passenger_id ON ride_requests (passenger_id);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_status_departure_time ON ride_requests (status, departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_departure_time ON ride_requests (departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_start_geohash ON ride_requests (start_geohash);
+CREATE INDEX IF NOT EXISTS idx_ride_requests_end_geohash ON ride_requests (end_geohash);
@@ -0,0 +1,16 @@
+-- GPS points drivers report while a ride is in progress.
+
+CREATE TABLE IF NOT EXISTS trip_locations (
+    id            TEXT PRIMARY KEY,
+    ride_offer_id TEXT NOT NULL,
+    latitude      REAL NOT NULL,
+    longitude     REAL NOT NULL,
+    heading       REAL,
+    speed_kmh     REAL,
+    accuracy_m    REAL,
+    recorded_at   DATETIME NOT NULL,
+    created_at    DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_trip_locations_ride_offer_id_recorded_at ON trip_locations (ride_offer_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_trip_locations_recorded_at ON trip_locations (recorded_at);
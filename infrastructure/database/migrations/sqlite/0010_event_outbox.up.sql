@@ -0,0 +1,16 @@
+-- Domain events written with the changes they record, waiting to be relayed to sinks.
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id           TEXT PRIMARY KEY,
+    type         VARCHAR(50) NOT NULL,
+    aggregate_id TEXT NOT NULL,
+    payload      TEXT NOT NULL,
+    occurred_at  DATETIME NOT NULL,
+    published_at DATETIME,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    created_at   DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (created_at) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at);
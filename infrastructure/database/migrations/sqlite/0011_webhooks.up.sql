@@ -0,0 +1,45 @@
+-- Partner webhooks, the domain events to be posted to them and every attempt at posting one.
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id          TEXT PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    url         TEXT NOT NULL,
+    secret      TEXT NOT NULL,
+    event_types TEXT NOT NULL,
+    user_ids    TEXT,
+    active      BOOLEAN NOT NULL DEFAULT 1,
+    created_at  DATETIME,
+    updated_at  DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              TEXT PRIMARY KEY,
+    subscription_id TEXT NOT NULL,
+    event_id        TEXT NOT NULL,
+    event_type      VARCHAR(50) NOT NULL,
+    body            TEXT NOT NULL,
+    status          VARCHAR(20) NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error      TEXT,
+    delivered_at    DATETIME,
+    created_at      DATETIME,
+    updated_at      DATETIME
+);
+
+-- An event is delivered to a webhook once, however often the relay hands it over
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id_event_id ON webhook_deliveries (subscription_id, event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id_created_at ON webhook_deliveries (subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+    id            TEXT PRIMARY KEY,
+    delivery_id   TEXT NOT NULL,
+    status_code   INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT,
+    error         TEXT,
+    duration_ms   INTEGER NOT NULL,
+    created_at    DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_attempts_delivery_id_created_at ON webhook_attempts (delivery_id, created_at);
@@ -0,0 +1,26 @@
+DROP TRIGGER IF EXISTS outbox_events_sequence;
+
+-- SQLite before 3.35 cannot drop columns, so outbox_events is rebuilt
+-- without sequence and status and its indexes are recreated. Dead events go
+-- back to waiting, as the relay no longer gives up on them.
+
+CREATE TABLE outbox_events_rebuild (
+    id           TEXT PRIMARY KEY,
+    type         VARCHAR(50) NOT NULL,
+    aggregate_id TEXT NOT NULL,
+    payload      TEXT NOT NULL,
+    occurred_at  DATETIME NOT NULL,
+    published_at DATETIME,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    created_at   DATETIME
+);
+
+INSERT INTO outbox_events_rebuild (id, type, aggregate_id, payload, occurred_at, published_at, attempts, last_error, created_at)
+SELECT id, type, aggregate_id, payload, occurred_at, published_at, attempts, last_error, created_at FROM outbox_events ORDER BY sequence;
+
+DROP TABLE outbox_events;
+ALTER TABLE outbox_events_rebuild RENAME TO outbox_events;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (created_at) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at);
@@ -0,0 +1,23 @@
+-- Outbox events are relayed in the order of a sequence number rather than by
+-- creation time, and the relay gives up on an event after too many failed
+-- attempts by marking it dead.
+--
+-- SQLite has no sequences, so the sequence is the row's rowid: a new row gets
+-- a rowid above every row in the table, which keeps the waiting events in the
+-- order they were written. Existing rows already have their rowids in that order.
+
+ALTER TABLE outbox_events ADD COLUMN sequence INTEGER;
+ALTER TABLE outbox_events ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+UPDATE outbox_events SET sequence = rowid;
+UPDATE outbox_events SET status = 'published' WHERE published_at IS NOT NULL;
+
+CREATE TRIGGER IF NOT EXISTS outbox_events_sequence AFTER INSERT ON outbox_events
+WHEN NEW.sequence IS NULL
+BEGIN
+    UPDATE outbox_events SET sequence = NEW.rowid WHERE rowid = NEW.rowid;
+END;
+
+DROP INDEX IF EXISTS idx_outbox_events_unpublished;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_events_sequence ON outbox_events (sequence);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (sequence) WHERE status = 'pending';
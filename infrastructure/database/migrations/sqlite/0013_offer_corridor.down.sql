@@ -0,0 +1,34 @@
+-- SQLite before 3.35 cannot drop columns, so ride_offers is rebuilt
+-- without the corridor box and its indexes are recreated.
+
+CREATE TABLE ride_offers_rebuild (
+    id                TEXT PRIMARY KEY,
+    driver_id         TEXT NOT NULL,
+    start_latitude    REAL NOT NULL,
+    start_longitude   REAL NOT NULL,
+    start_address     TEXT NOT NULL,
+    end_latitude      REAL NOT NULL,
+    end_longitude     REAL NOT NULL,
+    end_address       TEXT NOT NULL,
+    departure_time    DATETIME NOT NULL,
+    available_seats   INTEGER NOT NULL,
+    status            VARCHAR(20) DEFAULT 'pending',
+    price_per_seat    REAL NOT NULL,
+    allowed_detour_km REAL DEFAULT 5,
+    created_at        DATETIME,
+    updated_at        DATETIME,
+    start_geohash     VARCHAR(12),
+    end_geohash       VARCHAR(12)
+);
+
+INSERT INTO ride_offers_rebuild (id, driver_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, available_seats, status, price_per_seat, allowed_detour_km, created_at, updated_at, start_geohash, end_geohash)
+SELECT id, driver_id, start_latitude, start_longitude, start_address, end_latitude, end_longitude, end_address, departure_time, available_seats, status, price_per_seat, allowed_detour_km, created_at, updated_at, start_geohash, end_geohash FROM ride_offers;
+
+DROP TABLE ride_offers;
+ALTER TABLE ride_offers_rebuild RENAME TO ride_offers;
+
+CREATE INDEX IF NOT EXISTS idx_ride_offers_driver_id ON ride_offers (driver_id);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_status_departure_time ON ride_offers (status, departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_departure_time ON ride_offers (departure_time);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_start_geohash ON ride_offers (start_geohash);
+CREATE INDEX IF NOT EXISTS idx_ride_offers_end_geohash ON ride_offers (end_geohash);
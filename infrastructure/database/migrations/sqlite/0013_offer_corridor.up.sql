@@ -0,0 +1,8 @@
+-- The box around the corridor in which an offer's passengers may board and
+-- leave lets the search for offers matching a request skip the ones passing
+-- nowhere near it. Offers saved before have no box and are checked exactly.
+
+ALTER TABLE ride_offers ADD COLUMN corridor_min_latitude REAL;
+ALTER TABLE ride_offers ADD COLUMN corridor_max_latitude REAL;
+ALTER TABLE ride_offers ADD COLUMN corridor_min_longitude REAL;
+ALTER TABLE ride_offers ADD COLUMN corridor_max_longitude REAL;
@@ -0,0 +1,21 @@
+-- SQLite before 3.35 cannot drop columns, so driver_availabilities is rebuilt
+-- without the current trip and its index is recreated.
+
+CREATE TABLE driver_availabilities_rebuild (
+    driver_id          TEXT PRIMARY KEY,
+    online             BOOLEAN NOT NULL DEFAULT 0,
+    location_latitude  REAL NOT NULL,
+    location_longitude REAL NOT NULL,
+    location_address   TEXT NOT NULL,
+    location_geohash   VARCHAR(12),
+    created_at         DATETIME,
+    updated_at         DATETIME
+);
+
+INSERT INTO driver_availabilities_rebuild (driver_id, online, location_latitude, location_longitude, location_address, location_geohash, created_at, updated_at)
+SELECT driver_id, online, location_latitude, location_longitude, location_address, location_geohash, created_at, updated_at FROM driver_availabilities;
+
+DROP TABLE driver_availabilities;
+ALTER TABLE driver_availabilities_rebuild RENAME TO driver_availabilities;
+
+CREATE INDEX IF NOT EXISTS idx_driver_availabilities_location_geohash ON driver_availabilities (location_geohash);
@@ -0,0 +1,22 @@
+-- SQLite before 3.35 cannot drop columns, so users is rebuilt without the
+-- admin flag. Admins get the admin role back.
+
+UPDATE users SET role = 'admin' WHERE is_admin;
+
+CREATE TABLE users_rebuild (
+    id         TEXT PRIMARY KEY,
+    first_name TEXT NOT NULL,
+    last_name  TEXT NOT NULL,
+    email      TEXT NOT NULL UNIQUE,
+    password   TEXT NOT NULL,
+    phone      TEXT NOT NULL,
+    role       VARCHAR(20) NOT NULL,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+
+INSERT INTO users_rebuild (id, first_name, last_name, email, password, phone, role, created_at, updated_at)
+SELECT id, first_name, last_name, email, password, phone, role, created_at, updated_at FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_rebuild RENAME TO users;
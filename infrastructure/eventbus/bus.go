@@ -0,0 +1,58 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Handler consumes a domain event in the process that relays it
+type Handler func(ctx context.Context, message Message) error
+
+// Bus is a Sink that hands domain events to handlers in the same process
+type Bus struct {
+	mu sync.RWMutex
+	// handlers is keyed by event type; the handlers under "" take every event
+	handlers map[string][]Handler
+}
+
+// NewBus creates a Bus without handlers
+func NewBus() *Bus {
+	return &Bus{
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// Subscribe registers a handler for the events of the given types, or for every event when no type is given
+func (b *Bus) Subscribe(handler Handler, types ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(types) == 0 {
+		types = []string{""}
+	}
+	for _, eventType := range types {
+		b.handlers[eventType] = append(b.handlers[eventType], handler)
+	}
+}
+
+// Name identifies the bus in logs
+func (b *Bus) Name() string {
+	return "in-process"
+}
+
+// Deliver runs the handlers subscribed to the message's type one after the other.
+// Every handler runs even if an earlier one fails; the failures are returned together.
+func (b *Bus) Deliver(ctx context.Context, message Message) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler{}, b.handlers[""]...), b.handlers[message.Type]...)
+	b.mu.RUnlock()
+
+	var failures []error
+	for _, handler := range handlers {
+		if err := handler(ctx, message); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return errors.Join(failures...)
+}
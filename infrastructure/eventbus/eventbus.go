@@ -0,0 +1,30 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is a domain event as sinks receive it
+type Message struct {
+	// ID identifies the event; a message delivered twice has the same ID both times
+	ID   uuid.UUID `json:"id"`
+	Type string    `json:"type"`
+	// AggregateID is the ride offer, request or match that changed
+	AggregateID uuid.UUID `json:"aggregate_id"`
+	// Payload is the state of the aggregate after the change
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// Sink receives the domain events relayed from the outbox, in the order they occurred.
+// Delivery is at least once: a message is delivered again when it or another sink failed,
+// so sinks and their consumers should skip message IDs they have already seen.
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+	Deliver(ctx context.Context, message Message) error
+}
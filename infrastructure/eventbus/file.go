@@ -0,0 +1,68 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileSink is a Sink that appends domain events to a file as JSON lines, each published under a
+// NATS-style subject made of a prefix and the event type, such as "rides.ride_match.confirmed".
+// It stands in for a message broker when running locally: follow the file with tail -f, or replay
+// it into a broker.
+type FileSink struct {
+	prefix string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// fileRecord is one line of a FileSink's file
+type fileRecord struct {
+	Subject string `json:"subject"`
+	Message
+}
+
+// NewFileSink opens the file at path for appending, creating it if needed
+func NewFileSink(path string, subjectPrefix string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{prefix: subjectPrefix, file: file}, nil
+}
+
+// Name identifies the sink in logs
+func (s *FileSink) Name() string {
+	return "file " + s.file.Name()
+}
+
+// Subject returns the subject events of a type are published under
+func (s *FileSink) Subject(eventType string) string {
+	if s.prefix == "" {
+		return eventType
+	}
+	return s.prefix + "." + eventType
+}
+
+// Deliver appends the message to the file and flushes it to disk
+func (s *FileSink) Deliver(ctx context.Context, message Message) error {
+	line, err := json.Marshal(fileRecord{Subject: s.Subject(message.Type), Message: message})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type identifies what an event is about
+type Type string
+
+const (
+	// MatchProposed tells a driver and a passenger they were matched and can accept the match
+	MatchProposed Type = "match_proposed"
+	// MatchConfirmed tells a driver and a passenger both accepted their match
+	MatchConfirmed Type = "match_confirmed"
+	// MatchRejected tells a driver and a passenger one of them turned their match down
+	MatchRejected Type = "match_rejected"
+	// RideStarted tells a driver and their passengers the ride has started
+	RideStarted Type = "ride_started"
+	// RideCompleted tells a driver and their passengers the ride has ended
+	RideCompleted Type = "ride_completed"
+	// RideCancelled tells a driver or passenger a ride they were part of was cancelled
+	RideCancelled Type = "ride_cancelled"
+	// DriverLocation tells passengers where the car of their ride is
+	DriverLocation Type = "driver_location"
+)
+
+// ErrBrokerClosed is returned when subscribing after the broker has shut down
+var ErrBrokerClosed = errors.New("event broker is shut down")
+
+// Event is something that happened to one of a user's rides
+type Event struct {
+	// ID orders the events of a user; clients resume a stream after the last ID they saw
+	ID     uint64    `json:"id"`
+	Type   Type      `json:"type"`
+	UserID uuid.UUID `json:"-"`
+	// RideID is the ride offer, request or match the event is about
+	RideID    uuid.UUID   `json:"ride_id"`
+	Data      interface{} `json:"data,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Publisher delivers events to the users they are addressed to
+type Publisher interface {
+	Publish(events ...Event)
+}
+
+// Config sizes a broker's buffers
+type Config struct {
+	// HistorySize is how many recent events are kept per user for streams that resume
+	HistorySize int
+	// HistoryTTL is how long events are kept for streams that resume
+	HistoryTTL time.Duration
+	// SubscriberBuffer is how many events a stream can fall behind before it is disconnected
+	SubscriberBuffer int
+}
+
+// Broker is an in-process Publisher that fans events out to every open stream of their user.
+// It keeps each user's recent events so that a stream that reconnects can resume where it left off.
+type Broker struct {
+	config Config
+
+	mu          sync.Mutex
+	nextID      uint64
+	history     map[uuid.UUID][]Event
+	subscribers map[uuid.UUID]map[*Subscription]struct{}
+	lastPrune   time.Time
+	closed      bool
+}
+
+// Subscription is one open stream of a user's events
+type Subscription struct {
+	broker *Broker
+	userID uuid.UUID
+	events chan Event
+	// Replay holds the events published since the ID the stream resumed from, oldest first
+	Replay []Event
+}
+
+// NewBroker creates a Broker.
+// Event IDs start from the current time in microseconds so that they keep increasing across
+// restarts while staying small enough for JavaScript numbers.
+func NewBroker(config Config) *Broker {
+	return &Broker{
+		config:      config,
+		nextID:      uint64(time.Now().UnixMicro()),
+		history:     make(map[uuid.UUID][]Event),
+		subscribers: make(map[uuid.UUID]map[*Subscription]struct{}),
+	}
+}
+
+// Publish numbers the events, records them in their users' history and sends them to the users' streams.
+// Streams that fell too far behind are disconnected rather than slowing down the publisher.
+func (b *Broker) Publish(events ...Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.prune(now)
+	for _, event := range events {
+		b.nextID++
+		event.ID = b.nextID
+		event.CreatedAt = now
+
+		if b.config.HistorySize > 0 {
+			history := append(b.history[event.UserID], event)
+			if len(history) > b.config.HistorySize {
+				history = append([]Event{}, history[len(history)-b.config.HistorySize:]...)
+			}
+			b.history[event.UserID] = history
+		}
+
+		for subscription := range b.subscribers[event.UserID] {
+			select {
+			case subscription.events <- event:
+			default:
+				b.unsubscribe(subscription)
+			}
+		}
+	}
+}
+
+// Subscribe opens a stream of a user's events. With a lastEventID other than 0 the stream resumes
+// after that event, replaying the events still in the user's history.
+func (b *Broker) Subscribe(userID uuid.UUID, lastEventID uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	subscription := &Subscription{
+		broker: b,
+		userID: userID,
+		events: make(chan Event, b.config.SubscriberBuffer),
+	}
+	if lastEventID != 0 {
+		for _, event := range b.history[userID] {
+			if event.ID > lastEventID {
+				subscription.Replay = append(subscription.Replay, event)
+			}
+		}
+	}
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][subscription] = struct{}{}
+	return subscription, nil
+}
+
+// Close disconnects every stream and refuses new ones, so that open streams end at shutdown
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subscriptions := range b.subscribers {
+		for subscription := range subscriptions {
+			b.unsubscribe(subscription)
+		}
+	}
+}
+
+// unsubscribe removes a subscription and closes its channel. The caller must hold the lock.
+func (b *Broker) unsubscribe(subscription *Subscription) {
+	subscriptions, ok := b.subscribers[subscription.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subscriptions[subscription]; !ok {
+		return
+	}
+	delete(subscriptions, subscription)
+	if len(subscriptions) == 0 {
+		delete(b.subscribers, subscription.userID)
+	}
+	close(subscription.events)
+}
+
+// prune drops events older than the history TTL, at most once a minute. The caller must hold the lock.
+func (b *Broker) prune(now time.Time) {
+	if b.config.HistoryTTL <= 0 || now.Sub(b.lastPrune) < time.Minute {
+		return
+	}
+	cutoff := now.Add(-b.config.HistoryTTL)
+	for userID, history := range b.history {
+		expired := 0
+		for expired < len(history) && history[expired].CreatedAt.Before(cutoff) {
+			expired++
+		}
+		if expired == len(history) {
+			delete(b.history, userID)
+		} else if expired > 0 {
+			b.history[userID] = append([]Event{}, history[expired:]...)
+		}
+	}
+	b.lastPrune = now
+}
+
+// Events returns the channel the stream's events arrive on. It is closed when the stream is
+// disconnected: by Close, because it fell behind, or because the broker shut down.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the stream. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.unsubscribe(s)
+}
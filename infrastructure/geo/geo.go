@@ -0,0 +1,235 @@
+package geo
+
+import (
+	"math"
+	"strings"
+)
+
+// EarthRadiusKm is the mean radius of the Earth in kilometers
+const EarthRadiusKm = 6371.0
+
+// kmPerDegree is the length of one degree of latitude in kilometers, on the same sphere as Distance
+const kmPerDegree = EarthRadiusKm * math.Pi / 180
+
+// GeohashPrecision is the number of characters stored for indexed locations (about 5 m cells)
+const GeohashPrecision = 9
+
+// geohashAlphabet is the base32 alphabet used by geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Distance calculates the great-circle distance in kilometers between two points using the Haversine formula
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	// Convert degrees to radians
+	lat1Rad := lat1 * math.Pi / 180
+	lng1Rad := lng1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	lng2Rad := lng2 * math.Pi / 180
+
+	// Haversine formula
+	dlat := lat2Rad - lat1Rad
+	dlng := lng2Rad - lng1Rad
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dlng/2)*math.Sin(dlng/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return EarthRadiusKm * c
+}
+
+// EncodeGeohash encodes a point as a geohash with the given number of characters
+func EncodeGeohash(lat, lng float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+
+	var hash strings.Builder
+	bits, ch := 0, 0
+	evenBit := true
+	for hash.Len() < precision {
+		if evenBit {
+			mid := (minLng + maxLng) / 2
+			if lng >= mid {
+				ch = ch<<1 | 1
+				minLng = mid
+			} else {
+				ch <<= 1
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		evenBit = !evenBit
+
+		if bits++; bits == 5 {
+			hash.WriteByte(geohashAlphabet[ch])
+			bits, ch = 0, 0
+		}
+	}
+
+	return hash.String()
+}
+
+// Point is a position in degrees
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// PathLength returns the great-circle length in kilometers of a path visiting the points in order
+func PathLength(points ...Point) float64 {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += Distance(points[i-1].Latitude, points[i-1].Longitude, points[i].Latitude, points[i].Longitude)
+	}
+	return length
+}
+
+// InsertionDetour returns how much longer, in kilometers, the path through route gets when
+// pickup and then dropoff are inserted where they add the least distance
+func InsertionDetour(route []Point, pickup, dropoff Point) float64 {
+	if len(route) < 2 {
+		return PathLength(pickup, dropoff)
+	}
+
+	// added returns the extra distance of visiting p between the ends of segment i
+	added := func(i int, p Point) float64 {
+		return PathLength(route[i], p, route[i+1]) - PathLength(route[i], route[i+1])
+	}
+
+	best := math.Inf(1)
+	bestPickup := math.Inf(1)
+	for i := 0; i+1 < len(route); i++ {
+		// Both stops between the same pair of route points
+		both := PathLength(route[i], pickup, dropoff, route[i+1]) - PathLength(route[i], route[i+1])
+		best = math.Min(best, both)
+
+		// Drop off here after picking up on an earlier segment
+		best = math.Min(best, bestPickup+added(i, dropoff))
+		bestPickup = math.Min(bestPickup, added(i, pickup))
+	}
+	return best
+}
+
+// AccessDistance returns how far a passenger travels to and from a route: the smallest sum of the
+// distance from origin to a route point and from a later route point to destination, counting
+// only points within radiusKm. It reports false if the route does not pass within radiusKm of
+// origin and then of destination.
+func AccessDistance(route []Point, origin, destination Point, radiusKm float64) (float64, bool) {
+	best, found := 0.0, false
+	boarding := math.Inf(1)
+	for i := 1; i < len(route); i++ {
+		// Passengers board at any point before the one they leave at
+		if d := PathLength(origin, route[i-1]); d <= radiusKm {
+			boarding = math.Min(boarding, d)
+		}
+		if math.IsInf(boarding, 1) {
+			continue
+		}
+		leaving := PathLength(route[i], destination)
+		if leaving > radiusKm {
+			continue
+		}
+		if total := boarding + leaving; !found || total < best {
+			best, found = total, true
+		}
+	}
+	return best, found
+}
+
+// CorridorRadius returns how far from the straight line between two points, directKm apart,
+// a stop can lie while the path through it stays within detourKm of the direct distance.
+// Such stops lie on an ellipse with the two points as foci, whose semi-minor axis is the radius.
+func CorridorRadius(directKm, detourKm float64) float64 {
+	if detourKm <= 0 {
+		return 0
+	}
+	return math.Sqrt(detourKm*(2*directKm+detourKm)) / 2
+}
+
+// Box is a latitude/longitude bounding box
+type Box struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// BoxAround returns the smallest box containing the points, grown by radiusKm on every side
+func BoxAround(radiusKm float64, points ...Point) Box {
+	box := Box{MinLatitude: 90, MinLongitude: 180, MaxLatitude: -90, MaxLongitude: -180}
+	for _, point := range points {
+		box.MinLatitude = math.Min(box.MinLatitude, point.Latitude)
+		box.MaxLatitude = math.Max(box.MaxLatitude, point.Latitude)
+		box.MinLongitude = math.Min(box.MinLongitude, point.Longitude)
+		box.MaxLongitude = math.Max(box.MaxLongitude, point.Longitude)
+	}
+
+	// Degrees of longitude shrink towards the poles, so grow by the poleward edge's width
+	latGrowth := radiusKm / kmPerDegree
+	box.MinLatitude = clampLatitude(box.MinLatitude - latGrowth)
+	box.MaxLatitude = clampLatitude(box.MaxLatitude + latGrowth)
+	poleward := math.Max(math.Abs(box.MinLatitude), math.Abs(box.MaxLatitude))
+	lngGrowth := radiusKm / (kmPerDegree * math.Cos(poleward*math.Pi/180))
+	box.MinLongitude -= lngGrowth
+	box.MaxLongitude += lngGrowth
+
+	return box
+}
+
+// maxCoveringCells limits how many geohash prefixes a box search may use
+const maxCoveringCells = 16
+
+// CoveringGeohashes returns geohash prefixes whose cells together cover the box.
+// It returns nil when the box is too large for a prefix search to narrow anything
+// down or crosses the antimeridian, in which case callers should not filter.
+func (b Box) CoveringGeohashes() []string {
+	if b.MinLongitude < -180 || b.MaxLongitude >= 180 || math.IsInf(b.MaxLongitude, 0) || math.IsNaN(b.MaxLongitude) {
+		return nil
+	}
+
+	// Use the longest prefixes for which the box spans few enough cells
+	for precision := GeohashPrecision; precision >= 1; precision-- {
+		latSpan, lngSpan := cellSpan(precision)
+		latCells := cellIndex(b.MaxLatitude+90, latSpan) - cellIndex(b.MinLatitude+90, latSpan) + 1
+		lngCells := cellIndex(b.MaxLongitude+180, lngSpan) - cellIndex(b.MinLongitude+180, lngSpan) + 1
+		if latCells*lngCells > maxCoveringCells {
+			continue
+		}
+
+		// Encode the center of every cell the box touches
+		hashes := make([]string, 0, latCells*lngCells)
+		firstLat := float64(cellIndex(b.MinLatitude+90, latSpan))*latSpan - 90
+		firstLng := float64(cellIndex(b.MinLongitude+180, lngSpan))*lngSpan - 180
+		for i := 0; i < latCells; i++ {
+			for j := 0; j < lngCells; j++ {
+				lat := clampLatitude(firstLat + (float64(i)+0.5)*latSpan)
+				lng := firstLng + (float64(j)+0.5)*lngSpan
+				hashes = append(hashes, EncodeGeohash(lat, lng, precision))
+			}
+		}
+		return hashes
+	}
+	return nil
+}
+
+// cellIndex returns which cell of the given span an offset from the grid origin falls into
+func cellIndex(offset, span float64) int {
+	return int(math.Floor(offset / span))
+}
+
+// cellSpan returns the size in degrees of a geohash cell with the given number of characters
+func cellSpan(precision int) (latSpan, lngSpan float64) {
+	bits := 5 * precision
+	lngBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lngBits))
+}
+
+// clampLatitude keeps a latitude inside the valid range
+func clampLatitude(lat float64) float64 {
+	return math.Max(-90, math.Min(90, lat))
+}
@@ -0,0 +1,126 @@
+package geo
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// destination returns the point distanceKm away from a start point along a compass bearing
+func destination(start Point, distanceKm, bearingDegrees float64) Point {
+	angular := distanceKm / EarthRadiusKm
+	bearing := bearingDegrees * math.Pi / 180
+	lat1 := start.Latitude * math.Pi / 180
+	lng1 := start.Longitude * math.Pi / 180
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(angular) + math.Cos(lat1)*math.Sin(angular)*math.Cos(bearing))
+	lng2 := lng1 + math.Atan2(math.Sin(bearing)*math.Sin(angular)*math.Cos(lat1), math.Cos(angular)-math.Sin(lat1)*math.Sin(lat2))
+	return Point{Latitude: lat2 * 180 / math.Pi, Longitude: lng2 * 180 / math.Pi}
+}
+
+// contains reports whether a point lies in the box
+func (b Box) contains(point Point) bool {
+	return point.Latitude >= b.MinLatitude && point.Latitude <= b.MaxLatitude &&
+		point.Longitude >= b.MinLongitude && point.Longitude <= b.MaxLongitude
+}
+
+func TestBoxAroundTakesInEveryPointWithinTheRadius(t *testing.T) {
+	colombo := Point{Latitude: 6.9271, Longitude: 79.8612}
+	kandy := Point{Latitude: 7.2906, Longitude: 80.6337}
+	oslo := Point{Latitude: 59.9139, Longitude: 10.7522}
+	tromso := Point{Latitude: 69.6492, Longitude: 18.9553}
+
+	cases := []struct {
+		name     string
+		radiusKm float64
+		points   []Point
+	}{
+		{name: "single point", radiusKm: 5, points: []Point{colombo}},
+		{name: "route", radiusKm: 12, points: []Point{colombo, kandy}},
+		{name: "far north", radiusKm: 30, points: []Point{oslo, tromso}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := BoxAround(tc.radiusKm, tc.points...)
+			for _, point := range tc.points {
+				for bearing := 0.0; bearing < 360; bearing += 15 {
+					// Just inside the radius, to stay clear of rounding at the edge
+					edge := destination(point, tc.radiusKm*0.999, bearing)
+					if !box.contains(edge) {
+						t.Fatalf("box %+v leaves out %+v, %.0f km from %+v", box, edge, tc.radiusKm, point)
+					}
+				}
+			}
+
+			// The box grows by the radius and no more than needed north and south
+			growth := tc.radiusKm / kmPerDegree
+			if got := box.MaxLatitude - math.Max(tc.points[0].Latitude, tc.points[len(tc.points)-1].Latitude); math.Abs(got-growth) > 1e-9 {
+				t.Errorf("box grows %.6f degrees north, want %.6f", got, growth)
+			}
+		})
+	}
+}
+
+func TestBoxAroundStopsAtThePoles(t *testing.T) {
+	box := BoxAround(50, Point{Latitude: 89.9, Longitude: 0}, Point{Latitude: -89.9, Longitude: 0})
+	if box.MaxLatitude != 90 || box.MinLatitude != -90 {
+		t.Errorf("box latitudes %.4f to %.4f, want clamped to -90 and 90", box.MinLatitude, box.MaxLatitude)
+	}
+}
+
+func TestCoveringGeohashesCoverTheBox(t *testing.T) {
+	cases := []struct {
+		name string
+		box  Box
+	}{
+		{name: "city", box: BoxAround(2, Point{Latitude: 6.9271, Longitude: 79.8612})},
+		{name: "route", box: BoxAround(10, Point{Latitude: 6.9271, Longitude: 79.8612}, Point{Latitude: 7.2906, Longitude: 80.6337})},
+		{name: "across the equator and meridian", box: Box{MinLatitude: -0.3, MinLongitude: -0.4, MaxLatitude: 0.2, MaxLongitude: 0.1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hashes := tc.box.CoveringGeohashes()
+			if len(hashes) == 0 || len(hashes) > maxCoveringCells {
+				t.Fatalf("CoveringGeohashes = %d prefixes, want between 1 and %d", len(hashes), maxCoveringCells)
+			}
+
+			// Every point of the box, corners included, falls in one of the cells
+			const steps = 20
+			for i := 0; i <= steps; i++ {
+				for j := 0; j <= steps; j++ {
+					lat := tc.box.MinLatitude + (tc.box.MaxLatitude-tc.box.MinLatitude)*float64(i)/steps
+					lng := tc.box.MinLongitude + (tc.box.MaxLongitude-tc.box.MinLongitude)*float64(j)/steps
+					hash := EncodeGeohash(lat, lng, GeohashPrecision)
+					if !coveredBy(hash, hashes) {
+						t.Fatalf("point %.5f,%.5f (%s) is in no cell of %v", lat, lng, hash, hashes)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCoveringGeohashesGiveUpOnBoxesTheyCannotNarrow(t *testing.T) {
+	cases := []struct {
+		name string
+		box  Box
+	}{
+		{name: "crosses the antimeridian", box: BoxAround(20, Point{Latitude: -17.7134, Longitude: 179.99})},
+		{name: "whole globe", box: Box{MinLatitude: -90, MinLongitude: -180, MaxLatitude: 90, MaxLongitude: 179.9}},
+	}
+	for _, tc := range cases {
+		if hashes := tc.box.CoveringGeohashes(); hashes != nil {
+			t.Errorf("%s: CoveringGeohashes = %v, want nil", tc.name, hashes)
+		}
+	}
+}
+
+// coveredBy reports whether a geohash starts with one of the prefixes
+func coveredBy(hash string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
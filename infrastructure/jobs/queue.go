@@ -0,0 +1,247 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxBackoff caps the delay between two attempts of a job
+const maxBackoff = time.Minute
+
+// ErrQueueFull is returned when a job is enqueued while the queue is at capacity
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrQueueClosed is returned when a job is enqueued after shutdown has begun
+var ErrQueueClosed = errors.New("job queue is shut down")
+
+// Job is a unit of background work.
+// Run is retried with backoff while it returns an error, unless the error is permanent.
+type Job struct {
+	// Name identifies the job in logs
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Config sizes a queue and its retry policy
+type Config struct {
+	// Workers is the number of jobs run at the same time
+	Workers int
+	// QueueSize is the number of jobs that can wait for a worker
+	QueueSize int
+	// MaxAttempts is how many times a failing job is run before it is given up
+	MaxAttempts int
+	// Backoff is the delay before the second attempt; it doubles with every further attempt
+	Backoff time.Duration
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+// Error returns the wrapped error's message
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps an error so that the job returning it is not retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Queue runs jobs in the background on a bounded number of workers
+type Queue struct {
+	config Config
+	jobs   chan Job
+
+	// ctx is cancelled when a shutdown runs out of time, aborting running jobs and backoffs
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// stop is closed when shutdown begins, ending periodic schedules
+	stop chan struct{}
+
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// NewQueue creates a queue; call Start to begin running jobs
+func NewQueue(config Config) *Queue {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		config: config,
+		jobs:   make(chan Job, config.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start launches the queue's workers
+func (q *Queue) Start() {
+	for i := 0; i < q.config.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+}
+
+// Enqueue adds a job to the queue without blocking
+func (q *Queue) Enqueue(job Job) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Every enqueues a job at every interval until the queue shuts down.
+// A periodic job is not retried, as its next run is the retry, and a run is
+// skipped while the previous one is still queued or running.
+func (q *Queue) Every(interval time.Duration, job Job) {
+	var busy int32
+	run := job.Run
+	job.Run = func(ctx context.Context) error {
+		defer atomic.StoreInt32(&busy, 0)
+		return Permanent(run(ctx))
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-q.stop:
+				return
+			}
+
+			if !atomic.CompareAndSwapInt32(&busy, 0, 1) {
+				continue
+			}
+			if err := q.Enqueue(job); err != nil {
+				atomic.StoreInt32(&busy, 0)
+				log.Printf("Failed to queue %s: %v", job.Name, err)
+			}
+		}
+	}()
+}
+
+// Shutdown stops accepting jobs and waits for queued and running jobs to finish.
+// If ctx ends first, running jobs are cancelled, jobs still queued are dropped and
+// the context's error is returned.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.stop)
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work runs jobs until the queue is closed and drained
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for job := range q.jobs {
+		if q.ctx.Err() != nil {
+			log.Printf("Dropping job %s: queue shut down", job.Name)
+			continue
+		}
+		q.run(job)
+	}
+}
+
+// run runs a job, retrying it with exponential backoff while it fails
+func (q *Queue) run(job Job) {
+	backoff := q.config.Backoff
+	for attempt := 1; ; attempt++ {
+		err := q.attempt(job)
+		if err == nil {
+			return
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			log.Printf("Job %s failed, not retrying: %v", job.Name, permanent.err)
+			return
+		}
+		if attempt >= q.config.MaxAttempts {
+			log.Printf("Job %s failed after %d attempts, giving up: %v", job.Name, attempt, err)
+			return
+		}
+		log.Printf("Job %s failed on attempt %d, retrying in %s: %v", job.Name, attempt, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-q.ctx.Done():
+			timer.Stop()
+			log.Printf("Job %s abandoned: queue shut down", job.Name)
+			return
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// attempt runs a job once, turning a panic into an error so that one bad job cannot stop a worker
+func (q *Queue) attempt(job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(q.ctx)
+}
@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// attemptLog records when a job was run
+type attemptLog struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+// record notes an attempt and returns how many there have been
+func (l *attemptLog) record() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.times = append(l.times, time.Now())
+	return len(l.times)
+}
+
+// attempts returns the times of the attempts so far
+func (l *attemptLog) attempts() []time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]time.Time(nil), l.times...)
+}
+
+// shutdown drains a queue, failing the test if that takes longer than a few seconds
+func shutdown(t *testing.T, queue *Queue) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := queue.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	queue := NewQueue(Config{Workers: 1, QueueSize: 1, MaxAttempts: 5, Backoff: 20 * time.Millisecond})
+	queue.Start()
+
+	var history attemptLog
+	err := queue.Enqueue(Job{Name: "flaky", Run: func(ctx context.Context) error {
+		if history.record() < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	shutdown(t, queue)
+
+	times := history.attempts()
+	if len(times) != 3 {
+		t.Fatalf("job ran %d times, want 3: it succeeds on the third attempt", len(times))
+	}
+	// The backoff doubles: 20ms before the second attempt, 40ms before the third
+	for i, want := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond} {
+		if gap := times[i+1].Sub(times[i]); gap < want {
+			t.Errorf("attempt %d ran %s after the previous one, want at least %s", i+2, gap, want)
+		}
+	}
+}
+
+func TestQueueGivesUpAfterMaxAttempts(t *testing.T) {
+	queue := NewQueue(Config{Workers: 1, QueueSize: 2, MaxAttempts: 3, Backoff: time.Millisecond})
+	queue.Start()
+
+	var failing, permanent attemptLog
+	jobs := []Job{
+		{Name: "failing", Run: func(ctx context.Context) error {
+			failing.record()
+			return errors.New("always fails")
+		}},
+		{Name: "permanent", Run: func(ctx context.Context) error {
+			permanent.record()
+			return Permanent(errors.New("cannot be fixed by retrying"))
+		}},
+	}
+	for _, job := range jobs {
+		if err := queue.Enqueue(job); err != nil {
+			t.Fatalf("Enqueue(%s): %v", job.Name, err)
+		}
+	}
+	shutdown(t, queue)
+
+	if n := len(failing.attempts()); n != 3 {
+		t.Errorf("failing job ran %d times, want MaxAttempts = 3", n)
+	}
+	if n := len(permanent.attempts()); n != 1 {
+		t.Errorf("job with a permanent error ran %d times, want 1", n)
+	}
+}
+
+func TestQueueSurvivesPanics(t *testing.T) {
+	queue := NewQueue(Config{Workers: 1, QueueSize: 2, MaxAttempts: 1})
+	queue.Start()
+
+	var ran int32
+	if err := queue.Enqueue(Job{Name: "panics", Run: func(ctx context.Context) error { panic("boom") }}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := queue.Enqueue(Job{Name: "after", Run: func(ctx context.Context) error {
+		atomic.AddInt32(&ran, 1)
+		return nil
+	}}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	shutdown(t, queue)
+
+	if atomic.LoadInt32(&ran) != 1 {
+		t.Error("the job queued after a panicking job did not run")
+	}
+}
+
+func TestQueueRejectsJobsWhenFull(t *testing.T) {
+	// The queue is never started, so nothing leaves it
+	queue := NewQueue(Config{Workers: 1, QueueSize: 1})
+	noop := Job{Name: "noop", Run: func(ctx context.Context) error { return nil }}
+
+	if err := queue.Enqueue(noop); err != nil {
+		t.Fatalf("first Enqueue: %v", err)
+	}
+	if err := queue.Enqueue(noop); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Enqueue on a full queue = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestQueueShutdownDrainsQueuedJobs(t *testing.T) {
+	queue := NewQueue(Config{Workers: 2, QueueSize: 10, MaxAttempts: 1})
+	queue.Start()
+
+	var ran int32
+	for i := 0; i < 10; i++ {
+		err := queue.Enqueue(Job{Name: "slow", Run: func(ctx context.Context) error {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&ran, 1)
+			return nil
+		}})
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	shutdown(t, queue)
+
+	if n := atomic.LoadInt32(&ran); n != 10 {
+		t.Errorf("%d of 10 queued jobs ran before Shutdown returned", n)
+	}
+	if err := queue.Enqueue(Job{Name: "late", Run: func(ctx context.Context) error { return nil }}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue after Shutdown = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestQueueShutdownTimeoutCancelsJobs(t *testing.T) {
+	queue := NewQueue(Config{Workers: 1, QueueSize: 1, MaxAttempts: 1})
+	queue.Start()
+
+	started := make(chan struct{})
+	var cancelled, dropped int32
+	if err := queue.Enqueue(Job{Name: "blocking", Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		atomic.StoreInt32(&cancelled, 1)
+		return ctx.Err()
+	}}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	<-started
+	if err := queue.Enqueue(Job{Name: "queued", Run: func(ctx context.Context) error {
+		atomic.StoreInt32(&dropped, 1)
+		return nil
+	}}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := queue.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Error("the running job's context was not cancelled")
+	}
+	if atomic.LoadInt32(&dropped) != 0 {
+		t.Error("a job still queued when the shutdown timed out was run")
+	}
+}
+
+func TestQueueEverySkipsBusyRuns(t *testing.T) {
+	// A retried run would wait out the hour-long backoff and hold up the shutdown
+	queue := NewQueue(Config{Workers: 2, QueueSize: 10, MaxAttempts: 3, Backoff: time.Hour})
+	queue.Start()
+
+	var running, overlapped, runs int32
+	queue.Every(5*time.Millisecond, Job{Name: "periodic", Run: func(ctx context.Context) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("periodic jobs are not retried")
+	}})
+	time.Sleep(100 * time.Millisecond)
+	shutdown(t, queue)
+
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Error("a periodic job started while its previous run was still going")
+	}
+	// Each run takes 20ms, so 100ms fit about 5 of them
+	if n := atomic.LoadInt32(&runs); n < 2 || n > 6 {
+		t.Errorf("periodic job ran %d times in 100ms, want about 5", n)
+	}
+}
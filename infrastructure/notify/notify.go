@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// Type identifies what a notification is about
+type Type string
+
+const (
+	// RideOfferExpired tells a driver their offer departed without being confirmed
+	RideOfferExpired Type = "ride_offer_expired"
+	// RideRequestExpired tells a passenger their request departed without being confirmed
+	RideRequestExpired Type = "ride_request_expired"
+	// RideMatchExpired tells a driver or passenger a proposed match was not confirmed in time
+	RideMatchExpired Type = "ride_match_expired"
+	// RideMatchWithdrawn tells a driver or passenger a proposed match was withdrawn because the other party
+	// changed or withdrew their ride, or confirmed another one
+	RideMatchWithdrawn Type = "ride_match_withdrawn"
+	// RideBooked tells a driver a passenger booked seats on their offer
+	RideBooked Type = "ride_booked"
+	// TripOffered asks a driver to accept an on-demand trip near them
+	TripOffered Type = "trip_offered"
+	// TripAccepted tells a passenger a driver took their on-demand trip
+	TripAccepted Type = "trip_accepted"
+	// TripUnavailable tells a passenger no driver took their on-demand trip
+	TripUnavailable Type = "trip_unavailable"
+)
+
+// Notification tells a user that something happened to one of their rides
+type Notification struct {
+	UserID uuid.UUID `json:"user_id"`
+	Type   Type      `json:"type"`
+	// RideID is the ride offer, request or match the notification is about
+	RideID  uuid.UUID `json:"ride_id"`
+	Message string    `json:"message"`
+}
+
+// Notifier delivers notifications to users
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier writes notifications to the log instead of delivering them
+type LogNotifier struct{}
+
+// Notify logs the notification
+func (LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	log.Printf("Notification for user %s: %s (%s %s)",
+		notification.UserID, notification.Message, notification.Type, notification.RideID)
+	return nil
+}
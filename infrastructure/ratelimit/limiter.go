@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// window counts the events of one key since the window started
+type window struct {
+	start time.Time
+	count int
+}
+
+// Limiter allows each key up to a number of events per fixed time window.
+// A Limiter with a limit of 0 or less allows everything.
+type Limiter struct {
+	limit  int
+	period time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastPrune time.Time
+}
+
+// NewLimiter creates a Limiter allowing limit events per key every period
+func NewLimiter(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		period:  period,
+		windows: make(map[string]*window),
+	}
+}
+
+// Allow records n events for the key and reports whether they fit in the key's current window.
+// Events that do not fit are not recorded, so a smaller batch may still be allowed.
+func (l *Limiter) Allow(key string, n int) bool {
+	if l.limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.period {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count+n > l.limit {
+		return false
+	}
+	w.count += n
+	return true
+}
+
+// prune forgets the windows that ended, at most once a period. The caller must hold the lock.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.period {
+		return
+	}
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.period {
+			delete(l.windows, key)
+		}
+	}
+	l.lastPrune = now
+}
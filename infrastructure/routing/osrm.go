@@ -0,0 +1,67 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+// OSRMEstimator asks an OSRM-compatible routing service for driving distances.
+// Any service answering GET {url}/route/v1/driving/{lng},{lat};... in the OSRM
+// response format works, such as a local stand-in used in development.
+type OSRMEstimator struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOSRMEstimator creates an OSRMEstimator for the service at baseURL
+func NewOSRMEstimator(baseURL string, timeout time.Duration) *OSRMEstimator {
+	return &OSRMEstimator{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// osrmRouteResponse is the part of an OSRM route response the estimator reads
+type osrmRouteResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Routes  []struct {
+		// Distance is the route length in meters
+		Distance float64 `json:"distance"`
+	} `json:"routes"`
+}
+
+// RouteDistance returns the driving distance of the route in kilometers
+func (e *OSRMEstimator) RouteDistance(waypoints ...model.Location) (float64, error) {
+	if len(waypoints) < 2 {
+		return 0, nil
+	}
+
+	coordinates := make([]string, len(waypoints))
+	for i, waypoint := range waypoints {
+		coordinates[i] = strconv.FormatFloat(waypoint.Longitude, 'f', 6, 64) + "," +
+			strconv.FormatFloat(waypoint.Latitude, 'f', 6, 64)
+	}
+
+	resp, err := e.client.Get(e.baseURL + "/route/v1/driving/" + strings.Join(coordinates, ";") + "?overview=false")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var route osrmRouteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&route); err != nil {
+		return 0, fmt.Errorf("osrm: invalid response with status %d: %w", resp.StatusCode, err)
+	}
+	if route.Code != "Ok" || len(route.Routes) == 0 {
+		return 0, fmt.Errorf("osrm: no route found: %s %s", route.Code, route.Message)
+	}
+
+	return route.Routes[0].Distance / 1000, nil
+}
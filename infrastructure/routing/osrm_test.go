@@ -0,0 +1,125 @@
+package routing
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+// Colombo Fort and Kandy, a little over 90 km apart as the crow flies
+var (
+	colombo = model.Location{Latitude: 6.9344, Longitude: 79.8428}
+	kandy   = model.Location{Latitude: 7.2906, Longitude: 80.6337}
+)
+
+// newOSRMServer serves a route response for every request and records the path asked for
+func newOSRMServer(t *testing.T, status int, body string) (*httptest.Server, *string) {
+	t.Helper()
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requested
+}
+
+func TestOSRMEstimatorReadsTheRouteDistance(t *testing.T) {
+	server, requested := newOSRMServer(t, http.StatusOK, `{"code":"Ok","routes":[{"distance":115400.5},{"distance":130000}]}`)
+	estimator := NewOSRMEstimator(server.URL+"/", time.Second)
+
+	distance, err := estimator.RouteDistance(colombo, kandy)
+	if err != nil {
+		t.Fatalf("RouteDistance: %v", err)
+	}
+	if math.Abs(distance-115.4005) > 1e-9 {
+		t.Errorf("RouteDistance = %v km, want the first route's 115.4005 km", distance)
+	}
+	// OSRM takes longitude first
+	if want := "/route/v1/driving/79.842800,6.934400;80.633700,7.290600"; *requested != want {
+		t.Errorf("requested %q, want %q", *requested, want)
+	}
+
+	if distance, err := estimator.RouteDistance(colombo); err != nil || distance != 0 {
+		t.Errorf("RouteDistance of a single point = %v, %v, want 0 without asking the service", distance, err)
+	}
+}
+
+func TestOSRMEstimatorErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "no route", status: http.StatusBadRequest, body: `{"code":"NoRoute","message":"Impossible route between points"}`},
+		{name: "no routes in the response", status: http.StatusOK, body: `{"code":"Ok","routes":[]}`},
+		{name: "not JSON", status: http.StatusBadGateway, body: `<html>Bad Gateway</html>`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _ := newOSRMServer(t, tc.status, tc.body)
+			if distance, err := NewOSRMEstimator(server.URL, time.Second).RouteDistance(colombo, kandy); err == nil {
+				t.Errorf("RouteDistance = %v, want an error", distance)
+			}
+		})
+	}
+}
+
+func TestOSRMFallsBackToStraightLines(t *testing.T) {
+	straight, _ := StraightLineEstimator{}.RouteDistance(colombo, kandy)
+
+	t.Run("service answers", func(t *testing.T) {
+		server, _ := newOSRMServer(t, http.StatusOK, `{"code":"Ok","routes":[{"distance":115000}]}`)
+		estimator, err := New(ProviderOSRM, server.URL, time.Second)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if distance, err := estimator.RouteDistance(colombo, kandy); err != nil || distance != 115 {
+			t.Errorf("RouteDistance = %v, %v, want the service's 115 km", distance, err)
+		}
+		if StraightLine(estimator) {
+			t.Error("an OSRM estimator reports straight lines")
+		}
+	})
+
+	t.Run("service fails", func(t *testing.T) {
+		server, _ := newOSRMServer(t, http.StatusBadRequest, `{"code":"NoRoute"}`)
+		estimator, err := New(ProviderOSRM, server.URL, time.Second)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if distance, err := estimator.RouteDistance(colombo, kandy); err != nil || distance != straight {
+			t.Errorf("RouteDistance = %v, %v, want the straight-line %v km", distance, err, straight)
+		}
+	})
+
+	t.Run("service unreachable", func(t *testing.T) {
+		server, _ := newOSRMServer(t, http.StatusOK, `{}`)
+		server.Close()
+		estimator, err := New(ProviderOSRM, server.URL, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if distance, err := estimator.RouteDistance(colombo, kandy); err != nil || distance != straight {
+			t.Errorf("RouteDistance = %v, %v, want the straight-line %v km", distance, err, straight)
+		}
+	})
+}
+
+func TestNewChecksTheProvider(t *testing.T) {
+	if _, err := New(ProviderOSRM, "", time.Second); err == nil {
+		t.Error("New(osrm) without a url succeeded")
+	}
+	if _, err := New("google", "http://localhost", time.Second); err == nil {
+		t.Error("New with an unknown provider succeeded")
+	}
+	if estimator, err := New("", "", time.Second); err != nil || !StraightLine(estimator) {
+		t.Errorf("New with no provider = %T, %v, want straight lines", estimator, err)
+	}
+}
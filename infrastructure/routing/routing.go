@@ -0,0 +1,121 @@
+package routing
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+// Supported values for the routing provider setting
+const (
+	ProviderStraightLine = "straight_line"
+	ProviderOSRM         = "osrm"
+)
+
+// RouteEstimator estimates travel distances along a route
+type RouteEstimator interface {
+	// RouteDistance returns the length in kilometers of a route visiting the waypoints in order
+	RouteDistance(waypoints ...model.Location) (float64, error)
+}
+
+// New creates the route estimator for the configured provider.
+// Routing services fall back to straight-line distances when they cannot be reached.
+func New(provider string, url string, timeout time.Duration) (RouteEstimator, error) {
+	switch provider {
+	case ProviderStraightLine, "":
+		return StraightLineEstimator{}, nil
+	case ProviderOSRM:
+		if url == "" {
+			return nil, fmt.Errorf("routing provider %q needs a url", provider)
+		}
+		return &fallbackEstimator{primary: NewOSRMEstimator(url, timeout), fallback: StraightLineEstimator{}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported routing provider %q, expected %q or %q", provider, ProviderStraightLine, ProviderOSRM)
+	}
+}
+
+// Detour returns how many kilometers longer the driver's route becomes when it picks
+// a passenger up and drops them off on the way. The stops are inserted where they add
+// the least straight-line distance and the resulting route is measured by the estimator.
+func Detour(estimator RouteEstimator, route []model.Location, pickup, dropoff model.Location) (float64, error) {
+	direct, err := estimator.RouteDistance(route...)
+	if err != nil {
+		return 0, err
+	}
+	withPassenger, err := estimator.RouteDistance(InsertStops(route, pickup, dropoff)...)
+	if err != nil {
+		return 0, err
+	}
+	return withPassenger - direct, nil
+}
+
+// InsertStops returns the route with pickup and then dropoff inserted where they add
+// the least straight-line distance. Both stops go between the route's start and end,
+// whose order is kept.
+func InsertStops(route []model.Location, pickup, dropoff model.Location) []model.Location {
+	if len(route) < 2 {
+		return append(append([]model.Location{}, route...), pickup, dropoff)
+	}
+
+	var best []model.Location
+	bestKm := 0.0
+	for i := 1; i < len(route); i++ {
+		for j := i; j < len(route); j++ {
+			candidate := make([]model.Location, 0, len(route)+2)
+			candidate = append(candidate, route[:i]...)
+			candidate = append(candidate, pickup)
+			candidate = append(candidate, route[i:j]...)
+			candidate = append(candidate, dropoff)
+			candidate = append(candidate, route[j:]...)
+
+			km := geo.PathLength(points(candidate)...)
+			if best == nil || km < bestKm {
+				best, bestKm = candidate, km
+			}
+		}
+	}
+	return best
+}
+
+// StraightLineEstimator measures routes as great-circle distances between waypoints
+type StraightLineEstimator struct{}
+
+// RouteDistance returns the great-circle length of the route in kilometers
+func (StraightLineEstimator) RouteDistance(waypoints ...model.Location) (float64, error) {
+	return geo.PathLength(points(waypoints)...), nil
+}
+
+// StraightLine reports whether an estimator measures routes as straight lines, so that
+// straight-line distances worked out without it agree with its own
+func StraightLine(estimator RouteEstimator) bool {
+	_, ok := estimator.(StraightLineEstimator)
+	return ok
+}
+
+// fallbackEstimator uses a secondary estimator when the primary one fails
+type fallbackEstimator struct {
+	primary  RouteEstimator
+	fallback RouteEstimator
+}
+
+// RouteDistance returns the primary estimate, or the fallback estimate if the primary fails
+func (e *fallbackEstimator) RouteDistance(waypoints ...model.Location) (float64, error) {
+	distance, err := e.primary.RouteDistance(waypoints...)
+	if err == nil {
+		return distance, nil
+	}
+	log.Printf("Route estimation failed, using the fallback estimator: %v", err)
+	return e.fallback.RouteDistance(waypoints...)
+}
+
+// points converts locations to geo points
+func points(locations []model.Location) []geo.Point {
+	converted := make([]geo.Point, len(locations))
+	for i, location := range locations {
+		converted[i] = geo.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+	}
+	return converted
+}
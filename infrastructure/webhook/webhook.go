@@ -0,0 +1,116 @@
+// Package webhook posts signed event deliveries to the URLs of partner webhooks.
+//
+// Every delivery is a POST of a JSON body carrying these headers:
+//
+//	X-Webhook-Delivery   the delivery ID, the same on every retry of the delivery
+//	X-Webhook-Event      the event type, such as ride_match.confirmed
+//	X-Webhook-Timestamp  when the attempt was sent, in Unix seconds
+//	X-Webhook-Signature  sha256= and the hex HMAC-SHA256 of "{timestamp}.{body}", keyed with the secret
+//
+// Receivers check a delivery by computing the signature themselves and comparing it in
+// constant time, and should reject timestamps too far in the past so that a captured
+// delivery cannot be replayed against them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Header names of a delivery
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxResponseBytes is how much of a webhook's answer is kept for the delivery log
+const maxResponseBytes = 1024
+
+// Sign returns the signature of a delivery body sent at the given Unix time
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Delivery is one event to post to a webhook
+type Delivery struct {
+	ID        uuid.UUID
+	EventType string
+	URL       string
+	Secret    string
+	Body      []byte
+}
+
+// Result is what a webhook answered to a delivery
+type Result struct {
+	// StatusCode is 0 when the webhook could not be reached
+	StatusCode int
+	// Body is the start of the webhook's answer
+	Body     string
+	Duration time.Duration
+}
+
+// Sender posts deliveries to webhooks
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender creates a Sender whose posts give up after the timeout. Redirects are not followed,
+// so that a delivery only ever goes to the URL that was subscribed.
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send posts a signed delivery. The result is always returned; the error is set when the webhook
+// could not be reached or answered with a status other than 2xx.
+func (s *Sender) Send(ctx context.Context, delivery Delivery) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return Result{}, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ride-sharing-app-webhooks/1.0")
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Body))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(start)}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	// Drain the rest so that the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+	result := Result{StatusCode: resp.StatusCode, Body: string(body), Duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return result, nil
+}
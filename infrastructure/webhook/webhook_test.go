@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	// Computed independently as the hex HMAC-SHA256 of `1700000000.{"id":"evt_1"}` keyed with whsec_test
+	want := "sha256=c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925"
+	if got := Sign("whsec_test", 1700000000, body); got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+
+	for name, signature := range map[string]string{
+		"other secret":    Sign("whsec_other", 1700000000, body),
+		"other timestamp": Sign("whsec_test", 1700000001, body),
+		"other body":      Sign("whsec_test", 1700000000, []byte(`{"id":"evt_2"}`)),
+	} {
+		if signature == want {
+			t.Errorf("%s: signature unchanged", name)
+		}
+	}
+}
+
+func TestSendSignsDeliveries(t *testing.T) {
+	delivery := Delivery{
+		ID:        uuid.New(),
+		EventType: "ride_match.confirmed",
+		Secret:    "whsec_test",
+		Body:      []byte(`{"id":"evt_1"}`),
+	}
+
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	delivery.URL = server.URL
+
+	result, err := NewSender(time.Second).Send(context.Background(), delivery)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if result.StatusCode != http.StatusOK || result.Body != "ok" {
+		t.Errorf("result = %+v, want 200 ok", result)
+	}
+
+	if received.Header.Get(HeaderDelivery) != delivery.ID.String() || received.Header.Get(HeaderEvent) != delivery.EventType {
+		t.Errorf("headers = %v, want the delivery ID and event type", received.Header)
+	}
+	timestamp, err := strconv.ParseInt(received.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Fatalf("timestamp header = %q, want the current Unix time", received.Header.Get(HeaderTimestamp))
+	}
+	if got, want := received.Header.Get(HeaderSignature), Sign(delivery.Secret, timestamp, receivedBody); got != want {
+		t.Errorf("signature header = %s, want %s", got, want)
+	}
+	if string(receivedBody) != string(delivery.Body) {
+		t.Errorf("body = %s, want %s", receivedBody, delivery.Body)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try later", http.StatusBadGateway)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a redirect was followed")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	sender := NewSender(time.Second)
+	for path, wantStatus := range map[string]int{"/error": http.StatusBadGateway, "/redirect": http.StatusFound} {
+		result, err := sender.Send(context.Background(), Delivery{ID: uuid.New(), URL: server.URL + path, Body: []byte("{}")})
+		if err == nil {
+			t.Errorf("Send to %s succeeded, want an error", path)
+		}
+		if result.StatusCode != wantStatus {
+			t.Errorf("Send to %s: status %d, want %d", path, result.StatusCode, wantStatus)
+		}
+	}
+
+	// An unreachable webhook has no status
+	server.Close()
+	result, err := sender.Send(context.Background(), Delivery{ID: uuid.New(), URL: server.URL, Body: []byte("{}")})
+	if err == nil || result.StatusCode != 0 {
+		t.Errorf("Send to a closed server = %+v, %v, want status 0 and an error", result, err)
+	}
+}
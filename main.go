@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yourusername/ride-sharing-app/api/handlers"
@@ -10,6 +17,12 @@ import (
 	"github.com/yourusername/ride-sharing-app/config"
 	"github.com/yourusername/ride-sharing-app/infrastructure/auth"
 	"github.com/yourusername/ride-sharing-app/infrastructure/database"
+	"github.com/yourusername/ride-sharing-app/infrastructure/eventbus"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+	"github.com/yourusername/ride-sharing-app/infrastructure/jobs"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+	"github.com/yourusername/ride-sharing-app/infrastructure/routing"
+	"github.com/yourusername/ride-sharing-app/infrastructure/webhook"
 	"github.com/yourusername/ride-sharing-app/repository"
 	"github.com/yourusername/ride-sharing-app/service"
 )
@@ -22,35 +35,214 @@ func main() {
 	}
 
 	// Initialize database
-	db, err := database.InitDB(cfg.Database.URL)
+	db, err := database.InitDB(cfg.Database.Driver, cfg.Database.URL)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
+	// Run database migrations when invoked as "migrate up|down|status"
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Refuse to start on a schema that is behind the migrations
+	if err := database.CheckSchema(db); err != nil {
+		log.Fatalf("Failed to verify database schema: %v", err)
+	}
+
 	// Create repositories
 	userRepo := repository.NewGormUserRepository(db)
+
+	// Manage admins when invoked as "admin grant <email>" or "admin revoke <email>"
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := runAdmin(userRepo, os.Args[2:]); err != nil {
+			log.Fatalf("Admin command failed: %v", err)
+		}
+		return
+	}
+
 	rideRepo := repository.NewGormRideRepository(db)
 
+	// Create route estimator
+	routeEstimator, err := routing.New(cfg.Routing.Provider, cfg.Routing.URL, time.Duration(cfg.Routing.TimeoutSeconds)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to configure routing: %v", err)
+	}
+
+	// Create match scorer
+	matching := service.MatchingOptions{
+		Window:    time.Duration(cfg.Matching.WindowMinutes) * time.Minute,
+		Threshold: cfg.Matching.Threshold,
+	}
+	weights := service.ScoreWeights{
+		Price:    cfg.Matching.Weights.Price,
+		Time:     cfg.Matching.Weights.Time,
+		Location: cfg.Matching.Weights.Location,
+		Rating:   cfg.Matching.Weights.Rating,
+	}
+	matchScorer, err := service.NewMatchScorer(cfg.Matching.Scorer, userRepo, weights, matching.Window)
+	if err != nil {
+		log.Fatalf("Failed to configure matching: %v", err)
+	}
+
+	// Create the background job queue
+	jobQueue := jobs.NewQueue(jobs.Config{
+		Workers:     cfg.Jobs.Workers,
+		QueueSize:   cfg.Jobs.QueueSize,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+		Backoff:     time.Duration(cfg.Jobs.BackoffMilliseconds) * time.Millisecond,
+	})
+	jobQueue.Start()
+
+	// Relaying events and posting webhooks wait on sinks and partners, so they get their own workers
+	deliveryQueue := jobs.NewQueue(jobs.Config{
+		Workers:     cfg.Jobs.DeliveryWorkers,
+		QueueSize:   cfg.Jobs.QueueSize,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+		Backoff:     time.Duration(cfg.Jobs.BackoffMilliseconds) * time.Millisecond,
+	})
+	deliveryQueue.Start()
+
 	// Create services
 	jwtService := auth.NewJWTService(cfg.JWT.Secret, cfg.JWT.Issuer)
 	userService := service.NewUserService(userRepo)
-	rideService := service.NewRideService(rideRepo, userRepo)
+	expiry := service.ExpiryOptions{
+		Grace:    time.Duration(cfg.Expiry.GraceMinutes) * time.Minute,
+		MatchTTL: time.Duration(cfg.Expiry.MatchTTLMinutes) * time.Minute,
+	}
+	dispatch := service.DispatchOptions{
+		RadiusKm:       cfg.Dispatch.RadiusKm,
+		AcceptWindow:   time.Duration(cfg.Dispatch.AcceptSeconds) * time.Second,
+		MaxWait:        time.Duration(cfg.Dispatch.MaxWaitMinutes) * time.Minute,
+		LocationMaxAge: time.Duration(cfg.Dispatch.LocationMaxAgeMinutes) * time.Minute,
+		PricePerKm:     cfg.Dispatch.PricePerKm,
+	}
+	tracking := service.TrackingOptions{
+		MaxPointsPerMinute: cfg.Tracking.MaxPointsPerMinute,
+		MaxBatchSize:       cfg.Tracking.MaxBatchSize,
+		Retention:          time.Duration(cfg.Tracking.RetentionDays) * 24 * time.Hour,
+	}
+	eventBroker := events.NewBroker(events.Config{
+		HistorySize:      cfg.Events.HistorySize,
+		HistoryTTL:       time.Duration(cfg.Events.HistoryTTLMinutes) * time.Minute,
+		SubscriberBuffer: cfg.Events.SubscriberBuffer,
+	})
+	rideService := service.NewRideService(rideRepo, userRepo, routeEstimator, matchScorer, matching, expiry, dispatch,
+		tracking, jobQueue, notify.LogNotifier{}, eventBroker)
+
+	// Match pending rides again periodically
+	if cfg.Matching.SweepIntervalSeconds > 0 {
+		sweepInterval := time.Duration(cfg.Matching.SweepIntervalSeconds) * time.Second
+		jobQueue.Every(sweepInterval, jobs.Job{Name: "match sweep", Run: rideService.SweepMatches})
+	}
+
+	// Expire rides that departed unconfirmed and matches nobody confirmed in time
+	if cfg.Expiry.IntervalSeconds > 0 {
+		expiryInterval := time.Duration(cfg.Expiry.IntervalSeconds) * time.Second
+		jobQueue.Every(expiryInterval, jobs.Job{Name: "ride expiry", Run: rideService.ExpireRides})
+	}
+
+	// Move unanswered on-demand trips on to the next driver
+	if cfg.Dispatch.IntervalSeconds > 0 {
+		dispatchInterval := time.Duration(cfg.Dispatch.IntervalSeconds) * time.Second
+		jobQueue.Every(dispatchInterval, jobs.Job{Name: "ride dispatch", Run: rideService.DispatchRides})
+	}
+
+	// Delete trip locations past retention
+	if cfg.Tracking.RetentionDays > 0 && cfg.Tracking.PurgeIntervalMinutes > 0 {
+		purgeInterval := time.Duration(cfg.Tracking.PurgeIntervalMinutes) * time.Minute
+		jobQueue.Every(purgeInterval, jobs.Job{Name: "trip location purge", Run: rideService.PurgeTripLocations})
+	}
+
+	// Relay domain events from the outbox to the in-process bus and, if configured, a file
+	eventBus := eventbus.NewBus()
+	sinks := []eventbus.Sink{eventBus}
+	if cfg.Outbox.FilePath != "" {
+		fileSink, err := eventbus.NewFileSink(cfg.Outbox.FilePath, cfg.Outbox.SubjectPrefix)
+		if err != nil {
+			log.Fatalf("Failed to open event file: %v", err)
+		}
+		defer fileSink.Close()
+		sinks = append(sinks, fileSink)
+	}
+	outboxRelay := service.NewOutboxRelay(rideRepo, service.OutboxOptions{
+		BatchSize:   cfg.Outbox.BatchSize,
+		MaxAttempts: cfg.Outbox.MaxAttempts,
+		Retention:   time.Duration(cfg.Outbox.RetentionDays) * 24 * time.Hour,
+	}, sinks...)
+	if cfg.Outbox.RelayIntervalSeconds > 0 {
+		relayInterval := time.Duration(cfg.Outbox.RelayIntervalSeconds) * time.Second
+		deliveryQueue.Every(relayInterval, jobs.Job{Name: "outbox relay", Run: outboxRelay.Relay})
+	}
+	if cfg.Outbox.RetentionDays > 0 && cfg.Outbox.PurgeIntervalMinutes > 0 {
+		purgeInterval := time.Duration(cfg.Outbox.PurgeIntervalMinutes) * time.Minute
+		deliveryQueue.Every(purgeInterval, jobs.Job{Name: "outbox purge", Run: outboxRelay.PurgeOutbox})
+	}
+
+	// Queue the relayed events for partner webhooks and post them with retries
+	webhookService := service.NewWebhookService(
+		repository.NewGormWebhookRepository(db),
+		rideRepo,
+		webhook.NewSender(time.Duration(cfg.Webhooks.TimeoutSeconds)*time.Second),
+		service.WebhookOptions{
+			BatchSize:   cfg.Webhooks.BatchSize,
+			MaxAttempts: cfg.Webhooks.MaxAttempts,
+			Backoff:     time.Duration(cfg.Webhooks.BackoffSeconds) * time.Second,
+			MaxBackoff:  time.Duration(cfg.Webhooks.MaxBackoffMinutes) * time.Minute,
+		},
+	)
+	eventBus.Subscribe(webhookService.HandleEvent)
+	if cfg.Webhooks.DeliveryIntervalSeconds > 0 {
+		deliveryInterval := time.Duration(cfg.Webhooks.DeliveryIntervalSeconds) * time.Second
+		deliveryQueue.Every(deliveryInterval, jobs.Job{Name: "webhook delivery", Run: webhookService.DeliverWebhooks})
+	}
 
 	// Create handlers
 	userHandler := handlers.NewUserHandler(userService, jwtService)
 	rideHandler := handlers.NewRideHandler(rideService)
+	eventHandler := handlers.NewEventHandler(eventBroker)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
 
 	// Initialize Gin
 	router := gin.Default()
 
 	// Setup routes
-	routes.Setup(router, userHandler, rideHandler, jwtService)
+	routes.Setup(router, userHandler, rideHandler, eventHandler, webhookHandler, jwtService)
 
 	// Start server
-	serverAddr := fmt.Sprintf(":%s", cfg.Server.Port)
-	log.Printf("Starting server on %s", serverAddr)
-	if err := router.Run(serverAddr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", cfg.Server.Port),
+		Handler: router,
+	}
+	go func() {
+		log.Printf("Starting server on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for an interrupt, then stop taking requests before draining background jobs
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+	// End the event streams, which would otherwise keep the server from shutting down
+	eventBroker.Close()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down server gracefully: %v", err)
+	}
+	if err := jobQueue.Shutdown(ctx); err != nil {
+		log.Printf("Failed to finish background jobs: %v", err)
+	}
+	if err := deliveryQueue.Shutdown(ctx); err != nil {
+		log.Printf("Failed to finish event deliveries: %v", err)
 	}
+	log.Println("Server stopped")
 }
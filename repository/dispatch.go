@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+// FindDriverAvailability retrieves a driver's availability, or nil if the driver never reported it
+func (r *GormRideRepository) FindDriverAvailability(driverID uuid.UUID) (*model.DriverAvailability, error) {
+	var availability model.DriverAvailability
+	if err := r.db.Where("driver_id = ?", driverID).First(&availability).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// SaveDriverAvailability saves a driver's availability, creating it if it does not exist
+func (r *GormRideRepository) SaveDriverAvailability(availability *model.DriverAvailability) error {
+	indexDriverAvailability(availability)
+	return r.db.Save(availability).Error
+}
+
+// SetDriverCurrentTrip records the on-demand trip a driver is on, or that they are free again when
+// tripID is nil. It does not count as the driver reporting their availability.
+func (r *GormRideRepository) SetDriverCurrentTrip(driverID uuid.UUID, tripID *uuid.UUID) error {
+	return r.db.Model(&model.DriverAvailability{}).
+		Where("driver_id = ?", driverID).
+		UpdateColumn("current_trip_id", tripID).Error
+}
+
+// FindAvailableDrivers retrieves the online drivers within radiusKm of a location, nearest first.
+// Drivers who have not reported since seenSince are left out, as are busy drivers: those with a
+// trip offered to them, a ride in progress or an accepted on-demand trip they have not finished.
+func (r *GormRideRepository) FindAvailableDrivers(near model.Location, radiusKm float64, seenSince time.Time) ([]model.DriverAvailability, error) {
+	query := r.db.Where("online = ? AND updated_at >= ? AND current_trip_id IS NULL", true, seenSince.UTC()).
+		Where("driver_id NOT IN (SELECT driver_id FROM dispatches WHERE status = ?)", model.DispatchOffered).
+		Where(`driver_id NOT IN (SELECT driver_id FROM ride_offers WHERE status = ? OR (status = ? AND id IN (
+			SELECT ride_matches.ride_offer_id FROM ride_matches JOIN dispatches ON dispatches.ride_match_id = ride_matches.id)))`,
+			model.StatusInProgress, model.StatusConfirmed)
+	query = r.nearLocation(query, "location", near, radiusKm)
+
+	var candidates []model.DriverAvailability
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	return nearest(candidates, near, radiusKm), nil
+}
+
+// CreateDispatch adds a new dispatch to the database
+func (r *GormRideRepository) CreateDispatch(dispatch *model.Dispatch) error {
+	return r.db.Create(dispatch).Error
+}
+
+// FindDispatchByID retrieves a dispatch by ID
+func (r *GormRideRepository) FindDispatchByID(id uuid.UUID) (*model.Dispatch, error) {
+	var dispatch model.Dispatch
+	if err := r.db.Where("id = ?", id).First(&dispatch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &dispatch, nil
+}
+
+// FindDispatchesByRequestID retrieves every driver a ride request was offered to, in the order it was offered
+func (r *GormRideRepository) FindDispatchesByRequestID(requestID uuid.UUID) ([]model.Dispatch, error) {
+	var dispatches []model.Dispatch
+	if err := r.db.Where("ride_request_id = ?", requestID).Order("created_at").Find(&dispatches).Error; err != nil {
+		return nil, err
+	}
+	return dispatches, nil
+}
+
+// FindOpenDispatchesByDriverID retrieves the trips offered to a driver that wait for an answer
+func (r *GormRideRepository) FindOpenDispatchesByDriverID(driverID uuid.UUID) ([]model.Dispatch, error) {
+	var dispatches []model.Dispatch
+	err := r.db.Where("driver_id = ? AND status = ?", driverID, model.DispatchOffered).
+		Order("created_at").
+		Find(&dispatches).Error
+	if err != nil {
+		return nil, err
+	}
+	return dispatches, nil
+}
+
+// FindOverdueDispatches retrieves the open dispatches that expired before the given time
+func (r *GormRideRepository) FindOverdueDispatches(expiredBefore time.Time) ([]model.Dispatch, error) {
+	var dispatches []model.Dispatch
+	err := r.db.Where("status = ? AND expires_at < ?", model.DispatchOffered, expiredBefore.UTC()).
+		Order("expires_at").
+		Find(&dispatches).Error
+	if err != nil {
+		return nil, err
+	}
+	return dispatches, nil
+}
+
+// UpdateDispatch updates a dispatch in the database
+func (r *GormRideRepository) UpdateDispatch(dispatch *model.Dispatch) error {
+	return r.db.Save(dispatch).Error
+}
+
+// LockDriverAvailability retrieves a driver's availability and locks it for update
+func (r *GormRideRepository) LockDriverAvailability(driverID uuid.UUID) (*model.DriverAvailability, error) {
+	var availability model.DriverAvailability
+	if err := r.forUpdate().Where("driver_id = ?", driverID).First(&availability).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// LockDispatchByID retrieves a dispatch by ID and locks it for update
+func (r *GormRideRepository) LockDispatchByID(id uuid.UUID) (*model.Dispatch, error) {
+	var dispatch model.Dispatch
+	if err := r.forUpdate().Where("id = ?", id).First(&dispatch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &dispatch, nil
+}
+
+// nearest keeps the drivers within radiusKm of a location and sorts them nearest first
+func nearest(drivers []model.DriverAvailability, near model.Location, radiusKm float64) []model.DriverAvailability {
+	distances := make(map[uuid.UUID]float64, len(drivers))
+	nearby := make([]model.DriverAvailability, 0, len(drivers))
+	for _, driver := range drivers {
+		distance := geo.Distance(near.Latitude, near.Longitude, driver.Location.Latitude, driver.Location.Longitude)
+		if distance <= radiusKm {
+			distances[driver.DriverID] = distance
+			nearby = append(nearby, driver)
+		}
+	}
+	sort.Slice(nearby, func(i, j int) bool {
+		if distances[nearby[i].DriverID] != distances[nearby[j].DriverID] {
+			return distances[nearby[i].DriverID] < distances[nearby[j].DriverID]
+		}
+		return nearby[i].DriverID.String() < nearby[j].DriverID.String()
+	})
+	return nearby
+}
@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+// FindDriverAvailability retrieves a driver's availability, or nil if the driver never reported it
+func (r *RideRepository) FindDriverAvailability(driverID uuid.UUID) (*model.DriverAvailability, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	availability, ok := r.store.availability[driverID]
+	if !ok {
+		return nil, nil
+	}
+	return &availability, nil
+}
+
+// SaveDriverAvailability saves a driver's availability, creating it if it does not exist
+func (r *RideRepository) SaveDriverAvailability(availability *model.DriverAvailability) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := r.store.availability[availability.DriverID]; ok {
+		availability.CreatedAt = existing.CreatedAt
+	} else {
+		availability.CreatedAt = now
+	}
+	availability.UpdatedAt = now
+	r.store.availability[availability.DriverID] = *availability
+	return nil
+}
+
+// SetDriverCurrentTrip records the on-demand trip a driver is on, or that they are free again when
+// tripID is nil. It does not count as the driver reporting their availability.
+func (r *RideRepository) SetDriverCurrentTrip(driverID uuid.UUID, tripID *uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	availability, ok := r.store.availability[driverID]
+	if !ok {
+		return nil
+	}
+	availability.CurrentTripID = tripID
+	r.store.availability[driverID] = availability
+	return nil
+}
+
+// FindAvailableDrivers retrieves the online drivers within radiusKm of a location, nearest first.
+// Drivers who have not reported since seenSince are left out, as are busy drivers: those with a
+// trip offered to them, a ride in progress or an accepted on-demand trip they have not finished.
+func (r *RideRepository) FindAvailableDrivers(near model.Location, radiusKm float64, seenSince time.Time) ([]model.DriverAvailability, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	busy := make(map[uuid.UUID]bool)
+	dispatchedOffers := make(map[uuid.UUID]bool)
+	for _, dispatch := range r.store.dispatches {
+		if dispatch.Open() {
+			busy[dispatch.DriverID] = true
+		}
+		if dispatch.RideMatchID != nil {
+			if match, ok := r.store.matches[*dispatch.RideMatchID]; ok {
+				dispatchedOffers[match.RideOfferID] = true
+			}
+		}
+	}
+	for _, offer := range r.store.offers {
+		if offer.Status == model.StatusInProgress || (offer.Status == model.StatusConfirmed && dispatchedOffers[offer.ID]) {
+			busy[offer.DriverID] = true
+		}
+	}
+
+	distances := make(map[uuid.UUID]float64)
+	drivers := make([]model.DriverAvailability, 0)
+	for _, availability := range r.store.availability {
+		if !availability.Online || availability.UpdatedAt.Before(seenSince) || availability.CurrentTripID != nil || busy[availability.DriverID] {
+			continue
+		}
+		distance := geo.Distance(near.Latitude, near.Longitude, availability.Location.Latitude, availability.Location.Longitude)
+		if distance <= radiusKm {
+			distances[availability.DriverID] = distance
+			drivers = append(drivers, availability)
+		}
+	}
+	sort.Slice(drivers, func(i, j int) bool {
+		if distances[drivers[i].DriverID] != distances[drivers[j].DriverID] {
+			return distances[drivers[i].DriverID] < distances[drivers[j].DriverID]
+		}
+		return drivers[i].DriverID.String() < drivers[j].DriverID.String()
+	})
+	return drivers, nil
+}
+
+// CreateDispatch adds a new dispatch to the store
+func (r *RideRepository) CreateDispatch(dispatch *model.Dispatch) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := dispatch.BeforeCreate(); err != nil {
+		return err
+	}
+	if _, exists := r.store.dispatches[dispatch.ID]; exists {
+		return errors.New("dispatch already exists")
+	}
+
+	now := time.Now()
+	dispatch.CreatedAt = now
+	dispatch.UpdatedAt = now
+	r.store.dispatches[dispatch.ID] = *dispatch
+	return nil
+}
+
+// FindDispatchByID retrieves a dispatch by ID
+func (r *RideRepository) FindDispatchByID(id uuid.UUID) (*model.Dispatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	dispatch, ok := r.store.dispatches[id]
+	if !ok {
+		return nil, nil
+	}
+	return &dispatch, nil
+}
+
+// FindDispatchesByRequestID retrieves every driver a ride request was offered to, in the order it was offered
+func (r *RideRepository) FindDispatchesByRequestID(requestID uuid.UUID) ([]model.Dispatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.filterDispatches(func(dispatch model.Dispatch) bool {
+		return dispatch.RideRequestID == requestID
+	}), nil
+}
+
+// FindOpenDispatchesByDriverID retrieves the trips offered to a driver that wait for an answer
+func (r *RideRepository) FindOpenDispatchesByDriverID(driverID uuid.UUID) ([]model.Dispatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.filterDispatches(func(dispatch model.Dispatch) bool {
+		return dispatch.DriverID == driverID && dispatch.Open()
+	}), nil
+}
+
+// FindOverdueDispatches retrieves the open dispatches that expired before the given time
+func (r *RideRepository) FindOverdueDispatches(expiredBefore time.Time) ([]model.Dispatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	dispatches := r.filterDispatches(func(dispatch model.Dispatch) bool {
+		return dispatch.Open() && dispatch.ExpiresAt.Before(expiredBefore)
+	})
+	sort.SliceStable(dispatches, func(i, j int) bool {
+		return dispatches[i].ExpiresAt.Before(dispatches[j].ExpiresAt)
+	})
+	return dispatches, nil
+}
+
+// UpdateDispatch updates a dispatch in the store, creating it if it does not exist
+func (r *RideRepository) UpdateDispatch(dispatch *model.Dispatch) error {
+	if dispatch.ID == uuid.Nil {
+		return r.CreateDispatch(dispatch)
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	dispatch.UpdatedAt = time.Now()
+	r.store.dispatches[dispatch.ID] = *dispatch
+	return nil
+}
+
+// LockDriverAvailability retrieves a driver's availability.
+// Transactions are serialized, so no row lock is needed.
+func (r *RideRepository) LockDriverAvailability(driverID uuid.UUID) (*model.DriverAvailability, error) {
+	return r.FindDriverAvailability(driverID)
+}
+
+// LockDispatchByID retrieves a dispatch by ID.
+// Transactions are serialized, so no row lock is needed.
+func (r *RideRepository) LockDispatchByID(id uuid.UUID) (*model.Dispatch, error) {
+	return r.FindDispatchByID(id)
+}
+
+// filterDispatches returns the dispatches accepted by keep, oldest first. The caller must hold the lock.
+func (r *RideRepository) filterDispatches(keep func(model.Dispatch) bool) []model.Dispatch {
+	dispatches := make([]model.Dispatch, 0)
+	for _, dispatch := range r.store.dispatches {
+		if keep(dispatch) {
+			dispatches = append(dispatches, dispatch)
+		}
+	}
+	sort.Slice(dispatches, func(i, j int) bool {
+		return createdBefore(dispatches[i].CreatedAt, dispatches[i].ID, dispatches[j].CreatedAt, dispatches[j].ID)
+	})
+	return dispatches
+}
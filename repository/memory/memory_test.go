@@ -0,0 +1,27 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/repository/memory"
+	"github.com/yourusername/ride-sharing-app/repository/repotest"
+)
+
+func TestUserRepository(t *testing.T) {
+	repotest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+		return memory.NewUserRepository()
+	})
+}
+
+func TestRideRepository(t *testing.T) {
+	repotest.TestRideRepository(t, func(t *testing.T) repository.RideRepository {
+		return memory.NewRideRepository()
+	})
+}
+
+func TestWebhookRepository(t *testing.T) {
+	repotest.TestWebhookRepository(t, func(t *testing.T) repository.WebhookRepository {
+		return memory.NewWebhookRepository()
+	})
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+// CreateOutboxEvent adds a domain event to the outbox
+func (r *RideRepository) CreateOutboxEvent(event *model.OutboxEvent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := event.BeforeCreate(); err != nil {
+		return err
+	}
+	if err := event.BeforeSave(); err != nil {
+		return err
+	}
+	if _, exists := r.store.outbox[event.ID]; exists {
+		return errors.New("outbox event already exists")
+	}
+
+	r.store.outboxSequence++
+	event.Sequence = r.store.outboxSequence
+	event.CreatedAt = time.Now()
+	r.store.outbox[event.ID] = *event
+	return nil
+}
+
+// FindUnpublishedOutboxEvents retrieves up to limit pending events, in the order they were written
+func (r *RideRepository) FindUnpublishedOutboxEvents(limit int) ([]model.OutboxEvent, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	events := make([]model.OutboxEvent, 0)
+	for _, event := range r.store.outbox {
+		if event.Status == model.OutboxPending {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// UpdateOutboxEvent updates the delivery state of an outbox event, creating it if it does not exist
+func (r *RideRepository) UpdateOutboxEvent(event *model.OutboxEvent) error {
+	if event.ID == uuid.Nil {
+		return r.CreateOutboxEvent(event)
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := event.BeforeSave(); err != nil {
+		return err
+	}
+	r.store.outbox[event.ID] = *event
+	return nil
+}
+
+// DeleteOutboxEventsPublishedBefore removes the events delivered before the given time and returns how many it removed
+func (r *RideRepository) DeleteOutboxEventsPublishedBefore(publishedBefore time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for id, event := range r.store.outbox {
+		if event.PublishedAt != nil && event.PublishedAt.Before(publishedBefore) {
+			delete(r.store.outbox, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
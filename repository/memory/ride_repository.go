@@ -0,0 +1,697 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	repo "github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+// rideStore holds the rides shared by a RideRepository and its transactions
+type rideStore struct {
+	mu        sync.RWMutex
+	txMu      sync.Mutex
+	offers    map[uuid.UUID]model.RideOffer
+	requests  map[uuid.UUID]model.RideRequest
+	matches   map[uuid.UUID]model.RideMatch
+	stops     map[uuid.UUID][]model.RideStop
+	waypoints map[uuid.UUID][]model.RideWaypoint
+	// availability is keyed by driver ID
+	availability map[uuid.UUID]model.DriverAvailability
+	dispatches   map[uuid.UUID]model.Dispatch
+	// traces holds the GPS points of each ride offer in recording order
+	traces map[uuid.UUID][]model.TripLocation
+	outbox map[uuid.UUID]model.OutboxEvent
+	// outboxSequence is the sequence number of the last outbox event; like a database
+	// sequence it is not rolled back with a transaction
+	outboxSequence int64
+}
+
+// RideRepository is a thread-safe in-memory implementation of RideRepository.
+// Transactions are serialized with each other and roll back by restoring a snapshot.
+type RideRepository struct {
+	store *rideStore
+	inTx  bool
+}
+
+// NewRideRepository creates a new, empty in-memory RideRepository
+func NewRideRepository() repo.RideRepository {
+	return &RideRepository{
+		store: &rideStore{
+			offers:       make(map[uuid.UUID]model.RideOffer),
+			requests:     make(map[uuid.UUID]model.RideRequest),
+			matches:      make(map[uuid.UUID]model.RideMatch),
+			stops:        make(map[uuid.UUID][]model.RideStop),
+			waypoints:    make(map[uuid.UUID][]model.RideWaypoint),
+			availability: make(map[uuid.UUID]model.DriverAvailability),
+			dispatches:   make(map[uuid.UUID]model.Dispatch),
+			traces:       make(map[uuid.UUID][]model.TripLocation),
+			outbox:       make(map[uuid.UUID]model.OutboxEvent),
+		},
+	}
+}
+
+// CreateRideOffer adds a new ride offer to the store
+func (r *RideRepository) CreateRideOffer(offer *model.RideOffer) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := offer.BeforeCreate(); err != nil {
+		return err
+	}
+	if _, exists := r.store.offers[offer.ID]; exists {
+		return errors.New("ride offer already exists")
+	}
+	if offer.Status == "" {
+		offer.Status = model.StatusPending
+	}
+	if offer.AllowedDetourKm == 0 {
+		offer.AllowedDetourKm = 5
+	}
+
+	now := time.Now()
+	offer.CreatedAt = now
+	offer.UpdatedAt = now
+	r.store.offers[offer.ID] = *offer
+	return nil
+}
+
+// FindRideOfferByID retrieves a ride offer by ID
+func (r *RideRepository) FindRideOfferByID(id uuid.UUID) (*model.RideOffer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offer, ok := r.store.offers[id]
+	if !ok {
+		return nil, nil
+	}
+	return &offer, nil
+}
+
+// FindRideOffersByDriverID retrieves all ride offers by a specific driver
+func (r *RideRepository) FindRideOffersByDriverID(driverID uuid.UUID) ([]model.RideOffer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.filterOffers(func(offer model.RideOffer) bool {
+		return offer.DriverID == driverID
+	}), nil
+}
+
+// FindPendingRideOffers retrieves the pending ride offers departing after the given time, soonest first
+func (r *RideRepository) FindPendingRideOffers(departingAfter time.Time) ([]model.RideOffer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offers := r.filterOffers(func(offer model.RideOffer) bool {
+		return offer.Status == model.StatusPending && offer.DepartureTime.After(departingAfter)
+	})
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].DepartureTime.Before(offers[j].DepartureTime)
+	})
+	return offers, nil
+}
+
+// FindStaleRideOffers retrieves the pending and matched ride offers that departed before the given time
+func (r *RideRepository) FindStaleRideOffers(departedBefore time.Time) ([]model.RideOffer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offers := r.filterOffers(func(offer model.RideOffer) bool {
+		return (offer.Status == model.StatusPending || offer.Status == model.StatusMatched) &&
+			offer.DepartureTime.Before(departedBefore)
+	})
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].DepartureTime.Before(offers[j].DepartureTime)
+	})
+	return offers, nil
+}
+
+// SearchRideOffers retrieves the ride offers still taking passengers that fit a search, with their waypoints
+func (r *RideRepository) SearchRideOffers(search repo.RideOfferSearch) ([]model.RideOffer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offers := r.filterOffers(func(offer model.RideOffer) bool {
+		offer.Waypoints = r.store.waypoints[offer.ID]
+		return (offer.Status == model.StatusPending || offer.Status == model.StatusMatched ||
+			offer.Status == model.StatusConfirmed) &&
+			!offer.DepartureTime.Before(search.DepartingFrom) && !offer.DepartureTime.After(search.DepartingUntil) &&
+			offer.AvailableSeats >= search.Seats &&
+			(search.MaxPricePerSeat <= 0 || offer.PricePerSeat <= search.MaxPricePerSeat) &&
+			passesBy(&offer, &search)
+	})
+	for i := range offers {
+		offers[i].Waypoints = append([]model.RideWaypoint(nil), r.store.waypoints[offers[i].ID]...)
+	}
+	sort.Slice(offers, func(i, j int) bool {
+		return offerBefore(repo.CursorAt(&offers[i]), repo.CursorAt(&offers[j]), search.OrderBy)
+	})
+
+	// Skip the offers up to and including the cursor
+	if search.After != nil {
+		offers = offers[sort.Search(len(offers), func(i int) bool {
+			return offerBefore(search.After, repo.CursorAt(&offers[i]), search.OrderBy)
+		}):]
+	}
+	if search.Limit > 0 && len(offers) > search.Limit {
+		offers = offers[:search.Limit]
+	}
+	return offers, nil
+}
+
+// offerBefore orders ride offers in a search by departure time or price, then by ID
+func offerBefore(a, b *repo.RideOfferCursor, order repo.RideOfferOrder) bool {
+	if order == repo.OrderByPrice && a.PricePerSeat != b.PricePerSeat {
+		return a.PricePerSeat < b.PricePerSeat
+	}
+	if order != repo.OrderByPrice && !a.DepartureTime.Equal(b.DepartureTime) {
+		return a.DepartureTime.Before(b.DepartureTime)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// UpdateRideOffer updates a ride offer in the store, creating it if it does not exist
+func (r *RideRepository) UpdateRideOffer(offer *model.RideOffer) error {
+	if offer.ID == uuid.Nil {
+		return r.CreateRideOffer(offer)
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	offer.UpdatedAt = time.Now()
+	r.store.offers[offer.ID] = *offer
+	return nil
+}
+
+// DeleteRideOffer removes a ride offer from the store
+func (r *RideRepository) DeleteRideOffer(id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.offers, id)
+	return nil
+}
+
+// CreateRideRequest adds a new ride request to the store
+func (r *RideRepository) CreateRideRequest(request *model.RideRequest) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := request.BeforeCreate(); err != nil {
+		return err
+	}
+	if _, exists := r.store.requests[request.ID]; exists {
+		return errors.New("ride request already exists")
+	}
+	if request.Status == "" {
+		request.Status = model.StatusPending
+	}
+	if request.NumPassengers == 0 {
+		request.NumPassengers = 1
+	}
+	if request.Mode == "" {
+		request.Mode = model.ModeScheduled
+	}
+
+	now := time.Now()
+	request.CreatedAt = now
+	request.UpdatedAt = now
+	r.store.requests[request.ID] = *request
+	return nil
+}
+
+// FindRideRequestByID retrieves a ride request by ID
+func (r *RideRepository) FindRideRequestByID(id uuid.UUID) (*model.RideRequest, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	request, ok := r.store.requests[id]
+	if !ok {
+		return nil, nil
+	}
+	return &request, nil
+}
+
+// FindRideRequestsByPassengerID retrieves all ride requests by a specific passenger
+func (r *RideRepository) FindRideRequestsByPassengerID(passengerID uuid.UUID) ([]model.RideRequest, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.filterRequests(func(request model.RideRequest) bool {
+		return request.PassengerID == passengerID
+	}), nil
+}
+
+// FindPendingRideRequests retrieves the pending ride requests of a mode departing after the given time, soonest first
+func (r *RideRepository) FindPendingRideRequests(mode model.RideMode, departingAfter time.Time) ([]model.RideRequest, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	requests := r.filterRequests(func(request model.RideRequest) bool {
+		return request.Status == model.StatusPending && request.Mode == mode && request.DepartureTime.After(departingAfter)
+	})
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].DepartureTime.Before(requests[j].DepartureTime)
+	})
+	return requests, nil
+}
+
+// FindStaleRideRequests retrieves the pending and matched ride requests that departed before the given time
+func (r *RideRepository) FindStaleRideRequests(departedBefore time.Time) ([]model.RideRequest, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	requests := r.filterRequests(func(request model.RideRequest) bool {
+		return (request.Status == model.StatusPending || request.Status == model.StatusMatched) &&
+			request.DepartureTime.Before(departedBefore)
+	})
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].DepartureTime.Before(requests[j].DepartureTime)
+	})
+	return requests, nil
+}
+
+// UpdateRideRequest updates a ride request in the store, creating it if it does not exist
+func (r *RideRepository) UpdateRideRequest(request *model.RideRequest) error {
+	if request.ID == uuid.Nil {
+		return r.CreateRideRequest(request)
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	request.UpdatedAt = time.Now()
+	r.store.requests[request.ID] = *request
+	return nil
+}
+
+// DeleteRideRequest removes a ride request from the store
+func (r *RideRepository) DeleteRideRequest(id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.requests, id)
+	return nil
+}
+
+// CreateRideMatch adds a new ride match to the store.
+// It returns ErrDuplicateRideMatch if the offer and request already have a match.
+func (r *RideRepository) CreateRideMatch(match *model.RideMatch) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := match.BeforeCreate(); err != nil {
+		return err
+	}
+	if _, exists := r.store.matches[match.ID]; exists {
+		return errors.New("ride match already exists")
+	}
+	for _, existing := range r.store.matches {
+		if existing.RideOfferID == match.RideOfferID && existing.RideRequestID == match.RideRequestID {
+			return repo.ErrDuplicateRideMatch
+		}
+	}
+	if match.Status == "" {
+		match.Status = model.StatusMatched
+	}
+
+	now := time.Now()
+	match.CreatedAt = now
+	match.UpdatedAt = now
+	r.store.matches[match.ID] = *match
+	return nil
+}
+
+// FindRideMatchByID retrieves a ride match by ID
+func (r *RideRepository) FindRideMatchByID(id uuid.UUID) (*model.RideMatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	match, ok := r.store.matches[id]
+	if !ok {
+		return nil, nil
+	}
+	return &match, nil
+}
+
+// FindRideMatchesByOfferID retrieves all matches for a specific ride offer
+func (r *RideRepository) FindRideMatchesByOfferID(offerID uuid.UUID) ([]model.RideMatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.filterMatches(func(match model.RideMatch) bool {
+		return match.RideOfferID == offerID
+	}), nil
+}
+
+// FindRideMatchesByRequestID retrieves all matches for a specific ride request
+func (r *RideRepository) FindRideMatchesByRequestID(requestID uuid.UUID) ([]model.RideMatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.filterMatches(func(match model.RideMatch) bool {
+		return match.RideRequestID == requestID
+	}), nil
+}
+
+// FindRideMatchByOfferAndRequest retrieves the match between a specific ride offer and ride request
+func (r *RideRepository) FindRideMatchByOfferAndRequest(offerID, requestID uuid.UUID) (*model.RideMatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	matches := r.filterMatches(func(match model.RideMatch) bool {
+		return match.RideOfferID == offerID && match.RideRequestID == requestID
+	})
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	return &matches[0], nil
+}
+
+// FindStaleRideMatches retrieves the matches proposed before the given time that have not been confirmed
+func (r *RideRepository) FindStaleRideMatches(proposedBefore time.Time) ([]model.RideMatch, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	matches := r.filterMatches(func(match model.RideMatch) bool {
+		return match.Status == model.StatusMatched && match.CreatedAt.Before(proposedBefore)
+	})
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+	})
+	return matches, nil
+}
+
+// UpdateRideMatch updates a ride match in the store, creating it if it does not exist
+func (r *RideRepository) UpdateRideMatch(match *model.RideMatch) error {
+	if match.ID == uuid.Nil {
+		return r.CreateRideMatch(match)
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	match.UpdatedAt = time.Now()
+	r.store.matches[match.ID] = *match
+	return nil
+}
+
+// DeleteRideMatch removes a ride match from the store
+func (r *RideRepository) DeleteRideMatch(id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.matches, id)
+	return nil
+}
+
+// FindRideWaypointsByOfferID retrieves the waypoints of a ride offer in driving order
+func (r *RideRepository) FindRideWaypointsByOfferID(offerID uuid.UUID) ([]model.RideWaypoint, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return append([]model.RideWaypoint{}, r.store.waypoints[offerID]...), nil
+}
+
+// ReplaceRideWaypoints replaces the waypoints of a ride offer, numbering them in the given order.
+// Waypoint lists are replaced as a whole, so snapshots may share them.
+func (r *RideRepository) ReplaceRideWaypoints(offerID uuid.UUID, waypoints []model.RideWaypoint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for i := range waypoints {
+		waypoints[i].ID = uuid.Nil
+		if err := waypoints[i].BeforeCreate(); err != nil {
+			return err
+		}
+		waypoints[i].RideOfferID = offerID
+		waypoints[i].Sequence = i + 1
+		waypoints[i].CreatedAt = now
+	}
+
+	if len(waypoints) == 0 {
+		delete(r.store.waypoints, offerID)
+	} else {
+		r.store.waypoints[offerID] = append([]model.RideWaypoint{}, waypoints...)
+	}
+	return nil
+}
+
+// FindRideStopsByOfferID retrieves the stops of a ride offer in driving order
+func (r *RideRepository) FindRideStopsByOfferID(offerID uuid.UUID) ([]model.RideStop, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return append([]model.RideStop{}, r.store.stops[offerID]...), nil
+}
+
+// ReplaceRideStops replaces the stops of a ride offer, numbering them in the given order.
+// Stop lists are replaced as a whole, so snapshots may share them.
+func (r *RideRepository) ReplaceRideStops(offerID uuid.UUID, stops []model.RideStop) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for i := range stops {
+		stops[i].ID = uuid.Nil
+		if err := stops[i].BeforeCreate(); err != nil {
+			return err
+		}
+		stops[i].RideOfferID = offerID
+		stops[i].Sequence = i + 1
+		stops[i].CreatedAt = now
+	}
+
+	if len(stops) == 0 {
+		delete(r.store.stops, offerID)
+	} else {
+		r.store.stops[offerID] = append([]model.RideStop{}, stops...)
+	}
+	return nil
+}
+
+// FindPotentialMatches finds potential scheduled ride requests that match a ride offer,
+// departing no more than the candidates' window before or after it.
+// With straight-line candidates, the driver must be able to serve the request within the
+// offer's allowed detour and for no more than the passengers' maximum price.
+func (r *RideRepository) FindPotentialMatches(offerID uuid.UUID, candidates repo.MatchCandidates) ([]model.RideRequest, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offer, ok := r.store.offers[offerID]
+	if !ok {
+		return nil, errors.New("ride offer not found")
+	}
+
+	offer.Waypoints = r.store.waypoints[offerID]
+	startTime := offer.DepartureTime.Add(-candidates.Window)
+	endTime := offer.DepartureTime.Add(candidates.Window)
+
+	return r.filterRequests(func(request model.RideRequest) bool {
+		return (request.Status == model.StatusPending || request.Status == model.StatusMatched) &&
+			request.Mode == model.ModeScheduled &&
+			within(request.DepartureTime, startTime, endTime) &&
+			request.NumPassengers <= offer.AvailableSeats &&
+			(!candidates.StraightLine || withinDetour(&offer, &request))
+	}), nil
+}
+
+// FindPotentialOffers finds potential ride offers that match a ride request,
+// departing no more than the candidates' window before or after it.
+// With straight-line candidates, the driver must be able to serve the request within the
+// offer's allowed detour and for no more than the passengers' maximum price.
+func (r *RideRepository) FindPotentialOffers(requestID uuid.UUID, candidates repo.MatchCandidates) ([]model.RideOffer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	request, ok := r.store.requests[requestID]
+	if !ok {
+		return nil, errors.New("ride request not found")
+	}
+
+	startTime := request.DepartureTime.Add(-candidates.Window)
+	endTime := request.DepartureTime.Add(candidates.Window)
+
+	offers := r.filterOffers(func(offer model.RideOffer) bool {
+		offer.Waypoints = r.store.waypoints[offer.ID]
+		return (offer.Status == model.StatusPending || offer.Status == model.StatusMatched ||
+			offer.Status == model.StatusConfirmed) &&
+			within(offer.DepartureTime, startTime, endTime) &&
+			offer.AvailableSeats >= request.NumPassengers &&
+			(!candidates.StraightLine || withinDetour(&offer, &request))
+	})
+	for i := range offers {
+		offers[i].Waypoints = append([]model.RideWaypoint(nil), r.store.waypoints[offers[i].ID]...)
+	}
+	return offers, nil
+}
+
+// LockRideOfferByID retrieves a ride offer by ID.
+// Transactions are serialized, so no row lock is needed.
+func (r *RideRepository) LockRideOfferByID(id uuid.UUID) (*model.RideOffer, error) {
+	return r.FindRideOfferByID(id)
+}
+
+// LockRideRequestByID retrieves a ride request by ID.
+// Transactions are serialized, so no row lock is needed.
+func (r *RideRepository) LockRideRequestByID(id uuid.UUID) (*model.RideRequest, error) {
+	return r.FindRideRequestByID(id)
+}
+
+// LockRideMatchByID retrieves a ride match by ID.
+// Transactions are serialized, so no row lock is needed.
+func (r *RideRepository) LockRideMatchByID(id uuid.UUID) (*model.RideMatch, error) {
+	return r.FindRideMatchByID(id)
+}
+
+// WithTx runs fn as a single unit of work, restoring the previous state if it fails.
+// Calls made while a transaction is already open join that transaction.
+func (r *RideRepository) WithTx(fn func(repo.RideRepository) error) error {
+	if r.inTx {
+		return fn(r)
+	}
+
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+
+	snapshot := r.store.snapshot()
+	if err := fn(&RideRepository{store: r.store, inTx: true}); err != nil {
+		r.store.restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+// snapshot copies the current contents of the store
+func (s *rideStore) snapshot() *rideStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &rideStore{
+		offers:       copyMap(s.offers),
+		requests:     copyMap(s.requests),
+		matches:      copyMap(s.matches),
+		stops:        copyMap(s.stops),
+		waypoints:    copyMap(s.waypoints),
+		availability: copyMap(s.availability),
+		dispatches:   copyMap(s.dispatches),
+		traces:       copyMap(s.traces),
+		outbox:       copyMap(s.outbox),
+	}
+}
+
+// restore replaces the contents of the store with a snapshot
+func (s *rideStore) restore(snapshot *rideStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offers = snapshot.offers
+	s.requests = snapshot.requests
+	s.matches = snapshot.matches
+	s.stops = snapshot.stops
+	s.waypoints = snapshot.waypoints
+	s.availability = snapshot.availability
+	s.dispatches = snapshot.dispatches
+	s.traces = snapshot.traces
+	s.outbox = snapshot.outbox
+}
+
+// filterOffers returns the offers accepted by keep, oldest first. The caller must hold the lock.
+func (r *RideRepository) filterOffers(keep func(model.RideOffer) bool) []model.RideOffer {
+	offers := make([]model.RideOffer, 0)
+	for _, offer := range r.store.offers {
+		if keep(offer) {
+			offers = append(offers, offer)
+		}
+	}
+	sort.Slice(offers, func(i, j int) bool {
+		return createdBefore(offers[i].CreatedAt, offers[i].ID, offers[j].CreatedAt, offers[j].ID)
+	})
+	return offers
+}
+
+// filterRequests returns the requests accepted by keep, oldest first. The caller must hold the lock.
+func (r *RideRepository) filterRequests(keep func(model.RideRequest) bool) []model.RideRequest {
+	requests := make([]model.RideRequest, 0)
+	for _, request := range r.store.requests {
+		if keep(request) {
+			requests = append(requests, request)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return createdBefore(requests[i].CreatedAt, requests[i].ID, requests[j].CreatedAt, requests[j].ID)
+	})
+	return requests
+}
+
+// filterMatches returns the matches accepted by keep, oldest first. The caller must hold the lock.
+func (r *RideRepository) filterMatches(keep func(model.RideMatch) bool) []model.RideMatch {
+	matches := make([]model.RideMatch, 0)
+	for _, match := range r.store.matches {
+		if keep(match) {
+			matches = append(matches, match)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return createdBefore(matches[i].CreatedAt, matches[i].ID, matches[j].CreatedAt, matches[j].ID)
+	})
+	return matches
+}
+
+// createdBefore orders records by creation time, breaking ties by ID
+func createdBefore(createdA time.Time, idA uuid.UUID, createdB time.Time, idB uuid.UUID) bool {
+	if !createdA.Equal(createdB) {
+		return createdA.Before(createdB)
+	}
+	return idA.String() < idB.String()
+}
+
+// within reports whether t lies in the closed interval [start, end], like SQL BETWEEN
+func within(t, start, end time.Time) bool {
+	return !t.Before(start) && !t.After(end)
+}
+
+// copyMap returns a shallow copy of a map
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	copied := make(map[K]V, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+// withinDetour mirrors the straight-line detour and fare checks applied by the GORM candidate queries
+// for straight-line candidates
+func withinDetour(offer *model.RideOffer, request *model.RideRequest) bool {
+	route := make([]geo.Point, 0, len(offer.Waypoints)+2)
+	for _, location := range offer.Route() {
+		route = append(route, geo.Point{Latitude: location.Latitude, Longitude: location.Longitude})
+	}
+	pickup := geo.Point{Latitude: request.StartLocation.Latitude, Longitude: request.StartLocation.Longitude}
+	dropoff := geo.Point{Latitude: request.EndLocation.Latitude, Longitude: request.EndLocation.Longitude}
+	if geo.InsertionDetour(route, pickup, dropoff) > offer.AllowedDetourKm {
+		return false
+	}
+	fare := offer.Fare(request.NumPassengers, geo.PathLength(pickup, dropoff), geo.PathLength(route...))
+	return fare <= request.MaxPrice
+}
+
+// passesBy mirrors the route check applied by the GORM ride search
+func passesBy(offer *model.RideOffer, search *repo.RideOfferSearch) bool {
+	route := make([]geo.Point, 0, len(offer.Waypoints)+2)
+	for _, location := range offer.Route() {
+		route = append(route, geo.Point{Latitude: location.Latitude, Longitude: location.Longitude})
+	}
+	origin := geo.Point{Latitude: search.Origin.Latitude, Longitude: search.Origin.Longitude}
+	destination := geo.Point{Latitude: search.Destination.Latitude, Longitude: search.Destination.Longitude}
+	_, ok := geo.AccessDistance(route, origin, destination, search.RadiusKm)
+	return ok
+}
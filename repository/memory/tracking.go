@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+// CreateTripLocations adds the GPS points of a trip to the store.
+// Traces are replaced as a whole, so snapshots may share them.
+func (r *RideRepository) CreateTripLocations(locations []model.TripLocation) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for i := range locations {
+		if err := locations[i].BeforeCreate(); err != nil {
+			return err
+		}
+		if err := locations[i].BeforeSave(); err != nil {
+			return err
+		}
+		locations[i].CreatedAt = now
+	}
+
+	byOffer := make(map[uuid.UUID][]model.TripLocation)
+	for _, location := range locations {
+		byOffer[location.RideOfferID] = append(byOffer[location.RideOfferID], location)
+	}
+	for offerID, added := range byOffer {
+		trace := append(append([]model.TripLocation{}, r.store.traces[offerID]...), added...)
+		sort.SliceStable(trace, func(i, j int) bool {
+			// Ordered by recording time, then by ID like the database does
+			return createdBefore(trace[i].RecordedAt, trace[i].ID, trace[j].RecordedAt, trace[j].ID)
+		})
+		r.store.traces[offerID] = trace
+	}
+	return nil
+}
+
+// FindLatestTripLocation retrieves the most recently recorded GPS point of a ride offer, or nil if it has none
+func (r *RideRepository) FindLatestTripLocation(offerID uuid.UUID) (*model.TripLocation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	trace := r.store.traces[offerID]
+	if len(trace) == 0 {
+		return nil, nil
+	}
+	latest := trace[len(trace)-1]
+	return &latest, nil
+}
+
+// FindTripLocations retrieves the GPS points of a ride offer recorded after the given time, in recording order
+func (r *RideRepository) FindTripLocations(offerID uuid.UUID, recordedAfter time.Time) ([]model.TripLocation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	locations := make([]model.TripLocation, 0)
+	for _, location := range r.store.traces[offerID] {
+		if location.RecordedAt.After(recordedAfter) {
+			locations = append(locations, location)
+		}
+	}
+	return locations, nil
+}
+
+// DeleteTripLocationsBefore removes the GPS points recorded before the given time and returns how many it removed
+func (r *RideRepository) DeleteTripLocationsBefore(recordedBefore time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for offerID, trace := range r.store.traces {
+		kept := make([]model.TripLocation, 0, len(trace))
+		for _, location := range trace {
+			if location.RecordedAt.Before(recordedBefore) {
+				deleted++
+				continue
+			}
+			kept = append(kept, location)
+		}
+		if len(kept) == 0 {
+			delete(r.store.traces, offerID)
+		} else if len(kept) < len(trace) {
+			r.store.traces[offerID] = kept
+		}
+	}
+	return deleted, nil
+}
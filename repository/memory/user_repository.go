@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	repo "github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// UserRepository is a thread-safe in-memory implementation of UserRepository
+type UserRepository struct {
+	mu       sync.RWMutex
+	users    map[uuid.UUID]model.User
+	profiles map[uuid.UUID]model.DriverProfile
+	prefs    map[uuid.UUID]model.PassengerPreferences
+}
+
+// NewUserRepository creates a new, empty in-memory UserRepository
+func NewUserRepository() repo.UserRepository {
+	return &UserRepository{
+		users:    make(map[uuid.UUID]model.User),
+		profiles: make(map[uuid.UUID]model.DriverProfile),
+		prefs:    make(map[uuid.UUID]model.PassengerPreferences),
+	}
+}
+
+// Create adds a new user to the store
+func (r *UserRepository) Create(user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := user.BeforeCreate(); err != nil {
+		return err
+	}
+	if _, exists := r.users[user.ID]; exists {
+		return errors.New("user already exists")
+	}
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return errors.New("user with this email already exists")
+		}
+	}
+
+	now := time.Now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.users[user.ID] = *user
+	return nil
+}
+
+// FindByID retrieves a user by ID
+func (r *UserRepository) FindByID(id uuid.UUID) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// FindByEmail retrieves a user by email
+func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+// Update updates a user in the store, creating it if it does not exist
+func (r *UserRepository) Update(user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+// Delete removes a user from the store
+func (r *UserRepository) Delete(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, id)
+	return nil
+}
+
+// CreateDriverProfile adds a new driver profile to the store
+func (r *UserRepository) CreateDriverProfile(profile *model.DriverProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.profiles[profile.UserID]; exists {
+		return errors.New("driver profile already exists")
+	}
+
+	now := time.Now()
+	profile.CreatedAt = now
+	profile.UpdatedAt = now
+	r.profiles[profile.UserID] = *profile
+	return nil
+}
+
+// GetDriverProfile retrieves a driver profile by user ID
+func (r *UserRepository) GetDriverProfile(userID uuid.UUID) (*model.DriverProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profile, ok := r.profiles[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &profile, nil
+}
+
+// UpdateDriverProfile updates a driver profile in the store, creating it if it does not exist
+func (r *UserRepository) UpdateDriverProfile(profile *model.DriverProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = time.Now()
+	}
+	profile.UpdatedAt = time.Now()
+	r.profiles[profile.UserID] = *profile
+	return nil
+}
+
+// GetPassengerPreferences retrieves a passenger's match preferences by user ID
+func (r *UserRepository) GetPassengerPreferences(userID uuid.UUID) (*model.PassengerPreferences, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	preferences, ok := r.prefs[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &preferences, nil
+}
+
+// UpdatePassengerPreferences saves a passenger's match preferences, creating them if they do not exist
+func (r *UserRepository) UpdatePassengerPreferences(preferences *model.PassengerPreferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if preferences.CreatedAt.IsZero() {
+		preferences.CreatedAt = time.Now()
+	}
+	preferences.UpdatedAt = time.Now()
+	r.prefs[preferences.UserID] = *preferences
+	return nil
+}
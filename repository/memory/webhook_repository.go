@@ -0,0 +1,270 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	repo "github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// WebhookRepository is a thread-safe in-memory implementation of WebhookRepository
+type WebhookRepository struct {
+	mu            sync.RWMutex
+	subscriptions map[uuid.UUID]model.WebhookSubscription
+	deliveries    map[uuid.UUID]model.WebhookDelivery
+	attempts      map[uuid.UUID]model.WebhookAttempt
+}
+
+// NewWebhookRepository creates a new, empty in-memory WebhookRepository
+func NewWebhookRepository() repo.WebhookRepository {
+	return &WebhookRepository{
+		subscriptions: make(map[uuid.UUID]model.WebhookSubscription),
+		deliveries:    make(map[uuid.UUID]model.WebhookDelivery),
+		attempts:      make(map[uuid.UUID]model.WebhookAttempt),
+	}
+}
+
+// CreateSubscription adds a new webhook subscription to the store
+func (r *WebhookRepository) CreateSubscription(subscription *model.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := subscription.BeforeCreate(); err != nil {
+		return err
+	}
+	if _, exists := r.subscriptions[subscription.ID]; exists {
+		return errors.New("webhook subscription already exists")
+	}
+
+	now := time.Now()
+	subscription.CreatedAt = now
+	subscription.UpdatedAt = now
+	r.subscriptions[subscription.ID] = *subscription
+	return nil
+}
+
+// FindSubscriptionByID retrieves a webhook subscription by ID
+func (r *WebhookRepository) FindSubscriptionByID(id uuid.UUID) (*model.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &subscription, nil
+}
+
+// FindSubscriptions retrieves every webhook subscription, oldest first
+func (r *WebhookRepository) FindSubscriptions() ([]model.WebhookSubscription, error) {
+	return r.findSubscriptions(false)
+}
+
+// FindActiveSubscriptions retrieves the webhook subscriptions that are not paused
+func (r *WebhookRepository) FindActiveSubscriptions() ([]model.WebhookSubscription, error) {
+	return r.findSubscriptions(true)
+}
+
+// findSubscriptions retrieves the webhook subscriptions, only the active ones if activeOnly is set
+func (r *WebhookRepository) findSubscriptions(activeOnly bool) ([]model.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscriptions := make([]model.WebhookSubscription, 0)
+	for _, subscription := range r.subscriptions {
+		if activeOnly && !subscription.Active {
+			continue
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return createdBefore(subscriptions[i].CreatedAt, subscriptions[i].ID, subscriptions[j].CreatedAt, subscriptions[j].ID)
+	})
+	return subscriptions, nil
+}
+
+// UpdateSubscription updates a webhook subscription in the store, creating it if it does not exist
+func (r *WebhookRepository) UpdateSubscription(subscription *model.WebhookSubscription) error {
+	if subscription.ID == uuid.Nil {
+		return r.CreateSubscription(subscription)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscription.UpdatedAt = time.Now()
+	r.subscriptions[subscription.ID] = *subscription
+	return nil
+}
+
+// DeleteSubscription removes a webhook subscription together with its deliveries and their attempts
+func (r *WebhookRepository) DeleteSubscription(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.SubscriptionID != id {
+			continue
+		}
+		for attemptID, attempt := range r.attempts {
+			if attempt.DeliveryID == deliveryID {
+				delete(r.attempts, attemptID)
+			}
+		}
+		delete(r.deliveries, deliveryID)
+	}
+	delete(r.subscriptions, id)
+	return nil
+}
+
+// CreateDelivery adds a new webhook delivery to the store
+func (r *WebhookRepository) CreateDelivery(delivery *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := delivery.BeforeCreate(); err != nil {
+		return err
+	}
+	if err := delivery.BeforeSave(); err != nil {
+		return err
+	}
+	if _, exists := r.deliveries[delivery.ID]; exists {
+		return errors.New("webhook delivery already exists")
+	}
+	for _, existing := range r.deliveries {
+		if existing.SubscriptionID == delivery.SubscriptionID && existing.EventID == delivery.EventID {
+			return errors.New("a webhook delivery already exists for this subscription and event")
+		}
+	}
+
+	now := time.Now()
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+// FindDeliveryByID retrieves a webhook delivery by ID
+func (r *WebhookRepository) FindDeliveryByID(id uuid.UUID) (*model.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, nil
+	}
+	return &delivery, nil
+}
+
+// FindDeliveryByEvent retrieves the delivery of an event to a webhook subscription
+func (r *WebhookRepository) FindDeliveryByEvent(subscriptionID, eventID uuid.UUID) (*model.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionID == subscriptionID && delivery.EventID == eventID {
+			return &delivery, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindDeliveriesBySubscriptionID retrieves up to limit deliveries of a subscription, newest first;
+// an empty status matches every delivery
+func (r *WebhookRepository) FindDeliveriesBySubscriptionID(subscriptionID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := make([]model.WebhookDelivery, 0)
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionID == subscriptionID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return createdBefore(deliveries[j].CreatedAt, deliveries[j].ID, deliveries[i].CreatedAt, deliveries[i].ID)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// FindDueDeliveries retrieves up to limit pending deliveries due by the given time, the longest due first.
+// Deliveries to paused webhooks are held back until the webhook is resumed.
+func (r *WebhookRepository) FindDueDeliveries(dueBy time.Time, limit int) ([]model.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := make([]model.WebhookDelivery, 0)
+	for _, delivery := range r.deliveries {
+		if !r.subscriptions[delivery.SubscriptionID].Active {
+			continue
+		}
+		if delivery.Status == model.WebhookPending && !delivery.NextAttemptAt.After(dueBy) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return createdBefore(deliveries[i].NextAttemptAt, deliveries[i].ID, deliveries[j].NextAttemptAt, deliveries[j].ID)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery updates the state of a webhook delivery, creating it if it does not exist
+func (r *WebhookRepository) UpdateDelivery(delivery *model.WebhookDelivery) error {
+	if delivery.ID == uuid.Nil {
+		return r.CreateDelivery(delivery)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := delivery.BeforeSave(); err != nil {
+		return err
+	}
+	delivery.UpdatedAt = time.Now()
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+// CreateAttempt records an attempt at posting a webhook delivery
+func (r *WebhookRepository) CreateAttempt(attempt *model.WebhookAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := attempt.BeforeCreate(); err != nil {
+		return err
+	}
+	if _, exists := r.attempts[attempt.ID]; exists {
+		return errors.New("webhook attempt already exists")
+	}
+
+	attempt.CreatedAt = time.Now()
+	r.attempts[attempt.ID] = *attempt
+	return nil
+}
+
+// FindAttemptsByDeliveryID retrieves the attempts at posting a webhook delivery, oldest first
+func (r *WebhookRepository) FindAttemptsByDeliveryID(deliveryID uuid.UUID) ([]model.WebhookAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attempts := make([]model.WebhookAttempt, 0)
+	for _, attempt := range r.attempts {
+		if attempt.DeliveryID == deliveryID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	sort.Slice(attempts, func(i, j int) bool {
+		return createdBefore(attempts[i].CreatedAt, attempts[i].ID, attempts[j].CreatedAt, attempts[j].ID)
+	})
+	return attempts, nil
+}
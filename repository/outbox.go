@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+// CreateOutboxEvent adds a domain event to the outbox
+func (r *GormRideRepository) CreateOutboxEvent(event *model.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
+
+// FindUnpublishedOutboxEvents retrieves up to limit pending events, in the order they were written
+func (r *GormRideRepository) FindUnpublishedOutboxEvents(limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.db.Where("status = ?", model.OutboxPending).
+		Order("sequence").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// UpdateOutboxEvent updates the delivery state of an outbox event
+func (r *GormRideRepository) UpdateOutboxEvent(event *model.OutboxEvent) error {
+	return r.db.Save(event).Error
+}
+
+// DeleteOutboxEventsPublishedBefore removes the events delivered before the given time and returns how many it removed
+func (r *GormRideRepository) DeleteOutboxEventsPublishedBefore(publishedBefore time.Time) (int64, error) {
+	result := r.db.Where("published_at < ?", publishedBefore.UTC()).Delete(&model.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,91 @@
+package repository_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/database"
+	gormrepo "github.com/yourusername/ride-sharing-app/repository"
+	"github.com/yourusername/ride-sharing-app/repository/repotest"
+)
+
+// postgresURLEnv names the variable holding the connection string of a Postgres database to run the
+// suites against as well. The database is emptied before every subtest, so never point it at real data.
+const postgresURLEnv = "APP_TEST_POSTGRES_URL"
+
+func TestGormRepositoriesOnSQLite(t *testing.T) {
+	runSuites(t, func(t *testing.T) *gorm.DB {
+		return openMigrated(t, database.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	})
+}
+
+func TestGormRepositoriesOnPostgres(t *testing.T) {
+	url := os.Getenv(postgresURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set", postgresURLEnv)
+	}
+	runSuites(t, func(t *testing.T) *gorm.DB {
+		db := openMigrated(t, database.DriverPostgres, url)
+		truncateTables(t, db)
+		return db
+	})
+}
+
+// runSuites runs every repository conformance suite against databases opened by openDB
+func runSuites(t *testing.T, openDB func(t *testing.T) *gorm.DB) {
+	t.Run("Users", func(t *testing.T) {
+		repotest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+			return gormrepo.NewGormUserRepository(openDB(t))
+		})
+	})
+	t.Run("Rides", func(t *testing.T) {
+		repotest.TestRideRepository(t, func(t *testing.T) repository.RideRepository {
+			return gormrepo.NewGormRideRepository(openDB(t))
+		})
+	})
+	t.Run("Webhooks", func(t *testing.T) {
+		repotest.TestWebhookRepository(t, func(t *testing.T) repository.WebhookRepository {
+			return gormrepo.NewGormWebhookRepository(openDB(t))
+		})
+	})
+}
+
+// openMigrated opens a database, applies every migration and closes it when the test ends
+func openMigrated(t *testing.T, driver, url string) *gorm.DB {
+	t.Helper()
+	db, err := database.InitDB(driver, url)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.LogMode(false)
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
+
+// truncateTables empties every table of a Postgres database except the migration record
+func truncateTables(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	var tables []string
+	err := db.Raw(`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+		AND table_name NOT IN ('schema_migrations', 'spatial_ref_sys')`).Pluck("table_name", &tables).Error
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	for _, table := range tables {
+		if err := db.Exec(`TRUNCATE TABLE "` + table + `" CASCADE`).Error; err != nil {
+			t.Fatalf("truncating %s: %v", table, err)
+		}
+	}
+}
@@ -0,0 +1,932 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// errRollback is returned from transactions that are expected to roll back
+var errRollback = errors.New("rollback")
+
+// window is the departure time window the match finding subtests search within
+const window = 30 * time.Minute
+
+// straightLine selects the match candidates within the window by straight-line detour and fare
+var straightLine = repository.MatchCandidates{Window: window, StraightLine: true}
+
+// TestRideRepository runs the RideRepository conformance suite.
+// newRepo is called once per subtest and must return an empty repository.
+func TestRideRepository(t *testing.T, newRepo func(t *testing.T) repository.RideRepository) {
+	departure := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	t.Run("RideOffers", func(t *testing.T) {
+		repo := newRepo(t)
+		driverID := uuid.New()
+		offer := newOffer(driverID, departure)
+
+		if err := repo.CreateRideOffer(offer); err != nil {
+			t.Fatalf("CreateRideOffer: %v", err)
+		}
+		if offer.ID == uuid.Nil {
+			t.Fatal("CreateRideOffer did not assign an ID")
+		}
+
+		found, err := repo.FindRideOfferByID(offer.ID)
+		if err != nil || found == nil {
+			t.Fatalf("FindRideOfferByID = %+v, %v", found, err)
+		}
+		if found.StartLocation != offer.StartLocation || found.EndLocation != offer.EndLocation {
+			t.Fatalf("FindRideOfferByID locations = %+v -> %+v, want %+v -> %+v",
+				found.StartLocation, found.EndLocation, offer.StartLocation, offer.EndLocation)
+		}
+
+		found.AvailableSeats = 1
+		if err := repo.UpdateRideOffer(found); err != nil {
+			t.Fatalf("UpdateRideOffer: %v", err)
+		}
+		offers, err := repo.FindRideOffersByDriverID(driverID)
+		if err != nil || len(offers) != 1 || offers[0].AvailableSeats != 1 {
+			t.Fatalf("FindRideOffersByDriverID = %+v, %v", offers, err)
+		}
+
+		if err := repo.DeleteRideOffer(offer.ID); err != nil {
+			t.Fatalf("DeleteRideOffer: %v", err)
+		}
+		found, err = repo.FindRideOfferByID(offer.ID)
+		if err != nil || found != nil {
+			t.Fatalf("FindRideOfferByID after delete = %+v, %v, want nil, nil", found, err)
+		}
+	})
+
+	t.Run("RideRequests", func(t *testing.T) {
+		repo := newRepo(t)
+		passengerID := uuid.New()
+		request := newRequest(passengerID, departure)
+
+		if err := repo.CreateRideRequest(request); err != nil {
+			t.Fatalf("CreateRideRequest: %v", err)
+		}
+		if request.ID == uuid.Nil {
+			t.Fatal("CreateRideRequest did not assign an ID")
+		}
+
+		request.Status = model.StatusMatched
+		if err := repo.UpdateRideRequest(request); err != nil {
+			t.Fatalf("UpdateRideRequest: %v", err)
+		}
+		requests, err := repo.FindRideRequestsByPassengerID(passengerID)
+		if err != nil || len(requests) != 1 || requests[0].Status != model.StatusMatched {
+			t.Fatalf("FindRideRequestsByPassengerID = %+v, %v", requests, err)
+		}
+
+		if err := repo.DeleteRideRequest(request.ID); err != nil {
+			t.Fatalf("DeleteRideRequest: %v", err)
+		}
+		found, err := repo.FindRideRequestByID(request.ID)
+		if err != nil || found != nil {
+			t.Fatalf("FindRideRequestByID after delete = %+v, %v, want nil, nil", found, err)
+		}
+	})
+
+	t.Run("RideMatches", func(t *testing.T) {
+		repo := newRepo(t)
+		offer := newOffer(uuid.New(), departure)
+		request := newRequest(uuid.New(), departure)
+		mustCreate(t, repo, offer, request)
+
+		match := &model.RideMatch{
+			RideOfferID:   offer.ID,
+			RideRequestID: request.ID,
+			Status:        model.StatusMatched,
+			MatchScore:    0.9,
+			Price:         offer.PricePerSeat,
+			ScoreBreakdown: &model.MatchBreakdown{
+				Score:      0.9,
+				PriceScore: 1,
+				TimeScore:  0.8,
+				DetourKm:   1.5,
+				Fare:       offer.PricePerSeat,
+			},
+		}
+		if err := repo.CreateRideMatch(match); err != nil {
+			t.Fatalf("CreateRideMatch: %v", err)
+		}
+
+		byOffer, err := repo.FindRideMatchesByOfferID(offer.ID)
+		if err != nil || len(byOffer) != 1 || byOffer[0].ID != match.ID {
+			t.Fatalf("FindRideMatchesByOfferID = %+v, %v", byOffer, err)
+		}
+		byRequest, err := repo.FindRideMatchesByRequestID(request.ID)
+		if err != nil || len(byRequest) != 1 || byRequest[0].ID != match.ID {
+			t.Fatalf("FindRideMatchesByRequestID = %+v, %v", byRequest, err)
+		}
+		byPair, err := repo.FindRideMatchByOfferAndRequest(offer.ID, request.ID)
+		if err != nil || byPair == nil || byPair.ID != match.ID {
+			t.Fatalf("FindRideMatchByOfferAndRequest = %+v, %v", byPair, err)
+		}
+		missing, err := repo.FindRideMatchByOfferAndRequest(offer.ID, uuid.New())
+		if err != nil || missing != nil {
+			t.Fatalf("FindRideMatchByOfferAndRequest for unknown pair = %+v, %v, want nil, nil", missing, err)
+		}
+
+		duplicate := &model.RideMatch{RideOfferID: offer.ID, RideRequestID: request.ID, MatchScore: 0.7, Price: 1}
+		if err := repo.CreateRideMatch(duplicate); !errors.Is(err, repository.ErrDuplicateRideMatch) {
+			t.Fatalf("CreateRideMatch for a matched pair = %v, want ErrDuplicateRideMatch", err)
+		}
+
+		now := time.Now()
+		match.DriverAcceptedAt = &now
+		if err := repo.UpdateRideMatch(match); err != nil {
+			t.Fatalf("UpdateRideMatch: %v", err)
+		}
+		found, err := repo.FindRideMatchByID(match.ID)
+		if err != nil || found == nil || !found.DriverAccepted() || found.PassengerAccepted() {
+			t.Fatalf("FindRideMatchByID = %+v, %v", found, err)
+		}
+		if found.ScoreBreakdown == nil || *found.ScoreBreakdown != *match.ScoreBreakdown {
+			t.Fatalf("FindRideMatchByID breakdown = %+v, want %+v", found.ScoreBreakdown, match.ScoreBreakdown)
+		}
+
+		if err := repo.DeleteRideMatch(match.ID); err != nil {
+			t.Fatalf("DeleteRideMatch: %v", err)
+		}
+		found, err = repo.FindRideMatchByID(match.ID)
+		if err != nil || found != nil {
+			t.Fatalf("FindRideMatchByID after delete = %+v, %v, want nil, nil", found, err)
+		}
+	})
+
+	t.Run("RideStops", func(t *testing.T) {
+		repo := newRepo(t)
+		offer := newOffer(uuid.New(), departure)
+		first := newRequest(uuid.New(), departure)
+		second := newRequest(uuid.New(), departure)
+		mustCreate(t, repo, offer, first, second)
+
+		stops := []model.RideStop{
+			{RideRequestID: first.ID, Type: model.StopPickup, Location: first.StartLocation},
+			{RideRequestID: second.ID, Type: model.StopPickup, Location: second.StartLocation},
+			{RideRequestID: first.ID, Type: model.StopDropoff, Location: first.EndLocation},
+			{RideRequestID: second.ID, Type: model.StopDropoff, Location: second.EndLocation},
+		}
+		if err := repo.ReplaceRideStops(offer.ID, stops); err != nil {
+			t.Fatalf("ReplaceRideStops: %v", err)
+		}
+		found, err := repo.FindRideStopsByOfferID(offer.ID)
+		if err != nil || len(found) != len(stops) {
+			t.Fatalf("FindRideStopsByOfferID = %+v, %v", found, err)
+		}
+		for i, stop := range found {
+			if stop.Sequence != i+1 || stop.RideRequestID != stops[i].RideRequestID || stop.Type != stops[i].Type {
+				t.Fatalf("FindRideStopsByOfferID[%d] = %+v, want %+v at sequence %d", i, stop, stops[i], i+1)
+			}
+		}
+		if found[0].Location != first.StartLocation {
+			t.Fatalf("FindRideStopsByOfferID[0].Location = %+v, want %+v", found[0].Location, first.StartLocation)
+		}
+
+		if err := repo.ReplaceRideStops(offer.ID, stops[1:2]); err != nil {
+			t.Fatalf("ReplaceRideStops: %v", err)
+		}
+		found, err = repo.FindRideStopsByOfferID(offer.ID)
+		if err != nil || len(found) != 1 || found[0].Sequence != 1 || found[0].RideRequestID != second.ID {
+			t.Fatalf("FindRideStopsByOfferID after replace = %+v, %v", found, err)
+		}
+
+		if err := repo.ReplaceRideStops(offer.ID, nil); err != nil {
+			t.Fatalf("ReplaceRideStops: %v", err)
+		}
+		found, err = repo.FindRideStopsByOfferID(offer.ID)
+		if err != nil || len(found) != 0 {
+			t.Fatalf("FindRideStopsByOfferID after clear = %+v, %v", found, err)
+		}
+	})
+
+	t.Run("FindPendingRides", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+
+		later := newOffer(uuid.New(), departure.Add(time.Hour))
+		sooner := newOffer(uuid.New(), departure)
+		departed := newOffer(uuid.New(), now.Add(-time.Hour))
+		matched := newOffer(uuid.New(), departure)
+		matched.Status = model.StatusMatched
+		pending := newRequest(uuid.New(), departure)
+		departedRequest := newRequest(uuid.New(), now.Add(-time.Hour))
+		cancelled := newRequest(uuid.New(), departure)
+		cancelled.Status = model.StatusCancelled
+		onDemand := newRequest(uuid.New(), departure)
+		onDemand.Mode = model.ModeOnDemand
+		mustCreate(t, repo, later, sooner, departed, matched, pending, departedRequest, cancelled, onDemand)
+
+		offers, err := repo.FindPendingRideOffers(now)
+		if err != nil || len(offers) != 2 || offers[0].ID != sooner.ID || offers[1].ID != later.ID {
+			t.Fatalf("FindPendingRideOffers = %+v, %v, want %s then %s", offers, err, sooner.ID, later.ID)
+		}
+		requests, err := repo.FindPendingRideRequests(model.ModeScheduled, now)
+		if err != nil || len(requests) != 1 || requests[0].ID != pending.ID {
+			t.Fatalf("FindPendingRideRequests = %+v, %v, want only %s", requests, err, pending.ID)
+		}
+		requests, err = repo.FindPendingRideRequests(model.ModeOnDemand, now)
+		if err != nil || len(requests) != 1 || requests[0].ID != onDemand.ID {
+			t.Fatalf("FindPendingRideRequests on demand = %+v, %v, want only %s", requests, err, onDemand.ID)
+		}
+	})
+
+	t.Run("FindStaleRides", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+
+		upcoming := newOffer(uuid.New(), departure)
+		departed := newOffer(uuid.New(), now.Add(-time.Hour))
+		departedMatched := newOffer(uuid.New(), now.Add(-2*time.Hour))
+		departedMatched.Status = model.StatusMatched
+		departedConfirmed := newOffer(uuid.New(), now.Add(-time.Hour))
+		departedConfirmed.Status = model.StatusConfirmed
+		departedRequest := newRequest(uuid.New(), now.Add(-time.Hour))
+		upcomingRequest := newRequest(uuid.New(), departure)
+		cancelled := newRequest(uuid.New(), now.Add(-time.Hour))
+		cancelled.Status = model.StatusCancelled
+		mustCreate(t, repo, upcoming, departed, departedMatched, departedConfirmed, departedRequest, upcomingRequest, cancelled)
+
+		offers, err := repo.FindStaleRideOffers(now)
+		if err != nil || len(offers) != 2 || offers[0].ID != departedMatched.ID || offers[1].ID != departed.ID {
+			t.Fatalf("FindStaleRideOffers = %+v, %v, want %s then %s", offers, err, departedMatched.ID, departed.ID)
+		}
+		requests, err := repo.FindStaleRideRequests(now)
+		if err != nil || len(requests) != 1 || requests[0].ID != departedRequest.ID {
+			t.Fatalf("FindStaleRideRequests = %+v, %v, want only %s", requests, err, departedRequest.ID)
+		}
+
+		proposed := &model.RideMatch{RideOfferID: upcoming.ID, RideRequestID: upcomingRequest.ID, MatchScore: 0.9, Price: 1}
+		confirmed := &model.RideMatch{RideOfferID: departed.ID, RideRequestID: upcomingRequest.ID, MatchScore: 0.9, Price: 1,
+			Status: model.StatusConfirmed}
+		mustCreate(t, repo, proposed, confirmed)
+
+		matches, err := repo.FindStaleRideMatches(time.Now().Add(time.Minute))
+		if err != nil || len(matches) != 1 || matches[0].ID != proposed.ID {
+			t.Fatalf("FindStaleRideMatches = %+v, %v, want only %s", matches, err, proposed.ID)
+		}
+		matches, err = repo.FindStaleRideMatches(now.Add(-time.Minute))
+		if err != nil || len(matches) != 0 {
+			t.Fatalf("FindStaleRideMatches before the match was proposed = %+v, %v, want none", matches, err)
+		}
+	})
+
+	t.Run("FindPotentialMatches", func(t *testing.T) {
+		repo := newRepo(t)
+		offer := newOffer(uuid.New(), departure)
+
+		inWindow := newRequest(uuid.New(), departure.Add(20*time.Minute))
+		tooLate := newRequest(uuid.New(), departure.Add(45*time.Minute))
+		tooMany := newRequest(uuid.New(), departure)
+		tooMany.NumPassengers = offer.AvailableSeats + 1
+		alreadyMatched := newRequest(uuid.New(), departure)
+		alreadyMatched.Status = model.StatusMatched
+		notOpen := newRequest(uuid.New(), departure)
+		notOpen.Status = model.StatusConfirmed
+		tooFar := newRequest(uuid.New(), departure)
+		tooFar.StartLocation = model.Location{Latitude: 6.0535, Longitude: 80.2210, Address: "Galle"}
+		wrongWay := newRequest(uuid.New(), departure)
+		wrongWay.EndLocation = model.Location{Latitude: 7.2083, Longitude: 79.8358, Address: "Negombo"}
+		onTheWay := newRequest(uuid.New(), departure)
+		onTheWay.StartLocation = model.Location{Latitude: 7.1089, Longitude: 80.2475, Address: "Halfway"}
+		onDemand := newRequest(uuid.New(), departure)
+		onDemand.Mode = model.ModeOnDemand
+		mustCreate(t, repo, offer, inWindow, tooLate, tooMany, alreadyMatched, notOpen, tooFar, wrongWay, onTheWay, onDemand)
+
+		requests, err := repo.FindPotentialMatches(offer.ID, straightLine)
+		if err != nil {
+			t.Fatalf("FindPotentialMatches: %v", err)
+		}
+		found := make(map[uuid.UUID]bool, len(requests))
+		for _, request := range requests {
+			found[request.ID] = true
+		}
+		if len(requests) != 3 || !found[inWindow.ID] || !found[alreadyMatched.ID] || !found[onTheWay.ID] {
+			t.Fatalf("FindPotentialMatches = %d requests, want %s, %s and %s",
+				len(requests), inWindow.ID, alreadyMatched.ID, onTheWay.ID)
+		}
+
+		requests, err = repo.FindPotentialMatches(offer.ID, repository.MatchCandidates{Window: time.Hour, StraightLine: true})
+		if err != nil || len(requests) != 4 {
+			t.Fatalf("FindPotentialMatches within an hour = %d requests, %v, want 4 including %s", len(requests), err, tooLate.ID)
+		}
+
+		// Road routes may take in requests that straight lines rule out, so only time and seats count
+		requests, err = repo.FindPotentialMatches(offer.ID, repository.MatchCandidates{Window: window})
+		if err != nil || len(requests) != 5 {
+			t.Fatalf("FindPotentialMatches without straight lines = %d requests, %v, want 5 including %s and %s",
+				len(requests), err, tooFar.ID, wrongWay.ID)
+		}
+
+		if _, err := repo.FindPotentialMatches(uuid.New(), straightLine); err == nil {
+			t.Fatal("FindPotentialMatches for an unknown offer succeeded")
+		}
+	})
+
+	t.Run("FindPotentialOffers", func(t *testing.T) {
+		repo := newRepo(t)
+		request := newRequest(uuid.New(), departure)
+		request.NumPassengers = 2
+		request.MaxPrice = 2000
+
+		inWindow := newOffer(uuid.New(), departure.Add(-20*time.Minute))
+		tooEarly := newOffer(uuid.New(), departure.Add(-45*time.Minute))
+		tooExpensive := newOffer(uuid.New(), departure)
+		tooExpensive.PricePerSeat = 1500
+		tooFewSeats := newOffer(uuid.New(), departure)
+		tooFewSeats.AvailableSeats = 1
+		alreadyPooling := newOffer(uuid.New(), departure)
+		alreadyPooling.Status = model.StatusConfirmed
+		notOpen := newOffer(uuid.New(), departure)
+		notOpen.Status = model.StatusInProgress
+		tooFar := newOffer(uuid.New(), departure)
+		tooFar.StartLocation = model.Location{Latitude: 6.0535, Longitude: 80.2210, Address: "Galle"}
+		wideDetour := newOffer(uuid.New(), departure.Add(10*time.Minute))
+		wideDetour.StartLocation = model.Location{Latitude: 6.8390, Longitude: 79.8650, Address: "Dehiwala"}
+		wideDetour.AllowedDetourKm = 15
+		narrowDetour := newOffer(uuid.New(), departure)
+		narrowDetour.StartLocation = wideDetour.StartLocation
+		startsAfterPickup := newOffer(uuid.New(), departure)
+		startsAfterPickup.StartLocation = model.Location{Latitude: 7.1089, Longitude: 80.2475, Address: "Halfway"}
+		mustCreate(t, repo, request, inWindow, tooEarly, tooExpensive, tooFewSeats, alreadyPooling, notOpen,
+			tooFar, wideDetour, narrowDetour, startsAfterPickup)
+
+		offers, err := repo.FindPotentialOffers(request.ID, straightLine)
+		if err != nil {
+			t.Fatalf("FindPotentialOffers: %v", err)
+		}
+		found := make(map[uuid.UUID]bool, len(offers))
+		for _, offer := range offers {
+			found[offer.ID] = true
+		}
+		if len(offers) != 3 || !found[inWindow.ID] || !found[alreadyPooling.ID] || !found[wideDetour.ID] {
+			t.Fatalf("FindPotentialOffers = %d offers, want %s, %s and %s",
+				len(offers), inWindow.ID, alreadyPooling.ID, wideDetour.ID)
+		}
+
+		offers, err = repo.FindPotentialOffers(request.ID, repository.MatchCandidates{Window: time.Hour, StraightLine: true})
+		if err != nil || len(offers) != 4 {
+			t.Fatalf("FindPotentialOffers within an hour = %d offers, %v, want 4 including %s", len(offers), err, tooEarly.ID)
+		}
+
+		offers, err = repo.FindPotentialOffers(request.ID, repository.MatchCandidates{Window: window})
+		if err != nil || len(offers) != 7 {
+			t.Fatalf("FindPotentialOffers without straight lines = %d offers, %v, want 7 including %s and %s",
+				len(offers), err, narrowDetour.ID, tooExpensive.ID)
+		}
+
+		// Passengers boarding far from where the driver sets off are picked up on the way
+		halfway := newRequest(uuid.New(), departure)
+		halfway.StartLocation = startsAfterPickup.StartLocation
+		mustCreate(t, repo, halfway)
+		offers, err = repo.FindPotentialOffers(halfway.ID, straightLine)
+		if err != nil {
+			t.Fatalf("FindPotentialOffers: %v", err)
+		}
+		found = make(map[uuid.UUID]bool, len(offers))
+		for _, offer := range offers {
+			found[offer.ID] = true
+		}
+		if !found[inWindow.ID] {
+			t.Fatalf("FindPotentialOffers from halfway = %d offers without %s, which passes there", len(offers), inWindow.ID)
+		}
+
+		if _, err := repo.FindPotentialOffers(uuid.New(), straightLine); err == nil {
+			t.Fatal("FindPotentialOffers for an unknown request succeeded")
+		}
+	})
+
+	t.Run("Waypoints", func(t *testing.T) {
+		repo := newRepo(t)
+		offer := newOffer(uuid.New(), departure)
+		mustCreate(t, repo, offer)
+
+		kurunegala := model.Location{Latitude: 7.4863, Longitude: 80.3647, Address: "Kurunegala"}
+		waypoints := []model.RideWaypoint{{Location: kurunegala}}
+		if err := repo.ReplaceRideWaypoints(offer.ID, waypoints); err != nil {
+			t.Fatalf("ReplaceRideWaypoints: %v", err)
+		}
+		found, err := repo.FindRideWaypointsByOfferID(offer.ID)
+		if err != nil || len(found) != 1 || found[0].Sequence != 1 || found[0].Location != kurunegala {
+			t.Fatalf("FindRideWaypointsByOfferID = %+v, %v", found, err)
+		}
+
+		// Passengers may board at a waypoint off the straight line and pay for their segment only
+		fromWaypoint := newRequest(uuid.New(), departure)
+		fromWaypoint.StartLocation = kurunegala
+		fromWaypoint.MaxPrice = 300
+		tooCheap := newRequest(uuid.New(), departure)
+		tooCheap.MaxPrice = 300
+		mustCreate(t, repo, fromWaypoint, tooCheap)
+
+		requests, err := repo.FindPotentialMatches(offer.ID, straightLine)
+		if err != nil || len(requests) != 1 || requests[0].ID != fromWaypoint.ID {
+			t.Fatalf("FindPotentialMatches = %+v, %v, want only %s", requests, err, fromWaypoint.ID)
+		}
+		offers, err := repo.FindPotentialOffers(fromWaypoint.ID, straightLine)
+		if err != nil || len(offers) != 1 || offers[0].ID != offer.ID {
+			t.Fatalf("FindPotentialOffers = %+v, %v, want only %s", offers, err, offer.ID)
+		}
+
+		// Saving the offer without loading its waypoints keeps its route through them
+		locked, err := repo.LockRideOfferByID(offer.ID)
+		if err != nil || locked == nil {
+			t.Fatalf("LockRideOfferByID = %+v, %v", locked, err)
+		}
+		locked.AvailableSeats--
+		if err := repo.UpdateRideOffer(locked); err != nil {
+			t.Fatalf("UpdateRideOffer: %v", err)
+		}
+		offers, err = repo.FindPotentialOffers(fromWaypoint.ID, straightLine)
+		if err != nil || len(offers) != 1 || offers[0].ID != offer.ID {
+			t.Fatalf("FindPotentialOffers after updating = %+v, %v, want only %s", offers, err, offer.ID)
+		}
+
+		if err := repo.ReplaceRideWaypoints(offer.ID, nil); err != nil {
+			t.Fatalf("ReplaceRideWaypoints: %v", err)
+		}
+		requests, err = repo.FindPotentialMatches(offer.ID, straightLine)
+		if err != nil || len(requests) != 0 {
+			t.Fatalf("FindPotentialMatches without waypoints = %+v, %v, want none", requests, err)
+		}
+	})
+
+	t.Run("SearchRideOffers", func(t *testing.T) {
+		repo := newRepo(t)
+		direct := newOffer(uuid.New(), departure)
+		viaKurunegala := newOffer(uuid.New(), departure.Add(time.Hour))
+		expensive := newOffer(uuid.New(), departure)
+		expensive.PricePerSeat = 2000
+		full := newOffer(uuid.New(), departure)
+		full.AvailableSeats = 1
+		tooLate := newOffer(uuid.New(), departure.Add(48*time.Hour))
+		cancelled := newOffer(uuid.New(), departure)
+		cancelled.Status = model.StatusCancelled
+		mustCreate(t, repo, direct, viaKurunegala, expensive, full, tooLate, cancelled)
+
+		kurunegala := model.Location{Latitude: 7.4863, Longitude: 80.3647, Address: "Kurunegala"}
+		if err := repo.ReplaceRideWaypoints(viaKurunegala.ID, []model.RideWaypoint{{Location: kurunegala}}); err != nil {
+			t.Fatalf("ReplaceRideWaypoints: %v", err)
+		}
+
+		search := repository.RideOfferSearch{
+			Origin:          model.Location{Latitude: 6.9300, Longitude: 79.8650},
+			Destination:     model.Location{Latitude: 7.2950, Longitude: 80.6350},
+			RadiusKm:        2,
+			DepartingFrom:   departure.Add(-time.Hour),
+			DepartingUntil:  departure.Add(2 * time.Hour),
+			Seats:           2,
+			MaxPricePerSeat: 1000,
+		}
+		offers, err := repo.SearchRideOffers(search)
+		if err != nil || len(offers) != 2 || offers[0].ID != direct.ID || offers[1].ID != viaKurunegala.ID {
+			t.Fatalf("SearchRideOffers = %+v, %v, want %s then %s", offers, err, direct.ID, viaKurunegala.ID)
+		}
+		if len(offers[1].Waypoints) != 1 {
+			t.Fatalf("SearchRideOffers waypoints = %+v, want Kurunegala", offers[1].Waypoints)
+		}
+
+		// Passengers may board at a waypoint, but never travel against the route
+		search.Origin = kurunegala
+		offers, err = repo.SearchRideOffers(search)
+		if err != nil || len(offers) != 1 || offers[0].ID != viaKurunegala.ID {
+			t.Fatalf("SearchRideOffers from a waypoint = %+v, %v, want only %s", offers, err, viaKurunegala.ID)
+		}
+		search.Origin, search.Destination = search.Destination, kurunegala
+		offers, err = repo.SearchRideOffers(search)
+		if err != nil || len(offers) != 0 {
+			t.Fatalf("SearchRideOffers against the route = %+v, %v, want none", offers, err)
+		}
+	})
+
+	t.Run("SearchRideOffersPages", func(t *testing.T) {
+		repo := newRepo(t)
+		dearest := newOffer(uuid.New(), departure)
+		dearest.PricePerSeat = 900
+		cheap, alsoCheap := newOffer(uuid.New(), departure.Add(time.Hour)), newOffer(uuid.New(), departure.Add(2*time.Hour))
+		cheap.PricePerSeat, alsoCheap.PricePerSeat = 500, 500
+		// Equal prices are ordered by ID
+		cheap.ID, alsoCheap.ID = uuid.New(), uuid.New()
+		if alsoCheap.ID.String() < cheap.ID.String() {
+			cheap.ID, alsoCheap.ID = alsoCheap.ID, cheap.ID
+		}
+		middle := newOffer(uuid.New(), departure.Add(30*time.Minute))
+		middle.PricePerSeat = 700
+		mustCreate(t, repo, dearest, cheap, alsoCheap, middle)
+
+		// The cheapest offers stop near the origin only after stopping near the destination, so they
+		// are left out after being read
+		kandy := model.Location{Latitude: 7.2950, Longitude: 80.6350, Address: "Kandy Town"}
+		colombo := model.Location{Latitude: 6.9300, Longitude: 79.8650, Address: "Colombo Fort"}
+		for i := 0; i < 3; i++ {
+			backwards := newOffer(uuid.New(), departure)
+			backwards.StartLocation, backwards.EndLocation = backwards.EndLocation, backwards.StartLocation
+			backwards.PricePerSeat = 100
+			mustCreate(t, repo, backwards)
+			if err := repo.ReplaceRideWaypoints(backwards.ID, []model.RideWaypoint{{Location: kandy}, {Location: colombo}}); err != nil {
+				t.Fatalf("ReplaceRideWaypoints: %v", err)
+			}
+		}
+
+		search := repository.RideOfferSearch{
+			Origin:         colombo,
+			Destination:    kandy,
+			RadiusKm:       2,
+			DepartingFrom:  departure.Add(-time.Hour),
+			DepartingUntil: departure.Add(3 * time.Hour),
+			Seats:          1,
+			OrderBy:        repository.OrderByPrice,
+			Limit:          2,
+		}
+		for _, page := range [][]*model.RideOffer{{cheap, alsoCheap}, {middle, dearest}, {}} {
+			offers, err := repo.SearchRideOffers(search)
+			if err != nil || len(offers) != len(page) {
+				t.Fatalf("SearchRideOffers after %+v = %+v, %v, want %d offers", search.After, offers, err, len(page))
+			}
+			for i, offer := range page {
+				if offers[i].ID != offer.ID {
+					t.Fatalf("SearchRideOffers after %+v = offer %d %s, want %s", search.After, i+1, offers[i].ID, offer.ID)
+				}
+			}
+			if len(offers) > 0 {
+				search.After = repository.CursorAt(&offers[len(offers)-1])
+			}
+		}
+
+		// Sorted by departure, the next page starts right after an offer with the same departure time
+		search.OrderBy, search.After, search.Limit = repository.OrderByDeparture, repository.CursorAt(dearest), 0
+		offers, err := repo.SearchRideOffers(search)
+		if err != nil || len(offers) != 3 || offers[0].ID != middle.ID || offers[1].ID != cheap.ID || offers[2].ID != alsoCheap.ID {
+			t.Fatalf("SearchRideOffers by departure = %+v, %v, want %s, %s then %s", offers, err, middle.ID, cheap.ID, alsoCheap.ID)
+		}
+	})
+
+	t.Run("DriverAvailability", func(t *testing.T) {
+		repo := newRepo(t)
+		pickup := model.Location{Latitude: 6.9271, Longitude: 79.8612, Address: "Colombo"}
+
+		if found, err := repo.FindDriverAvailability(uuid.New()); err != nil || found != nil {
+			t.Fatalf("FindDriverAvailability for an unknown driver = %+v, %v, want nil, nil", found, err)
+		}
+
+		near := newAvailability(uuid.New(), 6.9300, 79.8650)
+		nearer := newAvailability(uuid.New(), 6.9280, 79.8620)
+		tooFar := newAvailability(uuid.New(), 7.2906, 80.6337)
+		offline := newAvailability(uuid.New(), 6.9271, 79.8612)
+		offline.Online = false
+		dispatched := newAvailability(uuid.New(), 6.9271, 79.8612)
+		driving := newAvailability(uuid.New(), 6.9271, 79.8612)
+		for _, availability := range []*model.DriverAvailability{near, nearer, tooFar, offline, dispatched, driving} {
+			if err := repo.SaveDriverAvailability(availability); err != nil {
+				t.Fatalf("SaveDriverAvailability: %v", err)
+			}
+		}
+
+		// Drivers with a trip offered to them or a ride in progress are busy
+		request := newRequest(uuid.New(), departure)
+		request.Mode = model.ModeOnDemand
+		inProgress := newOffer(driving.DriverID, departure)
+		inProgress.Status = model.StatusInProgress
+		mustCreate(t, repo, request, inProgress)
+		dispatch := &model.Dispatch{
+			RideRequestID: request.ID,
+			DriverID:      dispatched.DriverID,
+			Status:        model.DispatchOffered,
+			DistanceKm:    0.1,
+			ExpiresAt:     time.Now().Add(-time.Minute),
+		}
+		if err := repo.CreateDispatch(dispatch); err != nil {
+			t.Fatalf("CreateDispatch: %v", err)
+		}
+
+		drivers, err := repo.FindAvailableDrivers(pickup, 5, time.Now().Add(-time.Minute))
+		if err != nil || len(drivers) != 2 || drivers[0].DriverID != nearer.DriverID || drivers[1].DriverID != near.DriverID {
+			t.Fatalf("FindAvailableDrivers = %+v, %v, want %s then %s", drivers, err, nearer.DriverID, near.DriverID)
+		}
+		drivers, err = repo.FindAvailableDrivers(pickup, 5, time.Now().Add(time.Minute))
+		if err != nil || len(drivers) != 0 {
+			t.Fatalf("FindAvailableDrivers seen later = %+v, %v, want none", drivers, err)
+		}
+
+		// Going offline is saved over the previous availability
+		near.Online = false
+		if err := repo.SaveDriverAvailability(near); err != nil {
+			t.Fatalf("SaveDriverAvailability offline: %v", err)
+		}
+		found, err := repo.FindDriverAvailability(near.DriverID)
+		if err != nil || found == nil || found.Online || found.Location.Latitude != near.Location.Latitude {
+			t.Fatalf("FindDriverAvailability after going offline = %+v, %v", found, err)
+		}
+
+		// The dispatch is open and overdue until it is answered
+		open, err := repo.FindOpenDispatchesByDriverID(dispatched.DriverID)
+		if err != nil || len(open) != 1 || open[0].ID != dispatch.ID {
+			t.Fatalf("FindOpenDispatchesByDriverID = %+v, %v, want %s", open, err, dispatch.ID)
+		}
+		overdue, err := repo.FindOverdueDispatches(time.Now())
+		if err != nil || len(overdue) != 1 || overdue[0].ID != dispatch.ID {
+			t.Fatalf("FindOverdueDispatches = %+v, %v, want %s", overdue, err, dispatch.ID)
+		}
+		err = repo.WithTx(func(tx repository.RideRepository) error {
+			locked, err := tx.LockDispatchByID(dispatch.ID)
+			if err != nil || locked == nil {
+				t.Fatalf("LockDispatchByID = %+v, %v", locked, err)
+			}
+			if availability, err := tx.LockDriverAvailability(dispatched.DriverID); err != nil || availability == nil {
+				t.Fatalf("LockDriverAvailability = %+v, %v", availability, err)
+			}
+			locked.Status = model.DispatchTimedOut
+			return tx.UpdateDispatch(locked)
+		})
+		if err != nil {
+			t.Fatalf("WithTx: %v", err)
+		}
+		if open, err := repo.FindOpenDispatchesByDriverID(dispatched.DriverID); err != nil || len(open) != 0 {
+			t.Fatalf("FindOpenDispatchesByDriverID after timing out = %+v, %v, want none", open, err)
+		}
+		if overdue, err := repo.FindOverdueDispatches(time.Now()); err != nil || len(overdue) != 0 {
+			t.Fatalf("FindOverdueDispatches after timing out = %+v, %v, want none", overdue, err)
+		}
+		dispatches, err := repo.FindDispatchesByRequestID(request.ID)
+		if err != nil || len(dispatches) != 1 || dispatches[0].Status != model.DispatchTimedOut {
+			t.Fatalf("FindDispatchesByRequestID = %+v, %v", dispatches, err)
+		}
+
+		// A driver whose accepted on-demand trip has not started yet is busy as well
+		trip := newOffer(dispatched.DriverID, departure)
+		trip.Status = model.StatusConfirmed
+		mustCreate(t, repo, trip)
+		match := &model.RideMatch{RideOfferID: trip.ID, RideRequestID: request.ID, Status: model.StatusConfirmed}
+		mustCreate(t, repo, match)
+		accepted := &model.Dispatch{
+			RideRequestID: request.ID,
+			DriverID:      dispatched.DriverID,
+			Status:        model.DispatchAccepted,
+			ExpiresAt:     time.Now().Add(time.Minute),
+			RideMatchID:   &match.ID,
+		}
+		if err := repo.CreateDispatch(accepted); err != nil {
+			t.Fatalf("CreateDispatch: %v", err)
+		}
+		drivers, err = repo.FindAvailableDrivers(pickup, 5, time.Now().Add(-time.Minute))
+		if err != nil || len(drivers) != 1 || drivers[0].DriverID != nearer.DriverID {
+			t.Fatalf("FindAvailableDrivers with an accepted trip = %+v, %v, want only %s", drivers, err, nearer.DriverID)
+		}
+
+		// So is a driver on a trip, until they are free again
+		if err := repo.SetDriverCurrentTrip(nearer.DriverID, &trip.ID); err != nil {
+			t.Fatalf("SetDriverCurrentTrip: %v", err)
+		}
+		found, err = repo.FindDriverAvailability(nearer.DriverID)
+		if err != nil || found == nil || found.CurrentTripID == nil || *found.CurrentTripID != trip.ID {
+			t.Fatalf("FindDriverAvailability on a trip = %+v, %v, want trip %s", found, err, trip.ID)
+		}
+		if drivers, err := repo.FindAvailableDrivers(pickup, 5, time.Now().Add(-time.Minute)); err != nil || len(drivers) != 0 {
+			t.Fatalf("FindAvailableDrivers with a driver on a trip = %+v, %v, want none", drivers, err)
+		}
+		if err := repo.SetDriverCurrentTrip(nearer.DriverID, nil); err != nil {
+			t.Fatalf("SetDriverCurrentTrip free: %v", err)
+		}
+		drivers, err = repo.FindAvailableDrivers(pickup, 5, time.Now().Add(-time.Minute))
+		if err != nil || len(drivers) != 1 || drivers[0].DriverID != nearer.DriverID || drivers[0].CurrentTripID != nil {
+			t.Fatalf("FindAvailableDrivers after the trip = %+v, %v, want only %s", drivers, err, nearer.DriverID)
+		}
+	})
+
+	t.Run("TripLocations", func(t *testing.T) {
+		repo := newRepo(t)
+		offerID := uuid.New()
+		start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		if latest, err := repo.FindLatestTripLocation(offerID); err != nil || latest != nil {
+			t.Fatalf("FindLatestTripLocation without points = %+v, %v, want nil, nil", latest, err)
+		}
+
+		// Points uploaded late are kept in recording order
+		speed := 42.5
+		points := []model.TripLocation{
+			{RideOfferID: offerID, Latitude: 6.9300, Longitude: 79.8650, RecordedAt: start.Add(2 * time.Minute), SpeedKmh: &speed},
+			{RideOfferID: offerID, Latitude: 6.9271, Longitude: 79.8612, RecordedAt: start},
+		}
+		late := []model.TripLocation{
+			{RideOfferID: offerID, Latitude: 6.9280, Longitude: 79.8620, RecordedAt: start.Add(time.Minute)},
+			{RideOfferID: uuid.New(), Latitude: 7.2906, Longitude: 80.6337, RecordedAt: start},
+		}
+		for _, batch := range [][]model.TripLocation{points, late} {
+			if err := repo.CreateTripLocations(batch); err != nil {
+				t.Fatalf("CreateTripLocations: %v", err)
+			}
+		}
+
+		trace, err := repo.FindTripLocations(offerID, time.Time{})
+		if err != nil || len(trace) != 3 || trace[0].ID != points[1].ID || trace[1].ID != late[0].ID || trace[2].ID != points[0].ID {
+			t.Fatalf("FindTripLocations = %+v, %v, want the 3 points of the offer in recording order", trace, err)
+		}
+		if trace[2].SpeedKmh == nil || *trace[2].SpeedKmh != speed || trace[0].SpeedKmh != nil {
+			t.Fatalf("FindTripLocations speeds = %v, %v, want %v and none", trace[2].SpeedKmh, trace[0].SpeedKmh, speed)
+		}
+		trace, err = repo.FindTripLocations(offerID, start.Add(time.Minute))
+		if err != nil || len(trace) != 1 || trace[0].ID != points[0].ID {
+			t.Fatalf("FindTripLocations after a minute = %+v, %v, want %s", trace, err, points[0].ID)
+		}
+		latest, err := repo.FindLatestTripLocation(offerID)
+		if err != nil || latest == nil || latest.ID != points[0].ID {
+			t.Fatalf("FindLatestTripLocation = %+v, %v, want %s", latest, err, points[0].ID)
+		}
+
+		deleted, err := repo.DeleteTripLocationsBefore(start.Add(time.Minute))
+		if err != nil || deleted != 2 {
+			t.Fatalf("DeleteTripLocationsBefore = %d, %v, want 2", deleted, err)
+		}
+		trace, err = repo.FindTripLocations(offerID, time.Time{})
+		if err != nil || len(trace) != 2 || trace[0].ID != late[0].ID {
+			t.Fatalf("FindTripLocations after deleting = %+v, %v, want 2 points from %s", trace, err, late[0].ID)
+		}
+	})
+
+	t.Run("OutboxEvents", func(t *testing.T) {
+		repo := newRepo(t)
+		offerID := uuid.New()
+
+		var written []*model.OutboxEvent
+		for _, eventType := range []model.DomainEventType{model.EventRideOfferCreated, model.EventRideStarted, model.EventRideCompleted} {
+			event := &model.OutboxEvent{
+				Type:        eventType,
+				AggregateID: offerID,
+				Payload:     model.EventPayload(`{"id":"` + offerID.String() + `"}`),
+				OccurredAt:  time.Now(),
+			}
+			if err := repo.CreateOutboxEvent(event); err != nil {
+				t.Fatalf("CreateOutboxEvent: %v", err)
+			}
+			written = append(written, event)
+		}
+		// Events written within the same clock tick are still numbered in order
+		for i := 1; i < len(written); i++ {
+			if written[i].Sequence <= written[i-1].Sequence {
+				t.Fatalf("sequences %d then %d, want them increasing", written[i-1].Sequence, written[i].Sequence)
+			}
+		}
+		if written[0].Status != model.OutboxPending {
+			t.Fatalf("new event status = %s, want pending", written[0].Status)
+		}
+
+		events, err := repo.FindUnpublishedOutboxEvents(2)
+		if err != nil || len(events) != 2 || events[0].ID != written[0].ID || events[1].ID != written[1].ID {
+			t.Fatalf("FindUnpublishedOutboxEvents(2) = %+v, %v, want the 2 first events", events, err)
+		}
+		if events[0].Type != model.EventRideOfferCreated || string(events[0].Payload) != string(written[0].Payload) {
+			t.Fatalf("FindUnpublishedOutboxEvents()[0] = %s %s, want %s %s", events[0].Type, events[0].Payload, written[0].Type, written[0].Payload)
+		}
+
+		publishedAt := time.Now().Add(-time.Hour)
+		events[0].Status = model.OutboxPublished
+		events[0].PublishedAt = &publishedAt
+		events[1].Attempts = 1
+		events[1].LastError = "sink unavailable"
+		written[2].Status = model.OutboxDead
+		for _, event := range []*model.OutboxEvent{&events[0], &events[1], written[2]} {
+			if err := repo.UpdateOutboxEvent(event); err != nil {
+				t.Fatalf("UpdateOutboxEvent: %v", err)
+			}
+		}
+		events, err = repo.FindUnpublishedOutboxEvents(10)
+		if err != nil || len(events) != 1 || events[0].ID != written[1].ID || events[0].Attempts != 1 || events[0].LastError != "sink unavailable" {
+			t.Fatalf("FindUnpublishedOutboxEvents after publishing = %+v, %v, want only the failed event, without the dead one", events, err)
+		}
+		if events[0].Sequence != written[1].Sequence {
+			t.Fatalf("sequence after updating = %d, want %d", events[0].Sequence, written[1].Sequence)
+		}
+
+		// Dead events are kept for inspection
+		deleted, err := repo.DeleteOutboxEventsPublishedBefore(time.Now())
+		if err != nil || deleted != 1 {
+			t.Fatalf("DeleteOutboxEventsPublishedBefore = %d, %v, want 1", deleted, err)
+		}
+	})
+
+	t.Run("WithTxCommits", func(t *testing.T) {
+		repo := newRepo(t)
+		offer := newOffer(uuid.New(), departure)
+		mustCreate(t, repo, offer)
+
+		err := repo.WithTx(func(tx repository.RideRepository) error {
+			locked, err := tx.LockRideOfferByID(offer.ID)
+			if err != nil || locked == nil {
+				t.Fatalf("LockRideOfferByID = %+v, %v", locked, err)
+			}
+			locked.AvailableSeats--
+			return tx.UpdateRideOffer(locked)
+		})
+		if err != nil {
+			t.Fatalf("WithTx: %v", err)
+		}
+
+		found, err := repo.FindRideOfferByID(offer.ID)
+		if err != nil || found == nil || found.AvailableSeats != offer.AvailableSeats-1 {
+			t.Fatalf("FindRideOfferByID after commit = %+v, %v", found, err)
+		}
+	})
+
+	t.Run("WithTxRollsBack", func(t *testing.T) {
+		repo := newRepo(t)
+		offer := newOffer(uuid.New(), departure)
+		mustCreate(t, repo, offer)
+		request := newRequest(uuid.New(), departure)
+
+		err := repo.WithTx(func(tx repository.RideRepository) error {
+			// Nested units of work join the outer transaction
+			return tx.WithTx(func(tx repository.RideRepository) error {
+				locked, err := tx.LockRideOfferByID(offer.ID)
+				if err != nil || locked == nil {
+					t.Fatalf("LockRideOfferByID = %+v, %v", locked, err)
+				}
+				locked.AvailableSeats = 0
+				if err := tx.UpdateRideOffer(locked); err != nil {
+					return err
+				}
+				if err := tx.CreateRideRequest(request); err != nil {
+					return err
+				}
+				return errRollback
+			})
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("WithTx = %v, want %v", err, errRollback)
+		}
+
+		found, err := repo.FindRideOfferByID(offer.ID)
+		if err != nil || found == nil || found.AvailableSeats != offer.AvailableSeats {
+			t.Fatalf("FindRideOfferByID after rollback = %+v, %v", found, err)
+		}
+		missing, err := repo.FindRideRequestByID(request.ID)
+		if err != nil || missing != nil {
+			t.Fatalf("FindRideRequestByID after rollback = %+v, %v, want nil, nil", missing, err)
+		}
+	})
+}
+
+// newOffer builds a pending Colombo to Kandy ride offer
+func newOffer(driverID uuid.UUID, departure time.Time) *model.RideOffer {
+	return &model.RideOffer{
+		DriverID:        driverID,
+		StartLocation:   model.Location{Latitude: 6.9271, Longitude: 79.8612, Address: "Colombo"},
+		EndLocation:     model.Location{Latitude: 7.2906, Longitude: 80.6337, Address: "Kandy"},
+		DepartureTime:   departure,
+		AvailableSeats:  3,
+		Status:          model.StatusPending,
+		PricePerSeat:    800,
+		AllowedDetourKm: 5,
+	}
+}
+
+// newRequest builds a pending Colombo to Kandy ride request for one passenger
+func newRequest(passengerID uuid.UUID, departure time.Time) *model.RideRequest {
+	return &model.RideRequest{
+		PassengerID:   passengerID,
+		StartLocation: model.Location{Latitude: 6.9300, Longitude: 79.8650, Address: "Colombo Fort"},
+		EndLocation:   model.Location{Latitude: 7.2950, Longitude: 80.6350, Address: "Kandy Town"},
+		DepartureTime: departure,
+		NumPassengers: 1,
+		Status:        model.StatusPending,
+		MaxPrice:      1000,
+		Mode:          model.ModeScheduled,
+	}
+}
+
+// newAvailability builds an online driver at the given position
+func newAvailability(driverID uuid.UUID, latitude, longitude float64) *model.DriverAvailability {
+	return &model.DriverAvailability{
+		DriverID: driverID,
+		Online:   true,
+		Location: model.Location{Latitude: latitude, Longitude: longitude},
+	}
+}
+
+// mustCreate stores ride offers, requests and matches, failing the test on error
+func mustCreate(t *testing.T, repo repository.RideRepository, rides ...interface{}) {
+	t.Helper()
+	for _, ride := range rides {
+		var err error
+		switch ride := ride.(type) {
+		case *model.RideOffer:
+			err = repo.CreateRideOffer(ride)
+		case *model.RideRequest:
+			err = repo.CreateRideRequest(ride)
+		case *model.RideMatch:
+			err = repo.CreateRideMatch(ride)
+		default:
+			t.Fatalf("mustCreate: unsupported type %T", ride)
+		}
+		if err != nil {
+			t.Fatalf("mustCreate: %v", err)
+		}
+	}
+}
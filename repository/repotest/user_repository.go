@@ -0,0 +1,197 @@
+// Package repotest is a conformance suite for implementations of the domain
+// repository interfaces. Every backend is expected to pass it, so services
+// behave the same whichever storage they run on. Backends run it from their
+// own tests, passing a constructor that returns an empty repository:
+//
+//	func TestRideRepository(t *testing.T) {
+//		repotest.TestRideRepository(t, func(t *testing.T) repository.RideRepository {
+//			return memory.NewRideRepository()
+//		})
+//	}
+package repotest
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// TestUserRepository runs the UserRepository conformance suite.
+// newRepo is called once per subtest and must return an empty repository.
+func TestUserRepository(t *testing.T, newRepo func(t *testing.T) repository.UserRepository) {
+	t.Run("CreateAndFind", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if user.ID == uuid.Nil {
+			t.Fatal("Create did not assign an ID")
+		}
+
+		byID, err := repo.FindByID(user.ID)
+		if err != nil {
+			t.Fatalf("FindByID: %v", err)
+		}
+		if byID == nil || byID.Email != user.Email {
+			t.Fatalf("FindByID = %+v, want user %s", byID, user.Email)
+		}
+
+		byEmail, err := repo.FindByEmail(user.Email)
+		if err != nil {
+			t.Fatalf("FindByEmail: %v", err)
+		}
+		if byEmail == nil || byEmail.ID != user.ID {
+			t.Fatalf("FindByEmail = %+v, want user %s", byEmail, user.ID)
+		}
+	})
+
+	t.Run("FindMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user, err := repo.FindByID(uuid.New())
+		if err != nil || user != nil {
+			t.Fatalf("FindByID = %+v, %v, want nil, nil", user, err)
+		}
+		user, err = repo.FindByEmail("missing@example.com")
+		if err != nil || user != nil {
+			t.Fatalf("FindByEmail = %+v, %v, want nil, nil", user, err)
+		}
+	})
+
+	t.Run("DuplicateEmail", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		duplicate := newUser()
+		duplicate.Email = user.Email
+		if err := repo.Create(duplicate); err == nil {
+			t.Fatal("Create with a duplicate email succeeded")
+		}
+	})
+
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		user.FirstName = "Updated"
+		if err := repo.Update(user); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		found, err := repo.FindByID(user.ID)
+		if err != nil || found == nil || found.FirstName != "Updated" {
+			t.Fatalf("FindByID after Update = %+v, %v", found, err)
+		}
+
+		if err := repo.Delete(user.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		found, err = repo.FindByID(user.ID)
+		if err != nil || found != nil {
+			t.Fatalf("FindByID after Delete = %+v, %v, want nil, nil", found, err)
+		}
+	})
+
+	t.Run("AdminFlag", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if found, err := repo.FindByID(user.ID); err != nil || found == nil || found.IsAdmin {
+			t.Fatalf("FindByID after Create = %+v, %v, want no admin", found, err)
+		}
+
+		// Granting and revoking admin leaves the role alone
+		for _, admin := range []bool{true, false} {
+			user.IsAdmin = admin
+			if err := repo.Update(user); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			found, err := repo.FindByID(user.ID)
+			if err != nil || found == nil || found.IsAdmin != admin || found.Role != model.RoleBoth {
+				t.Fatalf("FindByID after Update = %+v, %v, want admin %t with both roles", found, err, admin)
+			}
+		}
+	})
+
+	t.Run("DriverProfile", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		missing, err := repo.GetDriverProfile(user.ID)
+		if err != nil || missing != nil {
+			t.Fatalf("GetDriverProfile before create = %+v, %v, want nil, nil", missing, err)
+		}
+
+		profile := &model.DriverProfile{
+			UserID:     user.ID,
+			LicenseNo:  "L-123",
+			CarModel:   "Corolla",
+			CarPlateNo: "CAB-1234",
+			NumSeats:   4,
+		}
+		if err := repo.CreateDriverProfile(profile); err != nil {
+			t.Fatalf("CreateDriverProfile: %v", err)
+		}
+
+		profile.NumSeats = 3
+		if err := repo.UpdateDriverProfile(profile); err != nil {
+			t.Fatalf("UpdateDriverProfile: %v", err)
+		}
+		found, err := repo.GetDriverProfile(user.ID)
+		if err != nil || found == nil || found.NumSeats != 3 || found.CarModel != "Corolla" {
+			t.Fatalf("GetDriverProfile = %+v, %v", found, err)
+		}
+	})
+
+	t.Run("PassengerPreferences", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser()
+		if err := repo.Create(user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		missing, err := repo.GetPassengerPreferences(user.ID)
+		if err != nil || missing != nil {
+			t.Fatalf("GetPassengerPreferences before update = %+v, %v, want nil, nil", missing, err)
+		}
+
+		preferences := &model.PassengerPreferences{UserID: user.ID, MinDriverRating: 4, Priority: model.PriorityRating}
+		if err := repo.UpdatePassengerPreferences(preferences); err != nil {
+			t.Fatalf("UpdatePassengerPreferences creating: %v", err)
+		}
+		preferences.Priority = model.PriorityPrice
+		if err := repo.UpdatePassengerPreferences(preferences); err != nil {
+			t.Fatalf("UpdatePassengerPreferences: %v", err)
+		}
+		found, err := repo.GetPassengerPreferences(user.ID)
+		if err != nil || found == nil || found.MinDriverRating != 4 || found.Priority != model.PriorityPrice {
+			t.Fatalf("GetPassengerPreferences = %+v, %v", found, err)
+		}
+	})
+}
+
+// newUser builds a user with a unique email address
+func newUser() *model.User {
+	return &model.User{
+		FirstName: "Test",
+		LastName:  "User",
+		Email:     uuid.NewString() + "@example.com",
+		Password:  "hashed",
+		Phone:     "+94770000000",
+		Role:      model.RoleBoth,
+	}
+}
@@ -0,0 +1,225 @@
+package repotest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// TestWebhookRepository runs the WebhookRepository conformance suite.
+// newRepo is called once per subtest and must return an empty repository.
+func TestWebhookRepository(t *testing.T, newRepo func(t *testing.T) repository.WebhookRepository) {
+	t.Run("Subscriptions", func(t *testing.T) {
+		repo := newRepo(t)
+		employee := uuid.New()
+
+		active := newSubscription()
+		active.UserIDs = model.UserIDList{employee}
+		paused := newSubscription()
+		paused.Active = false
+		for _, subscription := range []*model.WebhookSubscription{active, paused} {
+			if err := repo.CreateSubscription(subscription); err != nil {
+				t.Fatalf("CreateSubscription: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		found, err := repo.FindSubscriptionByID(active.ID)
+		if err != nil || found == nil || found.Secret != active.Secret || !found.Subscribes(model.EventMatchConfirmed) || !found.Covers([]uuid.UUID{employee}) {
+			t.Fatalf("FindSubscriptionByID = %+v, %v", found, err)
+		}
+		missing, err := repo.FindSubscriptionByID(uuid.New())
+		if err != nil || missing != nil {
+			t.Fatalf("FindSubscriptionByID(missing) = %+v, %v, want nil, nil", missing, err)
+		}
+
+		all, err := repo.FindSubscriptions()
+		if err != nil || len(all) != 2 || all[0].ID != active.ID || all[1].ID != paused.ID || all[1].Active {
+			t.Fatalf("FindSubscriptions = %+v, %v, want both subscriptions, oldest first", all, err)
+		}
+		activeOnly, err := repo.FindActiveSubscriptions()
+		if err != nil || len(activeOnly) != 1 || activeOnly[0].ID != active.ID {
+			t.Fatalf("FindActiveSubscriptions = %+v, %v, want the active subscription", activeOnly, err)
+		}
+
+		active.URL = "https://partner.example.com/updated"
+		active.EventTypes = model.EventTypeList{model.EventRideCompleted}
+		if err := repo.UpdateSubscription(active); err != nil {
+			t.Fatalf("UpdateSubscription: %v", err)
+		}
+		found, err = repo.FindSubscriptionByID(active.ID)
+		if err != nil || found == nil || found.URL != active.URL || found.Subscribes(model.EventMatchConfirmed) || !found.Subscribes(model.EventRideCompleted) {
+			t.Fatalf("FindSubscriptionByID after Update = %+v, %v", found, err)
+		}
+	})
+
+	t.Run("Deliveries", func(t *testing.T) {
+		repo := newRepo(t)
+		subscription := newSubscription()
+		if err := repo.CreateSubscription(subscription); err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+
+		now := time.Now()
+		var created []*model.WebhookDelivery
+		for _, due := range []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(time.Hour)} {
+			delivery := newDelivery(subscription.ID, due)
+			if err := repo.CreateDelivery(delivery); err != nil {
+				t.Fatalf("CreateDelivery: %v", err)
+			}
+			created = append(created, delivery)
+			time.Sleep(time.Millisecond)
+		}
+
+		duplicate := newDelivery(subscription.ID, now)
+		duplicate.EventID = created[0].EventID
+		if err := repo.CreateDelivery(duplicate); err == nil {
+			t.Fatal("CreateDelivery of an event already delivered to the subscription succeeded")
+		}
+
+		byEvent, err := repo.FindDeliveryByEvent(subscription.ID, created[0].EventID)
+		if err != nil || byEvent == nil || byEvent.ID != created[0].ID || string(byEvent.Body) != string(created[0].Body) {
+			t.Fatalf("FindDeliveryByEvent = %+v, %v, want delivery %s", byEvent, err, created[0].ID)
+		}
+		missing, err := repo.FindDeliveryByEvent(subscription.ID, uuid.New())
+		if err != nil || missing != nil {
+			t.Fatalf("FindDeliveryByEvent(missing) = %+v, %v, want nil, nil", missing, err)
+		}
+
+		due, err := repo.FindDueDeliveries(now, 10)
+		if err != nil || len(due) != 2 || due[0].ID != created[1].ID || due[1].ID != created[0].ID {
+			t.Fatalf("FindDueDeliveries = %+v, %v, want the 2 due deliveries, longest due first", due, err)
+		}
+
+		deliveredAt := time.Now()
+		due[0].Status = model.WebhookDelivered
+		due[0].Attempts = 1
+		due[0].DeliveredAt = &deliveredAt
+		due[1].Status = model.WebhookDead
+		due[1].Attempts = 3
+		due[1].LastError = "502 Bad Gateway"
+		for i := range due {
+			if err := repo.UpdateDelivery(&due[i]); err != nil {
+				t.Fatalf("UpdateDelivery: %v", err)
+			}
+		}
+		due, err = repo.FindDueDeliveries(now, 10)
+		if err != nil || len(due) != 0 {
+			t.Fatalf("FindDueDeliveries after attempts = %+v, %v, want none", due, err)
+		}
+
+		all, err := repo.FindDeliveriesBySubscriptionID(subscription.ID, "", 10)
+		if err != nil || len(all) != 3 || all[0].ID != created[2].ID || all[2].ID != created[0].ID {
+			t.Fatalf("FindDeliveriesBySubscriptionID = %+v, %v, want every delivery, newest first", all, err)
+		}
+		dead, err := repo.FindDeliveriesBySubscriptionID(subscription.ID, model.WebhookDead, 10)
+		if err != nil || len(dead) != 1 || dead[0].ID != created[0].ID || dead[0].Attempts != 3 || dead[0].LastError != "502 Bad Gateway" {
+			t.Fatalf("FindDeliveriesBySubscriptionID(dead) = %+v, %v, want the dead delivery", dead, err)
+		}
+		limited, err := repo.FindDeliveriesBySubscriptionID(subscription.ID, "", 1)
+		if err != nil || len(limited) != 1 || limited[0].ID != created[2].ID {
+			t.Fatalf("FindDeliveriesBySubscriptionID(limit 1) = %+v, %v, want the newest delivery", limited, err)
+		}
+	})
+
+	t.Run("PausedDeliveries", func(t *testing.T) {
+		repo := newRepo(t)
+		active, paused := newSubscription(), newSubscription()
+		paused.Active = false
+		for _, subscription := range []*model.WebhookSubscription{active, paused} {
+			if err := repo.CreateSubscription(subscription); err != nil {
+				t.Fatalf("CreateSubscription: %v", err)
+			}
+		}
+
+		now := time.Now()
+		held := newDelivery(paused.ID, now.Add(-2*time.Minute))
+		sent := newDelivery(active.ID, now.Add(-time.Minute))
+		for _, delivery := range []*model.WebhookDelivery{held, sent} {
+			if err := repo.CreateDelivery(delivery); err != nil {
+				t.Fatalf("CreateDelivery: %v", err)
+			}
+		}
+
+		due, err := repo.FindDueDeliveries(now, 10)
+		if err != nil || len(due) != 1 || due[0].ID != sent.ID {
+			t.Fatalf("FindDueDeliveries = %+v, %v, want only the delivery to the active webhook", due, err)
+		}
+
+		paused.Active = true
+		if err := repo.UpdateSubscription(paused); err != nil {
+			t.Fatalf("UpdateSubscription: %v", err)
+		}
+		due, err = repo.FindDueDeliveries(now, 10)
+		if err != nil || len(due) != 2 || due[0].ID != held.ID {
+			t.Fatalf("FindDueDeliveries after resuming = %+v, %v, want the held delivery first", due, err)
+		}
+	})
+
+	t.Run("AttemptsAndDelete", func(t *testing.T) {
+		repo := newRepo(t)
+		subscription := newSubscription()
+		if err := repo.CreateSubscription(subscription); err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+		delivery := newDelivery(subscription.ID, time.Now())
+		if err := repo.CreateDelivery(delivery); err != nil {
+			t.Fatalf("CreateDelivery: %v", err)
+		}
+
+		for _, statusCode := range []int{500, 200} {
+			attempt := &model.WebhookAttempt{DeliveryID: delivery.ID, StatusCode: statusCode, DurationMs: 12}
+			if err := repo.CreateAttempt(attempt); err != nil {
+				t.Fatalf("CreateAttempt: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		attempts, err := repo.FindAttemptsByDeliveryID(delivery.ID)
+		if err != nil || len(attempts) != 2 || attempts[0].StatusCode != 500 || attempts[1].StatusCode != 200 {
+			t.Fatalf("FindAttemptsByDeliveryID = %+v, %v, want both attempts, oldest first", attempts, err)
+		}
+
+		if err := repo.DeleteSubscription(subscription.ID); err != nil {
+			t.Fatalf("DeleteSubscription: %v", err)
+		}
+		found, err := repo.FindSubscriptionByID(subscription.ID)
+		if err != nil || found != nil {
+			t.Fatalf("FindSubscriptionByID after Delete = %+v, %v, want nil, nil", found, err)
+		}
+		foundDelivery, err := repo.FindDeliveryByID(delivery.ID)
+		if err != nil || foundDelivery != nil {
+			t.Fatalf("FindDeliveryByID after Delete = %+v, %v, want nil, nil", foundDelivery, err)
+		}
+		attempts, err = repo.FindAttemptsByDeliveryID(delivery.ID)
+		if err != nil || len(attempts) != 0 {
+			t.Fatalf("FindAttemptsByDeliveryID after Delete = %+v, %v, want none", attempts, err)
+		}
+	})
+}
+
+// newSubscription builds an active webhook subscription to match events
+func newSubscription() *model.WebhookSubscription {
+	return &model.WebhookSubscription{
+		Name:       "Partner",
+		URL:        "https://partner.example.com/webhooks",
+		Secret:     uuid.NewString(),
+		EventTypes: model.EventTypeList{model.EventMatchCreated, model.EventMatchConfirmed},
+		Active:     true,
+	}
+}
+
+// newDelivery builds a pending delivery of a new event, due at the given time
+func newDelivery(subscriptionID uuid.UUID, due time.Time) *model.WebhookDelivery {
+	eventID := uuid.New()
+	return &model.WebhookDelivery{
+		SubscriptionID: subscriptionID,
+		EventID:        eventID,
+		EventType:      model.EventMatchConfirmed,
+		Body:           model.EventPayload(`{"id":"` + eventID.String() + `"}`),
+		Status:         model.WebhookPending,
+		NextAttemptAt:  due,
+	}
+}
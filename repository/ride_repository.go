@@ -12,16 +12,21 @@ import (
 
 // GormRideRepository is an implementation of RideRepository using Gorm
 type GormRideRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	inTx    bool
+	postgis bool
 }
 
-// NewGormRideRepository creates a new GormRideRepository
+// NewGormRideRepository creates a new GormRideRepository.
+// Candidate searches use PostGIS when the extension is installed and geohash prefixes otherwise.
 func NewGormRideRepository(db *gorm.DB) repo.RideRepository {
-	return &GormRideRepository{db: db}
+	return &GormRideRepository{db: db, postgis: hasPostGIS(db)}
 }
 
 // CreateRideOffer adds a new ride offer to the database
 func (r *GormRideRepository) CreateRideOffer(offer *model.RideOffer) error {
+	indexRideOffer(offer)
+	indexCorridor(offer)
 	return r.db.Create(offer).Error
 }
 
@@ -46,8 +51,98 @@ func (r *GormRideRepository) FindRideOffersByDriverID(driverID uuid.UUID) ([]mod
 	return offers, nil
 }
 
+// FindPendingRideOffers retrieves the pending ride offers departing after the given time, soonest first
+func (r *GormRideRepository) FindPendingRideOffers(departingAfter time.Time) ([]model.RideOffer, error) {
+	var offers []model.RideOffer
+	err := r.db.Where("status = ? AND departure_time > ?", model.StatusPending, departingAfter.UTC()).
+		Order("departure_time").
+		Find(&offers).Error
+	if err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// FindStaleRideOffers retrieves the pending and matched ride offers that departed before the given time
+func (r *GormRideRepository) FindStaleRideOffers(departedBefore time.Time) ([]model.RideOffer, error) {
+	var offers []model.RideOffer
+	err := r.db.Where("status IN (?) AND departure_time < ?",
+		[]model.RideStatus{model.StatusPending, model.StatusMatched}, departedBefore.UTC()).
+		Order("departure_time").
+		Find(&offers).Error
+	if err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// SearchRideOffers retrieves the ride offers still taking passengers that fit a search, with their waypoints
+func (r *GormRideRepository) SearchRideOffers(search repo.RideOfferSearch) ([]model.RideOffer, error) {
+	order := search.OrderBy
+	if order != repo.OrderByPrice {
+		order = repo.OrderByDeparture
+	}
+	query := r.db.Where("status IN (?) AND departure_time BETWEEN ? AND ? AND available_seats >= ?",
+		[]model.RideStatus{model.StatusPending, model.StatusMatched, model.StatusConfirmed},
+		search.DepartingFrom.UTC(), search.DepartingUntil.UTC(), search.Seats)
+	if search.MaxPricePerSeat > 0 {
+		query = query.Where("price_per_seat <= ?", search.MaxPricePerSeat)
+	}
+	query = r.passingNear(query, &search).Order(string(order)).Order("id")
+	if search.Limit > 0 {
+		query = query.Limit(search.Limit)
+	}
+
+	// Passengers may board and leave at waypoints, so the route is checked rather than the offer's ends.
+	// Candidates that fail the check leave the page short, so the next ones are read until it is full.
+	offers := make([]model.RideOffer, 0, search.Limit)
+	for after := search.After; ; {
+		var candidates []model.RideOffer
+		if err := afterRideOffer(query, order, after).Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+		if err := r.attachWaypoints(candidates); err != nil {
+			return nil, err
+		}
+		for _, offer := range candidates {
+			if !passesBy(&offer, &search) {
+				continue
+			}
+			offers = append(offers, offer)
+			if len(offers) == search.Limit {
+				return offers, nil
+			}
+		}
+		if search.Limit <= 0 || len(candidates) < search.Limit {
+			return offers, nil
+		}
+		after = repo.CursorAt(&candidates[len(candidates)-1])
+	}
+}
+
+// afterRideOffer restricts a ride offer query sorted by order, then by ID, to the offers after a cursor
+func afterRideOffer(query *gorm.DB, order repo.RideOfferOrder, after *repo.RideOfferCursor) *gorm.DB {
+	if after == nil {
+		return query
+	}
+	var key interface{} = after.DepartureTime.UTC()
+	if order == repo.OrderByPrice {
+		key = after.PricePerSeat
+	}
+	column := string(order)
+	return query.Where("("+column+" > ? OR ("+column+" = ? AND id > ?))", key, key, after.ID)
+}
+
 // UpdateRideOffer updates a ride offer in the database
 func (r *GormRideRepository) UpdateRideOffer(offer *model.RideOffer) error {
+	indexRideOffer(offer)
+	// The corridor runs through the stored waypoints, which the caller may not have loaded
+	routed := []model.RideOffer{*offer}
+	if err := r.attachWaypoints(routed); err != nil {
+		return err
+	}
+	indexCorridor(&routed[0])
+	offer.Corridor = routed[0].Corridor
 	return r.db.Save(offer).Error
 }
 
@@ -58,6 +153,7 @@ func (r *GormRideRepository) DeleteRideOffer(id uuid.UUID) error {
 
 // CreateRideRequest adds a new ride request to the database
 func (r *GormRideRepository) CreateRideRequest(request *model.RideRequest) error {
+	indexRideRequest(request)
 	return r.db.Create(request).Error
 }
 
@@ -82,8 +178,34 @@ func (r *GormRideRepository) FindRideRequestsByPassengerID(passengerID uuid.UUID
 	return requests, nil
 }
 
+// FindPendingRideRequests retrieves the pending ride requests of a mode departing after the given time, soonest first
+func (r *GormRideRepository) FindPendingRideRequests(mode model.RideMode, departingAfter time.Time) ([]model.RideRequest, error) {
+	var requests []model.RideRequest
+	err := r.db.Where("status = ? AND mode = ? AND departure_time > ?", model.StatusPending, mode, departingAfter.UTC()).
+		Order("departure_time").
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// FindStaleRideRequests retrieves the pending and matched ride requests that departed before the given time
+func (r *GormRideRepository) FindStaleRideRequests(departedBefore time.Time) ([]model.RideRequest, error) {
+	var requests []model.RideRequest
+	err := r.db.Where("status IN (?) AND departure_time < ?",
+		[]model.RideStatus{model.StatusPending, model.StatusMatched}, departedBefore.UTC()).
+		Order("departure_time").
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
 // UpdateRideRequest updates a ride request in the database
 func (r *GormRideRepository) UpdateRideRequest(request *model.RideRequest) error {
+	indexRideRequest(request)
 	return r.db.Save(request).Error
 }
 
@@ -92,9 +214,16 @@ func (r *GormRideRepository) DeleteRideRequest(id uuid.UUID) error {
 	return r.db.Delete(&model.RideRequest{}, "id = ?", id).Error
 }
 
-// CreateRideMatch adds a new ride match to the database
+// CreateRideMatch adds a new ride match to the database.
+// It returns ErrDuplicateRideMatch if the offer and request already have a match.
 func (r *GormRideRepository) CreateRideMatch(match *model.RideMatch) error {
-	return r.db.Create(match).Error
+	if err := r.db.Create(match).Error; err != nil {
+		if isUniqueViolation(err) {
+			return repo.ErrDuplicateRideMatch
+		}
+		return err
+	}
+	return nil
 }
 
 // FindRideMatchByID retrieves a ride match by ID
@@ -127,6 +256,30 @@ func (r *GormRideRepository) FindRideMatchesByRequestID(requestID uuid.UUID) ([]
 	return matches, nil
 }
 
+// FindRideMatchByOfferAndRequest retrieves the match between a specific ride offer and ride request
+func (r *GormRideRepository) FindRideMatchByOfferAndRequest(offerID, requestID uuid.UUID) (*model.RideMatch, error) {
+	var match model.RideMatch
+	if err := r.db.Where("ride_offer_id = ? AND ride_request_id = ?", offerID, requestID).First(&match).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &match, nil
+}
+
+// FindStaleRideMatches retrieves the matches proposed before the given time that have not been confirmed
+func (r *GormRideRepository) FindStaleRideMatches(proposedBefore time.Time) ([]model.RideMatch, error) {
+	var matches []model.RideMatch
+	err := r.db.Where("status = ? AND created_at < ?", model.StatusMatched, proposedBefore.UTC()).
+		Order("created_at").
+		Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
 // UpdateRideMatch updates a ride match in the database
 func (r *GormRideRepository) UpdateRideMatch(match *model.RideMatch) error {
 	return r.db.Save(match).Error
@@ -137,52 +290,229 @@ func (r *GormRideRepository) DeleteRideMatch(id uuid.UUID) error {
 	return r.db.Delete(&model.RideMatch{}, "id = ?", id).Error
 }
 
-// FindPotentialMatches finds potential ride requests that match a ride offer
-func (r *GormRideRepository) FindPotentialMatches(offerID uuid.UUID) ([]model.RideRequest, error) {
+// FindRideWaypointsByOfferID retrieves the waypoints of a ride offer in driving order
+func (r *GormRideRepository) FindRideWaypointsByOfferID(offerID uuid.UUID) ([]model.RideWaypoint, error) {
+	var waypoints []model.RideWaypoint
+	if err := r.db.Where("ride_offer_id = ?", offerID).Order("sequence").Find(&waypoints).Error; err != nil {
+		return nil, err
+	}
+	return waypoints, nil
+}
+
+// ReplaceRideWaypoints replaces the waypoints of a ride offer, numbering them in the given order
+func (r *GormRideRepository) ReplaceRideWaypoints(offerID uuid.UUID, waypoints []model.RideWaypoint) error {
+	return r.WithTx(func(tx repo.RideRepository) error {
+		db := tx.(*GormRideRepository).db
+		if err := db.Delete(&model.RideWaypoint{}, "ride_offer_id = ?", offerID).Error; err != nil {
+			return err
+		}
+		for i := range waypoints {
+			waypoints[i].ID = uuid.Nil
+			waypoints[i].RideOfferID = offerID
+			waypoints[i].Sequence = i + 1
+			if err := db.Create(&waypoints[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		// The corridor of the offer follows its new route
+		var offer model.RideOffer
+		if err := db.Where("id = ?", offerID).First(&offer).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		offer.Waypoints = waypoints
+		indexCorridor(&offer)
+		return db.Model(&offer).UpdateColumns(map[string]interface{}{
+			"corridor_min_latitude":  offer.Corridor.MinLatitude,
+			"corridor_max_latitude":  offer.Corridor.MaxLatitude,
+			"corridor_min_longitude": offer.Corridor.MinLongitude,
+			"corridor_max_longitude": offer.Corridor.MaxLongitude,
+		}).Error
+	})
+}
+
+// FindRideStopsByOfferID retrieves the stops of a ride offer in driving order
+func (r *GormRideRepository) FindRideStopsByOfferID(offerID uuid.UUID) ([]model.RideStop, error) {
+	var stops []model.RideStop
+	if err := r.db.Where("ride_offer_id = ?", offerID).Order("sequence").Find(&stops).Error; err != nil {
+		return nil, err
+	}
+	return stops, nil
+}
+
+// ReplaceRideStops replaces the stops of a ride offer, numbering them in the given order
+func (r *GormRideRepository) ReplaceRideStops(offerID uuid.UUID, stops []model.RideStop) error {
+	return r.WithTx(func(tx repo.RideRepository) error {
+		db := tx.(*GormRideRepository).db
+		if err := db.Delete(&model.RideStop{}, "ride_offer_id = ?", offerID).Error; err != nil {
+			return err
+		}
+		for i := range stops {
+			stops[i].ID = uuid.Nil
+			stops[i].RideOfferID = offerID
+			stops[i].Sequence = i + 1
+			if err := db.Create(&stops[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindPotentialMatches finds potential scheduled ride requests that match a ride offer,
+// departing no more than the candidates' window before or after it.
+// With straight-line candidates, only requests the driver can pick up and drop off on the way,
+// within the offer's allowed detour and the passengers' maximum price, are returned.
+func (r *GormRideRepository) FindPotentialMatches(offerID uuid.UUID, candidates repo.MatchCandidates) ([]model.RideRequest, error) {
 	var offer model.RideOffer
 	if err := r.db.Where("id = ?", offerID).First(&offer).Error; err != nil {
 		return nil, err
 	}
+	offers := []model.RideOffer{offer}
+	if err := r.attachWaypoints(offers); err != nil {
+		return nil, err
+	}
+	offer = offers[0]
 
-	// Define the time window for potential matches (e.g., +/- 30 minutes)
-	timeWindow := 30 * time.Minute
-	startTime := offer.DepartureTime.Add(-timeWindow)
-	endTime := offer.DepartureTime.Add(timeWindow)
+	// Only requests departing within the window around the offer are considered
+	startTime := offer.DepartureTime.Add(-candidates.Window).UTC()
+	endTime := offer.DepartureTime.Add(candidates.Window).UTC()
 
-	var requests []model.RideRequest
-	// Find requests within the same timeframe and with compatible locations
-	// This is a simplified version, in a real app you would use more sophisticated geospatial queries
-	if err := r.db.Where("status = ? AND departure_time BETWEEN ? AND ? AND num_passengers <= ?",
-		model.StatusPending, startTime, endTime, offer.AvailableSeats).
-		Find(&requests).Error; err != nil {
+	// Find scheduled requests still looking for a ride within the same timeframe
+	query := r.db.Where("status IN (?) AND mode = ? AND departure_time BETWEEN ? AND ? AND num_passengers <= ?",
+		[]model.RideStatus{model.StatusPending, model.StatusMatched}, model.ModeScheduled, startTime, endTime, offer.AvailableSeats)
+	if !candidates.StraightLine {
+		var requests []model.RideRequest
+		if err := query.Find(&requests).Error; err != nil {
+			return nil, err
+		}
+		return requests, nil
+	}
+
+	// Narrow them down to the corridor around the driver's route
+	radius := corridorRadius(&offer)
+	query = r.inCorridor(query, "start", offer.Route(), radius)
+	query = r.inCorridor(query, "end", offer.Route(), radius)
+
+	var inCorridor []model.RideRequest
+	if err := query.Find(&inCorridor).Error; err != nil {
 		return nil, err
 	}
 
-	// Further filtering would be done in the service layer
+	// The corridor is wider than the detour allows, so check the exact detour
+	requests := make([]model.RideRequest, 0, len(inCorridor))
+	for _, request := range inCorridor {
+		if withinDetour(&offer, &request) {
+			requests = append(requests, request)
+		}
+	}
 	return requests, nil
 }
 
-// FindPotentialOffers finds potential ride offers that match a ride request
-func (r *GormRideRepository) FindPotentialOffers(requestID uuid.UUID) ([]model.RideOffer, error) {
+// FindPotentialOffers finds potential ride offers that match a ride request,
+// departing no more than the candidates' window before or after it.
+// With straight-line candidates, only offers that can pick up and drop off the passengers on the
+// way, within their own allowed detour and the passengers' maximum price, are returned.
+func (r *GormRideRepository) FindPotentialOffers(requestID uuid.UUID, candidates repo.MatchCandidates) ([]model.RideOffer, error) {
 	var request model.RideRequest
 	if err := r.db.Where("id = ?", requestID).First(&request).Error; err != nil {
 		return nil, err
 	}
 
-	// Define the time window for potential matches (e.g., +/- 30 minutes)
-	timeWindow := 30 * time.Minute
-	startTime := request.DepartureTime.Add(-timeWindow)
-	endTime := request.DepartureTime.Add(timeWindow)
+	// Only offers departing within the window around the request are considered
+	startTime := request.DepartureTime.Add(-candidates.Window).UTC()
+	endTime := request.DepartureTime.Add(candidates.Window).UTC()
 
-	var offers []model.RideOffer
-	// Find offers within the same timeframe and with sufficient seats
-	// This is a simplified version, in a real app you would use more sophisticated geospatial queries
-	if err := r.db.Where("status = ? AND departure_time BETWEEN ? AND ? AND available_seats >= ? AND price_per_seat <= ?",
-		model.StatusPending, startTime, endTime, request.NumPassengers, request.MaxPrice/float64(request.NumPassengers)).
-		Find(&offers).Error; err != nil {
+	// Find offers that have not left yet within the same timeframe and with sufficient seats.
+	// With straight-line candidates, their corridor must take in both the pickup and the
+	// drop-off; each offer has its own route, detour and fare, so those are checked exactly
+	// per offer.
+	query := r.db.Where("status IN (?) AND departure_time BETWEEN ? AND ? AND available_seats >= ?",
+		[]model.RideStatus{model.StatusPending, model.StatusMatched, model.StatusConfirmed},
+		startTime, endTime, request.NumPassengers)
+	if candidates.StraightLine {
+		query = inOfferCorridor(query, request.StartLocation)
+		query = inOfferCorridor(query, request.EndLocation)
+	}
+
+	var found []model.RideOffer
+	if err := query.Find(&found).Error; err != nil {
 		return nil, err
 	}
+	if err := r.attachWaypoints(found); err != nil {
+		return nil, err
+	}
+	if !candidates.StraightLine {
+		return found, nil
+	}
 
-	// Further filtering would be done in the service layer
+	offers := make([]model.RideOffer, 0, len(found))
+	for _, offer := range found {
+		if withinDetour(&offer, &request) {
+			offers = append(offers, offer)
+		}
+	}
 	return offers, nil
 }
+
+// LockRideOfferByID retrieves a ride offer by ID and locks it for update
+func (r *GormRideRepository) LockRideOfferByID(id uuid.UUID) (*model.RideOffer, error) {
+	var offer model.RideOffer
+	if err := r.forUpdate().Where("id = ?", id).First(&offer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// LockRideRequestByID retrieves a ride request by ID and locks it for update
+func (r *GormRideRepository) LockRideRequestByID(id uuid.UUID) (*model.RideRequest, error) {
+	var request model.RideRequest
+	if err := r.forUpdate().Where("id = ?", id).First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// LockRideMatchByID retrieves a ride match by ID and locks it for update
+func (r *GormRideRepository) LockRideMatchByID(id uuid.UUID) (*model.RideMatch, error) {
+	var match model.RideMatch
+	if err := r.forUpdate().Where("id = ?", id).First(&match).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &match, nil
+}
+
+// WithTx runs fn inside a database transaction.
+// Calls made while a transaction is already open join that transaction.
+func (r *GormRideRepository) WithTx(fn func(repo.RideRepository) error) error {
+	if r.inTx {
+		return fn(r)
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&GormRideRepository{db: tx, inTx: true, postgis: r.postgis})
+	})
+}
+
+// forUpdate returns a query that takes a row lock with SELECT ... FOR UPDATE.
+// SQLite has no row locks, and a deferred transaction only takes the write lock at its first
+// write, so two transactions could both read a row before either changes it. That cannot happen
+// here only because InitDB limits SQLite to one open connection (SetMaxOpenConns(1)), which
+// runs transactions one at a time; raising that limit would need _txlock=immediate instead.
+func (r *GormRideRepository) forUpdate() *gorm.DB {
+	if r.db.Dialect().GetName() == "sqlite3" {
+		return r.db
+	}
+	return r.db.Set("gorm:query_option", "FOR UPDATE")
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	repo "github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+// pointParam builds a PostGIS point from longitude and latitude parameters
+const pointParam = "ST_SetSRID(ST_MakePoint(?, ?), 4326)"
+
+// geographyColumn returns the PostGIS geography expression for the start or end
+// location columns. It matches the expression indexes created by the migrations.
+func geographyColumn(prefix string) string {
+	return "ST_SetSRID(ST_MakePoint(" + prefix + "_longitude, " + prefix + "_latitude), 4326)::geography"
+}
+
+// hasPostGIS reports whether the database has the PostGIS extension installed
+func hasPostGIS(db *gorm.DB) bool {
+	if db.Dialect().GetName() != "postgres" {
+		return false
+	}
+
+	var installed bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis')").Row().Scan(&installed); err != nil {
+		return false
+	}
+	return installed
+}
+
+// inCorridor restricts a ride query to rows whose start or end location may lie within
+// radiusKm of a route. With PostGIS the restriction is exact; geohash cells cover a
+// bounding box and may include rows slightly further away.
+func (r *GormRideRepository) inCorridor(query *gorm.DB, prefix string, route []model.Location, radiusKm float64) *gorm.DB {
+	if r.postgis {
+		params := make([]string, len(route))
+		args := make([]interface{}, 0, 2*len(route)+1)
+		for i, location := range route {
+			params[i] = pointParam
+			args = append(args, location.Longitude, location.Latitude)
+		}
+		args = append(args, radiusKm*1000)
+		return query.Where("ST_DWithin("+geographyColumn(prefix)+", ST_MakeLine(ARRAY["+strings.Join(params, ", ")+"])::geography, ?)", args...)
+	}
+
+	return inBox(query, prefix, geo.BoxAround(radiusKm, points(route)...))
+}
+
+// nearLocation restricts a query to rows whose location may lie within radiusKm of a point.
+// Like inCorridor, the restriction is exact with PostGIS and a geohash bounding box otherwise.
+func (r *GormRideRepository) nearLocation(query *gorm.DB, prefix string, location model.Location, radiusKm float64) *gorm.DB {
+	condition, args := r.nearCondition(prefix, location, radiusKm)
+	if condition == "" {
+		return query
+	}
+	return query.Where(condition, args...)
+}
+
+// nearCondition returns the condition behind nearLocation, or an empty one if the geohash
+// bounding box cannot narrow the rows down
+func (r *GormRideRepository) nearCondition(prefix string, location model.Location, radiusKm float64) (string, []interface{}) {
+	if r.postgis {
+		return withinCondition(prefix, location, radiusKm)
+	}
+	return geohashCondition(prefix, geo.BoxAround(radiusKm, point(location)))
+}
+
+// withinCondition returns a PostGIS condition on rows whose prefixed location lies within radiusKm of a point
+func withinCondition(prefix string, location model.Location, radiusKm float64) (string, []interface{}) {
+	return "ST_DWithin(" + geographyColumn(prefix) + ", " + pointParam + "::geography, ?)",
+		[]interface{}{location.Longitude, location.Latitude, radiusKm * 1000}
+}
+
+// inBox restricts a query to rows whose geohash for the prefixed location lies in a bounding box
+func inBox(query *gorm.DB, prefix string, box geo.Box) *gorm.DB {
+	condition, args := geohashCondition(prefix, box)
+	if condition == "" {
+		return query
+	}
+	return query.Where(condition, args...)
+}
+
+// geohashCondition returns the condition behind inBox, or an empty one if no geohash cells cover the box
+func geohashCondition(prefix string, box geo.Box) (string, []interface{}) {
+	hashes := box.CoveringGeohashes()
+	if hashes == nil {
+		return "", nil
+	}
+
+	// Rows saved before geohashes were introduced have none and are checked exactly later
+	column := prefix + "_geohash"
+	conditions := []string{column + " IS NULL"}
+	args := make([]interface{}, 0, len(hashes))
+	for _, hash := range hashes {
+		conditions = append(conditions, column+" LIKE ?")
+		args = append(args, hash+"%")
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// passingNear restricts a ride offer search to offers with a route point that may lie near its
+// origin and one that may lie near its destination. Passengers board at the start or a waypoint
+// and leave at a waypoint or the end; passesBy checks the distances and their order exactly.
+func (r *GormRideRepository) passingNear(query *gorm.DB, search *repo.RideOfferSearch) *gorm.DB {
+	query = r.nearRoutePoint(query, "start", search.Origin, search.RadiusKm)
+	return r.nearRoutePoint(query, "end", search.Destination, search.RadiusKm)
+}
+
+// nearRoutePoint restricts a ride offer query to offers whose corridor box comes within radiusKm
+// of a location and whose prefixed end or one of whose waypoints may lie that close to it
+func (r *GormRideRepository) nearRoutePoint(query *gorm.DB, prefix string, location model.Location, radiusKm float64) *gorm.DB {
+	box := geo.BoxAround(radiusKm, point(location))
+	if box.MinLongitude < -180 || box.MaxLongitude > 180 {
+		// Plain coordinates cannot describe a box across the antimeridian
+		return query
+	}
+	query = query.Where("(corridor_min_latitude IS NULL OR "+
+		"(corridor_min_latitude <= ? AND corridor_max_latitude >= ? AND corridor_min_longitude <= ? AND corridor_max_longitude >= ?))",
+		box.MaxLatitude, box.MinLatitude, box.MaxLongitude, box.MinLongitude)
+
+	end, endArgs := r.nearCondition(prefix, location, radiusKm)
+	if end == "" {
+		return query
+	}
+	// Waypoints have no geohash, so without PostGIS their coordinates are compared with the box
+	waypoint, waypointArgs := "location_latitude BETWEEN ? AND ? AND location_longitude BETWEEN ? AND ?",
+		[]interface{}{box.MinLatitude, box.MaxLatitude, box.MinLongitude, box.MaxLongitude}
+	if r.postgis {
+		waypoint, waypointArgs = withinCondition("location", location, radiusKm)
+	}
+	return query.Where("("+end+" OR EXISTS (SELECT 1 FROM ride_waypoints WHERE ride_waypoints.ride_offer_id = ride_offers.id AND "+waypoint+"))",
+		append(endArgs, waypointArgs...)...)
+}
+
+// inOfferCorridor restricts a ride offer query to offers whose corridor box contains a location.
+// Offers without a box are kept and checked exactly later.
+func inOfferCorridor(query *gorm.DB, location model.Location) *gorm.DB {
+	return query.Where("(corridor_min_latitude IS NULL OR "+
+		"(? BETWEEN corridor_min_latitude AND corridor_max_latitude AND ? BETWEEN corridor_min_longitude AND corridor_max_longitude))",
+		location.Latitude, location.Longitude)
+}
+
+// indexCorridor stores the box around the corridor of a ride offer's route, waypoints included.
+// A box crossing the antimeridian cannot be compared with plain coordinates, so none is stored.
+func indexCorridor(offer *model.RideOffer) {
+	box := geo.BoxAround(corridorRadius(offer), points(offer.Route())...)
+	if box.MinLongitude < -180 || box.MaxLongitude > 180 {
+		offer.Corridor = model.CorridorBox{}
+		return
+	}
+	offer.Corridor = model.CorridorBox{
+		MinLatitude:  &box.MinLatitude,
+		MaxLatitude:  &box.MaxLatitude,
+		MinLongitude: &box.MinLongitude,
+		MaxLongitude: &box.MaxLongitude,
+	}
+}
+
+// corridorRadius returns how far from an offer's route its passengers may board or leave.
+// Stops inserted between two route points lie on an ellipse around that leg, so the
+// longest leg bounds the corridor.
+func corridorRadius(offer *model.RideOffer) float64 {
+	route := points(offer.Route())
+	longest := 0.0
+	for i := 1; i < len(route); i++ {
+		longest = math.Max(longest, geo.PathLength(route[i-1], route[i]))
+	}
+	return geo.CorridorRadius(longest, offer.AllowedDetourKm)
+}
+
+// withinDetour reports whether the offer's driver can pick up and drop off the request's
+// passengers on the way within the allowed detour, and for no more than they would pay,
+// measured along straight lines. A road detour can be shorter than the straight-line one,
+// so this is only a safe filter when routes are measured as straight lines too.
+func withinDetour(offer *model.RideOffer, request *model.RideRequest) bool {
+	route := points(offer.Route())
+	pickup, dropoff := point(request.StartLocation), point(request.EndLocation)
+	if geo.InsertionDetour(route, pickup, dropoff) > offer.AllowedDetourKm {
+		return false
+	}
+	fare := offer.Fare(request.NumPassengers, geo.PathLength(pickup, dropoff), geo.PathLength(route...))
+	return fare <= request.MaxPrice
+}
+
+// passesBy reports whether an offer's route passes near a search's origin and then its destination
+func passesBy(offer *model.RideOffer, search *repo.RideOfferSearch) bool {
+	_, ok := geo.AccessDistance(points(offer.Route()), point(search.Origin), point(search.Destination), search.RadiusKm)
+	return ok
+}
+
+// attachWaypoints loads the waypoints of ride offers
+func (r *GormRideRepository) attachWaypoints(offers []model.RideOffer) error {
+	if len(offers) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(offers))
+	for i, offer := range offers {
+		ids[i] = offer.ID
+	}
+	var waypoints []model.RideWaypoint
+	if err := r.db.Where("ride_offer_id IN (?)", ids).Order("sequence").Find(&waypoints).Error; err != nil {
+		return err
+	}
+
+	byOffer := make(map[uuid.UUID][]model.RideWaypoint, len(offers))
+	for _, waypoint := range waypoints {
+		byOffer[waypoint.RideOfferID] = append(byOffer[waypoint.RideOfferID], waypoint)
+	}
+	for i := range offers {
+		offers[i].Waypoints = byOffer[offers[i].ID]
+	}
+	return nil
+}
+
+// point converts a location to a geo point
+func point(location model.Location) geo.Point {
+	return geo.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+}
+
+// points converts locations to geo points
+func points(locations []model.Location) []geo.Point {
+	converted := make([]geo.Point, len(locations))
+	for i, location := range locations {
+		converted[i] = point(location)
+	}
+	return converted
+}
+
+// indexRideOffer stores the geohashes of a ride offer's start and end locations
+func indexRideOffer(offer *model.RideOffer) {
+	offer.StartGeohash = geo.EncodeGeohash(offer.StartLocation.Latitude, offer.StartLocation.Longitude, geo.GeohashPrecision)
+	offer.EndGeohash = geo.EncodeGeohash(offer.EndLocation.Latitude, offer.EndLocation.Longitude, geo.GeohashPrecision)
+}
+
+// indexDriverAvailability stores the geohash of a driver's location
+func indexDriverAvailability(availability *model.DriverAvailability) {
+	availability.Geohash = geo.EncodeGeohash(availability.Location.Latitude, availability.Location.Longitude, geo.GeohashPrecision)
+}
+
+// indexRideRequest stores the geohashes of a ride request's pickup and drop-off locations
+func indexRideRequest(request *model.RideRequest) {
+	request.StartGeohash = geo.EncodeGeohash(request.StartLocation.Latitude, request.StartLocation.Longitude, geo.GeohashPrecision)
+	request.EndGeohash = geo.EncodeGeohash(request.EndLocation.Latitude, request.EndLocation.Longitude, geo.GeohashPrecision)
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	repo "github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// CreateTripLocations adds the GPS points of a trip to the database, all or none of them
+func (r *GormRideRepository) CreateTripLocations(locations []model.TripLocation) error {
+	return r.WithTx(func(tx repo.RideRepository) error {
+		db := tx.(*GormRideRepository).db
+		for i := range locations {
+			if err := db.Create(&locations[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindLatestTripLocation retrieves the most recently recorded GPS point of a ride offer, or nil if it has none
+func (r *GormRideRepository) FindLatestTripLocation(offerID uuid.UUID) (*model.TripLocation, error) {
+	var location model.TripLocation
+	err := r.db.Where("ride_offer_id = ?", offerID).Order("recorded_at DESC").Order("id DESC").First(&location).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &location, nil
+}
+
+// FindTripLocations retrieves the GPS points of a ride offer recorded after the given time, in recording order
+func (r *GormRideRepository) FindTripLocations(offerID uuid.UUID, recordedAfter time.Time) ([]model.TripLocation, error) {
+	var locations []model.TripLocation
+	err := r.db.Where("ride_offer_id = ? AND recorded_at > ?", offerID, recordedAfter.UTC()).
+		Order("recorded_at").
+		Order("id").
+		Find(&locations).Error
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// DeleteTripLocationsBefore removes the GPS points recorded before the given time and returns how many it removed
+func (r *GormRideRepository) DeleteTripLocationsBefore(recordedBefore time.Time) (int64, error) {
+	result := r.db.Where("recorded_at < ?", recordedBefore.UTC()).Delete(&model.TripLocation{})
+	return result.RowsAffected, result.Error
+}
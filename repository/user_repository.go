@@ -79,3 +79,20 @@ func (r *GormUserRepository) GetDriverProfile(userID uuid.UUID) (*model.DriverPr
 func (r *GormUserRepository) UpdateDriverProfile(profile *model.DriverProfile) error {
 	return r.db.Save(profile).Error
 }
+
+// GetPassengerPreferences retrieves a passenger's match preferences by user ID
+func (r *GormUserRepository) GetPassengerPreferences(userID uuid.UUID) (*model.PassengerPreferences, error) {
+	var preferences model.PassengerPreferences
+	if err := r.db.Where("user_id = ?", userID).First(&preferences).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// UpdatePassengerPreferences saves a passenger's match preferences, creating them if they do not exist
+func (r *GormUserRepository) UpdatePassengerPreferences(preferences *model.PassengerPreferences) error {
+	return r.db.Save(preferences).Error
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	repo "github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// GormWebhookRepository is an implementation of WebhookRepository using Gorm
+type GormWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewGormWebhookRepository creates a new GormWebhookRepository
+func NewGormWebhookRepository(db *gorm.DB) repo.WebhookRepository {
+	return &GormWebhookRepository{db: db}
+}
+
+// CreateSubscription adds a new webhook subscription to the database
+func (r *GormWebhookRepository) CreateSubscription(subscription *model.WebhookSubscription) error {
+	return r.db.Create(subscription).Error
+}
+
+// FindSubscriptionByID retrieves a webhook subscription by ID
+func (r *GormWebhookRepository) FindSubscriptionByID(id uuid.UUID) (*model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+	if err := r.db.Where("id = ?", id).First(&subscription).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// FindSubscriptions retrieves every webhook subscription, oldest first
+func (r *GormWebhookRepository) FindSubscriptions() ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+	if err := r.db.Order("created_at").Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// FindActiveSubscriptions retrieves the webhook subscriptions that are not paused
+func (r *GormWebhookRepository) FindActiveSubscriptions() ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+	if err := r.db.Where("active = ?", true).Order("created_at").Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// UpdateSubscription updates a webhook subscription in the database
+func (r *GormWebhookRepository) UpdateSubscription(subscription *model.WebhookSubscription) error {
+	return r.db.Save(subscription).Error
+}
+
+// DeleteSubscription removes a webhook subscription together with its deliveries and their attempts
+func (r *GormWebhookRepository) DeleteSubscription(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		attempts := tx.Where("delivery_id IN (SELECT id FROM webhook_deliveries WHERE subscription_id = ?)", id)
+		if err := attempts.Delete(&model.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("subscription_id = ?", id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.WebhookSubscription{}, "id = ?", id).Error
+	})
+}
+
+// CreateDelivery adds a new webhook delivery to the database
+func (r *GormWebhookRepository) CreateDelivery(delivery *model.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+// FindDeliveryByID retrieves a webhook delivery by ID
+func (r *GormWebhookRepository) FindDeliveryByID(id uuid.UUID) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	if err := r.db.Where("id = ?", id).First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// FindDeliveryByEvent retrieves the delivery of an event to a webhook subscription
+func (r *GormWebhookRepository) FindDeliveryByEvent(subscriptionID, eventID uuid.UUID) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	if err := r.db.Where("subscription_id = ? AND event_id = ?", subscriptionID, eventID).First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// FindDeliveriesBySubscriptionID retrieves up to limit deliveries of a subscription, newest first;
+// an empty status matches every delivery
+func (r *GormWebhookRepository) FindDeliveriesBySubscriptionID(subscriptionID uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	query := r.db.Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []model.WebhookDelivery
+	if err := query.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// FindDueDeliveries retrieves up to limit pending deliveries due by the given time, the longest due first.
+// Deliveries to paused webhooks are held back until the webhook is resumed.
+func (r *GormWebhookRepository) FindDueDeliveries(dueBy time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", model.WebhookPending, dueBy.UTC()).
+		Where("subscription_id IN (SELECT id FROM webhook_subscriptions WHERE active = ?)", true).
+		Order("next_attempt_at").
+		Order("id").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery updates the state of a webhook delivery
+func (r *GormWebhookRepository) UpdateDelivery(delivery *model.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+// CreateAttempt records an attempt at posting a webhook delivery
+func (r *GormWebhookRepository) CreateAttempt(attempt *model.WebhookAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// FindAttemptsByDeliveryID retrieves the attempts at posting a webhook delivery, oldest first
+func (r *GormWebhookRepository) FindAttemptsByDeliveryID(deliveryID uuid.UUID) ([]model.WebhookAttempt, error) {
+	var attempts []model.WebhookAttempt
+	if err := r.db.Where("delivery_id = ?", deliveryID).Order("created_at").Order("id").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
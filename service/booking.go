@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+)
+
+// ErrBookingRejected is returned when a passenger books a ride offer that rejected them before
+var ErrBookingRejected = errors.New("your match with this ride was rejected, it cannot be booked again")
+
+// BookRideOffer books seats on a ride offer the passenger picked, for example from a search.
+// A ride request is created for the passenger together with a match against the offer that the
+// passenger has already accepted; the booking is confirmed once the driver accepts the match.
+// The passenger's stops are added to the offer's route the same way matching adds them, so the
+// booking is refused if it does not fit the offer's seats, detour or the passenger's price, and
+// if a match between the offer and one of the passenger's requests was rejected.
+func (s *RideService) BookRideOffer(
+	offerID uuid.UUID,
+	passengerID uuid.UUID,
+	pickup, dropoff model.Location,
+	numPassengers int,
+	maxPrice float64,
+) (*model.RideRequest, *model.RideMatch, error) {
+	// Validate passenger
+	passenger, err := s.userRepo.FindByID(passengerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if passenger == nil {
+		return nil, nil, errors.New("passenger not found")
+	}
+
+	// Check if the number of passengers is valid
+	if numPassengers <= 0 {
+		return nil, nil, errors.New("invalid number of passengers")
+	}
+
+	offer, err := s.rideRepo.FindRideOfferByID(offerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offer == nil {
+		return nil, nil, errors.New("ride offer not found")
+	}
+	if offer.Waypoints, err = s.rideRepo.FindRideWaypointsByOfferID(offer.ID); err != nil {
+		return nil, nil, err
+	}
+
+	request := &model.RideRequest{
+		PassengerID:   passengerID,
+		StartLocation: pickup,
+		EndLocation:   dropoff,
+		DepartureTime: offer.DepartureTime,
+		NumPassengers: numPassengers,
+		MaxPrice:      maxPrice,
+		Status:        model.StatusPending,
+		Mode:          model.ModeScheduled,
+	}
+
+	// Score the booking so that it reads like any other match; the passenger chose
+	// this ride, so a low score does not stop them from booking it
+	breakdown := s.evaluateMatch(offer, request)
+	breakdown.Rejection = ""
+
+	var match *model.RideMatch
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		offer, err := rideRepo.LockRideOfferByID(offerID)
+		if err != nil {
+			return err
+		}
+		if offer == nil {
+			return errors.New("ride offer not found")
+		}
+		if offer.DriverID == passengerID {
+			return errors.New("cannot book your own ride offer")
+		}
+		if !offer.AcceptsMatches() {
+			return &model.TransitionError{Entity: "ride offer", From: offer.Status, To: model.StatusMatched}
+		}
+		if offer.DepartureTime.Before(time.Now()) {
+			return errors.New("the ride has already departed")
+		}
+		// A rejected pair is never proposed again, and booking it anew must not get around that
+		rejected, err := rejectedPassenger(rideRepo, offer.ID, passengerID)
+		if err != nil {
+			return err
+		}
+		if rejected {
+			return ErrBookingRejected
+		}
+
+		request.DepartureTime = offer.DepartureTime
+		if err := rideRepo.CreateRideRequest(request); err != nil {
+			return err
+		}
+
+		pool, err := s.loadRidePool(rideRepo, offer)
+		if err != nil {
+			return err
+		}
+		if offer.AvailableSeats-pool.seatsHeld < numPassengers {
+			return ErrNotEnoughSeats
+		}
+		fare, ok := s.tryAddToPool(pool, request)
+		if !ok {
+			return errors.New("the ride cannot take you within its allowed detour and your maximum price")
+		}
+
+		now := time.Now()
+		match = &model.RideMatch{
+			RideOfferID:         offer.ID,
+			RideRequestID:       request.ID,
+			Status:              model.StatusMatched,
+			MatchScore:          breakdown.Score,
+			Price:               fare,
+			ScoreBreakdown:      &breakdown,
+			PassengerAcceptedAt: &now,
+		}
+		if err := rideRepo.CreateRideMatch(match); err != nil {
+			return err
+		}
+		if err := markMatched(rideRepo, offer, request); err != nil {
+			return err
+		}
+		if err := recordEvent(rideRepo, model.EventRideRequestCreated, request.ID, request); err != nil {
+			return err
+		}
+		if err := recordEvent(rideRepo, model.EventMatchCreated, match.ID, match); err != nil {
+			return err
+		}
+		return rideRepo.ReplaceRideStops(offer.ID, pool.stops())
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.notify(context.Background(), []notify.Notification{{
+		UserID:  offer.DriverID,
+		Type:    notify.RideBooked,
+		RideID:  match.ID,
+		Message: fmt.Sprintf("A passenger booked your ride offer %s for %d passengers and waits for you to accept", offer.ID, numPassengers),
+	}})
+	s.publish(matchEvents(events.MatchProposed, match, offer.DriverID, passengerID))
+
+	return request, match, nil
+}
+
+// rejectedPassenger reports whether one of a passenger's ride requests has a rejected match with a ride offer
+func rejectedPassenger(rideRepo repository.RideRepository, offerID, passengerID uuid.UUID) (bool, error) {
+	matches, err := rideRepo.FindRideMatchesByOfferID(offerID)
+	if err != nil {
+		return false, err
+	}
+	for _, match := range matches {
+		if match.Status != model.StatusRejected {
+			continue
+		}
+		request, err := rideRepo.FindRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return false, err
+		}
+		if request != nil && request.PassengerID == passengerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
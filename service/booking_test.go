@@ -0,0 +1,41 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// createPassenger saves a passenger account
+func createPassenger(t *testing.T, userRepo repository.UserRepository) *model.User {
+	t.Helper()
+	passenger := &model.User{FirstName: "Pat", LastName: "Passenger", Email: "pat@example.com", Phone: "0612345678", Role: model.RolePassenger}
+	if err := userRepo.Create(passenger); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return passenger
+}
+
+func TestBookRideOfferAfterARejection(t *testing.T) {
+	service, rideRepo, userRepo := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	passenger := createPassenger(t, userRepo)
+
+	_, match, err := service.BookRideOffer(offer.ID, passenger.ID, centralStation, museumSquare, 1, 50)
+	if err != nil {
+		t.Fatalf("BookRideOffer: %v", err)
+	}
+	if _, err := service.RejectMatch(match.ID, offer.DriverID, "no room for luggage"); err != nil {
+		t.Fatalf("RejectMatch: %v", err)
+	}
+
+	// Booking again makes a new request, but the pair was rejected all the same
+	if _, _, err := service.BookRideOffer(offer.ID, passenger.ID, centralStation, museumSquare, 1, 50); !errors.Is(err, ErrBookingRejected) {
+		t.Fatalf("BookRideOffer after a rejection = %v, want ErrBookingRejected", err)
+	}
+	if requests, err := rideRepo.FindRideRequestsByPassengerID(passenger.ID); err != nil || len(requests) != 1 {
+		t.Errorf("FindRideRequestsByPassengerID = %d requests, %v, want only the first booking", len(requests), err)
+	}
+}
@@ -0,0 +1,598 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+	"github.com/yourusername/ride-sharing-app/infrastructure/jobs"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+)
+
+// DispatchOptions configures how on-demand requests are offered to drivers
+type DispatchOptions struct {
+	// RadiusKm is how far from the pickup drivers are offered a trip
+	RadiusKm float64
+	// AcceptWindow is how long a driver has to accept a trip before it moves on to the next driver
+	AcceptWindow time.Duration
+	// MaxWait is how long a request looks for a driver before it expires
+	MaxWait time.Duration
+	// LocationMaxAge is how recently a driver must have reported their location to be offered trips
+	LocationMaxAge time.Duration
+	// PricePerKm sets the fare of on-demand trips
+	PricePerKm float64
+}
+
+// ErrDispatchClosed is returned when a driver answers a trip that is no longer offered to them
+var ErrDispatchClosed = errors.New("this trip is no longer offered to you")
+
+// ErrDriverOnTrip is returned when a driver accepts a trip before finishing the one they are on
+var ErrDriverOnTrip = errors.New("finish your current trip before accepting another")
+
+// ErrOnDemandNotEditable is returned when an on-demand request is changed or withdrawn instead of cancelled
+var ErrOnDemandNotEditable = errors.New("on-demand requests cannot be changed or withdrawn, cancel them instead")
+
+// errNotDispatching stops a dispatch when the request found a driver or no longer needs one
+var errNotDispatching = errors.New("ride request is not waiting for a driver")
+
+// DispatchDetails describes a trip offered to a driver
+type DispatchDetails struct {
+	Dispatch    model.Dispatch    `json:"dispatch"`
+	RideRequest model.RideRequest `json:"ride_request"`
+	Fare        float64           `json:"fare"`
+}
+
+// SetDriverAvailability records whether a driver takes on-demand trips and where they are.
+// Going online needs the driver's location; going offline keeps the last one reported.
+func (s *RideService) SetDriverAvailability(driverID uuid.UUID, online bool, location *model.Location) (*model.DriverAvailability, error) {
+	driverProfile, err := s.userRepo.GetDriverProfile(driverID)
+	if err != nil {
+		return nil, err
+	}
+	if driverProfile == nil {
+		return nil, errors.New("driver profile not found")
+	}
+
+	var availability *model.DriverAvailability
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Locked so that the trip a driver accepts meanwhile is not saved over
+		availability, err = rideRepo.LockDriverAvailability(driverID)
+		if err != nil {
+			return err
+		}
+		if location == nil && (online || availability == nil) {
+			return errors.New("location is required to go online")
+		}
+		if availability == nil {
+			availability = &model.DriverAvailability{DriverID: driverID}
+		}
+
+		availability.Online = online
+		if location != nil {
+			availability.Location = *location
+		}
+		return rideRepo.SaveDriverAvailability(availability)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// GetDriverAvailability retrieves a driver's availability, or nil if the driver never reported it
+func (s *RideService) GetDriverAvailability(driverID uuid.UUID) (*model.DriverAvailability, error) {
+	return s.rideRepo.FindDriverAvailability(driverID)
+}
+
+// RequestRideNow creates an on-demand ride request leaving now and offers it to the nearest
+// available driver in the background
+func (s *RideService) RequestRideNow(
+	passengerID uuid.UUID,
+	pickup, dropoff model.Location,
+	numPassengers int,
+	maxPrice float64,
+) (*model.RideRequest, error) {
+	// Validate passenger
+	passenger, err := s.userRepo.FindByID(passengerID)
+	if err != nil {
+		return nil, err
+	}
+	if passenger == nil {
+		return nil, errors.New("passenger not found")
+	}
+
+	// Check if the number of passengers is valid
+	if numPassengers <= 0 {
+		return nil, errors.New("invalid number of passengers")
+	}
+
+	// Refuse trips the passenger would not pay for before bothering any driver
+	fare, err := s.tripFare(pickup, dropoff)
+	if err != nil {
+		return nil, err
+	}
+	if fare > maxPrice {
+		return nil, fmt.Errorf("the trip costs %.2f, more than your maximum price", fare)
+	}
+
+	request := &model.RideRequest{
+		PassengerID:   passengerID,
+		StartLocation: pickup,
+		EndLocation:   dropoff,
+		DepartureTime: time.Now(),
+		NumPassengers: numPassengers,
+		MaxPrice:      maxPrice,
+		Status:        model.StatusPending,
+		Mode:          model.ModeOnDemand,
+	}
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		if err := rideRepo.CreateRideRequest(request); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideRequestCreated, request.ID, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.queueDispatch(request.ID)
+	return request, nil
+}
+
+// GetOpenDispatches lists the trips offered to a driver that wait for their answer
+func (s *RideService) GetOpenDispatches(driverID uuid.UUID) ([]DispatchDetails, error) {
+	dispatches, err := s.rideRepo.FindOpenDispatchesByDriverID(driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]DispatchDetails, 0, len(dispatches))
+	for _, dispatch := range dispatches {
+		request, err := s.rideRepo.FindRideRequestByID(dispatch.RideRequestID)
+		if err != nil {
+			return nil, err
+		}
+		if request == nil {
+			continue
+		}
+		fare, err := s.tripFare(request.StartLocation, request.EndLocation)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, DispatchDetails{Dispatch: dispatch, RideRequest: *request, Fare: fare})
+	}
+	return details, nil
+}
+
+// AcceptDispatch assigns an on-demand trip to the driver it was offered to.
+// The trip becomes a confirmed ride offer from the driver's location to the passenger's
+// destination, with a confirmed match for the passenger and their pickup and drop-off as stops.
+// The driver is not offered other trips until this one is completed or cancelled.
+func (s *RideService) AcceptDispatch(dispatchID uuid.UUID, driverID uuid.UUID) (*model.RideMatch, error) {
+	dispatch, err := s.rideRepo.FindDispatchByID(dispatchID)
+	if err != nil {
+		return nil, err
+	}
+	if dispatch == nil {
+		return nil, errors.New("dispatch not found")
+	}
+	if dispatch.DriverID != driverID {
+		return nil, errors.New("unauthorized: the trip was not offered to this driver")
+	}
+	request, err := s.rideRepo.FindRideRequestByID(dispatch.RideRequestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, errors.New("ride request not found")
+	}
+	fare, err := s.tripFare(request.StartLocation, request.EndLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	var match *model.RideMatch
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in request, availability, dispatch order, like offering a trip does
+		request, err := rideRepo.LockRideRequestByID(dispatch.RideRequestID)
+		if err != nil {
+			return err
+		}
+		if request == nil {
+			return errors.New("ride request not found")
+		}
+		availability, err := rideRepo.LockDriverAvailability(driverID)
+		if err != nil {
+			return err
+		}
+		if availability == nil {
+			return errors.New("driver availability not found")
+		}
+		dispatch, err = rideRepo.LockDispatchByID(dispatchID)
+		if err != nil {
+			return err
+		}
+		if dispatch == nil {
+			return errors.New("dispatch not found")
+		}
+
+		now := time.Now()
+		if !dispatch.Open() || now.After(dispatch.ExpiresAt) || request.Status != model.StatusPending {
+			return ErrDispatchClosed
+		}
+		if availability.CurrentTripID != nil {
+			return ErrDriverOnTrip
+		}
+
+		// The driver takes only this passenger, so the trip has no seats left
+		offer := &model.RideOffer{
+			DriverID:       driverID,
+			StartLocation:  availability.Location,
+			EndLocation:    request.EndLocation,
+			DepartureTime:  now,
+			AvailableSeats: 0,
+			PricePerSeat:   fare / float64(request.NumPassengers),
+			Status:         model.StatusConfirmed,
+		}
+		if err := rideRepo.CreateRideOffer(offer); err != nil {
+			return err
+		}
+		if err := rideRepo.SetDriverCurrentTrip(driverID, &offer.ID); err != nil {
+			return err
+		}
+
+		requestedAt := request.CreatedAt
+		match = &model.RideMatch{
+			RideOfferID:         offer.ID,
+			RideRequestID:       request.ID,
+			Status:              model.StatusConfirmed,
+			Price:               fare,
+			DriverAcceptedAt:    &now,
+			PassengerAcceptedAt: &requestedAt,
+		}
+		if err := rideRepo.CreateRideMatch(match); err != nil {
+			return err
+		}
+		if err := request.TransitionTo(model.StatusMatched); err != nil {
+			return err
+		}
+		if err := request.TransitionTo(model.StatusConfirmed); err != nil {
+			return err
+		}
+		if err := rideRepo.UpdateRideRequest(request); err != nil {
+			return err
+		}
+		if err := rideRepo.ReplaceRideStops(offer.ID, []model.RideStop{pickupStop(request), dropoffStop(request)}); err != nil {
+			return err
+		}
+		if err := recordEvent(rideRepo, model.EventRideOfferCreated, offer.ID, offer); err != nil {
+			return err
+		}
+		if err := recordEvent(rideRepo, model.EventMatchConfirmed, match.ID, match); err != nil {
+			return err
+		}
+
+		dispatch.Status = model.DispatchAccepted
+		dispatch.RespondedAt = &now
+		dispatch.RideMatchID = &match.ID
+		return rideRepo.UpdateDispatch(dispatch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(context.Background(), []notify.Notification{{
+		UserID:  request.PassengerID,
+		Type:    notify.TripAccepted,
+		RideID:  match.ID,
+		Message: fmt.Sprintf("A driver %.1f km away accepted your trip", dispatch.DistanceKm),
+	}})
+	s.publish(matchEvents(events.MatchConfirmed, match, driverID, request.PassengerID))
+	return match, nil
+}
+
+// DeclineDispatch turns down an on-demand trip on behalf of the driver it was offered to.
+// The trip is offered to the next nearest driver.
+func (s *RideService) DeclineDispatch(dispatchID uuid.UUID, driverID uuid.UUID) error {
+	var requestID uuid.UUID
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		dispatch, err := rideRepo.LockDispatchByID(dispatchID)
+		if err != nil {
+			return err
+		}
+		if dispatch == nil {
+			return errors.New("dispatch not found")
+		}
+		if dispatch.DriverID != driverID {
+			return errors.New("unauthorized: the trip was not offered to this driver")
+		}
+		if !dispatch.Open() {
+			return ErrDispatchClosed
+		}
+
+		now := time.Now()
+		dispatch.Status = model.DispatchDeclined
+		dispatch.RespondedAt = &now
+		requestID = dispatch.RideRequestID
+		return rideRepo.UpdateDispatch(dispatch)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.queueDispatch(requestID)
+	return nil
+}
+
+// DispatchRides times out the trips drivers did not answer in time and offers every on-demand
+// request still waiting for a driver to the next nearest one. Requests nobody took within the
+// maximum wait expire.
+func (s *RideService) DispatchRides(ctx context.Context) error {
+	overdue, err := s.rideRepo.FindOverdueDispatches(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, dispatch := range overdue {
+		if err := s.timeOutDispatch(dispatch.ID); err != nil {
+			return err
+		}
+	}
+
+	requests, err := s.rideRepo.FindPendingRideRequests(model.ModeOnDemand, time.Time{})
+	if err != nil {
+		return err
+	}
+	var failures []error
+	for _, request := range requests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.dispatchRequest(ctx, request.ID); err != nil {
+			failures = append(failures, fmt.Errorf("request %s: %w", request.ID, err))
+		}
+	}
+
+	if len(overdue) > 0 {
+		log.Printf("Dispatch timed out %d unanswered trips", len(overdue))
+	}
+	return errors.Join(failures...)
+}
+
+// queueDispatch offers an on-demand request to the next driver on the job queue.
+// The dispatch sweep picks up requests whose dispatch could not be queued.
+func (s *RideService) queueDispatch(requestID uuid.UUID) {
+	name := "dispatch ride request " + requestID.String()
+	run := func(ctx context.Context) error {
+		return s.dispatchRequest(ctx, requestID)
+	}
+	if err := s.jobQueue.Enqueue(jobs.Job{Name: name, Run: run}); err != nil {
+		log.Printf("Failed to queue %s: %v", name, err)
+	}
+}
+
+// dispatchRequest offers an on-demand request to the nearest available driver it was not offered
+// to yet. Nothing happens while a driver is deciding; when no driver is free the request waits
+// for the next sweep, until it has waited too long and expires.
+func (s *RideService) dispatchRequest(ctx context.Context, requestID uuid.UUID) error {
+	request, err := s.rideRepo.FindRideRequestByID(requestID)
+	if err != nil {
+		return err
+	}
+	if request == nil {
+		return jobs.Permanent(errors.New("ride request not found"))
+	}
+	if request.Mode != model.ModeOnDemand || request.Status != model.StatusPending {
+		return nil
+	}
+
+	dispatches, err := s.rideRepo.FindDispatchesByRequestID(request.ID)
+	if err != nil {
+		return err
+	}
+	offered := make(map[uuid.UUID]bool, len(dispatches))
+	for _, dispatch := range dispatches {
+		if dispatch.Open() {
+			return nil
+		}
+		offered[dispatch.DriverID] = true
+	}
+
+	if time.Since(request.CreatedAt) > s.dispatch.MaxWait {
+		return s.expireOnDemandRequest(ctx, request.ID)
+	}
+
+	drivers, err := s.rideRepo.FindAvailableDrivers(request.StartLocation, s.dispatch.RadiusKm, time.Now().Add(-s.dispatch.LocationMaxAge))
+	if err != nil {
+		return err
+	}
+	for _, driver := range drivers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if offered[driver.DriverID] || driver.DriverID == request.PassengerID {
+			continue
+		}
+
+		// The car must fit the whole party
+		driverProfile, err := s.userRepo.GetDriverProfile(driver.DriverID)
+		if err != nil {
+			return err
+		}
+		if driverProfile == nil || driverProfile.NumSeats < request.NumPassengers {
+			continue
+		}
+
+		distance := geo.Distance(request.StartLocation.Latitude, request.StartLocation.Longitude,
+			driver.Location.Latitude, driver.Location.Longitude)
+		dispatch, err := s.offerTrip(request.ID, driver.DriverID, distance)
+		if errors.Is(err, errNotDispatching) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if dispatch == nil {
+			continue
+		}
+
+		s.notify(ctx, []notify.Notification{{
+			UserID: driver.DriverID,
+			Type:   notify.TripOffered,
+			RideID: dispatch.ID,
+			Message: fmt.Sprintf("A passenger %.1f km away wants a ride now; accept within %s",
+				distance, s.dispatch.AcceptWindow),
+		}})
+		return nil
+	}
+	return nil
+}
+
+// offerTrip offers an on-demand request to a driver. It returns nil if the driver went offline,
+// accepted or was offered another trip meanwhile, and errNotDispatching if the request no longer waits
+// for a driver.
+func (s *RideService) offerTrip(requestID uuid.UUID, driverID uuid.UUID, distanceKm float64) (*model.Dispatch, error) {
+	var dispatch *model.Dispatch
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		request, err := rideRepo.LockRideRequestByID(requestID)
+		if err != nil {
+			return err
+		}
+		if request == nil || request.Status != model.StatusPending {
+			return errNotDispatching
+		}
+		dispatches, err := rideRepo.FindDispatchesByRequestID(request.ID)
+		if err != nil {
+			return err
+		}
+		for _, existing := range dispatches {
+			if existing.Open() {
+				return errNotDispatching
+			}
+		}
+
+		// Drivers answer one trip at a time
+		availability, err := rideRepo.LockDriverAvailability(driverID)
+		if err != nil {
+			return err
+		}
+		if availability == nil || !availability.Online || availability.CurrentTripID != nil {
+			return nil
+		}
+		open, err := rideRepo.FindOpenDispatchesByDriverID(driverID)
+		if err != nil {
+			return err
+		}
+		if len(open) > 0 {
+			return nil
+		}
+
+		dispatch = &model.Dispatch{
+			RideRequestID: request.ID,
+			DriverID:      driverID,
+			Status:        model.DispatchOffered,
+			DistanceKm:    distanceKm,
+			ExpiresAt:     time.Now().Add(s.dispatch.AcceptWindow),
+		}
+		return rideRepo.CreateDispatch(dispatch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dispatch, nil
+}
+
+// timeOutDispatch closes a dispatch the driver did not answer in time
+func (s *RideService) timeOutDispatch(dispatchID uuid.UUID) error {
+	return s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		dispatch, err := rideRepo.LockDispatchByID(dispatchID)
+		if err != nil {
+			return err
+		}
+		if dispatch == nil || !dispatch.Open() || time.Now().Before(dispatch.ExpiresAt) {
+			return nil
+		}
+		dispatch.Status = model.DispatchTimedOut
+		return rideRepo.UpdateDispatch(dispatch)
+	})
+}
+
+// expireOnDemandRequest gives up on an on-demand request no driver took and tells the passenger
+func (s *RideService) expireOnDemandRequest(ctx context.Context, requestID uuid.UUID) error {
+	var request *model.RideRequest
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		var err error
+		request, err = rideRepo.LockRideRequestByID(requestID)
+		if err != nil {
+			return err
+		}
+		if request == nil || request.Status != model.StatusPending {
+			request = nil
+			return nil
+		}
+		if err := request.TransitionTo(model.StatusExpired); err != nil {
+			return err
+		}
+		if err := rideRepo.UpdateRideRequest(request); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideRequestExpired, request.ID, request)
+	})
+	if err != nil || request == nil {
+		return err
+	}
+
+	s.notify(ctx, []notify.Notification{{
+		UserID:  request.PassengerID,
+		Type:    notify.TripUnavailable,
+		RideID:  request.ID,
+		Message: "No driver was available for your trip",
+	}})
+	return nil
+}
+
+// cancelDispatches closes the trips of a request that drivers have not answered yet
+func cancelDispatches(rideRepo repository.RideRepository, requestID uuid.UUID) error {
+	dispatches, err := rideRepo.FindDispatchesByRequestID(requestID)
+	if err != nil {
+		return err
+	}
+	for i := range dispatches {
+		if !dispatches[i].Open() {
+			continue
+		}
+		dispatches[i].Status = model.DispatchCancelled
+		if err := rideRepo.UpdateDispatch(&dispatches[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseDriver lets the driver of an on-demand trip be offered other trips once it is over.
+// Offers that are not the driver's current trip leave the driver as they are.
+func releaseDriver(rideRepo repository.RideRepository, offer *model.RideOffer) error {
+	availability, err := rideRepo.LockDriverAvailability(offer.DriverID)
+	if err != nil || availability == nil {
+		return err
+	}
+	if availability.CurrentTripID == nil || *availability.CurrentTripID != offer.ID {
+		return nil
+	}
+	return rideRepo.SetDriverCurrentTrip(offer.DriverID, nil)
+}
+
+// tripFare returns the price of an on-demand trip between two points
+func (s *RideService) tripFare(pickup, dropoff model.Location) (float64, error) {
+	distance, err := s.routeEstimator.RouteDistance(pickup, dropoff)
+	if err != nil {
+		return 0, err
+	}
+	return distance * s.dispatch.PricePerKm, nil
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+)
+
+// recordingNotifier keeps the notifications it is given
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []notify.Notification
+}
+
+// Notify records the notification
+func (n *recordingNotifier) Notify(ctx context.Context, notification notify.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// sent reports whether a user was sent a notification of the given type
+func (n *recordingNotifier) sent(userID uuid.UUID, notificationType notify.Type) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, notification := range n.notifications {
+		if notification.UserID == userID && notification.Type == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// goOnline registers a driver with a car of the given size, online the given distance north of the central station
+func goOnline(t *testing.T, service *RideService, userRepo repository.UserRepository, seats int, degreesNorth float64) uuid.UUID {
+	t.Helper()
+	driverID := uuid.New()
+	if err := userRepo.CreateDriverProfile(&model.DriverProfile{UserID: driverID, NumSeats: seats}); err != nil {
+		t.Fatalf("CreateDriverProfile: %v", err)
+	}
+	location := centralStation
+	location.Latitude += degreesNorth
+	if _, err := service.SetDriverAvailability(driverID, true, &location); err != nil {
+		t.Fatalf("SetDriverAvailability: %v", err)
+	}
+	return driverID
+}
+
+// createOnDemandRequest saves a pending on-demand request for two passengers from the central station
+func createOnDemandRequest(t *testing.T, rideRepo repository.RideRepository) *model.RideRequest {
+	t.Helper()
+	request := createRequest(t, rideRepo, 2)
+	request.Mode = model.ModeOnDemand
+	request.DepartureTime = time.Now()
+	if err := rideRepo.UpdateRideRequest(request); err != nil {
+		t.Fatalf("UpdateRideRequest: %v", err)
+	}
+	return request
+}
+
+// openDispatch returns the dispatch of a request that waits for a driver's answer, failing if there is none
+func openDispatch(t *testing.T, rideRepo repository.RideRepository, requestID uuid.UUID) model.Dispatch {
+	t.Helper()
+	dispatches, err := rideRepo.FindDispatchesByRequestID(requestID)
+	if err != nil {
+		t.Fatalf("FindDispatchesByRequestID: %v", err)
+	}
+	var open []model.Dispatch
+	for _, dispatch := range dispatches {
+		if dispatch.Open() {
+			open = append(open, dispatch)
+		}
+	}
+	if len(open) != 1 {
+		t.Fatalf("%d open dispatches, want 1: %+v", len(open), dispatches)
+	}
+	return open[0]
+}
+
+// makeOverdue moves a dispatch's answer deadline into the past
+func makeOverdue(t *testing.T, rideRepo repository.RideRepository, dispatch model.Dispatch) {
+	t.Helper()
+	dispatch.ExpiresAt = time.Now().Add(-time.Second)
+	if err := rideRepo.UpdateDispatch(&dispatch); err != nil {
+		t.Fatalf("UpdateDispatch: %v", err)
+	}
+}
+
+func TestDispatchCascadesToTheNextNearestDriver(t *testing.T) {
+	service, rideRepo, userRepo := newTestService(t)
+	notifier := &recordingNotifier{}
+	service.notifier = notifier
+	ctx := context.Background()
+
+	// The nearest car is too small for the party of two
+	tooSmall := goOnline(t, service, userRepo, 1, 0.005)
+	nearest := goOnline(t, service, userRepo, 3, 0.01)
+	second := goOnline(t, service, userRepo, 3, 0.02)
+	third := goOnline(t, service, userRepo, 3, 0.03)
+	request := createOnDemandRequest(t, rideRepo)
+
+	if err := service.dispatchRequest(ctx, request.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	first := openDispatch(t, rideRepo, request.ID)
+	if first.DriverID != nearest {
+		t.Fatalf("trip offered to %s, want the nearest driver whose car fits, %s", first.DriverID, nearest)
+	}
+	if !notifier.sent(nearest, notify.TripOffered) || notifier.sent(tooSmall, notify.TripOffered) {
+		t.Error("the trip offer was not notified to the nearest driver only")
+	}
+
+	// Nothing moves while the driver decides
+	if err := service.dispatchRequest(ctx, request.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	if open := openDispatch(t, rideRepo, request.ID); open.ID != first.ID {
+		t.Fatalf("trip offered again to %s while %s was deciding", open.DriverID, nearest)
+	}
+
+	// A declined trip goes to the next nearest driver
+	if err := service.DeclineDispatch(first.ID, nearest); err != nil {
+		t.Fatalf("DeclineDispatch: %v", err)
+	}
+	if err := service.dispatchRequest(ctx, request.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	next := openDispatch(t, rideRepo, request.ID)
+	if next.DriverID != second {
+		t.Fatalf("declined trip offered to %s, want %s", next.DriverID, second)
+	}
+
+	// An unanswered trip times out and goes to the driver after that
+	makeOverdue(t, rideRepo, next)
+	if err := service.DispatchRides(ctx); err != nil {
+		t.Fatalf("DispatchRides: %v", err)
+	}
+	if timedOut, _ := rideRepo.FindDispatchByID(next.ID); timedOut == nil || timedOut.Status != model.DispatchTimedOut {
+		t.Fatalf("overdue dispatch = %+v, want it timed out", timedOut)
+	}
+	last := openDispatch(t, rideRepo, request.ID)
+	if last.DriverID != third {
+		t.Fatalf("timed out trip offered to %s, want %s", last.DriverID, third)
+	}
+
+	if _, err := service.AcceptDispatch(next.ID, second); !errors.Is(err, ErrDispatchClosed) {
+		t.Fatalf("AcceptDispatch of a timed out trip = %v, want ErrDispatchClosed", err)
+	}
+	match, err := service.AcceptDispatch(last.ID, third)
+	if err != nil {
+		t.Fatalf("AcceptDispatch: %v", err)
+	}
+	if match.Status != model.StatusConfirmed {
+		t.Errorf("match status = %s, want confirmed", match.Status)
+	}
+	if offer := findOffer(t, rideRepo, match.RideOfferID); offer.DriverID != third || offer.AvailableSeats != 0 || offer.Status != model.StatusConfirmed {
+		t.Errorf("trip = %+v, want a confirmed offer of %s with no seats left", offer, third)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusConfirmed {
+		t.Errorf("request status = %s, want confirmed", status)
+	}
+	if !notifier.sent(request.PassengerID, notify.TripAccepted) {
+		t.Error("the passenger was not told a driver took the trip")
+	}
+
+	// A trip that was taken is offered to nobody else
+	if err := service.dispatchRequest(ctx, request.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	dispatches, err := rideRepo.FindDispatchesByRequestID(request.ID)
+	if err != nil || len(dispatches) != 3 {
+		t.Fatalf("FindDispatchesByRequestID = %d dispatches, %v, want 3", len(dispatches), err)
+	}
+}
+
+func TestDispatchExpiresRequestsAfterMaxWait(t *testing.T) {
+	service, rideRepo, userRepo := newTestService(t)
+	notifier := &recordingNotifier{}
+	service.notifier = notifier
+	ctx := context.Background()
+
+	// Without a driver nearby the request keeps waiting until MaxWait
+	request := createOnDemandRequest(t, rideRepo)
+	if err := service.DispatchRides(ctx); err != nil {
+		t.Fatalf("DispatchRides: %v", err)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusPending {
+		t.Fatalf("request status = %s, want pending within the maximum wait", status)
+	}
+
+	driver := goOnline(t, service, userRepo, 3, 0.01)
+	if err := service.DispatchRides(ctx); err != nil {
+		t.Fatalf("DispatchRides: %v", err)
+	}
+	dispatch := openDispatch(t, rideRepo, request.ID)
+
+	// The request has now waited too long, but the driver may still accept
+	request.CreatedAt = time.Now().Add(-service.dispatch.MaxWait - time.Minute)
+	if err := rideRepo.UpdateRideRequest(request); err != nil {
+		t.Fatalf("UpdateRideRequest: %v", err)
+	}
+	if err := service.DispatchRides(ctx); err != nil {
+		t.Fatalf("DispatchRides: %v", err)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusPending {
+		t.Fatalf("request status = %s, want pending while a driver decides", status)
+	}
+
+	// Once the driver lets the trip time out it is not offered again
+	goOnline(t, service, userRepo, 3, 0.02)
+	makeOverdue(t, rideRepo, dispatch)
+	if err := service.DispatchRides(ctx); err != nil {
+		t.Fatalf("DispatchRides: %v", err)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusExpired {
+		t.Errorf("request status = %s, want expired", status)
+	}
+	dispatches, err := rideRepo.FindDispatchesByRequestID(request.ID)
+	if err != nil || len(dispatches) != 1 || dispatches[0].Status != model.DispatchTimedOut {
+		t.Errorf("dispatches = %+v, %v, want only the timed out one", dispatches, err)
+	}
+	if !notifier.sent(request.PassengerID, notify.TripUnavailable) {
+		t.Error("the passenger was not told no driver was available")
+	}
+	if _, err := service.AcceptDispatch(dispatch.ID, driver); !errors.Is(err, ErrDispatchClosed) {
+		t.Errorf("AcceptDispatch after expiry = %v, want ErrDispatchClosed", err)
+	}
+}
+
+func TestDispatchSkipsDriversOnATrip(t *testing.T) {
+	service, rideRepo, userRepo := newTestService(t)
+	ctx := context.Background()
+
+	driver := goOnline(t, service, userRepo, 3, 0.01)
+	first := createOnDemandRequest(t, rideRepo)
+	if err := service.dispatchRequest(ctx, first.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	match, err := service.AcceptDispatch(openDispatch(t, rideRepo, first.ID).ID, driver)
+	if err != nil {
+		t.Fatalf("AcceptDispatch: %v", err)
+	}
+
+	// While on the trip the driver is not offered another one
+	second := createOnDemandRequest(t, rideRepo)
+	if err := service.dispatchRequest(ctx, second.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	if dispatches, err := rideRepo.FindDispatchesByRequestID(second.ID); err != nil || len(dispatches) != 0 {
+		t.Fatalf("dispatches = %+v, %v, want none while the only driver is on a trip", dispatches, err)
+	}
+
+	// Nor can they accept one offered before they took the first
+	stale := &model.Dispatch{
+		RideRequestID: second.ID,
+		DriverID:      driver,
+		Status:        model.DispatchOffered,
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	if err := rideRepo.CreateDispatch(stale); err != nil {
+		t.Fatalf("CreateDispatch: %v", err)
+	}
+	if _, err := service.AcceptDispatch(stale.ID, driver); !errors.Is(err, ErrDriverOnTrip) {
+		t.Fatalf("AcceptDispatch during a trip = %v, want ErrDriverOnTrip", err)
+	}
+	if err := service.DeclineDispatch(stale.ID, driver); err != nil {
+		t.Fatalf("DeclineDispatch: %v", err)
+	}
+
+	// Once the trip is over the driver takes trips again
+	if err := service.StartRide(match.RideOfferID, driver); err != nil {
+		t.Fatalf("StartRide: %v", err)
+	}
+	if err := service.CompleteRide(match.RideOfferID, driver); err != nil {
+		t.Fatalf("CompleteRide: %v", err)
+	}
+	third := createOnDemandRequest(t, rideRepo)
+	if err := service.dispatchRequest(ctx, third.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	if dispatch := openDispatch(t, rideRepo, third.ID); dispatch.DriverID != driver {
+		t.Fatalf("trip offered to %s, want %s after their trip was completed", dispatch.DriverID, driver)
+	}
+}
+
+func TestCancelledTripFreesTheDriver(t *testing.T) {
+	service, rideRepo, userRepo := newTestService(t)
+	ctx := context.Background()
+
+	driver := goOnline(t, service, userRepo, 3, 0.01)
+	request := createOnDemandRequest(t, rideRepo)
+	if err := service.dispatchRequest(ctx, request.ID); err != nil {
+		t.Fatalf("dispatchRequest: %v", err)
+	}
+	if _, err := service.AcceptDispatch(openDispatch(t, rideRepo, request.ID).ID, driver); err != nil {
+		t.Fatalf("AcceptDispatch: %v", err)
+	}
+	if availability, err := rideRepo.FindDriverAvailability(driver); err != nil || availability.CurrentTripID == nil {
+		t.Fatalf("FindDriverAvailability = %+v, %v, want the accepted trip recorded", availability, err)
+	}
+
+	if err := service.CancelRideRequest(request.ID, request.PassengerID); err != nil {
+		t.Fatalf("CancelRideRequest: %v", err)
+	}
+	if availability, err := rideRepo.FindDriverAvailability(driver); err != nil || availability.CurrentTripID != nil {
+		t.Fatalf("FindDriverAvailability = %+v, %v, want the driver free after the passenger cancelled", availability, err)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+// MatchDryRun is the outcome of scoring a ride offer and request pair without proposing a match
+type MatchDryRun struct {
+	RideOfferID   uuid.UUID            `json:"ride_offer_id"`
+	RideRequestID uuid.UUID            `json:"ride_request_id"`
+	Matched       bool                 `json:"matched"`
+	Breakdown     model.MatchBreakdown `json:"breakdown"`
+}
+
+// DryRunMatch scores a ride offer and request pair the way matching does and explains why it
+// would or would not be matched. Nothing is stored. It returns nil if either does not exist.
+// Seats held by other pooled passengers are not taken into account.
+func (s *RideService) DryRunMatch(offerID uuid.UUID, requestID uuid.UUID) (*MatchDryRun, error) {
+	offer, err := s.rideRepo.FindRideOfferByID(offerID)
+	if err != nil {
+		return nil, err
+	}
+	request, err := s.rideRepo.FindRideRequestByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil || request == nil {
+		return nil, nil
+	}
+	if offer.Waypoints, err = s.rideRepo.FindRideWaypointsByOfferID(offer.ID); err != nil {
+		return nil, err
+	}
+
+	breakdown := s.evaluateMatch(offer, request)
+
+	// Pairs that matching never considers are rejected whatever their score
+	reason, err := s.matchPrecondition(offer, request)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		breakdown.Rejection = reason
+	}
+
+	return &MatchDryRun{
+		RideOfferID:   offer.ID,
+		RideRequestID: request.ID,
+		Matched:       breakdown.Rejection == "",
+		Breakdown:     breakdown,
+	}, nil
+}
+
+// matchPrecondition returns why matching would not consider a pair at all, or "" if it would
+func (s *RideService) matchPrecondition(offer *model.RideOffer, request *model.RideRequest) (string, error) {
+	if offer.DriverID == request.PassengerID {
+		return "the driver cannot ride as their own passenger", nil
+	}
+	if !offer.AcceptsMatches() {
+		return fmt.Sprintf("the ride offer is %s", offer.Status), nil
+	}
+	if !request.CanTransitionTo(model.StatusMatched) {
+		return fmt.Sprintf("the ride request is %s", request.Status), nil
+	}
+
+	gap := offer.DepartureTime.Sub(request.DepartureTime)
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap > s.matching.Window {
+		return fmt.Sprintf("departures are %.0f minutes apart, more than the %.0f minute window",
+			gap.Minutes(), s.matching.Window.Minutes()), nil
+	}
+
+	existing, err := s.rideRepo.FindRideMatchByOfferAndRequest(offer.ID, request.ID)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return fmt.Sprintf("the pair was already proposed and is %s", existing.Status), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+)
+
+// publish hands events to the publisher; the changes they report have already been saved
+func (s *RideService) publish(published []events.Event) {
+	if s.publisher == nil || len(published) == 0 {
+		return
+	}
+	s.publisher.Publish(published...)
+}
+
+// matchEvents addresses an event about a match to both of its parties
+func matchEvents(eventType events.Type, match *model.RideMatch, driverID, passengerID uuid.UUID) []events.Event {
+	return []events.Event{
+		{Type: eventType, UserID: driverID, RideID: match.ID, Data: *match},
+		{Type: eventType, UserID: passengerID, RideID: match.ID, Data: *match},
+	}
+}
+
+// rideEvent addresses an event about a ride to a user, with the RideID being the user's own ride
+// offer or request. Events that concern one passenger carry their match on the ride offer.
+func rideEvent(eventType events.Type, userID uuid.UUID, rideID uuid.UUID, match *model.RideMatch) events.Event {
+	event := events.Event{Type: eventType, UserID: userID, RideID: rideID}
+	if match != nil {
+		event.Data = *match
+	}
+	return event
+}
+
+// passengerRideEvents addresses an event about a ride to the passenger of a match on it
+func passengerRideEvents(rideRepo repository.RideRepository, eventType events.Type, match *model.RideMatch) ([]events.Event, error) {
+	request, err := rideRepo.FindRideRequestByID(match.RideRequestID)
+	if err != nil || request == nil {
+		return nil, err
+	}
+	return []events.Event{rideEvent(eventType, request.PassengerID, request.ID, match)}, nil
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+)
+
+// ExpiryOptions sets when stale rides and proposed matches expire
+type ExpiryOptions struct {
+	// Grace is how long after departure an unconfirmed offer or request expires
+	Grace time.Duration
+	// MatchTTL is how long a proposed match waits for both parties to accept; 0 disables it
+	MatchTTL time.Duration
+}
+
+// ExpireRides expires proposed matches that were not confirmed within the match TTL, and
+// offers and requests that departed more than the grace period ago without being confirmed.
+// Each ride expires in its own transaction, and the affected users are notified once it commits.
+func (s *RideService) ExpireRides(ctx context.Context) error {
+	now := time.Now()
+	var failures []error
+	var expired int
+
+	if s.expiry.MatchTTL > 0 {
+		matches, err := s.rideRepo.FindStaleRideMatches(now.Add(-s.expiry.MatchTTL))
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			notifications, err := s.expireRideMatch(match.ID)
+			if err != nil {
+				failures = append(failures, fmt.Errorf("match %s: %w", match.ID, err))
+				continue
+			}
+			if len(notifications) > 0 {
+				expired++
+			}
+			s.notify(ctx, notifications)
+		}
+	}
+
+	departedBefore := now.Add(-s.expiry.Grace)
+	offers, err := s.rideRepo.FindStaleRideOffers(departedBefore)
+	if err != nil {
+		return err
+	}
+	for _, offer := range offers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		notifications, err := s.expireRideOffer(offer.ID, departedBefore)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("offer %s: %w", offer.ID, err))
+			continue
+		}
+		if len(notifications) > 0 {
+			expired++
+		}
+		s.notify(ctx, notifications)
+	}
+
+	requests, err := s.rideRepo.FindStaleRideRequests(departedBefore)
+	if err != nil {
+		return err
+	}
+	for _, request := range requests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// On-demand requests expire when dispatch finds no driver for them
+		if request.Mode == model.ModeOnDemand {
+			continue
+		}
+		notifications, err := s.expireRideRequest(request.ID, departedBefore)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("request %s: %w", request.ID, err))
+			continue
+		}
+		if len(notifications) > 0 {
+			expired++
+		}
+		s.notify(ctx, notifications)
+	}
+
+	if expired > 0 {
+		log.Printf("Expired %d stale rides and matches", expired)
+	}
+	return errors.Join(failures...)
+}
+
+// expireRideMatch lapses a proposed match and returns the offer and request to matching.
+// A match that was confirmed, rejected or cancelled meanwhile is left alone.
+func (s *RideService) expireRideMatch(matchID uuid.UUID) ([]notify.Notification, error) {
+	match, err := s.rideRepo.FindRideMatchByID(matchID)
+	if err != nil || match == nil {
+		return nil, err
+	}
+
+	var notifications []notify.Notification
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in offer, request, match order to avoid deadlocks
+		offer, err := rideRepo.LockRideOfferByID(match.RideOfferID)
+		if err != nil {
+			return err
+		}
+		request, err := rideRepo.LockRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return err
+		}
+		match, err = rideRepo.LockRideMatchByID(matchID)
+		if err != nil {
+			return err
+		}
+		if offer == nil || request == nil || match == nil || match.Status != model.StatusMatched {
+			return nil
+		}
+
+		if err := expireMatch(rideRepo, match); err != nil {
+			return err
+		}
+		if isOpenStatus(offer.Status) {
+			if err := syncRideOfferStatus(rideRepo, offer); err != nil {
+				return err
+			}
+		}
+		if err := syncRideRequestStatus(rideRepo, request.ID); err != nil {
+			return err
+		}
+
+		notifications = matchExpiredNotifications(match, offer.DriverID, request.PassengerID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// expireRideOffer expires an unconfirmed offer that departed before the given time,
+// lapsing its proposed matches and returning their requests to matching
+func (s *RideService) expireRideOffer(offerID uuid.UUID, departedBefore time.Time) ([]notify.Notification, error) {
+	var notifications []notify.Notification
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		offer, err := rideRepo.LockRideOfferByID(offerID)
+		if err != nil {
+			return err
+		}
+		if offer == nil || !offer.CanTransitionTo(model.StatusExpired) || !offer.DepartureTime.Before(departedBefore) {
+			return nil
+		}
+		if err := offer.TransitionTo(model.StatusExpired); err != nil {
+			return err
+		}
+
+		matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+		if err != nil {
+			return err
+		}
+		for i := range matches {
+			match := &matches[i]
+			if match.Status != model.StatusMatched {
+				continue
+			}
+			request, err := rideRepo.FindRideRequestByID(match.RideRequestID)
+			if err != nil {
+				return err
+			}
+			if err := expireMatch(rideRepo, match); err != nil {
+				return err
+			}
+			if err := syncRideRequestStatus(rideRepo, match.RideRequestID); err != nil {
+				return err
+			}
+			if request != nil {
+				notifications = append(notifications, notify.Notification{
+					UserID:  request.PassengerID,
+					Type:    notify.RideMatchExpired,
+					RideID:  match.ID,
+					Message: "The ride you were matched with departed without being confirmed",
+				})
+			}
+		}
+
+		if err := rideRepo.UpdateRideOffer(offer); err != nil {
+			return err
+		}
+		if err := recordEvent(rideRepo, model.EventRideOfferExpired, offer.ID, offer); err != nil {
+			return err
+		}
+		notifications = append(notifications, notify.Notification{
+			UserID:  offer.DriverID,
+			Type:    notify.RideOfferExpired,
+			RideID:  offer.ID,
+			Message: "Your ride offer departed without being confirmed and has expired",
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// expireRideRequest expires an unconfirmed request that departed before the given time,
+// lapsing its proposed matches and updating the offers they were on
+func (s *RideService) expireRideRequest(requestID uuid.UUID, departedBefore time.Time) ([]notify.Notification, error) {
+	var notifications []notify.Notification
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in offer, request, match order to avoid deadlocks
+		offers, err := lockRequestOffers(rideRepo, requestID)
+		if err != nil {
+			return err
+		}
+		request, err := rideRepo.LockRideRequestByID(requestID)
+		if err != nil {
+			return err
+		}
+		if request == nil || !request.CanTransitionTo(model.StatusExpired) || !request.DepartureTime.Before(departedBefore) {
+			return nil
+		}
+		if err := request.TransitionTo(model.StatusExpired); err != nil {
+			return err
+		}
+
+		matches, err := lockRequestMatches(rideRepo, request.ID)
+		if err != nil {
+			return err
+		}
+		for i := range matches {
+			match := &matches[i]
+			if match.Status != model.StatusMatched {
+				continue
+			}
+			offer, err := requestOffer(rideRepo, offers, match.RideOfferID)
+			if err != nil {
+				return err
+			}
+			if err := expireMatch(rideRepo, match); err != nil {
+				return err
+			}
+			if offer == nil {
+				continue
+			}
+			notifications = append(notifications, notify.Notification{
+				UserID:  offer.DriverID,
+				Type:    notify.RideMatchExpired,
+				RideID:  match.ID,
+				Message: "A passenger you were matched with departed without confirming",
+			})
+			if isOpenStatus(offer.Status) {
+				if err := syncRideOfferStatus(rideRepo, offer); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := rideRepo.UpdateRideRequest(request); err != nil {
+			return err
+		}
+		if err := recordEvent(rideRepo, model.EventRideRequestExpired, request.ID, request); err != nil {
+			return err
+		}
+		notifications = append(notifications, notify.Notification{
+			UserID:  request.PassengerID,
+			Type:    notify.RideRequestExpired,
+			RideID:  request.ID,
+			Message: "Your ride request departed without being confirmed and has expired",
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// expireMatch marks a single proposed match as expired and takes its stops off the route
+func expireMatch(rideRepo repository.RideRepository, match *model.RideMatch) error {
+	if err := match.TransitionTo(model.StatusExpired); err != nil {
+		return err
+	}
+	if err := rideRepo.UpdateRideMatch(match); err != nil {
+		return err
+	}
+	if err := recordEvent(rideRepo, model.EventMatchExpired, match.ID, match); err != nil {
+		return err
+	}
+	return removeRideStops(rideRepo, match.RideOfferID, match.RideRequestID)
+}
+
+// matchExpiredNotifications tells both parties of a match that it lapsed
+func matchExpiredNotifications(match *model.RideMatch, driverID, passengerID uuid.UUID) []notify.Notification {
+	message := "A proposed ride match was not confirmed in time and has expired"
+	return []notify.Notification{
+		{UserID: driverID, Type: notify.RideMatchExpired, RideID: match.ID, Message: message},
+		{UserID: passengerID, Type: notify.RideMatchExpired, RideID: match.ID, Message: message},
+	}
+}
+
+// notify delivers notifications, logging the ones that fail; the change they report has already been saved
+func (s *RideService) notify(ctx context.Context, notifications []notify.Notification) {
+	for _, notification := range notifications {
+		if err := s.notifier.Notify(ctx, notification); err != nil {
+			log.Printf("Failed to notify user %s of %s: %v", notification.UserID, notification.Type, err)
+		}
+	}
+}
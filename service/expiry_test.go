@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+)
+
+// departedAgo moves the departure of an offer and its passenger's request into the past
+func departedAgo(t *testing.T, rideRepo repository.RideRepository, offer *model.RideOffer, request *model.RideRequest, ago time.Duration) {
+	t.Helper()
+	offer.DepartureTime = time.Now().Add(-ago)
+	if err := rideRepo.UpdateRideOffer(offer); err != nil {
+		t.Fatalf("UpdateRideOffer: %v", err)
+	}
+	request.DepartureTime = offer.DepartureTime
+	if err := rideRepo.UpdateRideRequest(request); err != nil {
+		t.Fatalf("UpdateRideRequest: %v", err)
+	}
+}
+
+// proposedAgo moves the time a match was proposed into the past
+func proposedAgo(t *testing.T, rideRepo repository.RideRepository, match *model.RideMatch, ago time.Duration) {
+	t.Helper()
+	match.CreatedAt = time.Now().Add(-ago)
+	if err := rideRepo.UpdateRideMatch(match); err != nil {
+		t.Fatalf("UpdateRideMatch: %v", err)
+	}
+}
+
+func TestExpireRidesAfterTheGracePeriod(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	notifier := &recordingNotifier{}
+	service.notifier = notifier
+
+	// The grace period is an hour
+	recent := createOffer(t, rideRepo, 3)
+	recentRequest := createRequest(t, rideRepo, 1)
+	recentMatch := createMatch(t, rideRepo, recent, recentRequest)
+	departedAgo(t, rideRepo, findOffer(t, rideRepo, recent.ID), findRequest(t, rideRepo, recentRequest.ID), 30*time.Minute)
+
+	stale := createOffer(t, rideRepo, 3)
+	staleRequest := createRequest(t, rideRepo, 1)
+	staleMatch := createMatch(t, rideRepo, stale, staleRequest)
+	departedAgo(t, rideRepo, findOffer(t, rideRepo, stale.ID), findRequest(t, rideRepo, staleRequest.ID), 2*time.Hour)
+
+	confirmed := createOffer(t, rideRepo, 3)
+	confirmedRequest := createRequest(t, rideRepo, 1)
+	confirmedMatch := createMatch(t, rideRepo, confirmed, confirmedRequest)
+	for _, userID := range []uuid.UUID{confirmed.DriverID, confirmedRequest.PassengerID} {
+		if _, err := service.ConfirmMatch(confirmedMatch.ID, userID); err != nil {
+			t.Fatalf("ConfirmMatch: %v", err)
+		}
+	}
+	departedAgo(t, rideRepo, findOffer(t, rideRepo, confirmed.ID), findRequest(t, rideRepo, confirmedRequest.ID), 2*time.Hour)
+
+	if err := service.ExpireRides(context.Background()); err != nil {
+		t.Fatalf("ExpireRides: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		offer   *model.RideOffer
+		request *model.RideRequest
+		match   *model.RideMatch
+		status  model.RideStatus
+	}{
+		{name: "within the grace period", offer: recent, request: recentRequest, match: recentMatch, status: model.StatusMatched},
+		{name: "past the grace period", offer: stale, request: staleRequest, match: staleMatch, status: model.StatusExpired},
+		{name: "confirmed", offer: confirmed, request: confirmedRequest, match: confirmedMatch, status: model.StatusConfirmed},
+	}
+	for _, tc := range cases {
+		offer, request, match := findOffer(t, rideRepo, tc.offer.ID), findRequest(t, rideRepo, tc.request.ID), findMatch(t, rideRepo, tc.match.ID)
+		if offer.Status != tc.status || request.Status != tc.status || match.Status != tc.status {
+			t.Errorf("%s: offer %s, request %s, match %s; want all %s", tc.name, offer.Status, request.Status, match.Status, tc.status)
+		}
+	}
+	if !notifier.sent(stale.DriverID, notify.RideOfferExpired) || !notifier.sent(staleRequest.PassengerID, notify.RideRequestExpired) {
+		t.Error("the driver and passenger of the expired rides were not told")
+	}
+	if notifier.sent(recent.DriverID, notify.RideOfferExpired) || notifier.sent(confirmed.DriverID, notify.RideOfferExpired) {
+		t.Error("a driver whose ride did not expire was told it had")
+	}
+}
+
+func TestExpireRidesLapsesMatchesAfterTheMatchTTL(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	notifier := &recordingNotifier{}
+	service.notifier = notifier
+	offer := createOffer(t, rideRepo, 3)
+	staleRequest := createRequest(t, rideRepo, 1)
+	freshRequest := createRequest(t, rideRepo, 1)
+	stale := createMatch(t, rideRepo, offer, staleRequest)
+	fresh := createMatch(t, rideRepo, offer, freshRequest)
+	proposedAgo(t, rideRepo, stale, 20*time.Minute)
+	proposedAgo(t, rideRepo, fresh, 5*time.Minute)
+
+	// Without a match TTL proposed matches wait until the ride departs
+	if err := service.ExpireRides(context.Background()); err != nil {
+		t.Fatalf("ExpireRides without a match TTL: %v", err)
+	}
+	if status := findMatch(t, rideRepo, stale.ID).Status; status != model.StatusMatched {
+		t.Fatalf("match status without a match TTL = %s, want matched", status)
+	}
+
+	service.expiry.MatchTTL = 15 * time.Minute
+	if err := service.ExpireRides(context.Background()); err != nil {
+		t.Fatalf("ExpireRides: %v", err)
+	}
+	if status := findMatch(t, rideRepo, stale.ID).Status; status != model.StatusExpired {
+		t.Errorf("match proposed 20 minutes ago = %s, want expired", status)
+	}
+	if status := findMatch(t, rideRepo, fresh.ID).Status; status != model.StatusMatched {
+		t.Errorf("match proposed 5 minutes ago = %s, want matched", status)
+	}
+
+	// The passenger goes back to matching, while the offer keeps its other passenger
+	if status := findRequest(t, rideRepo, staleRequest.ID).Status; status != model.StatusPending {
+		t.Errorf("request of the lapsed match = %s, want pending", status)
+	}
+	if status := findOffer(t, rideRepo, offer.ID).Status; status != model.StatusMatched {
+		t.Errorf("offer status = %s, want matched", status)
+	}
+	if !notifier.sent(offer.DriverID, notify.RideMatchExpired) || !notifier.sent(staleRequest.PassengerID, notify.RideMatchExpired) {
+		t.Error("the driver and passenger of the lapsed match were not told")
+	}
+	if notifier.sent(freshRequest.PassengerID, notify.RideMatchExpired) {
+		t.Error("the passenger of the fresh match was told it lapsed")
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+// PublicProfile is the part of a user's profile shown to the other side of a match
+type PublicProfile struct {
+	UserID    uuid.UUID `json:"user_id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Rating    *float32  `json:"rating,omitempty"`
+	CarModel  string    `json:"car_model,omitempty"`
+}
+
+// MatchDetails describes a ride match from the point of view of one of its parties
+type MatchDetails struct {
+	Match       model.RideMatch `json:"match"`
+	Role        model.UserRole  `json:"role"`
+	Counterpart PublicProfile   `json:"counterpart"`
+	MatchScore  float64         `json:"match_score"`
+	Price       float64         `json:"price"`
+	// ScoreBreakdown explains the match score; matches proposed before it was recorded have none
+	ScoreBreakdown *model.MatchBreakdown `json:"score_breakdown,omitempty"`
+}
+
+// GetMatchesForOffer lists the matches of a ride offer for its driver.
+// It returns nil if the offer does not exist or belongs to someone else.
+func (s *RideService) GetMatchesForOffer(offerID uuid.UUID, driverID uuid.UUID) ([]MatchDetails, error) {
+	offer, err := s.rideRepo.FindRideOfferByID(offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil || offer.DriverID != driverID {
+		return nil, nil
+	}
+
+	matches, err := s.rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]MatchDetails, 0, len(matches))
+	for _, match := range matches {
+		request, err := s.rideRepo.FindRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return nil, err
+		}
+		if request == nil {
+			continue
+		}
+
+		counterpart, err := s.passengerProfile(request.PassengerID)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, newMatchDetails(match, model.RoleDriver, counterpart))
+	}
+
+	return details, nil
+}
+
+// GetMatchesForRequest lists the matches of a ride request for its passenger.
+// It returns nil if the request does not exist or belongs to someone else.
+func (s *RideService) GetMatchesForRequest(requestID uuid.UUID, passengerID uuid.UUID) ([]MatchDetails, error) {
+	request, err := s.rideRepo.FindRideRequestByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || request.PassengerID != passengerID {
+		return nil, nil
+	}
+
+	matches, err := s.rideRepo.FindRideMatchesByRequestID(request.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]MatchDetails, 0, len(matches))
+	for _, match := range matches {
+		offer, err := s.rideRepo.FindRideOfferByID(match.RideOfferID)
+		if err != nil {
+			return nil, err
+		}
+		if offer == nil {
+			continue
+		}
+
+		counterpart, err := s.driverProfile(offer.DriverID)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, newMatchDetails(match, model.RolePassenger, counterpart))
+	}
+
+	return details, nil
+}
+
+// GetMatch retrieves a single ride match for one of its parties.
+// It returns nil if the match does not exist or the user is not part of it.
+func (s *RideService) GetMatch(matchID uuid.UUID, userID uuid.UUID) (*MatchDetails, error) {
+	match, err := s.rideRepo.FindRideMatchByID(matchID)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return nil, nil
+	}
+
+	offer, err := s.rideRepo.FindRideOfferByID(match.RideOfferID)
+	if err != nil {
+		return nil, err
+	}
+	request, err := s.rideRepo.FindRideRequestByID(match.RideRequestID)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil || request == nil {
+		return nil, nil
+	}
+
+	var details MatchDetails
+	switch userID {
+	case offer.DriverID:
+		counterpart, err := s.passengerProfile(request.PassengerID)
+		if err != nil {
+			return nil, err
+		}
+		details = newMatchDetails(*match, model.RoleDriver, counterpart)
+	case request.PassengerID:
+		counterpart, err := s.driverProfile(offer.DriverID)
+		if err != nil {
+			return nil, err
+		}
+		details = newMatchDetails(*match, model.RolePassenger, counterpart)
+	default:
+		return nil, nil
+	}
+
+	return &details, nil
+}
+
+// driverProfile builds the public profile of a driver, including rating and car model
+func (s *RideService) driverProfile(driverID uuid.UUID) (PublicProfile, error) {
+	profile, err := s.passengerProfile(driverID)
+	if err != nil {
+		return profile, err
+	}
+
+	driverProfile, err := s.userRepo.GetDriverProfile(driverID)
+	if err != nil {
+		return profile, err
+	}
+	if driverProfile != nil {
+		rating := driverProfile.AverageRating
+		profile.Rating = &rating
+		profile.CarModel = driverProfile.CarModel
+	}
+
+	return profile, nil
+}
+
+// passengerProfile builds the public profile of a passenger
+func (s *RideService) passengerProfile(userID uuid.UUID) (PublicProfile, error) {
+	profile := PublicProfile{UserID: userID}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return profile, err
+	}
+	if user != nil {
+		profile.FirstName = user.FirstName
+		profile.LastName = user.LastName
+	}
+
+	return profile, nil
+}
+
+// newMatchDetails assembles the view of a match for one of its parties
+func newMatchDetails(match model.RideMatch, role model.UserRole, counterpart PublicProfile) MatchDetails {
+	return MatchDetails{
+		Match:          match,
+		Role:           role,
+		Counterpart:    counterpart,
+		MatchScore:     match.MatchScore,
+		Price:          match.Price,
+		ScoreBreakdown: match.ScoreBreakdown,
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/eventbus"
+)
+
+const (
+	// defaultOutboxBatchSize is how many events a relay run reads at once when no batch size is configured
+	defaultOutboxBatchSize = 100
+	// defaultOutboxMaxAttempts is how many times an event is relayed before it is given up when no limit is configured
+	defaultOutboxMaxAttempts = 10
+)
+
+// OutboxOptions configures how domain events are relayed from the outbox
+type OutboxOptions struct {
+	// BatchSize is how many events are read from the outbox at once
+	BatchSize int
+	// MaxAttempts is how many failed attempts to relay an event are made before it is marked dead
+	MaxAttempts int
+	// Retention is how long delivered events stay in the outbox; 0 keeps them forever
+	Retention time.Duration
+}
+
+// recordEvent writes a domain event to the outbox with the aggregate's state as its payload.
+// It must be given the repository of the transaction making the change, so that the change and
+// its event are saved together or not at all.
+func recordEvent(rideRepo repository.RideRepository, eventType model.DomainEventType, aggregateID uuid.UUID, aggregate interface{}) error {
+	payload, err := json.Marshal(aggregate)
+	if err != nil {
+		return err
+	}
+	return rideRepo.CreateOutboxEvent(&model.OutboxEvent{
+		Type:        eventType,
+		AggregateID: aggregateID,
+		Payload:     model.EventPayload(payload),
+		OccurredAt:  time.Now(),
+	})
+}
+
+// OutboxRelay delivers the domain events waiting in the outbox to sinks
+type OutboxRelay struct {
+	rideRepo repository.RideRepository
+	options  OutboxOptions
+	sinks    []eventbus.Sink
+}
+
+// NewOutboxRelay creates a new OutboxRelay delivering to the given sinks
+func NewOutboxRelay(rideRepo repository.RideRepository, options OutboxOptions, sinks ...eventbus.Sink) *OutboxRelay {
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultOutboxBatchSize
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	return &OutboxRelay{
+		rideRepo: rideRepo,
+		options:  options,
+		sinks:    sinks,
+	}
+}
+
+// Relay delivers the waiting events to every sink, in the order they were written, until the outbox is empty.
+// When a sink fails to take an event, the failure is recorded on the event and the run stops there,
+// so that sinks keep seeing events in the order they occurred; the next run starts again from that
+// event and delivers it to every sink, including the ones that already took it. After MaxAttempts
+// failures the event is marked dead and skipped, so that one event no sink takes cannot hold up the rest.
+func (r *OutboxRelay) Relay(ctx context.Context) error {
+	for {
+		events, err := r.rideRepo.FindUnpublishedOutboxEvents(r.options.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range events {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			event := &events[i]
+			if err := r.deliver(ctx, event); err != nil {
+				event.Attempts++
+				event.LastError = err.Error()
+				if event.Attempts >= r.options.MaxAttempts {
+					event.Status = model.OutboxDead
+				}
+				if err := r.rideRepo.UpdateOutboxEvent(event); err != nil {
+					return err
+				}
+				if event.Status != model.OutboxDead {
+					return fmt.Errorf("failed to relay %s event %s: %w", event.Type, event.ID, err)
+				}
+				log.Printf("Gave up relaying %s event %s after %d attempts: %v", event.Type, event.ID, event.Attempts, err)
+				continue
+			}
+
+			now := time.Now()
+			event.Status = model.OutboxPublished
+			event.PublishedAt = &now
+			event.LastError = ""
+			if err := r.rideRepo.UpdateOutboxEvent(event); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < r.options.BatchSize {
+			return nil
+		}
+	}
+}
+
+// PurgeOutbox removes the delivered events older than the retention period
+func (r *OutboxRelay) PurgeOutbox(ctx context.Context) error {
+	if r.options.Retention <= 0 {
+		return nil
+	}
+	deleted, err := r.rideRepo.DeleteOutboxEventsPublishedBefore(time.Now().Add(-r.options.Retention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d delivered events from the outbox", deleted)
+	}
+	return nil
+}
+
+// deliver hands an event to every sink in turn
+func (r *OutboxRelay) deliver(ctx context.Context, event *model.OutboxEvent) error {
+	message := eventbus.Message{
+		ID:          event.ID,
+		Type:        string(event.Type),
+		AggregateID: event.AggregateID,
+		Payload:     json.RawMessage(event.Payload),
+		OccurredAt:  event.OccurredAt,
+	}
+	for _, sink := range r.sinks {
+		if err := sink.Deliver(ctx, message); err != nil {
+			return fmt.Errorf("%s: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/eventbus"
+	"github.com/yourusername/ride-sharing-app/repository/memory"
+)
+
+// recordingSink keeps the messages it takes and refuses the events of one aggregate
+type recordingSink struct {
+	refuse   uuid.UUID
+	messages []eventbus.Message
+}
+
+// Name identifies the sink
+func (s *recordingSink) Name() string { return "recording" }
+
+// Deliver records the message, or fails if it is about the refused aggregate
+func (s *recordingSink) Deliver(ctx context.Context, message eventbus.Message) error {
+	if message.AggregateID == s.refuse {
+		return errors.New("sink unavailable")
+	}
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// recordEvents writes one event per aggregate to the outbox
+func recordEvents(t *testing.T, rideRepo repository.RideRepository, aggregateIDs ...uuid.UUID) {
+	t.Helper()
+	for _, id := range aggregateIDs {
+		if err := recordEvent(rideRepo, model.EventRideOfferCreated, id, map[string]uuid.UUID{"id": id}); err != nil {
+			t.Fatalf("recordEvent: %v", err)
+		}
+	}
+}
+
+// outboxEvent returns the event of an aggregate among the given ones
+func outboxEvent(t *testing.T, aggregateID uuid.UUID, events []model.OutboxEvent) model.OutboxEvent {
+	t.Helper()
+	for _, event := range events {
+		if event.AggregateID == aggregateID {
+			return event
+		}
+	}
+	t.Fatalf("no outbox event for %s", aggregateID)
+	return model.OutboxEvent{}
+}
+
+func TestOutboxRelayDeliversInOrder(t *testing.T) {
+	rideRepo := memory.NewRideRepository()
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	recordEvents(t, rideRepo, ids...)
+
+	// A batch smaller than the outbox makes the relay read several batches
+	sink := &recordingSink{}
+	relay := NewOutboxRelay(rideRepo, OutboxOptions{BatchSize: 2}, sink)
+	if err := relay.Relay(context.Background()); err != nil {
+		t.Fatalf("Relay: %v", err)
+	}
+
+	if len(sink.messages) != len(ids) {
+		t.Fatalf("sink took %d events, want %d", len(sink.messages), len(ids))
+	}
+	for i, message := range sink.messages {
+		if message.AggregateID != ids[i] {
+			t.Fatalf("event %d is about %s, want %s: events are relayed in the order they were written", i+1, message.AggregateID, ids[i])
+		}
+	}
+	if waiting, err := rideRepo.FindUnpublishedOutboxEvents(10); err != nil || len(waiting) != 0 {
+		t.Errorf("FindUnpublishedOutboxEvents after relaying = %d events, %v, want none", len(waiting), err)
+	}
+}
+
+func TestOutboxRelayGivesUpAfterMaxAttempts(t *testing.T) {
+	rideRepo := memory.NewRideRepository()
+	first, refused, last := uuid.New(), uuid.New(), uuid.New()
+	recordEvents(t, rideRepo, first, refused, last)
+
+	sink := &recordingSink{refuse: refused}
+	relay := NewOutboxRelay(rideRepo, OutboxOptions{MaxAttempts: 2}, sink)
+
+	// The refused event holds up the ones after it while it has attempts left
+	if err := relay.Relay(context.Background()); err == nil {
+		t.Fatal("Relay succeeded, want the sink's failure")
+	}
+	if len(sink.messages) != 1 || sink.messages[0].AggregateID != first {
+		t.Fatalf("sink took %+v, want only the event before the refused one", sink.messages)
+	}
+	waiting, err := rideRepo.FindUnpublishedOutboxEvents(10)
+	if err != nil || len(waiting) != 2 {
+		t.Fatalf("FindUnpublishedOutboxEvents = %d events, %v, want the refused event and the one after it", len(waiting), err)
+	}
+	if failed := outboxEvent(t, refused, waiting); failed.Attempts != 1 || failed.LastError == "" {
+		t.Fatalf("refused event = %+v, want one failed attempt recorded", failed)
+	}
+
+	// On its last attempt it is marked dead and the relay moves past it
+	if err := relay.Relay(context.Background()); err != nil {
+		t.Fatalf("Relay: %v", err)
+	}
+	if len(sink.messages) != 2 || sink.messages[1].AggregateID != last {
+		t.Fatalf("sink took %+v, want the event after the dead one too", sink.messages)
+	}
+	if waiting, err := rideRepo.FindUnpublishedOutboxEvents(10); err != nil || len(waiting) != 0 {
+		t.Fatalf("FindUnpublishedOutboxEvents = %+v, %v, want none: dead events are skipped", waiting, err)
+	}
+
+	// A dead event is not tried again
+	sink.refuse = uuid.Nil
+	if err := relay.Relay(context.Background()); err != nil {
+		t.Fatalf("Relay: %v", err)
+	}
+	if len(sink.messages) != 2 {
+		t.Errorf("sink took %d events, want the dead event left alone", len(sink.messages))
+	}
+}
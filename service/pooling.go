@@ -0,0 +1,333 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+// scoredRequest is a ride request that scored well enough to be offered a seat
+type scoredRequest struct {
+	request   model.RideRequest
+	breakdown model.MatchBreakdown
+}
+
+// stopWaypoint marks the offer's own waypoints in a pool's route. They are never stored as stops.
+const stopWaypoint model.StopType = "waypoint"
+
+// ridePool is the set of passengers a ride offer serves, as an ordered list of stops
+type ridePool struct {
+	offer *model.RideOffer
+	// route holds the offer's waypoints and the passenger stops in driving order
+	route []model.RideStop
+	// seatsHeld counts the seats promised to passengers who have not confirmed yet
+	seatsHeld int
+	// routeKm is the estimated length of the driver's route without passengers
+	routeKm float64
+}
+
+// poolRequests adds the best set of candidate requests to a ride offer's pool.
+// Candidates are tried from the highest score down and each is kept only if the
+// offer still has seats for it, the driver's total detour stays within the
+// allowed detour and the fare stays within the passengers' maximum price. A match
+// is proposed for every request that is kept, and the offer's stop list is updated
+// to the new pickup and drop-off order.
+func (s *RideService) poolRequests(offerID uuid.UUID, candidates []scoredRequest) error {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].breakdown.Score > candidates[j].breakdown.Score
+	})
+
+	var proposed []events.Event
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		offer, err := rideRepo.LockRideOfferByID(offerID)
+		if err != nil {
+			return err
+		}
+		if offer == nil {
+			return errors.New("ride offer not found")
+		}
+		if !offer.AcceptsMatches() {
+			return nil
+		}
+
+		pool, err := s.loadRidePool(rideRepo, offer)
+		if err != nil {
+			return err
+		}
+
+		added := 0
+		for _, candidate := range candidates {
+			// Never propose a pair twice, in particular one that was rejected
+			existing, err := rideRepo.FindRideMatchByOfferAndRequest(offer.ID, candidate.request.ID)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				continue
+			}
+
+			request, err := rideRepo.LockRideRequestByID(candidate.request.ID)
+			if err != nil {
+				return err
+			}
+			if request == nil || !request.CanTransitionTo(model.StatusMatched) {
+				continue
+			}
+			fare, ok := s.tryAddToPool(pool, request)
+			if !ok {
+				continue
+			}
+
+			breakdown := candidate.breakdown
+			match := &model.RideMatch{
+				RideOfferID:    offer.ID,
+				RideRequestID:  request.ID,
+				Status:         model.StatusMatched,
+				MatchScore:     breakdown.Score,
+				Price:          fare,
+				ScoreBreakdown: &breakdown,
+			}
+			if err := rideRepo.CreateRideMatch(match); err != nil {
+				return err
+			}
+			if err := markMatched(rideRepo, offer, request); err != nil {
+				return err
+			}
+			if err := recordEvent(rideRepo, model.EventMatchCreated, match.ID, match); err != nil {
+				return err
+			}
+			proposed = append(proposed, matchEvents(events.MatchProposed, match, offer.DriverID, request.PassengerID)...)
+			added++
+		}
+
+		if added == 0 {
+			return nil
+		}
+		return rideRepo.ReplaceRideStops(offer.ID, pool.stops())
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(proposed)
+	return nil
+}
+
+// loadRidePool builds the current pool of a ride offer from its waypoints, stops and live matches.
+// Passengers matched before stops were recorded are appended at the end of the route.
+func (s *RideService) loadRidePool(rideRepo repository.RideRepository, offer *model.RideOffer) (*ridePool, error) {
+	waypoints, err := rideRepo.FindRideWaypointsByOfferID(offer.ID)
+	if err != nil {
+		return nil, err
+	}
+	offer.Waypoints = waypoints
+
+	routeKm, err := s.routeEstimator.RouteDistance(offer.Route()...)
+	if err != nil {
+		return nil, err
+	}
+	pool := &ridePool{offer: offer, routeKm: routeKm}
+
+	matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil {
+		return nil, err
+	}
+	stops, err := rideRepo.FindRideStopsByOfferID(offer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(map[uuid.UUID]bool)
+	for _, match := range matches {
+		if match.Status != model.StatusMatched && match.Status != model.StatusConfirmed && match.Status != model.StatusInProgress {
+			continue
+		}
+		live[match.RideRequestID] = true
+
+		request, err := rideRepo.FindRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return nil, err
+		}
+		if request == nil {
+			continue
+		}
+		// Confirmed passengers were already taken off the available seats
+		if match.Status == model.StatusMatched {
+			pool.seatsHeld += request.NumPassengers
+		}
+		if !hasStops(stops, request.ID) {
+			pickup, dropoff := pickupStop(request), dropoffStop(request)
+			pickup.AfterWaypoint, dropoff.AfterWaypoint = len(waypoints), len(waypoints)
+			stops = append(stops, pickup, dropoff)
+		}
+	}
+
+	// Interleave the stops with the waypoints they follow
+	for w := 0; w <= len(waypoints); w++ {
+		if w > 0 {
+			pool.route = append(pool.route, model.RideStop{Type: stopWaypoint, Location: waypoints[w-1].Location})
+		}
+		for _, stop := range stops {
+			after := stop.AfterWaypoint
+			if after > len(waypoints) {
+				after = len(waypoints)
+			}
+			if after == w && live[stop.RideRequestID] {
+				pool.route = append(pool.route, stop)
+			}
+		}
+	}
+	return pool, nil
+}
+
+// tryAddToPool inserts a request's pickup and drop-off where they lengthen the route the least
+// and returns the request's fare. It reports false and leaves the pool unchanged if the
+// request does not fit.
+func (s *RideService) tryAddToPool(pool *ridePool, request *model.RideRequest) (float64, bool) {
+	if pool.offer.AvailableSeats-pool.seatsHeld < request.NumPassengers {
+		return 0, false
+	}
+
+	fare, err := s.segmentFare(pool.offer, request, pool.routeKm)
+	if err != nil {
+		log.Printf("Failed to estimate fare for offer %s and request %s: %v", pool.offer.ID, request.ID, err)
+		return 0, false
+	}
+	if fare > request.MaxPrice {
+		return 0, false
+	}
+
+	// Choose the insertion points by straight-line distance, then check the
+	// resulting route with the route estimator
+	pickup, dropoff := pickupStop(request), dropoffStop(request)
+	var best []model.RideStop
+	bestKm := 0.0
+	for i := 0; i <= len(pool.route); i++ {
+		for j := i; j <= len(pool.route); j++ {
+			route := make([]model.RideStop, 0, len(pool.route)+2)
+			route = append(route, pool.route[:i]...)
+			route = append(route, pickup)
+			route = append(route, pool.route[i:j]...)
+			route = append(route, dropoff)
+			route = append(route, pool.route[j:]...)
+
+			km := straightLineLength(pool.path(route))
+			if best == nil || km < bestKm {
+				best, bestKm = route, km
+			}
+		}
+	}
+
+	routeKm, err := s.routeEstimator.RouteDistance(pool.path(best)...)
+	if err != nil {
+		log.Printf("Failed to estimate pooled route for offer %s: %v", pool.offer.ID, err)
+		return 0, false
+	}
+	if routeKm-pool.routeKm > pool.offer.AllowedDetourKm {
+		return 0, false
+	}
+
+	pool.route = best
+	pool.seatsHeld += request.NumPassengers
+	return fare, true
+}
+
+// path returns the driver's locations from start to end through the given route
+func (p *ridePool) path(route []model.RideStop) []model.Location {
+	path := make([]model.Location, 0, len(route)+2)
+	path = append(path, p.offer.StartLocation)
+	for _, stop := range route {
+		path = append(path, stop.Location)
+	}
+	return append(path, p.offer.EndLocation)
+}
+
+// stops returns the passenger stops of the pool's route, each recording the waypoint it follows
+func (p *ridePool) stops() []model.RideStop {
+	stops := make([]model.RideStop, 0, len(p.route))
+	waypoints := 0
+	for _, stop := range p.route {
+		if stop.Type == stopWaypoint {
+			waypoints++
+			continue
+		}
+		stop.AfterWaypoint = waypoints
+		stops = append(stops, stop)
+	}
+	return stops
+}
+
+// markMatched moves an offer and a request to matched after a new match was proposed.
+// Offers that already have a confirmed passenger keep their confirmed status.
+func markMatched(rideRepo repository.RideRepository, offer *model.RideOffer, request *model.RideRequest) error {
+	status := offer.Status
+	if err := offer.MarkMatched(); err != nil {
+		return err
+	}
+	if offer.Status != status {
+		if err := rideRepo.UpdateRideOffer(offer); err != nil {
+			return err
+		}
+	}
+	if request.Status != model.StatusMatched {
+		if err := request.TransitionTo(model.StatusMatched); err != nil {
+			return err
+		}
+		return rideRepo.UpdateRideRequest(request)
+	}
+	return nil
+}
+
+// removeRideStops takes a passenger's pickup and drop-off off a ride offer's route
+func removeRideStops(rideRepo repository.RideRepository, offerID uuid.UUID, requestID uuid.UUID) error {
+	stops, err := rideRepo.FindRideStopsByOfferID(offerID)
+	if err != nil {
+		return err
+	}
+	if !hasStops(stops, requestID) {
+		return nil
+	}
+
+	remaining := make([]model.RideStop, 0, len(stops))
+	for _, stop := range stops {
+		if stop.RideRequestID != requestID {
+			remaining = append(remaining, stop)
+		}
+	}
+	return rideRepo.ReplaceRideStops(offerID, remaining)
+}
+
+// hasStops reports whether a request has stops in the list
+func hasStops(stops []model.RideStop, requestID uuid.UUID) bool {
+	for _, stop := range stops {
+		if stop.RideRequestID == requestID {
+			return true
+		}
+	}
+	return false
+}
+
+// pickupStop returns the stop where a request's passengers board
+func pickupStop(request *model.RideRequest) model.RideStop {
+	return model.RideStop{RideRequestID: request.ID, Type: model.StopPickup, Location: request.StartLocation}
+}
+
+// dropoffStop returns the stop where a request's passengers leave
+func dropoffStop(request *model.RideRequest) model.RideStop {
+	return model.RideStop{RideRequestID: request.ID, Type: model.StopDropoff, Location: request.EndLocation}
+}
+
+// straightLineLength returns the great-circle length of a route in kilometers
+func straightLineLength(waypoints []model.Location) float64 {
+	points := make([]geo.Point, len(waypoints))
+	for i, waypoint := range waypoints {
+		points[i] = geo.Point{Latitude: waypoint.Latitude, Longitude: waypoint.Longitude}
+	}
+	return geo.PathLength(points...)
+}
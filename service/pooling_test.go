@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// lineLocation returns a point on the 52nd parallel, where the pooling test offers drive
+// west to east, optionally north (positive) or south (negative) of it
+func lineLocation(longitude, offset float64) model.Location {
+	return model.Location{Latitude: 52 + offset, Longitude: longitude}
+}
+
+// createPoolingOffer saves an offer driving about 34 km from longitude 4.0 to 4.5 at 20 per seat
+func createPoolingOffer(t *testing.T, rideRepo repository.RideRepository, seats int, allowedDetourKm float64) *model.RideOffer {
+	t.Helper()
+	offer := &model.RideOffer{
+		DriverID:        uuid.New(),
+		StartLocation:   lineLocation(4.0, 0),
+		EndLocation:     lineLocation(4.5, 0),
+		DepartureTime:   time.Now().Add(time.Hour),
+		AvailableSeats:  seats,
+		PricePerSeat:    20,
+		AllowedDetourKm: allowedDetourKm,
+		Status:          model.StatusPending,
+	}
+	if err := rideRepo.CreateRideOffer(offer); err != nil {
+		t.Fatalf("CreateRideOffer: %v", err)
+	}
+	return offer
+}
+
+// createPoolingRequest saves a pending request between two points
+func createPoolingRequest(t *testing.T, rideRepo repository.RideRepository, pickup, dropoff model.Location, passengers int, maxPrice float64) *model.RideRequest {
+	t.Helper()
+	request := &model.RideRequest{
+		PassengerID:   uuid.New(),
+		StartLocation: pickup,
+		EndLocation:   dropoff,
+		DepartureTime: time.Now().Add(time.Hour),
+		NumPassengers: passengers,
+		MaxPrice:      maxPrice,
+		Status:        model.StatusPending,
+		Mode:          model.ModeScheduled,
+	}
+	if err := rideRepo.CreateRideRequest(request); err != nil {
+		t.Fatalf("CreateRideRequest: %v", err)
+	}
+	return request
+}
+
+// candidates scores requests in the order given, the first highest
+func candidates(requests ...*model.RideRequest) []scoredRequest {
+	scored := make([]scoredRequest, len(requests))
+	for i, request := range requests {
+		scored[i] = scoredRequest{request: *request, breakdown: model.MatchBreakdown{Score: 1 - float64(i)/10}}
+	}
+	return scored
+}
+
+// matchedRequests returns the matches proposed for an offer by the ID of their request
+func matchedRequests(t *testing.T, rideRepo repository.RideRepository, offerID uuid.UUID) map[uuid.UUID]model.RideMatch {
+	t.Helper()
+	matches, err := rideRepo.FindRideMatchesByOfferID(offerID)
+	if err != nil {
+		t.Fatalf("FindRideMatchesByOfferID: %v", err)
+	}
+	matched := make(map[uuid.UUID]model.RideMatch, len(matches))
+	for _, match := range matches {
+		matched[match.RideRequestID] = match
+	}
+	return matched
+}
+
+func TestPoolRequestsCountsSeatsHeldByUnconfirmedMatches(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createPoolingOffer(t, rideRepo, 3, 5)
+
+	// Two of the three seats are promised to a passenger who has not confirmed yet
+	held := createPoolingRequest(t, rideRepo, lineLocation(4.1, 0), lineLocation(4.2, 0), 2, 50)
+	if err := service.poolRequests(offer.ID, candidates(held)); err != nil {
+		t.Fatalf("poolRequests: %v", err)
+	}
+
+	couple := createPoolingRequest(t, rideRepo, lineLocation(4.1, 0), lineLocation(4.3, 0), 2, 50)
+	single := createPoolingRequest(t, rideRepo, lineLocation(4.2, 0), lineLocation(4.4, 0), 1, 50)
+	another := createPoolingRequest(t, rideRepo, lineLocation(4.2, 0), lineLocation(4.4, 0), 1, 50)
+	if err := service.poolRequests(offer.ID, candidates(couple, single, another)); err != nil {
+		t.Fatalf("poolRequests: %v", err)
+	}
+
+	matched := matchedRequests(t, rideRepo, offer.ID)
+	if len(matched) != 2 {
+		t.Fatalf("%d requests matched, want 2", len(matched))
+	}
+	if _, ok := matched[single.ID]; !ok {
+		t.Error("the request for the last seat was not matched")
+	}
+	for _, request := range []*model.RideRequest{couple, another} {
+		if _, ok := matched[request.ID]; ok {
+			t.Errorf("request %s was matched although the seats were held", request.ID)
+		}
+		if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusPending {
+			t.Errorf("request %s status = %s, want pending", request.ID, status)
+		}
+	}
+	if seats := findOffer(t, rideRepo, offer.ID).AvailableSeats; seats != 3 {
+		t.Errorf("AvailableSeats = %d, want 3 until the passengers confirm", seats)
+	}
+}
+
+func TestPoolRequestsChecksTheSegmentFare(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createPoolingOffer(t, rideRepo, 3, 5)
+
+	// Riding 0.2 of the 0.5 degrees of the route costs 2/5 of the seat price, 8
+	tooExpensive := createPoolingRequest(t, rideRepo, lineLocation(4.1, 0), lineLocation(4.3, 0), 1, 7.5)
+	affordable := createPoolingRequest(t, rideRepo, lineLocation(4.1, 0), lineLocation(4.3, 0), 1, 8.5)
+	if err := service.poolRequests(offer.ID, candidates(tooExpensive, affordable)); err != nil {
+		t.Fatalf("poolRequests: %v", err)
+	}
+
+	matched := matchedRequests(t, rideRepo, offer.ID)
+	if _, ok := matched[tooExpensive.ID]; ok {
+		t.Error("a request whose maximum price is below the segment fare was matched")
+	}
+	match, ok := matched[affordable.ID]
+	if !ok {
+		t.Fatal("a request whose maximum price covers the segment fare was not matched")
+	}
+	if math.Abs(match.Price-8) > 0.05 {
+		t.Errorf("match price = %.2f, want about 8", match.Price)
+	}
+}
+
+func TestPoolRequestsLimitsTheTotalDetour(t *testing.T) {
+	// Each of these requests adds under 0.5 km to the route on its own, but together they add 1.5 km
+	north := func(rideRepo repository.RideRepository) *model.RideRequest {
+		return createPoolingRequest(t, rideRepo, lineLocation(4.1, 0.02), lineLocation(4.15, 0.02), 1, 50)
+	}
+	south := func(rideRepo repository.RideRepository) *model.RideRequest {
+		return createPoolingRequest(t, rideRepo, lineLocation(4.3, -0.02), lineLocation(4.35, -0.02), 1, 50)
+	}
+
+	t.Run("Alone", func(t *testing.T) {
+		service, rideRepo, _ := newTestService(t)
+		offer := createPoolingOffer(t, rideRepo, 3, 1)
+		request := south(rideRepo)
+		if err := service.poolRequests(offer.ID, candidates(request)); err != nil {
+			t.Fatalf("poolRequests: %v", err)
+		}
+		if _, ok := matchedRequests(t, rideRepo, offer.ID)[request.ID]; !ok {
+			t.Error("a request within the allowed detour was not matched")
+		}
+	})
+
+	t.Run("Together", func(t *testing.T) {
+		service, rideRepo, _ := newTestService(t)
+		offer := createPoolingOffer(t, rideRepo, 3, 1)
+		first, second := north(rideRepo), south(rideRepo)
+		if err := service.poolRequests(offer.ID, candidates(first, second)); err != nil {
+			t.Fatalf("poolRequests: %v", err)
+		}
+		matched := matchedRequests(t, rideRepo, offer.ID)
+		if _, ok := matched[first.ID]; !ok {
+			t.Error("the best request was not matched")
+		}
+		if _, ok := matched[second.ID]; ok {
+			t.Error("a request taking the total detour over the allowed detour was matched")
+		}
+	})
+}
+
+func TestPoolRequestsOrdersTheStops(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createPoolingOffer(t, rideRepo, 3, 5)
+	waypoint := []model.RideWaypoint{{Location: lineLocation(4.25, 0)}}
+	if err := rideRepo.ReplaceRideWaypoints(offer.ID, waypoint); err != nil {
+		t.Fatalf("ReplaceRideWaypoints: %v", err)
+	}
+
+	first := createPoolingRequest(t, rideRepo, lineLocation(4.1, 0), lineLocation(4.3, 0), 1, 50)
+	if err := service.poolRequests(offer.ID, candidates(first)); err != nil {
+		t.Fatalf("poolRequests: %v", err)
+	}
+	// A passenger added later is slotted in between the stops already on the route
+	second := createPoolingRequest(t, rideRepo, lineLocation(4.2, 0), lineLocation(4.4, 0), 1, 50)
+	if err := service.poolRequests(offer.ID, candidates(second)); err != nil {
+		t.Fatalf("poolRequests: %v", err)
+	}
+
+	stops, err := rideRepo.FindRideStopsByOfferID(offer.ID)
+	if err != nil {
+		t.Fatalf("FindRideStopsByOfferID: %v", err)
+	}
+	want := []struct {
+		requestID     uuid.UUID
+		stopType      model.StopType
+		afterWaypoint int
+	}{
+		{first.ID, model.StopPickup, 0},
+		{second.ID, model.StopPickup, 0},
+		{first.ID, model.StopDropoff, 1},
+		{second.ID, model.StopDropoff, 1},
+	}
+	if len(stops) != len(want) {
+		t.Fatalf("%d stops, want %d: %+v", len(stops), len(want), stops)
+	}
+	for i, stop := range stops {
+		if stop.Sequence != i+1 || stop.RideRequestID != want[i].requestID || stop.Type != want[i].stopType || stop.AfterWaypoint != want[i].afterWaypoint {
+			t.Errorf("stop %d = sequence %d, %s of %s after waypoint %d; want %s of %s after waypoint %d",
+				i, stop.Sequence, stop.Type, stop.RideRequestID, stop.AfterWaypoint,
+				want[i].stopType, want[i].requestID, want[i].afterWaypoint)
+		}
+	}
+}
+
+func TestWaypointsLetPassengersBoardAlongTheRoute(t *testing.T) {
+	service, rideRepo, userRepo := newTestService(t)
+	driverID := uuid.New()
+	if err := userRepo.CreateDriverProfile(&model.DriverProfile{UserID: driverID, NumSeats: 4}); err != nil {
+		t.Fatalf("CreateDriverProfile: %v", err)
+	}
+
+	// The driver bends north through a town and back, about 41 km instead of 34
+	start, town, village, end := lineLocation(4.0, 0), lineLocation(4.25, 0.1), lineLocation(4.4, 0.04), lineLocation(4.5, 0)
+	offer, err := service.CreateRideOffer(driverID, start.Latitude, start.Longitude, "", end.Latitude, end.Longitude, "",
+		time.Now().Add(time.Hour), 3, 20, 2, []model.Location{town, village})
+	if err != nil {
+		t.Fatalf("CreateRideOffer: %v", err)
+	}
+	waypoints, err := rideRepo.FindRideWaypointsByOfferID(offer.ID)
+	if err != nil || len(waypoints) != 2 {
+		t.Fatalf("FindRideWaypointsByOfferID = %+v, %v, want both waypoints", waypoints, err)
+	}
+	if waypoints[0].Location != town || waypoints[0].Sequence != 1 || waypoints[1].Location != village || waypoints[1].Sequence != 2 {
+		t.Errorf("waypoints = %+v, want the town and then the village", waypoints)
+	}
+
+	// Boarding in the town is on the way, but far off the straight line between start and end
+	request := createPoolingRequest(t, rideRepo, town, end, 1, 15)
+	straight := createPoolingOffer(t, rideRepo, 3, 2)
+	for _, offerID := range []uuid.UUID{offer.ID, straight.ID} {
+		if err := service.findMatchesForOffer(context.Background(), offerID); err != nil {
+			t.Fatalf("findMatchesForOffer: %v", err)
+		}
+	}
+	if _, ok := matchedRequests(t, rideRepo, straight.ID)[request.ID]; ok {
+		t.Error("a request far off an offer without waypoints was matched")
+	}
+	match, ok := matchedRequests(t, rideRepo, offer.ID)[request.ID]
+	if !ok {
+		t.Fatal("a request boarding at a waypoint was not matched")
+	}
+
+	// The passenger rides the second half of the route and pays half the seat price
+	routeKm, _ := service.routeEstimator.RouteDistance(offer.Route()...)
+	rideKm, _ := service.routeEstimator.RouteDistance(town, end)
+	if want := 20 * rideKm / routeKm; math.Abs(match.Price-want) > 0.01 || math.Abs(match.Price-10) > 1 {
+		t.Errorf("match price = %.2f, want %.2f, about half the seat price", match.Price, want)
+	}
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+)
+
+// ErrRideNotEditable is returned when a ride is changed or withdrawn after it was confirmed, started or closed
+var ErrRideNotEditable = errors.New("only pending or matched rides can be changed or withdrawn")
+
+// ErrNoChanges is returned when an update names no fields to change
+var ErrNoChanges = errors.New("no changes given")
+
+// RideOfferChanges lists the fields of a ride offer to change; nil fields are left as they are
+type RideOfferChanges struct {
+	StartLocation   *model.Location
+	EndLocation     *model.Location
+	DepartureTime   *time.Time
+	AvailableSeats  *int
+	PricePerSeat    *float64
+	AllowedDetourKm *float64
+	// Waypoints replaces the offer's waypoints; an empty list removes them
+	Waypoints *[]model.Location
+}
+
+// RideRequestChanges lists the fields of a ride request to change; nil fields are left as they are
+type RideRequestChanges struct {
+	StartLocation *model.Location
+	EndLocation   *model.Location
+	DepartureTime *time.Time
+	NumPassengers *int
+	MaxPrice      *float64
+}
+
+// isEditableStatus reports whether a ride can still be changed or withdrawn by its owner
+func isEditableStatus(status model.RideStatus) bool {
+	return status == model.StatusPending || status == model.StatusMatched
+}
+
+// UpdateRideOffer changes a ride offer on behalf of its driver, as long as nobody has confirmed a seat.
+// Changing the time, route or price, or lowering the seats or the allowed detour, withdraws the
+// offer's unconfirmed matches, as the passengers agreed to different terms. Matching then runs
+// again for the offer.
+func (s *RideService) UpdateRideOffer(offerID uuid.UUID, driverID uuid.UUID, changes RideOfferChanges) (*model.RideOffer, error) {
+	if changes == (RideOfferChanges{}) {
+		return nil, ErrNoChanges
+	}
+	if changes.DepartureTime != nil && changes.DepartureTime.Before(time.Now()) {
+		return nil, errors.New("departure time must be in the future")
+	}
+	if changes.AvailableSeats != nil {
+		driverProfile, err := s.userRepo.GetDriverProfile(driverID)
+		if err != nil {
+			return nil, err
+		}
+		if driverProfile == nil {
+			return nil, errors.New("driver profile not found")
+		}
+		if *changes.AvailableSeats <= 0 || *changes.AvailableSeats > driverProfile.NumSeats {
+			return nil, errors.New("invalid number of available seats")
+		}
+	}
+
+	var offer *model.RideOffer
+	var notifications []notify.Notification
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		var err error
+		offer, err = lockDriverRideOffer(rideRepo, offerID, driverID)
+		if err != nil {
+			return err
+		}
+		if !isEditableStatus(offer.Status) {
+			return ErrRideNotEditable
+		}
+
+		// Fewer seats or a shorter detour may no longer fit the passengers already matched
+		termsChanged := changes.StartLocation != nil || changes.EndLocation != nil || changes.DepartureTime != nil ||
+			changes.PricePerSeat != nil || changes.Waypoints != nil ||
+			(changes.AvailableSeats != nil && *changes.AvailableSeats < offer.AvailableSeats) ||
+			(changes.AllowedDetourKm != nil && *changes.AllowedDetourKm < offer.AllowedDetourKm)
+		if changes.StartLocation != nil {
+			offer.StartLocation = *changes.StartLocation
+		}
+		if changes.EndLocation != nil {
+			offer.EndLocation = *changes.EndLocation
+		}
+		if changes.DepartureTime != nil {
+			offer.DepartureTime = *changes.DepartureTime
+		}
+		if changes.AvailableSeats != nil {
+			offer.AvailableSeats = *changes.AvailableSeats
+		}
+		if changes.PricePerSeat != nil {
+			offer.PricePerSeat = *changes.PricePerSeat
+		}
+		if changes.AllowedDetourKm != nil {
+			offer.AllowedDetourKm = *changes.AllowedDetourKm
+		}
+		if changes.Waypoints != nil {
+			waypoints := make([]model.RideWaypoint, len(*changes.Waypoints))
+			for i, location := range *changes.Waypoints {
+				waypoints[i] = model.RideWaypoint{Location: location}
+			}
+			if err := rideRepo.ReplaceRideWaypoints(offer.ID, waypoints); err != nil {
+				return err
+			}
+		}
+
+		if !termsChanged {
+			if err := rideRepo.UpdateRideOffer(offer); err != nil {
+				return err
+			}
+			return recordEvent(rideRepo, model.EventRideOfferUpdated, offer.ID, offer)
+		}
+
+		// Stops of withdrawn matches go with them, so no stop is left pointing at a removed waypoint
+		matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+		if err != nil {
+			return err
+		}
+		for i := range matches {
+			match := &matches[i]
+			if match.Status != model.StatusMatched {
+				continue
+			}
+			request, err := rideRepo.FindRideRequestByID(match.RideRequestID)
+			if err != nil {
+				return err
+			}
+			if err := withdrawMatch(rideRepo, match); err != nil {
+				return err
+			}
+			if err := syncRideRequestStatus(rideRepo, match.RideRequestID); err != nil {
+				return err
+			}
+			if request != nil {
+				notifications = append(notifications, notify.Notification{
+					UserID:  request.PassengerID,
+					Type:    notify.RideMatchWithdrawn,
+					RideID:  match.ID,
+					Message: "The driver changed the ride you were matched with, so the match was withdrawn",
+				})
+			}
+		}
+		if err := syncRideOfferStatus(rideRepo, offer); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideOfferUpdated, offer.ID, offer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if offer.Waypoints, err = s.rideRepo.FindRideWaypointsByOfferID(offer.ID); err != nil {
+		return nil, err
+	}
+	s.notify(context.Background(), notifications)
+
+	// Look for passengers that fit the changed offer in the background
+	s.queueMatching("match ride offer "+offer.ID.String(), func(ctx context.Context) error {
+		return s.findMatchesForOffer(ctx, offer.ID)
+	})
+
+	return offer, nil
+}
+
+// UpdateRideRequest changes a ride request on behalf of its passenger, as long as it is not confirmed.
+// Any change withdraws the request's unconfirmed matches, as the drivers agreed to different
+// terms. Matching then runs again for the request.
+func (s *RideService) UpdateRideRequest(requestID uuid.UUID, passengerID uuid.UUID, changes RideRequestChanges) (*model.RideRequest, error) {
+	if changes == (RideRequestChanges{}) {
+		return nil, ErrNoChanges
+	}
+	if changes.DepartureTime != nil && changes.DepartureTime.Before(time.Now()) {
+		return nil, errors.New("departure time must be in the future")
+	}
+	if changes.NumPassengers != nil && *changes.NumPassengers <= 0 {
+		return nil, errors.New("invalid number of passengers")
+	}
+
+	var request *model.RideRequest
+	var notifications []notify.Notification
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in offer, request, match order to avoid deadlocks
+		offers, err := lockRequestOffers(rideRepo, requestID)
+		if err != nil {
+			return err
+		}
+		request, err = lockPassengerRideRequest(rideRepo, requestID, passengerID)
+		if err != nil {
+			return err
+		}
+		if request.Mode == model.ModeOnDemand {
+			return ErrOnDemandNotEditable
+		}
+		if !isEditableStatus(request.Status) {
+			return ErrRideNotEditable
+		}
+
+		if changes.StartLocation != nil {
+			request.StartLocation = *changes.StartLocation
+		}
+		if changes.EndLocation != nil {
+			request.EndLocation = *changes.EndLocation
+		}
+		if changes.DepartureTime != nil {
+			request.DepartureTime = *changes.DepartureTime
+		}
+		if changes.NumPassengers != nil {
+			request.NumPassengers = *changes.NumPassengers
+		}
+		if changes.MaxPrice != nil {
+			request.MaxPrice = *changes.MaxPrice
+		}
+		if err := rideRepo.UpdateRideRequest(request); err != nil {
+			return err
+		}
+
+		notifications, err = withdrawRequestMatches(rideRepo, request, offers,
+			"The passenger changed their ride request, so the match was withdrawn")
+		if err != nil {
+			return err
+		}
+		request, err = rideRepo.FindRideRequestByID(request.ID)
+		if err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideRequestUpdated, request.ID, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notify(context.Background(), notifications)
+
+	// Look for offers that fit the changed request in the background
+	s.queueMatching("match ride request "+request.ID.String(), func(ctx context.Context) error {
+		return s.findMatchesForRequest(ctx, request.ID)
+	})
+
+	return request, nil
+}
+
+// DeleteRideOffer withdraws a ride offer that nobody has confirmed a seat on, along with its
+// matches, waypoints and stops. Passengers with a proposed match go back to matching.
+func (s *RideService) DeleteRideOffer(offerID uuid.UUID, driverID uuid.UUID) error {
+	var notifications []notify.Notification
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		offer, err := lockDriverRideOffer(rideRepo, offerID, driverID)
+		if err != nil {
+			return err
+		}
+		if !isEditableStatus(offer.Status) {
+			return ErrRideNotEditable
+		}
+
+		matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			if err := rideRepo.DeleteRideMatch(match.ID); err != nil {
+				return err
+			}
+			if match.Status != model.StatusMatched {
+				continue
+			}
+			if err := recordEvent(rideRepo, model.EventMatchWithdrawn, match.ID, match); err != nil {
+				return err
+			}
+			request, err := rideRepo.FindRideRequestByID(match.RideRequestID)
+			if err != nil {
+				return err
+			}
+			if err := syncRideRequestStatus(rideRepo, match.RideRequestID); err != nil {
+				return err
+			}
+			if request != nil {
+				notifications = append(notifications, notify.Notification{
+					UserID:  request.PassengerID,
+					Type:    notify.RideMatchWithdrawn,
+					RideID:  match.ID,
+					Message: "The driver withdrew the ride you were matched with",
+				})
+			}
+		}
+
+		if err := rideRepo.ReplaceRideStops(offer.ID, nil); err != nil {
+			return err
+		}
+		if err := rideRepo.ReplaceRideWaypoints(offer.ID, nil); err != nil {
+			return err
+		}
+		if err := rideRepo.DeleteRideOffer(offer.ID); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideOfferDeleted, offer.ID, offer)
+	})
+	if err != nil {
+		return err
+	}
+	s.notify(context.Background(), notifications)
+	return nil
+}
+
+// DeleteRideRequest withdraws a ride request that is not confirmed, along with its matches.
+// The offers it was proposed to drop its stops and update their status.
+func (s *RideService) DeleteRideRequest(requestID uuid.UUID, passengerID uuid.UUID) error {
+	var notifications []notify.Notification
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in offer, request, match order to avoid deadlocks
+		offers, err := lockRequestOffers(rideRepo, requestID)
+		if err != nil {
+			return err
+		}
+		request, err := lockPassengerRideRequest(rideRepo, requestID, passengerID)
+		if err != nil {
+			return err
+		}
+		if request.Mode == model.ModeOnDemand {
+			return ErrOnDemandNotEditable
+		}
+		if !isEditableStatus(request.Status) {
+			return ErrRideNotEditable
+		}
+
+		notifications, err = withdrawRequestMatches(rideRepo, request, offers, "The passenger withdrew their ride request")
+		if err != nil {
+			return err
+		}
+
+		// The request's rejected, expired and cancelled matches go with it
+		matches, err := rideRepo.FindRideMatchesByRequestID(request.ID)
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			if err := rideRepo.DeleteRideMatch(match.ID); err != nil {
+				return err
+			}
+		}
+		if err := rideRepo.DeleteRideRequest(request.ID); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideRequestDeleted, request.ID, request)
+	})
+	if err != nil {
+		return err
+	}
+	s.notify(context.Background(), notifications)
+	return nil
+}
+
+// withdrawRequestMatches withdraws a ride request's unconfirmed matches and updates the offers they
+// were on, returning a notification for each driver. The request's own status is brought up to date.
+// The request must be locked, after the offers it is matched on (see lockRequestOffers).
+func withdrawRequestMatches(rideRepo repository.RideRepository, request *model.RideRequest, offers map[uuid.UUID]*model.RideOffer, message string) ([]notify.Notification, error) {
+	matches, err := lockRequestMatches(rideRepo, request.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var notifications []notify.Notification
+	for i := range matches {
+		match := &matches[i]
+		if match.Status != model.StatusMatched {
+			continue
+		}
+		offer, err := requestOffer(rideRepo, offers, match.RideOfferID)
+		if err != nil {
+			return nil, err
+		}
+		if err := withdrawMatch(rideRepo, match); err != nil {
+			return nil, err
+		}
+		if offer == nil {
+			continue
+		}
+		notifications = append(notifications, notify.Notification{
+			UserID:  offer.DriverID,
+			Type:    notify.RideMatchWithdrawn,
+			RideID:  match.ID,
+			Message: message,
+		})
+		if isOpenStatus(offer.Status) {
+			if err := syncRideOfferStatus(rideRepo, offer); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return notifications, syncRideRequestStatus(rideRepo, request.ID)
+}
+
+// withdrawMatch deletes an unconfirmed match and takes its stops off the route.
+// The match is deleted rather than cancelled so that the pair can be proposed again on the new terms.
+func withdrawMatch(rideRepo repository.RideRepository, match *model.RideMatch) error {
+	if err := rideRepo.DeleteRideMatch(match.ID); err != nil {
+		return err
+	}
+	if err := recordEvent(rideRepo, model.EventMatchWithdrawn, match.ID, match); err != nil {
+		return err
+	}
+	return removeRideStops(rideRepo, match.RideOfferID, match.RideRequestID)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yourusername/ride-sharing-app/domain/model"
+)
+
+func TestUpdateRideOfferWithdrawsMatchesOnNarrowerTerms(t *testing.T) {
+	fewer, more := 2, 4
+	shorter, longer := 2.0, 8.0
+	cases := []struct {
+		name          string
+		changes       RideOfferChanges
+		wantWithdrawn bool
+	}{
+		{name: "fewer seats", changes: RideOfferChanges{AvailableSeats: &fewer}, wantWithdrawn: true},
+		{name: "more seats", changes: RideOfferChanges{AvailableSeats: &more}},
+		{name: "shorter detour", changes: RideOfferChanges{AllowedDetourKm: &shorter}, wantWithdrawn: true},
+		{name: "longer detour", changes: RideOfferChanges{AllowedDetourKm: &longer}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, rideRepo, userRepo := newTestService(t)
+			offer := createOffer(t, rideRepo, 3)
+			if err := userRepo.CreateDriverProfile(&model.DriverProfile{UserID: offer.DriverID, NumSeats: 4}); err != nil {
+				t.Fatalf("CreateDriverProfile: %v", err)
+			}
+			request := createRequest(t, rideRepo, 1)
+			match := createMatch(t, rideRepo, offer, request)
+
+			if _, err := service.UpdateRideOffer(offer.ID, offer.DriverID, tc.changes); err != nil {
+				t.Fatalf("UpdateRideOffer: %v", err)
+			}
+
+			kept, err := rideRepo.FindRideMatchByID(match.ID)
+			if err != nil {
+				t.Fatalf("FindRideMatchByID: %v", err)
+			}
+			if withdrawn := kept == nil; withdrawn != tc.wantWithdrawn {
+				t.Fatalf("match withdrawn = %t, want %t", withdrawn, tc.wantWithdrawn)
+			}
+			wantStatus := model.StatusMatched
+			if tc.wantWithdrawn {
+				wantStatus = model.StatusPending
+			}
+			if status := findRequest(t, rideRepo, request.ID).Status; status != wantStatus {
+				t.Errorf("request status = %s, want %s", status, wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+// Orders in which ride search results can be sorted
+const (
+	SortByDeparture = "departure"
+	SortByPrice     = "price"
+	SortByDistance  = "distance"
+)
+
+const (
+	// defaultSearchRadiusKm is how far from the route a passenger looks when no radius is given
+	defaultSearchRadiusKm = 5
+	// defaultSearchPeriod is how far ahead a search looks when no end of the date range is given
+	defaultSearchPeriod = 30 * 24 * time.Hour
+	// defaultSearchLimit is the page size when none is given
+	defaultSearchLimit = 20
+	// maxSearchLimit is the largest page size
+	maxSearchLimit = 100
+)
+
+// ErrInvalidCursor is returned when a search cursor is malformed or belongs to a search sorted differently
+var ErrInvalidCursor = errors.New("invalid search cursor")
+
+// RideSearch describes the ride offers a passenger is looking for
+type RideSearch struct {
+	Origin      model.Location
+	Destination model.Location
+	// RadiusKm is how far from the offer's route the passenger will travel to board and leave
+	RadiusKm float64
+	// From and Until bound the departure time; From defaults to now, Until to 30 days after From
+	From  time.Time
+	Until time.Time
+	Seats int
+	// MaxPrice is the most the passenger pays per seat; 0 means any price
+	MaxPrice float64
+	// Sort is SortByDeparture, SortByPrice or SortByDistance
+	Sort string
+	// Cursor continues a search after the page it was returned with
+	Cursor string
+	Limit  int
+}
+
+// RideSearchResult is a ride offer found by a search
+type RideSearchResult struct {
+	RideOffer model.RideOffer `json:"ride_offer"`
+	// DistanceKm is how far the passenger travels to board the ride and from where they leave it
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// RideSearchPage is one page of ride search results
+type RideSearchPage struct {
+	Results []RideSearchResult `json:"results"`
+	// NextCursor fetches the next page; it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// searchCursor marks the last result of a page by its sort key and ID
+type searchCursor struct {
+	sort string
+	// departure is the sort key of searches by departure, and key that of the others
+	departure time.Time
+	key       float64
+	id        uuid.UUID
+}
+
+// SearchRideOffers finds the ride offers still taking passengers that pass near the search's
+// origin and then its destination, sorted and paginated. Ties in the sort order are broken by
+// offer ID, so pages neither skip nor repeat offers while the results stay the same.
+func (s *RideService) SearchRideOffers(search RideSearch) (*RideSearchPage, error) {
+	switch search.Sort {
+	case "":
+		search.Sort = SortByDeparture
+	case SortByDeparture, SortByPrice, SortByDistance:
+	default:
+		return nil, fmt.Errorf("invalid sort %q, expected %q, %q or %q", search.Sort, SortByDeparture, SortByPrice, SortByDistance)
+	}
+	if search.RadiusKm <= 0 {
+		search.RadiusKm = defaultSearchRadiusKm
+	}
+	if search.Seats <= 0 {
+		search.Seats = 1
+	}
+	if search.Limit <= 0 {
+		search.Limit = defaultSearchLimit
+	}
+	if search.Limit > maxSearchLimit {
+		search.Limit = maxSearchLimit
+	}
+
+	// Offers that have left cannot be booked
+	now := time.Now()
+	if search.From.Before(now) {
+		search.From = now
+	}
+	if search.Until.IsZero() {
+		search.Until = search.From.Add(defaultSearchPeriod)
+	}
+	if search.Until.Before(search.From) {
+		return nil, errors.New("the end of the date range must be after its start")
+	}
+
+	var after *searchCursor
+	if search.Cursor != "" {
+		cursor, err := decodeSearchCursor(search.Cursor)
+		if err != nil || cursor.sort != search.Sort {
+			return nil, ErrInvalidCursor
+		}
+		after = cursor
+	}
+
+	offerSearch := repository.RideOfferSearch{
+		Origin:          search.Origin,
+		Destination:     search.Destination,
+		RadiusKm:        search.RadiusKm,
+		DepartingFrom:   search.From,
+		DepartingUntil:  search.Until,
+		Seats:           search.Seats,
+		MaxPricePerSeat: search.MaxPrice,
+	}
+	if search.Sort == SortByDistance {
+		return s.searchRideOffersByDistance(offerSearch, search, after)
+	}
+
+	// The repository sorts and pages by departure and price; one offer more tells whether a next page follows
+	offerSearch.OrderBy = repository.OrderByDeparture
+	if search.Sort == SortByPrice {
+		offerSearch.OrderBy = repository.OrderByPrice
+	}
+	if after != nil {
+		offerSearch.After = &repository.RideOfferCursor{DepartureTime: after.departure, PricePerSeat: after.key, ID: after.id}
+	}
+	offerSearch.Limit = search.Limit + 1
+	offers, err := s.rideRepo.SearchRideOffers(offerSearch)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &RideSearchPage{}
+	if len(offers) > search.Limit {
+		offers = offers[:search.Limit]
+		last := offers[len(offers)-1]
+		page.NextCursor = encodeSearchCursor(searchCursor{sort: search.Sort, departure: last.DepartureTime, key: last.PricePerSeat, id: last.ID})
+	}
+	page.Results = make([]RideSearchResult, len(offers))
+	for i, offer := range offers {
+		page.Results[i] = searchResult(offer, &search)
+	}
+	return page, nil
+}
+
+// searchRideOffersByDistance sorts and pages the offers of a search by how far the passenger
+// travels to and from them. The distance depends on the passenger, so every offer is read.
+func (s *RideService) searchRideOffersByDistance(offerSearch repository.RideOfferSearch, search RideSearch, after *searchCursor) (*RideSearchPage, error) {
+	offers, err := s.rideRepo.SearchRideOffers(offerSearch)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]RideSearchResult, len(offers))
+	for i, offer := range offers {
+		results[i] = searchResult(offer, &search)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return searchBefore(results[i].DistanceKm, results[i].RideOffer.ID, results[j].DistanceKm, results[j].RideOffer.ID)
+	})
+
+	// Skip the results up to and including the cursor
+	start := 0
+	if after != nil {
+		start = sort.Search(len(results), func(i int) bool {
+			return searchBefore(after.key, after.id, results[i].DistanceKm, results[i].RideOffer.ID)
+		})
+	}
+	end := start + search.Limit
+	if end > len(results) {
+		end = len(results)
+	}
+
+	page := &RideSearchPage{Results: results[start:end]}
+	if end < len(results) {
+		last := results[end-1]
+		page.NextCursor = encodeSearchCursor(searchCursor{sort: search.Sort, key: last.DistanceKm, id: last.RideOffer.ID})
+	}
+	return page, nil
+}
+
+// searchResult returns an offer found by a search with how far the passenger travels to and from it
+func searchResult(offer model.RideOffer, search *RideSearch) RideSearchResult {
+	distance, _ := geo.AccessDistance(geoRoute(offer.Route()), geoPoint(search.Origin), geoPoint(search.Destination), search.RadiusKm)
+	return RideSearchResult{RideOffer: offer, DistanceKm: distance}
+}
+
+// searchBefore orders search results by sort key, then by ID
+func searchBefore(keyA float64, idA uuid.UUID, keyB float64, idB uuid.UUID) bool {
+	if keyA != keyB {
+		return keyA < keyB
+	}
+	return strings.Compare(idA.String(), idB.String()) < 0
+}
+
+// encodeSearchCursor turns a cursor into an opaque string. Departure times keep all their digits,
+// so the next page starts right after the last offer.
+func encodeSearchCursor(cursor searchCursor) string {
+	key := strconv.FormatFloat(cursor.key, 'g', -1, 64)
+	if cursor.sort == SortByDeparture {
+		key = cursor.departure.UTC().Format(time.RFC3339Nano)
+	}
+	raw := cursor.sort + "|" + key + "|" + cursor.id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeSearchCursor reads a cursor made by encodeSearchCursor
+func decodeSearchCursor(encoded string) (*searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	cursor := &searchCursor{sort: parts[0]}
+	if cursor.sort == SortByDeparture {
+		cursor.departure, err = time.Parse(time.RFC3339Nano, parts[1])
+	} else {
+		cursor.key, err = strconv.ParseFloat(parts[1], 64)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cursor.id, err = uuid.Parse(parts[2]); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// geoPoint converts a location to a geo point
+func geoPoint(location model.Location) geo.Point {
+	return geo.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+}
+
+// geoRoute converts a route to geo points
+func geoRoute(route []model.Location) []geo.Point {
+	points := make([]geo.Point, len(route))
+	for i, location := range route {
+		points[i] = geoPoint(location)
+	}
+	return points
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+)
+
+// createSearchOffers saves one offer per price, all departing at the same time
+func createSearchOffers(t *testing.T, rideRepo repository.RideRepository, departure time.Time, prices ...float64) []*model.RideOffer {
+	t.Helper()
+	offers := make([]*model.RideOffer, len(prices))
+	for i, price := range prices {
+		offer := createOffer(t, rideRepo, 3)
+		offer.PricePerSeat = price
+		offer.DepartureTime = departure
+		if err := rideRepo.UpdateRideOffer(offer); err != nil {
+			t.Fatalf("UpdateRideOffer: %v", err)
+		}
+		offers[i] = offer
+	}
+	return offers
+}
+
+// searchAll pages through a search and returns the offer IDs of every page
+func searchAll(t *testing.T, service *RideService, search RideSearch) [][]uuid.UUID {
+	t.Helper()
+	var pages [][]uuid.UUID
+	for {
+		page, err := service.SearchRideOffers(search)
+		if err != nil {
+			t.Fatalf("SearchRideOffers(page %d): %v", len(pages)+1, err)
+		}
+		ids := make([]uuid.UUID, len(page.Results))
+		for i, result := range page.Results {
+			ids[i] = result.RideOffer.ID
+		}
+		pages = append(pages, ids)
+		if page.NextCursor == "" {
+			return pages
+		}
+		if len(pages) > 10 {
+			t.Fatal("SearchRideOffers keeps returning a next cursor")
+		}
+		search.Cursor = page.NextCursor
+	}
+}
+
+// priceOrder returns the offers' IDs sorted by price, then by ID
+func priceOrder(offers []*model.RideOffer) []uuid.UUID {
+	sorted := append([]*model.RideOffer(nil), offers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return searchBefore(sorted[i].PricePerSeat, sorted[i].ID, sorted[j].PricePerSeat, sorted[j].ID)
+	})
+	ids := make([]uuid.UUID, len(sorted))
+	for i, offer := range sorted {
+		ids[i] = offer.ID
+	}
+	return ids
+}
+
+// rideSearch looks for rides from the central station to the museum square
+func rideSearch(sortBy string, limit int) RideSearch {
+	return RideSearch{Origin: centralStation, Destination: museumSquare, Sort: sortBy, Limit: limit}
+}
+
+func TestSearchRideOffersPages(t *testing.T) {
+	departure := time.Now().Add(time.Hour).Truncate(time.Second)
+	cases := []struct {
+		name      string
+		prices    []float64
+		limit     int
+		wantPages []int
+	}{
+		{name: "last page partly full", prices: []float64{12, 8, 10, 8, 15}, limit: 2, wantPages: []int{2, 2, 1}},
+		{name: "last page exactly full", prices: []float64{12, 8, 10, 8}, limit: 2, wantPages: []int{2, 2}},
+		{name: "one page", prices: []float64{12, 8, 10}, limit: 5, wantPages: []int{3}},
+		{name: "page of one", prices: []float64{9, 9, 9}, limit: 1, wantPages: []int{1, 1, 1}},
+		{name: "no results", prices: nil, limit: 2, wantPages: []int{0}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, rideRepo, _ := newTestService(t)
+			offers := createSearchOffers(t, rideRepo, departure, tc.prices...)
+
+			pages := searchAll(t, service, rideSearch(SortByPrice, tc.limit))
+			if len(pages) != len(tc.wantPages) {
+				t.Fatalf("%d pages, want %d", len(pages), len(tc.wantPages))
+			}
+			var got []uuid.UUID
+			for i, page := range pages {
+				if len(page) != tc.wantPages[i] {
+					t.Errorf("page %d has %d results, want %d", i+1, len(page), tc.wantPages[i])
+				}
+				got = append(got, page...)
+			}
+			// Every offer appears once, in price order with ties broken by ID
+			want := priceOrder(offers)
+			if len(got) != len(want) {
+				t.Fatalf("%d results across the pages, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("results = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchRideOffersTiesOnDeparture(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	departure := time.Now().Add(time.Hour).Truncate(time.Second)
+	same := createSearchOffers(t, rideRepo, departure, 10, 10, 10)
+	later := createSearchOffers(t, rideRepo, departure.Add(time.Minute), 10)
+
+	pages := searchAll(t, service, rideSearch(SortByDeparture, 2))
+	if len(pages) != 2 || len(pages[0]) != 2 || len(pages[1]) != 2 {
+		t.Fatalf("pages = %v, want two pages of two", pages)
+	}
+	got := append(pages[0], pages[1]...)
+	want := append(priceOrder(same), later[0].ID)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("results = %v, want %v: equal departures ordered by ID, then the later offer", got, want)
+		}
+	}
+}
+
+func TestSearchRideOffersCursorAfterItsOfferIsGone(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offers := createSearchOffers(t, rideRepo, time.Now().Add(time.Hour), 8, 9, 10, 11)
+
+	first, err := service.SearchRideOffers(rideSearch(SortByPrice, 2))
+	if err != nil {
+		t.Fatalf("SearchRideOffers: %v", err)
+	}
+	if first.NextCursor == "" || first.Results[1].RideOffer.ID != offers[1].ID {
+		t.Fatalf("first page = %+v, want the two cheapest offers and a cursor", first)
+	}
+
+	// The last offer of the page is cancelled before the next page is fetched
+	offers[1].Status = model.StatusCancelled
+	if err := rideRepo.UpdateRideOffer(offers[1]); err != nil {
+		t.Fatalf("UpdateRideOffer: %v", err)
+	}
+	search := rideSearch(SortByPrice, 2)
+	search.Cursor = first.NextCursor
+	second, err := service.SearchRideOffers(search)
+	if err != nil {
+		t.Fatalf("SearchRideOffers(second page): %v", err)
+	}
+	if len(second.Results) != 2 || second.Results[0].RideOffer.ID != offers[2].ID || second.Results[1].RideOffer.ID != offers[3].ID || second.NextCursor != "" {
+		t.Fatalf("second page = %+v, want the two dearest offers and no cursor", second)
+	}
+}
+
+func TestSearchRideOffersRejectsBadCursors(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	createSearchOffers(t, rideRepo, time.Now().Add(time.Hour), 8, 9, 10)
+
+	page, err := service.SearchRideOffers(rideSearch(SortByPrice, 1))
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("SearchRideOffers = %+v, %v, want a next cursor", page, err)
+	}
+
+	for name, cursor := range map[string]string{
+		"other sort":    page.NextCursor,
+		"not base64":    "not a cursor!",
+		"missing parts": base64.RawURLEncoding.EncodeToString([]byte(SortByDeparture + "|8")),
+	} {
+		search := rideSearch(SortByDeparture, 1)
+		search.Cursor = cursor
+		if _, err := service.SearchRideOffers(search); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: SearchRideOffers = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+
+	if _, err := service.SearchRideOffers(rideSearch("rating", 1)); err == nil || !strings.Contains(err.Error(), "invalid sort") {
+		t.Errorf("SearchRideOffers with an unknown sort = %v, want an invalid sort error", err)
+	}
+}
@@ -1,30 +1,73 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"errors"
-	"math"
+	"fmt"
+	"log"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/yourusername/ride-sharing-app/domain/model"
 	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+	"github.com/yourusername/ride-sharing-app/infrastructure/jobs"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+	"github.com/yourusername/ride-sharing-app/infrastructure/ratelimit"
+	"github.com/yourusername/ride-sharing-app/infrastructure/routing"
 )
 
 // RideService handles ride-related business logic
 type RideService struct {
-	rideRepo repository.RideRepository
-	userRepo repository.UserRepository
+	rideRepo       repository.RideRepository
+	userRepo       repository.UserRepository
+	routeEstimator routing.RouteEstimator
+	matchScorer    MatchScorer
+	matching       MatchingOptions
+	expiry         ExpiryOptions
+	dispatch       DispatchOptions
+	tracking       TrackingOptions
+	jobQueue       *jobs.Queue
+	notifier       notify.Notifier
+	publisher      events.Publisher
+	// trackingLimiter counts the GPS points each ride offer records per minute
+	trackingLimiter *ratelimit.Limiter
 }
 
 // NewRideService creates a new RideService
-func NewRideService(rideRepo repository.RideRepository, userRepo repository.UserRepository) *RideService {
+func NewRideService(
+	rideRepo repository.RideRepository,
+	userRepo repository.UserRepository,
+	routeEstimator routing.RouteEstimator,
+	matchScorer MatchScorer,
+	matching MatchingOptions,
+	expiry ExpiryOptions,
+	dispatch DispatchOptions,
+	tracking TrackingOptions,
+	jobQueue *jobs.Queue,
+	notifier notify.Notifier,
+	publisher events.Publisher,
+) *RideService {
 	return &RideService{
-		rideRepo: rideRepo,
-		userRepo: userRepo,
+		rideRepo:        rideRepo,
+		userRepo:        userRepo,
+		routeEstimator:  routeEstimator,
+		matchScorer:     matchScorer,
+		matching:        matching,
+		expiry:          expiry,
+		dispatch:        dispatch,
+		tracking:        tracking,
+		jobQueue:        jobQueue,
+		notifier:        notifier,
+		publisher:       publisher,
+		trackingLimiter: ratelimit.NewLimiter(tracking.MaxPointsPerMinute, time.Minute),
 	}
 }
 
-// CreateRideOffer creates a new ride offer
+// CreateRideOffer creates a new ride offer passing through the given waypoints
 func (s *RideService) CreateRideOffer(
 	driverID uuid.UUID,
 	startLat, startLng float64,
@@ -35,6 +78,7 @@ func (s *RideService) CreateRideOffer(
 	availableSeats int,
 	pricePerSeat float64,
 	allowedDetourKm float64,
+	waypoints []model.Location,
 ) (*model.RideOffer, error) {
 	// Validate driver
 	driverProfile, err := s.userRepo.GetDriverProfile(driverID)
@@ -74,14 +118,28 @@ func (s *RideService) CreateRideOffer(
 		AllowedDetourKm: allowedDetourKm,
 		Status:          model.StatusPending,
 	}
+	for _, location := range waypoints {
+		offer.Waypoints = append(offer.Waypoints, model.RideWaypoint{Location: location})
+	}
 
-	// Save offer to database
-	if err := s.rideRepo.CreateRideOffer(offer); err != nil {
+	// Save offer and its waypoints to database
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		if err := rideRepo.CreateRideOffer(offer); err != nil {
+			return err
+		}
+		if err := rideRepo.ReplaceRideWaypoints(offer.ID, offer.Waypoints); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideOfferCreated, offer.ID, offer)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Find potential matches for this offer
-	go s.findMatchesForOffer(offer.ID)
+	// Find potential matches for this offer in the background
+	s.queueMatching("match ride offer "+offer.ID.String(), func(ctx context.Context) error {
+		return s.findMatchesForOffer(ctx, offer.ID)
+	})
 
 	return offer, nil
 }
@@ -133,22 +191,44 @@ func (s *RideService) CreateRideRequest(
 		NumPassengers: numPassengers,
 		MaxPrice:      maxPrice,
 		Status:        model.StatusPending,
+		Mode:          model.ModeScheduled,
 	}
 
 	// Save request to database
-	if err := s.rideRepo.CreateRideRequest(request); err != nil {
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		if err := rideRepo.CreateRideRequest(request); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideRequestCreated, request.ID, request)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Find potential matches for this request
-	go s.findMatchesForRequest(request.ID)
+	// Find potential matches for this request in the background
+	s.queueMatching("match ride request "+request.ID.String(), func(ctx context.Context) error {
+		return s.findMatchesForRequest(ctx, request.ID)
+	})
 
 	return request, nil
 }
 
-// GetRideOffersByDriver retrieves all ride offers by a specific driver
+// GetRideOffersByDriver retrieves all ride offers by a specific driver, with their waypoints and stops
 func (s *RideService) GetRideOffersByDriver(driverID uuid.UUID) ([]model.RideOffer, error) {
-	return s.rideRepo.FindRideOffersByDriverID(driverID)
+	offers, err := s.rideRepo.FindRideOffersByDriverID(driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range offers {
+		if offers[i].Waypoints, err = s.rideRepo.FindRideWaypointsByOfferID(offers[i].ID); err != nil {
+			return nil, err
+		}
+		if offers[i].Stops, err = s.rideRepo.FindRideStopsByOfferID(offers[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return offers, nil
 }
 
 // GetRideRequestsByPassenger retrieves all ride requests by a specific passenger
@@ -158,235 +238,906 @@ func (s *RideService) GetRideRequestsByPassenger(passengerID uuid.UUID) ([]model
 
 // CalculateDistanceBetweenPoints calculates the distance between two geographical points
 func (s *RideService) CalculateDistanceBetweenPoints(lat1, lng1, lat2, lng2 float64) float64 {
-	// Earth radius in kilometers
-	const earthRadius = 6371.0
-
-	// Convert degrees to radians
-	lat1Rad := lat1 * math.Pi / 180
-	lng1Rad := lng1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	lng2Rad := lng2 * math.Pi / 180
+	return geo.Distance(lat1, lng1, lat2, lng2)
+}
 
-	// Haversine formula
-	dlat := lat2Rad - lat1Rad
-	dlng := lng2Rad - lng1Rad
-	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dlng/2)*math.Sin(dlng/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	distance := earthRadius * c
+// queueMatching runs a match search on the job queue.
+// The ride is saved either way, so a search that cannot be queued is only logged.
+func (s *RideService) queueMatching(name string, run func(ctx context.Context) error) {
+	if err := s.jobQueue.Enqueue(jobs.Job{Name: name, Run: run}); err != nil {
+		log.Printf("Failed to queue %s: %v", name, err)
+	}
+}
 
-	return distance
+// matchCandidates selects the offers and requests matching looks at. The repositories only
+// rule out pairs by straight-line detour and fare when routes are measured that way as well,
+// since road routes may accept a pair that straight lines would not.
+func (s *RideService) matchCandidates() repository.MatchCandidates {
+	return repository.MatchCandidates{
+		Window:       s.matching.Window,
+		StraightLine: routing.StraightLine(s.routeEstimator),
+	}
 }
 
-// findMatchesForOffer finds and creates potential matches for a ride offer
-func (s *RideService) findMatchesForOffer(offerID uuid.UUID) error {
+// findMatchesForOffer pools the best set of potential requests into a ride offer
+func (s *RideService) findMatchesForOffer(ctx context.Context, offerID uuid.UUID) error {
 	offer, err := s.rideRepo.FindRideOfferByID(offerID)
 	if err != nil {
 		return err
 	}
 	if offer == nil {
-		return errors.New("ride offer not found")
+		return jobs.Permanent(errors.New("ride offer not found"))
+	}
+	if !offer.AcceptsMatches() {
+		return nil
+	}
+	if offer.Waypoints, err = s.rideRepo.FindRideWaypointsByOfferID(offer.ID); err != nil {
+		return err
 	}
 
-	potentialRequests, err := s.rideRepo.FindPotentialMatches(offerID)
+	potentialRequests, err := s.rideRepo.FindPotentialMatches(offerID, s.matchCandidates())
 	if err != nil {
 		return err
 	}
 
-	for _, request := range potentialRequests {
-		// Calculate match score based on route proximity, time, etc.
-		matchScore := s.calculateMatchScore(offer, &request)
-
-		// If match score is good enough, create a match
-		if matchScore > 0.6 {
-			match := &model.RideMatch{
-				RideOfferID:   offer.ID,
-				RideRequestID: request.ID,
-				Status:        model.StatusMatched,
-				MatchScore:    matchScore,
-				Price:         offer.PricePerSeat * float64(request.NumPassengers),
-			}
+	// Pairs are proposed at most once, so requests the offer has a match with are not scored again
+	matches, err := s.rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil {
+		return err
+	}
+	proposed := make(map[uuid.UUID]bool, len(matches))
+	for _, match := range matches {
+		proposed[match.RideRequestID] = true
+	}
 
-			if err := s.rideRepo.CreateRideMatch(match); err != nil {
-				// Log error but continue processing other matches
-				continue
-			}
+	var candidates []scoredRequest
+	for _, request := range potentialRequests {
+		if request.PassengerID == offer.DriverID || !request.CanTransitionTo(model.StatusMatched) || proposed[request.ID] {
+			continue
+		}
 
-			// Update offer and request statuses
-			offer.Status = model.StatusMatched
-			request.Status = model.StatusMatched
+		// Calculate match score based on route proximity, time, etc.
+		breakdown := s.evaluateMatch(offer, &request)
 
-			s.rideRepo.UpdateRideOffer(offer)
-			s.rideRepo.UpdateRideRequest(&request)
+		// If match score is good enough, the request competes for a seat
+		if breakdown.Rejection == "" {
+			candidates = append(candidates, scoredRequest{request: request, breakdown: breakdown})
 		}
 	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	return nil
+	return s.poolRequests(offer.ID, candidates)
 }
 
-// findMatchesForRequest finds and creates potential matches for a ride request
-func (s *RideService) findMatchesForRequest(requestID uuid.UUID) error {
+// findMatchesForRequest adds a ride request to the pool of every potential offer it fits.
+// Offers it could not be pooled into are reported together; pairs already proposed are
+// skipped when the search is retried.
+func (s *RideService) findMatchesForRequest(ctx context.Context, requestID uuid.UUID) error {
 	request, err := s.rideRepo.FindRideRequestByID(requestID)
 	if err != nil {
 		return err
 	}
 	if request == nil {
-		return errors.New("ride request not found")
+		return jobs.Permanent(errors.New("ride request not found"))
+	}
+	if !request.CanTransitionTo(model.StatusMatched) {
+		return nil
 	}
 
-	potentialOffers, err := s.rideRepo.FindPotentialOffers(requestID)
+	potentialOffers, err := s.rideRepo.FindPotentialOffers(requestID, s.matchCandidates())
 	if err != nil {
 		return err
 	}
 
+	// Pairs are proposed at most once, so offers the request has a match with are not scored again
+	matches, err := s.rideRepo.FindRideMatchesByRequestID(request.ID)
+	if err != nil {
+		return err
+	}
+	proposed := make(map[uuid.UUID]bool, len(matches))
+	for _, match := range matches {
+		proposed[match.RideOfferID] = true
+	}
+
+	var failures []error
 	for _, offer := range potentialOffers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if offer.DriverID == request.PassengerID || !offer.AcceptsMatches() || proposed[offer.ID] {
+			continue
+		}
+
 		// Calculate match score based on route proximity, time, etc.
-		matchScore := s.calculateMatchScore(&offer, request)
-
-		// If match score is good enough, create a match
-		if matchScore > 0.6 {
-			match := &model.RideMatch{
-				RideOfferID:   offer.ID,
-				RideRequestID: request.ID,
-				Status:        model.StatusMatched,
-				MatchScore:    matchScore,
-				Price:         offer.PricePerSeat * float64(request.NumPassengers),
-			}
+		breakdown := s.evaluateMatch(&offer, request)
 
-			if err := s.rideRepo.CreateRideMatch(match); err != nil {
-				// Log error but continue processing other matches
-				continue
+		// If match score is good enough, try to fit the request into the offer's pool
+		if breakdown.Rejection == "" {
+			if err := s.poolRequests(offer.ID, []scoredRequest{{request: *request, breakdown: breakdown}}); err != nil {
+				// Keep processing other offers and report the failure afterwards
+				failures = append(failures, fmt.Errorf("offer %s: %w", offer.ID, err))
 			}
+		}
+	}
+
+	return errors.Join(failures...)
+}
 
-			// Update offer and request statuses
-			offer.Status = model.StatusMatched
-			request.Status = model.StatusMatched
+// SweepMatches re-runs matching for every pending ride offer and request that has not departed yet,
+// catching rides that became a fit after they were created. Pairs are never proposed twice, so
+// sweeps can run any number of times.
+func (s *RideService) SweepMatches(ctx context.Context) error {
+	now := time.Now()
+	var failures []error
 
-			s.rideRepo.UpdateRideOffer(&offer)
-			s.rideRepo.UpdateRideRequest(request)
+	offers, err := s.rideRepo.FindPendingRideOffers(now)
+	if err != nil {
+		return err
+	}
+	for _, offer := range offers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.findMatchesForOffer(ctx, offer.ID); err != nil {
+			failures = append(failures, fmt.Errorf("offer %s: %w", offer.ID, err))
 		}
 	}
 
-	return nil
+	// Pending requests may also fit offers that already have passengers
+	requests, err := s.rideRepo.FindPendingRideRequests(model.ModeScheduled, now)
+	if err != nil {
+		return err
+	}
+	for _, request := range requests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.findMatchesForRequest(ctx, request.ID); err != nil {
+			failures = append(failures, fmt.Errorf("request %s: %w", request.ID, err))
+		}
+	}
+
+	if len(offers) > 0 || len(requests) > 0 {
+		log.Printf("Match sweep checked %d offers and %d requests", len(offers), len(requests))
+	}
+	return errors.Join(failures...)
 }
 
-// calculateMatchScore calculates a matching score between an offer and a request
-// A higher score means a better match
-func (s *RideService) calculateMatchScore(offer *model.RideOffer, request *model.RideRequest) float64 {
+// evaluateMatch scores a request for an offer with the service's scorer and explains the score.
+// Requests the offer cannot serve score 0, with the reason in the breakdown's Rejection.
+func (s *RideService) evaluateMatch(offer *model.RideOffer, request *model.RideRequest) model.MatchBreakdown {
+	candidate := &MatchCandidate{Offer: offer, Request: request}
+	reject := func(format string, args ...interface{}) model.MatchBreakdown {
+		breakdown := measureMatch(candidate)
+		breakdown.Rejection = fmt.Sprintf(format, args...)
+		return breakdown
+	}
+
 	// Check if there are enough seats
 	if offer.AvailableSeats < request.NumPassengers {
-		return 0
+		return reject("the offer has %d seats available for %d passengers", offer.AvailableSeats, request.NumPassengers)
 	}
 
-	// Calculate distance from passenger pickup point to driver start point
-	pickupDistance := s.CalculateDistanceBetweenPoints(
-		request.StartLocation.Latitude,
-		request.StartLocation.Longitude,
-		offer.StartLocation.Latitude,
-		offer.StartLocation.Longitude,
-	)
-
-	// Calculate distance from passenger drop-off point to driver end point
-	dropoffDistance := s.CalculateDistanceBetweenPoints(
-		request.EndLocation.Latitude,
-		request.EndLocation.Longitude,
-		offer.EndLocation.Latitude,
-		offer.EndLocation.Longitude,
-	)
+	// Calculate how much longer the driver's route gets by picking the passenger up on the way
+	detour, err := routing.Detour(s.routeEstimator, offer.Route(), request.StartLocation, request.EndLocation)
+	if err != nil {
+		log.Printf("Failed to estimate detour for offer %s and request %s: %v", offer.ID, request.ID, err)
+		return reject("the detour could not be estimated")
+	}
+	candidate.DetourKm = detour
 
 	// If the detour is too great, it's not a good match
-	if pickupDistance > offer.AllowedDetourKm || dropoffDistance > offer.AllowedDetourKm {
-		return 0
+	if detour > offer.AllowedDetourKm {
+		return reject("a detour of %.1f km exceeds the allowed %.1f km", detour, offer.AllowedDetourKm)
 	}
 
-	// Calculate time difference in minutes
-	timeDiff := math.Abs(float64(offer.DepartureTime.Sub(request.DepartureTime).Minutes()))
+	// Work out the fare for the part of the route the passengers travel
+	routeKm, err := s.routeEstimator.RouteDistance(offer.Route()...)
+	if err != nil {
+		log.Printf("Failed to estimate route for offer %s: %v", offer.ID, err)
+		return reject("the route could not be estimated")
+	}
+	fare, err := s.segmentFare(offer, request, routeKm)
+	if err != nil {
+		log.Printf("Failed to estimate fare for offer %s and request %s: %v", offer.ID, request.ID, err)
+		return reject("the fare could not be estimated")
+	}
+	candidate.Fare = fare
 
-	// Calculate price compatibility (0-1)
-	pricePerSeat := offer.PricePerSeat
-	maxPricePerSeat := request.MaxPrice / float64(request.NumPassengers)
-	priceCompat := 1.0
-	if pricePerSeat > maxPricePerSeat {
-		priceCompat = 0
+	breakdown, err := s.matchScorer.Score(candidate)
+	if err != nil {
+		log.Printf("Failed to score offer %s and request %s: %v", offer.ID, request.ID, err)
+		return reject("the match could not be scored")
+	}
+	if breakdown.Rejection == "" && breakdown.Score <= s.matching.Threshold {
+		breakdown.Rejection = fmt.Sprintf("a score of %.2f does not exceed the threshold of %.2f",
+			breakdown.Score, s.matching.Threshold)
 	}
+	return breakdown
+}
+
+// segmentFare returns what a request's passengers pay for riding part of an offer's routeKm long route
+func (s *RideService) segmentFare(offer *model.RideOffer, request *model.RideRequest, routeKm float64) (float64, error) {
+	rideKm, err := s.routeEstimator.RouteDistance(request.StartLocation, request.EndLocation)
+	if err != nil {
+		return 0, err
+	}
+	return offer.Fare(request.NumPassengers, rideKm, routeKm), nil
+}
 
-	// Calculate time compatibility (0-1)
-	timeCompat := math.Max(0, 1-timeDiff/30) // 30 minutes is the max time difference
+// ErrNotEnoughSeats is returned when a ride offer has no room left for a request
+var ErrNotEnoughSeats = errors.New("not enough seats available on this ride")
 
-	// Calculate location compatibility (0-1)
-	locationCompat := math.Max(0, 1-(pickupDistance+dropoffDistance)/(2*offer.AllowedDetourKm))
+// ErrAlreadyAccepted is returned when a party accepts a match it has already accepted
+var ErrAlreadyAccepted = errors.New("match has already been accepted by this party")
 
-	// Weight factors for the final score
-	const (
-		priceWeight    = 0.3
-		timeWeight     = 0.3
-		locationWeight = 0.4
-	)
+// ConfirmMatch records the caller's acceptance of a ride match.
+// Whether the caller accepts as the driver or the passenger is worked out from the match itself.
+// The match is confirmed and seats are reserved once both sides have accepted; the offer,
+// request and match are locked meanwhile so that concurrent confirmations cannot overbook.
+// The request's other proposed matches are then withdrawn and their drivers told.
+func (s *RideService) ConfirmMatch(matchID uuid.UUID, userID uuid.UUID) (*model.RideMatch, error) {
+	match, err := s.rideRepo.FindRideMatchByID(matchID)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return nil, errors.New("match not found")
+	}
 
-	// Calculate weighted average
-	return priceCompat*priceWeight + timeCompat*timeWeight + locationCompat*locationWeight
+	var confirmed []events.Event
+	var withdrawn []notify.Notification
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in offer, request, match order to avoid deadlocks. The offers of the
+		// request's other matches are locked too, as confirming withdraws those matches.
+		offers, err := lockRequestOffers(rideRepo, match.RideRequestID)
+		if err != nil {
+			return err
+		}
+		offer, err := requestOffer(rideRepo, offers, match.RideOfferID)
+		if err != nil {
+			return err
+		}
+		if offer == nil {
+			return errors.New("ride offer not found")
+		}
+		request, err := rideRepo.LockRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return err
+		}
+		if request == nil {
+			return errors.New("ride request not found")
+		}
+		match, err = rideRepo.LockRideMatchByID(matchID)
+		if err != nil {
+			return err
+		}
+		if match == nil {
+			return errors.New("match not found")
+		}
+
+		// Verify user is involved in this match
+		isDriver := offer.DriverID == userID
+		isPassenger := request.PassengerID == userID
+		if !isDriver && !isPassenger {
+			return errors.New("unauthorized: user is not part of this match")
+		}
+		if !match.CanTransitionTo(model.StatusConfirmed) {
+			return &model.TransitionError{Entity: "ride match", From: match.Status, To: model.StatusConfirmed}
+		}
+
+		// Record acceptance for the side the user is on
+		now := time.Now()
+		if (!isDriver || match.DriverAccepted()) && (!isPassenger || match.PassengerAccepted()) {
+			return ErrAlreadyAccepted
+		}
+		if isDriver && !match.DriverAccepted() {
+			match.DriverAcceptedAt = &now
+		}
+		if isPassenger && !match.PassengerAccepted() {
+			match.PassengerAcceptedAt = &now
+		}
+
+		// Wait for the other side before reserving seats
+		if !match.DriverAccepted() || !match.PassengerAccepted() {
+			return rideRepo.UpdateRideMatch(match)
+		}
+
+		// Update match, offer and request statuses
+		if err := match.TransitionTo(model.StatusConfirmed); err != nil {
+			return err
+		}
+		if err := offer.Confirm(); err != nil {
+			return err
+		}
+		if err := request.TransitionTo(model.StatusConfirmed); err != nil {
+			return err
+		}
+
+		// Update available seats
+		if offer.AvailableSeats < request.NumPassengers {
+			return ErrNotEnoughSeats
+		}
+		offer.AvailableSeats -= request.NumPassengers
+
+		if err := rideRepo.UpdateRideMatch(match); err != nil {
+			return err
+		}
+		if err := rideRepo.UpdateRideOffer(offer); err != nil {
+			return err
+		}
+		if err := rideRepo.UpdateRideRequest(request); err != nil {
+			return err
+		}
+		confirmed = matchEvents(events.MatchConfirmed, match, offer.DriverID, request.PassengerID)
+		if err := recordEvent(rideRepo, model.EventMatchConfirmed, match.ID, match); err != nil {
+			return err
+		}
+
+		// The passenger has their ride, so the other drivers are not left waiting on the request
+		withdrawn, err = withdrawRequestMatches(rideRepo, request, offers,
+			"The passenger confirmed another ride, so the match was withdrawn")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(context.Background(), withdrawn)
+	s.publish(confirmed)
+	return match, nil
 }
 
-// ConfirmMatch confirms a ride match
-func (s *RideService) ConfirmMatch(matchID uuid.UUID, userID uuid.UUID, isDriver bool) error {
+// RejectMatch declines a proposed ride match on behalf of either of its parties.
+// The offer and request go back to pending when they have no other live matches.
+func (s *RideService) RejectMatch(matchID uuid.UUID, userID uuid.UUID, reason string) (*model.RideMatch, error) {
 	match, err := s.rideRepo.FindRideMatchByID(matchID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if match == nil {
-		return errors.New("match not found")
+		return nil, errors.New("match not found")
+	}
+
+	var rejected []events.Event
+	err = s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in offer, request, match order to avoid deadlocks
+		offer, err := rideRepo.LockRideOfferByID(match.RideOfferID)
+		if err != nil {
+			return err
+		}
+		if offer == nil {
+			return errors.New("ride offer not found")
+		}
+		request, err := rideRepo.LockRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return err
+		}
+		if request == nil {
+			return errors.New("ride request not found")
+		}
+		match, err = rideRepo.LockRideMatchByID(matchID)
+		if err != nil {
+			return err
+		}
+		if match == nil {
+			return errors.New("match not found")
+		}
+
+		// Verify user is involved in this match
+		if offer.DriverID != userID && request.PassengerID != userID {
+			return errors.New("unauthorized: user is not part of this match")
+		}
+
+		if err := match.TransitionTo(model.StatusRejected); err != nil {
+			return err
+		}
+		match.RejectedBy = &userID
+		match.RejectionReason = reason
+		if err := rideRepo.UpdateRideMatch(match); err != nil {
+			return err
+		}
+		if err := recordEvent(rideRepo, model.EventMatchRejected, match.ID, match); err != nil {
+			return err
+		}
+		if err := removeRideStops(rideRepo, offer.ID, request.ID); err != nil {
+			return err
+		}
+
+		if isOpenStatus(offer.Status) {
+			if err := syncRideOfferStatus(rideRepo, offer); err != nil {
+				return err
+			}
+		}
+		rejected = matchEvents(events.MatchRejected, match, offer.DriverID, request.PassengerID)
+		return syncRideRequestStatus(rideRepo, request.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(rejected)
+	return match, nil
+}
+
+// StartRide marks a confirmed ride offer and its confirmed matches as in progress
+func (s *RideService) StartRide(offerID uuid.UUID, driverID uuid.UUID) error {
+	var started []events.Event
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		offer, err := lockDriverRideOffer(rideRepo, offerID, driverID)
+		if err != nil {
+			return err
+		}
+		if err := offer.TransitionTo(model.StatusInProgress); err != nil {
+			return err
+		}
+
+		matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+		if err != nil {
+			return err
+		}
+
+		started = append(started, rideEvent(events.RideStarted, offer.DriverID, offer.ID, nil))
+		for i := range matches {
+			match := &matches[i]
+			switch match.Status {
+			case model.StatusConfirmed:
+				// Passengers with a confirmed seat travel with the driver
+				if err := transitionMatchAndRequest(rideRepo, match, model.StatusInProgress); err != nil {
+					return err
+				}
+				passengerEvents, err := passengerRideEvents(rideRepo, events.RideStarted, match)
+				if err != nil {
+					return err
+				}
+				started = append(started, passengerEvents...)
+			case model.StatusMatched:
+				// Proposals nobody confirmed are dropped once the car leaves
+				if err := cancelMatch(rideRepo, match); err != nil {
+					return err
+				}
+				if err := syncRideRequestStatus(rideRepo, match.RideRequestID); err != nil {
+					return err
+				}
+				passengerEvents, err := passengerRideEvents(rideRepo, events.RideCancelled, match)
+				if err != nil {
+					return err
+				}
+				started = append(started, passengerEvents...)
+			}
+		}
+
+		if err := rideRepo.UpdateRideOffer(offer); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideStarted, offer.ID, offer)
+	})
+	if err != nil {
+		return err
 	}
 
-	// Verify user is involved in this match
-	if isDriver {
-		offer, err := s.rideRepo.FindRideOfferByID(match.RideOfferID)
+	s.publish(started)
+	return nil
+}
+
+// CompleteRide marks an in-progress ride offer and its passengers as completed
+func (s *RideService) CompleteRide(offerID uuid.UUID, driverID uuid.UUID) error {
+	var completed []events.Event
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		offer, err := lockDriverRideOffer(rideRepo, offerID, driverID)
 		if err != nil {
 			return err
 		}
-		if offer == nil || offer.DriverID != userID {
-			return errors.New("unauthorized: user is not the driver for this match")
+		if err := offer.TransitionTo(model.StatusCompleted); err != nil {
+			return err
 		}
-	} else {
-		request, err := s.rideRepo.FindRideRequestByID(match.RideRequestID)
+
+		matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
 		if err != nil {
 			return err
 		}
-		if request == nil || request.PassengerID != userID {
-			return errors.New("unauthorized: user is not the passenger for this match")
+
+		completed = append(completed, rideEvent(events.RideCompleted, offer.DriverID, offer.ID, nil))
+		for i := range matches {
+			match := &matches[i]
+			if match.Status != model.StatusInProgress {
+				continue
+			}
+			if err := transitionMatchAndRequest(rideRepo, match, model.StatusCompleted); err != nil {
+				return err
+			}
+			passengerEvents, err := passengerRideEvents(rideRepo, events.RideCompleted, match)
+			if err != nil {
+				return err
+			}
+			completed = append(completed, passengerEvents...)
+		}
+
+		if err := rideRepo.UpdateRideOffer(offer); err != nil {
+			return err
+		}
+		if err := releaseDriver(rideRepo, offer); err != nil {
+			return err
 		}
+		return recordEvent(rideRepo, model.EventRideCompleted, offer.ID, offer)
+	})
+	if err != nil {
+		return err
 	}
 
-	// Update match status
-	if match.Status != model.StatusMatched {
-		return errors.New("match cannot be confirmed in its current state")
+	s.publish(completed)
+	return nil
+}
+
+// CancelRideOffer cancels a ride offer on behalf of its driver.
+// All live matches are cancelled and the affected requests go back to matching.
+func (s *RideService) CancelRideOffer(offerID uuid.UUID, driverID uuid.UUID) error {
+	var cancelled []events.Event
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		offer, err := lockDriverRideOffer(rideRepo, offerID, driverID)
+		if err != nil {
+			return err
+		}
+		if err := offer.TransitionTo(model.StatusCancelled); err != nil {
+			return err
+		}
+
+		matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+		if err != nil {
+			return err
+		}
+
+		cancelled = append(cancelled, rideEvent(events.RideCancelled, offer.DriverID, offer.ID, nil))
+		for i := range matches {
+			match := &matches[i]
+			if !match.CanTransitionTo(model.StatusCancelled) {
+				continue
+			}
+
+			if match.Status == model.StatusConfirmed {
+				request, err := rideRepo.FindRideRequestByID(match.RideRequestID)
+				if err != nil {
+					return err
+				}
+				if request != nil {
+					offer.AvailableSeats += request.NumPassengers
+				}
+			}
+
+			if err := cancelMatch(rideRepo, match); err != nil {
+				return err
+			}
+			if err := syncRideRequestStatus(rideRepo, match.RideRequestID); err != nil {
+				return err
+			}
+			passengerEvents, err := passengerRideEvents(rideRepo, events.RideCancelled, match)
+			if err != nil {
+				return err
+			}
+			cancelled = append(cancelled, passengerEvents...)
+		}
+
+		if err := rideRepo.UpdateRideOffer(offer); err != nil {
+			return err
+		}
+		if err := releaseDriver(rideRepo, offer); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideOfferCancelled, offer.ID, offer)
+	})
+	if err != nil {
+		return err
 	}
 
-	match.Status = model.StatusConfirmed
+	s.publish(cancelled)
+	return nil
+}
+
+// CancelRideRequest cancels a ride request on behalf of its passenger.
+// Reserved seats are given back to the offers the passenger was confirmed on.
+// Cancelling an on-demand request withdraws it from drivers and cancels the trip of the driver who took it.
+func (s *RideService) CancelRideRequest(requestID uuid.UUID, passengerID uuid.UUID) error {
+	var cancelled []events.Event
+	err := s.rideRepo.WithTx(func(rideRepo repository.RideRepository) error {
+		// Lock rows in offer, request, match order to avoid deadlocks
+		offers, err := lockRequestOffers(rideRepo, requestID)
+		if err != nil {
+			return err
+		}
+		request, err := lockPassengerRideRequest(rideRepo, requestID, passengerID)
+		if err != nil {
+			return err
+		}
+		matches, err := lockRequestMatches(rideRepo, request.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := request.TransitionTo(model.StatusCancelled); err != nil {
+			return err
+		}
+		if request.Mode == model.ModeOnDemand {
+			if err := cancelDispatches(rideRepo, request.ID); err != nil {
+				return err
+			}
+		}
+
+		cancelled = append(cancelled, rideEvent(events.RideCancelled, request.PassengerID, request.ID, nil))
+		for i := range matches {
+			match := &matches[i]
+			if !match.CanTransitionTo(model.StatusCancelled) {
+				continue
+			}
+
+			offer, err := requestOffer(rideRepo, offers, match.RideOfferID)
+			if err != nil {
+				return err
+			}
+
+			wasConfirmed := match.Status == model.StatusConfirmed
+			if err := cancelMatch(rideRepo, match); err != nil {
+				return err
+			}
+			if offer != nil {
+				cancelled = append(cancelled, rideEvent(events.RideCancelled, offer.DriverID, offer.ID, match))
+			}
 
-	// Update offer and request statuses
-	offer, err := s.rideRepo.FindRideOfferByID(match.RideOfferID)
+			if offer == nil || !isOpenStatus(offer.Status) {
+				continue
+			}
+			// The trip existed only for this passenger
+			if request.Mode == model.ModeOnDemand {
+				if err := offer.TransitionTo(model.StatusCancelled); err != nil {
+					return err
+				}
+				if err := rideRepo.UpdateRideOffer(offer); err != nil {
+					return err
+				}
+				if err := releaseDriver(rideRepo, offer); err != nil {
+					return err
+				}
+				if err := recordEvent(rideRepo, model.EventRideOfferCancelled, offer.ID, offer); err != nil {
+					return err
+				}
+				continue
+			}
+			if wasConfirmed {
+				offer.AvailableSeats += request.NumPassengers
+			}
+			if err := syncRideOfferStatus(rideRepo, offer); err != nil {
+				return err
+			}
+		}
+
+		if err := rideRepo.UpdateRideRequest(request); err != nil {
+			return err
+		}
+		return recordEvent(rideRepo, model.EventRideRequestCancelled, request.ID, request)
+	})
 	if err != nil {
 		return err
 	}
-	request, err := s.rideRepo.FindRideRequestByID(match.RideRequestID)
+
+	s.publish(cancelled)
+	return nil
+}
+
+// lockDriverRideOffer locks a ride offer and checks that it belongs to the driver
+func lockDriverRideOffer(rideRepo repository.RideRepository, offerID uuid.UUID, driverID uuid.UUID) (*model.RideOffer, error) {
+	offer, err := rideRepo.LockRideOfferByID(offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil {
+		return nil, errors.New("ride offer not found")
+	}
+	if offer.DriverID != driverID {
+		return nil, errors.New("unauthorized: user is not the driver for this ride offer")
+	}
+	return offer, nil
+}
+
+// lockPassengerRideRequest locks a ride request and checks that it belongs to the passenger
+func lockPassengerRideRequest(rideRepo repository.RideRepository, requestID uuid.UUID, passengerID uuid.UUID) (*model.RideRequest, error) {
+	request, err := rideRepo.LockRideRequestByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, errors.New("ride request not found")
+	}
+	if request.PassengerID != passengerID {
+		return nil, errors.New("unauthorized: user is not the passenger for this ride request")
+	}
+	return request, nil
+}
+
+// lockRequestOffers locks the offers a ride request is matched on, in ID order, so that they can be
+// locked ahead of the request. Offers that no longer exist are left out of the returned map.
+func lockRequestOffers(rideRepo repository.RideRepository, requestID uuid.UUID) (map[uuid.UUID]*model.RideOffer, error) {
+	matches, err := rideRepo.FindRideMatchesByRequestID(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.RideOfferID)
+	}
+	sortIDs(ids)
+
+	offers := make(map[uuid.UUID]*model.RideOffer, len(ids))
+	for _, id := range ids {
+		if _, ok := offers[id]; ok {
+			continue
+		}
+		offer, err := rideRepo.LockRideOfferByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if offer != nil {
+			offers[id] = offer
+		}
+	}
+	return offers, nil
+}
+
+// requestOffer returns one of the offers locked by lockRequestOffers. An offer the request was
+// matched on after they were locked is locked now; matching holds the request lock, so that can
+// only happen when it finished between reading the matches and locking the request.
+func requestOffer(rideRepo repository.RideRepository, offers map[uuid.UUID]*model.RideOffer, offerID uuid.UUID) (*model.RideOffer, error) {
+	if offer, ok := offers[offerID]; ok {
+		return offer, nil
+	}
+	offer, err := rideRepo.LockRideOfferByID(offerID)
+	if err != nil || offer == nil {
+		return nil, err
+	}
+	offers[offerID] = offer
+	return offer, nil
+}
+
+// lockRequestMatches locks the matches of a ride request in ID order.
+// It is called once the request is locked, after the offers the matches are on.
+func lockRequestMatches(rideRepo repository.RideRepository, requestID uuid.UUID) ([]model.RideMatch, error) {
+	found, err := rideRepo.FindRideMatchesByRequestID(requestID)
 	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(found))
+	for _, match := range found {
+		ids = append(ids, match.ID)
+	}
+	sortIDs(ids)
+
+	matches := make([]model.RideMatch, 0, len(ids))
+	for _, id := range ids {
+		locked, err := rideRepo.LockRideMatchByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if locked != nil {
+			matches = append(matches, *locked)
+		}
+	}
+	return matches, nil
+}
+
+// sortIDs puts IDs in the order rows are locked in when a transaction locks several of a kind
+func sortIDs(ids []uuid.UUID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+}
+
+// transitionMatchAndRequest moves a match and its ride request to the same status
+func transitionMatchAndRequest(rideRepo repository.RideRepository, match *model.RideMatch, status model.RideStatus) error {
+	if err := match.TransitionTo(status); err != nil {
+		return err
+	}
+	if err := rideRepo.UpdateRideMatch(match); err != nil {
 		return err
 	}
 
-	offer.Status = model.StatusConfirmed
-	request.Status = model.StatusConfirmed
+	request, err := rideRepo.LockRideRequestByID(match.RideRequestID)
+	if err != nil {
+		return err
+	}
+	if request == nil {
+		return nil
+	}
+	if err := request.TransitionTo(status); err != nil {
+		return err
+	}
+	return rideRepo.UpdateRideRequest(request)
+}
 
-	// Update available seats
-	offer.AvailableSeats -= request.NumPassengers
+// cancelMatch marks a single match as cancelled and takes its stops off the route
+func cancelMatch(rideRepo repository.RideRepository, match *model.RideMatch) error {
+	if err := match.TransitionTo(model.StatusCancelled); err != nil {
+		return err
+	}
+	if err := rideRepo.UpdateRideMatch(match); err != nil {
+		return err
+	}
+	if err := recordEvent(rideRepo, model.EventMatchCancelled, match.ID, match); err != nil {
+		return err
+	}
+	return removeRideStops(rideRepo, match.RideOfferID, match.RideRequestID)
+}
 
-	if err := s.rideRepo.UpdateRideMatch(match); err != nil {
+// syncRideOfferStatus recomputes the status of an open ride offer from its remaining matches
+func syncRideOfferStatus(rideRepo repository.RideRepository, offer *model.RideOffer) error {
+	matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil {
 		return err
 	}
-	if err := s.rideRepo.UpdateRideOffer(offer); err != nil {
+
+	switch status := openStatusFromMatches(matches); {
+	case status == offer.Status:
+	case offer.Status == model.StatusConfirmed:
+		if err := offer.Reopen(status); err != nil {
+			return err
+		}
+	default:
+		if err := offer.TransitionTo(status); err != nil {
+			return err
+		}
+	}
+	return rideRepo.UpdateRideOffer(offer)
+}
+
+// syncRideRequestStatus recomputes the status of an open ride request from its remaining matches
+func syncRideRequestStatus(rideRepo repository.RideRepository, requestID uuid.UUID) error {
+	request, err := rideRepo.LockRideRequestByID(requestID)
+	if err != nil {
 		return err
 	}
-	if err := s.rideRepo.UpdateRideRequest(request); err != nil {
+	if request == nil || !isOpenStatus(request.Status) {
+		return nil
+	}
+
+	matches, err := rideRepo.FindRideMatchesByRequestID(request.ID)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	switch status := openStatusFromMatches(matches); {
+	case status == request.Status:
+		return nil
+	case request.Status == model.StatusConfirmed:
+		if err := request.Reopen(status); err != nil {
+			return err
+		}
+	default:
+		if err := request.TransitionTo(status); err != nil {
+			return err
+		}
+	}
+	return rideRepo.UpdateRideRequest(request)
+}
+
+// isOpenStatus reports whether an offer or request has not started, finished or been cancelled
+func isOpenStatus(status model.RideStatus) bool {
+	return status == model.StatusPending || status == model.StatusMatched || status == model.StatusConfirmed
+}
+
+// openStatusFromMatches derives the status of an open offer or request from its matches:
+// confirmed if any match is confirmed, matched if any is still proposed, pending otherwise
+func openStatusFromMatches(matches []model.RideMatch) model.RideStatus {
+	status := model.StatusPending
+	for _, match := range matches {
+		switch match.Status {
+		case model.StatusConfirmed:
+			return model.StatusConfirmed
+		case model.StatusMatched:
+			status = model.StatusMatched
+		}
+	}
+	return status
 }
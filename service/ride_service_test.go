@@ -0,0 +1,419 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/jobs"
+	"github.com/yourusername/ride-sharing-app/infrastructure/notify"
+	"github.com/yourusername/ride-sharing-app/infrastructure/routing"
+	"github.com/yourusername/ride-sharing-app/repository/memory"
+)
+
+// Places the test rides start and end at, a few kilometres apart
+var (
+	centralStation = model.Location{Latitude: 52.3791, Longitude: 4.9003, Address: "Centraal Station"}
+	museumSquare   = model.Location{Latitude: 52.3579, Longitude: 4.8816, Address: "Museumplein"}
+	zuidStation    = model.Location{Latitude: 52.3389, Longitude: 4.8730, Address: "Station Zuid"}
+	amstelStation  = model.Location{Latitude: 52.3467, Longitude: 4.9177, Address: "Station Amstel"}
+)
+
+// newTestService builds a RideService over in-memory repositories. Its job queue is never started,
+// so matching and dispatch only run when a test calls them.
+func newTestService(t *testing.T) (*RideService, repository.RideRepository, repository.UserRepository) {
+	t.Helper()
+	rideRepo := memory.NewRideRepository()
+	userRepo := memory.NewUserRepository()
+	queue := jobs.NewQueue(jobs.Config{Workers: 1, QueueSize: 100, MaxAttempts: 1, Backoff: time.Millisecond})
+	service := NewRideService(
+		rideRepo,
+		userRepo,
+		routing.StraightLineEstimator{},
+		NewDefaultScorer(ScoreWeights{Price: 0.4, Time: 0.3, Location: 0.3}, 2*time.Hour),
+		MatchingOptions{Window: 2 * time.Hour, Threshold: 0.1},
+		ExpiryOptions{Grace: time.Hour},
+		DispatchOptions{RadiusKm: 5, AcceptWindow: time.Minute, MaxWait: 10 * time.Minute, LocationMaxAge: time.Minute, PricePerKm: 1},
+		TrackingOptions{MaxBatchSize: 100},
+		queue,
+		notify.LogNotifier{},
+		nil,
+	)
+	return service, rideRepo, userRepo
+}
+
+// createOffer saves a pending offer from the central station to station Zuid departing in an hour
+func createOffer(t *testing.T, rideRepo repository.RideRepository, seats int) *model.RideOffer {
+	t.Helper()
+	offer := &model.RideOffer{
+		DriverID:        uuid.New(),
+		StartLocation:   centralStation,
+		EndLocation:     zuidStation,
+		DepartureTime:   time.Now().Add(time.Hour),
+		AvailableSeats:  seats,
+		PricePerSeat:    10,
+		AllowedDetourKm: 5,
+		Status:          model.StatusPending,
+	}
+	if err := rideRepo.CreateRideOffer(offer); err != nil {
+		t.Fatalf("CreateRideOffer: %v", err)
+	}
+	return offer
+}
+
+// createRequest saves a pending request from the central station to the museum square departing in an hour
+func createRequest(t *testing.T, rideRepo repository.RideRepository, passengers int) *model.RideRequest {
+	t.Helper()
+	request := &model.RideRequest{
+		PassengerID:   uuid.New(),
+		StartLocation: centralStation,
+		EndLocation:   museumSquare,
+		DepartureTime: time.Now().Add(time.Hour),
+		NumPassengers: passengers,
+		MaxPrice:      50,
+		Status:        model.StatusPending,
+		Mode:          model.ModeScheduled,
+	}
+	if err := rideRepo.CreateRideRequest(request); err != nil {
+		t.Fatalf("CreateRideRequest: %v", err)
+	}
+	return request
+}
+
+// createMatch proposes a match between an offer and a request and marks both as matched
+func createMatch(t *testing.T, rideRepo repository.RideRepository, offer *model.RideOffer, request *model.RideRequest) *model.RideMatch {
+	t.Helper()
+	match := &model.RideMatch{
+		RideOfferID:   offer.ID,
+		RideRequestID: request.ID,
+		Status:        model.StatusMatched,
+		MatchScore:    0.8,
+		Price:         10,
+	}
+	if err := rideRepo.CreateRideMatch(match); err != nil {
+		t.Fatalf("CreateRideMatch: %v", err)
+	}
+	if err := markMatched(rideRepo, offer, request); err != nil {
+		t.Fatalf("markMatched: %v", err)
+	}
+	return match
+}
+
+// findOffer reads an offer back from the repository
+func findOffer(t *testing.T, rideRepo repository.RideRepository, id uuid.UUID) *model.RideOffer {
+	t.Helper()
+	offer, err := rideRepo.FindRideOfferByID(id)
+	if err != nil || offer == nil {
+		t.Fatalf("FindRideOfferByID(%s) = %+v, %v", id, offer, err)
+	}
+	return offer
+}
+
+// findRequest reads a request back from the repository
+func findRequest(t *testing.T, rideRepo repository.RideRepository, id uuid.UUID) *model.RideRequest {
+	t.Helper()
+	request, err := rideRepo.FindRideRequestByID(id)
+	if err != nil || request == nil {
+		t.Fatalf("FindRideRequestByID(%s) = %+v, %v", id, request, err)
+	}
+	return request
+}
+
+// findMatch reads a match back from the repository
+func findMatch(t *testing.T, rideRepo repository.RideRepository, id uuid.UUID) *model.RideMatch {
+	t.Helper()
+	match, err := rideRepo.FindRideMatchByID(id)
+	if err != nil || match == nil {
+		t.Fatalf("FindRideMatchByID(%s) = %+v, %v", id, match, err)
+	}
+	return match
+}
+
+func TestConfirmMatchWaitsForBothSides(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	request := createRequest(t, rideRepo, 2)
+	match := createMatch(t, rideRepo, offer, request)
+
+	accepted, err := service.ConfirmMatch(match.ID, offer.DriverID)
+	if err != nil {
+		t.Fatalf("ConfirmMatch(driver): %v", err)
+	}
+	if !accepted.DriverAccepted() || accepted.PassengerAccepted() || accepted.Status != model.StatusMatched {
+		t.Fatalf("after the driver accepted: %+v, want only the driver's acceptance", accepted)
+	}
+	if seats := findOffer(t, rideRepo, offer.ID).AvailableSeats; seats != 3 {
+		t.Fatalf("AvailableSeats after one side accepted = %d, want 3", seats)
+	}
+
+	confirmed, err := service.ConfirmMatch(match.ID, request.PassengerID)
+	if err != nil {
+		t.Fatalf("ConfirmMatch(passenger): %v", err)
+	}
+	if confirmed.Status != model.StatusConfirmed || !confirmed.DriverAccepted() || !confirmed.PassengerAccepted() {
+		t.Fatalf("after both sides accepted: %+v, want a confirmed match", confirmed)
+	}
+	savedOffer := findOffer(t, rideRepo, offer.ID)
+	if savedOffer.Status != model.StatusConfirmed || savedOffer.AvailableSeats != 1 {
+		t.Errorf("offer = %s with %d seats, want confirmed with 1 seat", savedOffer.Status, savedOffer.AvailableSeats)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusConfirmed {
+		t.Errorf("request status = %s, want confirmed", status)
+	}
+}
+
+func TestConfirmMatchWithdrawsTheRequestsOtherMatches(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	notifier := &recordingNotifier{}
+	service.notifier = notifier
+	offer := createOffer(t, rideRepo, 3)
+	other := createOffer(t, rideRepo, 3)
+	request := createRequest(t, rideRepo, 1)
+	match := createMatch(t, rideRepo, offer, request)
+	otherMatch := createMatch(t, rideRepo, other, request)
+
+	// The other driver accepted too, and would only find out when confirming
+	if _, err := service.ConfirmMatch(otherMatch.ID, other.DriverID); err != nil {
+		t.Fatalf("ConfirmMatch(other driver): %v", err)
+	}
+	for _, userID := range []uuid.UUID{offer.DriverID, request.PassengerID} {
+		if _, err := service.ConfirmMatch(match.ID, userID); err != nil {
+			t.Fatalf("ConfirmMatch: %v", err)
+		}
+	}
+
+	if withdrawn, err := rideRepo.FindRideMatchByID(otherMatch.ID); err != nil || withdrawn != nil {
+		t.Fatalf("FindRideMatchByID(other match) = %+v, %v, want it withdrawn", withdrawn, err)
+	}
+	if status := findOffer(t, rideRepo, other.ID).Status; status != model.StatusPending {
+		t.Errorf("other offer status = %s, want pending", status)
+	}
+	if !notifier.sent(other.DriverID, notify.RideMatchWithdrawn) {
+		t.Error("the other driver was not told the match was withdrawn")
+	}
+	if status := findMatch(t, rideRepo, match.ID).Status; status != model.StatusConfirmed {
+		t.Errorf("confirmed match status = %s, want confirmed", status)
+	}
+}
+
+func TestConfirmMatchTwiceByTheSameSide(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	request := createRequest(t, rideRepo, 1)
+	match := createMatch(t, rideRepo, offer, request)
+
+	if _, err := service.ConfirmMatch(match.ID, request.PassengerID); err != nil {
+		t.Fatalf("ConfirmMatch(passenger): %v", err)
+	}
+	if _, err := service.ConfirmMatch(match.ID, request.PassengerID); !errors.Is(err, ErrAlreadyAccepted) {
+		t.Fatalf("second ConfirmMatch(passenger) = %v, want ErrAlreadyAccepted", err)
+	}
+	if _, err := service.ConfirmMatch(match.ID, uuid.New()); err == nil {
+		t.Fatal("ConfirmMatch by a user outside the match succeeded")
+	}
+
+	saved := findMatch(t, rideRepo, match.ID)
+	if saved.Status != model.StatusMatched || saved.DriverAccepted() {
+		t.Errorf("match = %+v, want it still waiting for the driver", saved)
+	}
+}
+
+func TestConfirmMatchWithoutSeatsLeft(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 2)
+	first := createRequest(t, rideRepo, 2)
+	second := createRequest(t, rideRepo, 1)
+	firstMatch := createMatch(t, rideRepo, offer, first)
+	secondMatch := createMatch(t, rideRepo, offer, second)
+
+	for _, userID := range []uuid.UUID{offer.DriverID, first.PassengerID} {
+		if _, err := service.ConfirmMatch(firstMatch.ID, userID); err != nil {
+			t.Fatalf("ConfirmMatch(first match): %v", err)
+		}
+	}
+	if _, err := service.ConfirmMatch(secondMatch.ID, offer.DriverID); err != nil {
+		t.Fatalf("ConfirmMatch(second match, driver): %v", err)
+	}
+	if _, err := service.ConfirmMatch(secondMatch.ID, second.PassengerID); !errors.Is(err, ErrNotEnoughSeats) {
+		t.Fatalf("ConfirmMatch(second match, passenger) = %v, want ErrNotEnoughSeats", err)
+	}
+
+	// The failed confirmation is rolled back entirely
+	saved := findMatch(t, rideRepo, secondMatch.ID)
+	if saved.Status != model.StatusMatched || saved.PassengerAccepted() {
+		t.Errorf("second match = %+v, want it unconfirmed", saved)
+	}
+	if status := findRequest(t, rideRepo, second.ID).Status; status != model.StatusMatched {
+		t.Errorf("second request status = %s, want matched", status)
+	}
+	if seats := findOffer(t, rideRepo, offer.ID).AvailableSeats; seats != 0 {
+		t.Errorf("AvailableSeats = %d, want 0", seats)
+	}
+}
+
+func TestRejectMatchFreesBothSides(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	request := createRequest(t, rideRepo, 1)
+	match := createMatch(t, rideRepo, offer, request)
+
+	if _, err := service.RejectMatch(match.ID, uuid.New(), "not my ride"); err == nil {
+		t.Fatal("RejectMatch by a user outside the match succeeded")
+	}
+	rejected, err := service.RejectMatch(match.ID, offer.DriverID, "no room for luggage")
+	if err != nil {
+		t.Fatalf("RejectMatch: %v", err)
+	}
+	if rejected.Status != model.StatusRejected || rejected.RejectedBy == nil || *rejected.RejectedBy != offer.DriverID {
+		t.Errorf("match = %+v, want rejected by the driver", rejected)
+	}
+	if reason := findMatch(t, rideRepo, match.ID).RejectionReason; reason != "no room for luggage" {
+		t.Errorf("RejectionReason = %q, want the driver's reason", reason)
+	}
+	if status := findOffer(t, rideRepo, offer.ID).Status; status != model.StatusPending {
+		t.Errorf("offer status = %s, want pending", status)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusPending {
+		t.Errorf("request status = %s, want pending", status)
+	}
+	if _, err := service.RejectMatch(match.ID, request.PassengerID, "changed my mind"); err == nil {
+		t.Error("rejecting a rejected match again succeeded")
+	}
+}
+
+func TestRejectedPairIsNeverProposedAgain(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	request := createRequest(t, rideRepo, 1)
+
+	if err := service.findMatchesForOffer(context.Background(), offer.ID); err != nil {
+		t.Fatalf("findMatchesForOffer: %v", err)
+	}
+	matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("FindRideMatchesByOfferID = %d matches, %v, want the pair proposed", len(matches), err)
+	}
+	if _, err := service.RejectMatch(matches[0].ID, request.PassengerID, "too early"); err != nil {
+		t.Fatalf("RejectMatch: %v", err)
+	}
+
+	// Both sides are pending again, but matching from either one skips the pair
+	if err := service.findMatchesForOffer(context.Background(), offer.ID); err != nil {
+		t.Fatalf("findMatchesForOffer after the rejection: %v", err)
+	}
+	if err := service.findMatchesForRequest(context.Background(), request.ID); err != nil {
+		t.Fatalf("findMatchesForRequest after the rejection: %v", err)
+	}
+	matches, err = rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil || len(matches) != 1 || matches[0].Status != model.StatusRejected {
+		t.Errorf("matches = %+v, %v, want only the rejected one", matches, err)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusPending {
+		t.Errorf("request status = %s, want pending", status)
+	}
+}
+
+func TestSweepMatchesProposesPairsOnce(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	request := createRequest(t, rideRepo, 1)
+
+	for i := 0; i < 2; i++ {
+		if err := service.SweepMatches(context.Background()); err != nil {
+			t.Fatalf("SweepMatches run %d: %v", i+1, err)
+		}
+	}
+
+	matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil || len(matches) != 1 || matches[0].RideRequestID != request.ID {
+		t.Fatalf("matches after two sweeps = %+v, %v, want the pair proposed once", matches, err)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusMatched {
+		t.Errorf("request status = %s, want matched", status)
+	}
+}
+
+func TestSweepMatchesFitsRequestsIntoMatchedOffers(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	// The offer already carries a passenger, so only the request's side of the sweep reaches it
+	offer := createOffer(t, rideRepo, 3)
+	createMatch(t, rideRepo, offer, createRequest(t, rideRepo, 1))
+	request := createRequest(t, rideRepo, 1)
+
+	if err := service.SweepMatches(context.Background()); err != nil {
+		t.Fatalf("SweepMatches: %v", err)
+	}
+	if _, ok := matchedRequests(t, rideRepo, offer.ID)[request.ID]; !ok {
+		t.Error("a pending request was not pooled into a matched offer")
+	}
+}
+
+func TestSweepMatchesSkipsDepartedRides(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	offer.DepartureTime = time.Now().Add(-time.Minute)
+	if err := rideRepo.UpdateRideOffer(offer); err != nil {
+		t.Fatalf("UpdateRideOffer: %v", err)
+	}
+	request := createRequest(t, rideRepo, 1)
+	request.DepartureTime = offer.DepartureTime
+	if err := rideRepo.UpdateRideRequest(request); err != nil {
+		t.Fatalf("UpdateRideRequest: %v", err)
+	}
+
+	if err := service.SweepMatches(context.Background()); err != nil {
+		t.Fatalf("SweepMatches: %v", err)
+	}
+	if matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID); err != nil || len(matches) != 0 {
+		t.Errorf("departed offer has %d matches, %v, want none", len(matches), err)
+	}
+}
+
+func TestSweepMatchesStopsWhenCancelled(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	createRequest(t, rideRepo, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := service.SweepMatches(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SweepMatches = %v, want context.Canceled", err)
+	}
+	if matches, err := rideRepo.FindRideMatchesByOfferID(offer.ID); err != nil || len(matches) != 0 {
+		t.Errorf("offer has %d matches, %v, want none after a cancelled sweep", len(matches), err)
+	}
+}
+
+func TestCancelRideRequestRestoresSeats(t *testing.T) {
+	service, rideRepo, _ := newTestService(t)
+	offer := createOffer(t, rideRepo, 3)
+	request := createRequest(t, rideRepo, 2)
+	match := createMatch(t, rideRepo, offer, request)
+	for _, userID := range []uuid.UUID{offer.DriverID, request.PassengerID} {
+		if _, err := service.ConfirmMatch(match.ID, userID); err != nil {
+			t.Fatalf("ConfirmMatch: %v", err)
+		}
+	}
+
+	if err := service.CancelRideRequest(request.ID, uuid.New()); err == nil {
+		t.Fatal("CancelRideRequest by another passenger succeeded")
+	}
+	if err := service.CancelRideRequest(request.ID, request.PassengerID); err != nil {
+		t.Fatalf("CancelRideRequest: %v", err)
+	}
+
+	savedOffer := findOffer(t, rideRepo, offer.ID)
+	if savedOffer.AvailableSeats != 3 || savedOffer.Status != model.StatusPending {
+		t.Errorf("offer = %s with %d seats, want pending with 3 seats", savedOffer.Status, savedOffer.AvailableSeats)
+	}
+	if status := findMatch(t, rideRepo, match.ID).Status; status != model.StatusCancelled {
+		t.Errorf("match status = %s, want cancelled", status)
+	}
+	if status := findRequest(t, rideRepo, request.ID).Status; status != model.StatusCancelled {
+		t.Errorf("request status = %s, want cancelled", status)
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/geo"
+)
+
+const (
+	// ScorerDefault scores matches by price, departure time and detour
+	ScorerDefault = "default"
+	// ScorerRating also takes the driver's rating and the passenger's preferences into account
+	ScorerRating = "rating"
+)
+
+// neutralRating is the rating factor of drivers nobody has rated yet
+const neutralRating = 0.5
+
+// maxRating is the highest rating a driver can have
+const maxRating = 5
+
+// MatchCandidate is a ride request being considered for a seat on a ride offer
+type MatchCandidate struct {
+	Offer   *model.RideOffer
+	Request *model.RideRequest
+	// DetourKm is how much longer the driver's route gets by serving the request
+	DetourKm float64
+	// Fare is what the request's passengers pay for their part of the route
+	Fare float64
+}
+
+// MatchScorer rates how well a ride request fits a ride offer
+type MatchScorer interface {
+	// Score returns a breakdown whose Score is between 0 and 1; a higher score means a better match.
+	// Pairs the scorer rules out score 0 with the reason in Rejection.
+	Score(candidate *MatchCandidate) (model.MatchBreakdown, error)
+}
+
+// ScoreWeights sets how much each factor counts towards a match score.
+// Scores are weighted averages, so only the ratios between weights matter.
+type ScoreWeights struct {
+	Price    float64
+	Time     float64
+	Location float64
+	Rating   float64
+}
+
+// MatchingOptions tunes which ride offers and requests are paired
+type MatchingOptions struct {
+	// Window is how far apart in time an offer and a request may depart
+	Window time.Duration
+	// Threshold is the score a pairing must exceed to be proposed
+	Threshold float64
+}
+
+// NewMatchScorer creates the match scorer selected by name
+func NewMatchScorer(name string, userRepo repository.UserRepository, weights ScoreWeights, window time.Duration) (MatchScorer, error) {
+	switch name {
+	case "", ScorerDefault:
+		return NewDefaultScorer(weights, window), nil
+	case ScorerRating:
+		return NewRatingScorer(userRepo, weights, window), nil
+	default:
+		return nil, fmt.Errorf("unknown match scorer %q", name)
+	}
+}
+
+// measureMatch returns a breakdown holding a candidate's measurements but no scores
+func measureMatch(candidate *MatchCandidate) model.MatchBreakdown {
+	offer, request := candidate.Offer, candidate.Request
+	return model.MatchBreakdown{
+		PickupDistanceKm: geo.Distance(offer.StartLocation.Latitude, offer.StartLocation.Longitude,
+			request.StartLocation.Latitude, request.StartLocation.Longitude),
+		DropoffDistanceKm: geo.Distance(request.EndLocation.Latitude, request.EndLocation.Longitude,
+			offer.EndLocation.Latitude, offer.EndLocation.Longitude),
+		TimeDifferenceMinutes: math.Abs(offer.DepartureTime.Sub(request.DepartureTime).Minutes()),
+		DetourKm:              candidate.DetourKm,
+		Fare:                  candidate.Fare,
+	}
+}
+
+// newBreakdown measures a candidate and works out its price, time and location scores
+func newBreakdown(candidate *MatchCandidate, window time.Duration) model.MatchBreakdown {
+	offer, request := candidate.Offer, candidate.Request
+	breakdown := measureMatch(candidate)
+
+	// Calculate price compatibility (0-1) for the part of the route the passengers travel
+	breakdown.PriceScore = 1
+	if candidate.Fare > request.MaxPrice {
+		breakdown.PriceScore = 0
+	}
+
+	// Calculate time compatibility (0-1)
+	if window > 0 {
+		breakdown.TimeScore = math.Max(0, 1-breakdown.TimeDifferenceMinutes/window.Minutes())
+	}
+
+	// Calculate location compatibility (0-1)
+	breakdown.LocationScore = 1
+	if offer.AllowedDetourKm > 0 {
+		breakdown.LocationScore = math.Max(0, 1-candidate.DetourKm/offer.AllowedDetourKm)
+	}
+
+	return breakdown
+}
+
+// weightedScore returns the weighted average of a breakdown's component scores
+func weightedScore(breakdown model.MatchBreakdown, weights ScoreWeights) float64 {
+	total := weights.Price + weights.Time + weights.Location + weights.Rating
+	if total <= 0 {
+		return 0
+	}
+	score := breakdown.PriceScore*weights.Price +
+		breakdown.TimeScore*weights.Time +
+		breakdown.LocationScore*weights.Location
+	if breakdown.RatingScore != nil {
+		score += *breakdown.RatingScore * weights.Rating
+	}
+	return score / total
+}
+
+// DefaultScorer scores matches by price, departure time and detour
+type DefaultScorer struct {
+	weights ScoreWeights
+	window  time.Duration
+}
+
+// NewDefaultScorer creates a new DefaultScorer. The rating weight is ignored.
+func NewDefaultScorer(weights ScoreWeights, window time.Duration) *DefaultScorer {
+	weights.Rating = 0
+	return &DefaultScorer{weights: weights, window: window}
+}
+
+// Score calculates a matching score between an offer and a request
+func (s *DefaultScorer) Score(candidate *MatchCandidate) (model.MatchBreakdown, error) {
+	breakdown := newBreakdown(candidate, s.window)
+	breakdown.Score = weightedScore(breakdown, s.weights)
+	return breakdown, nil
+}
+
+// RatingScorer scores matches like DefaultScorer and also by the driver's rating.
+// Passengers can exclude drivers below a minimum rating and name the factor they
+// care about most, which then counts twice.
+type RatingScorer struct {
+	userRepo repository.UserRepository
+	weights  ScoreWeights
+	window   time.Duration
+}
+
+// NewRatingScorer creates a new RatingScorer
+func NewRatingScorer(userRepo repository.UserRepository, weights ScoreWeights, window time.Duration) *RatingScorer {
+	return &RatingScorer{userRepo: userRepo, weights: weights, window: window}
+}
+
+// Score calculates a matching score between an offer and a request
+func (s *RatingScorer) Score(candidate *MatchCandidate) (model.MatchBreakdown, error) {
+	breakdown := newBreakdown(candidate, s.window)
+
+	profile, err := s.userRepo.GetDriverProfile(candidate.Offer.DriverID)
+	if err != nil {
+		return breakdown, err
+	}
+	preferences, err := s.userRepo.GetPassengerPreferences(candidate.Request.PassengerID)
+	if err != nil {
+		return breakdown, err
+	}
+
+	var rating float32
+	if profile != nil {
+		rating = profile.AverageRating
+	}
+	ratingScore := neutralRating
+	if rating > 0 {
+		ratingScore = math.Min(1, float64(rating)/maxRating)
+	}
+	breakdown.RatingScore = &ratingScore
+
+	weights := s.weights
+	if preferences != nil {
+		// Drivers below the passenger's minimum rating, or not rated yet, are never a match
+		if preferences.MinDriverRating > 0 && rating < preferences.MinDriverRating {
+			breakdown.Rejection = fmt.Sprintf("driver rating %.1f is below the passenger's minimum of %.1f",
+				rating, preferences.MinDriverRating)
+			return breakdown, nil
+		}
+
+		switch preferences.Priority {
+		case model.PriorityPrice:
+			weights.Price *= 2
+		case model.PriorityTime:
+			weights.Time *= 2
+		case model.PriorityLocation:
+			weights.Location *= 2
+		case model.PriorityRating:
+			weights.Rating *= 2
+		}
+	}
+
+	breakdown.Score = weightedScore(breakdown, weights)
+	return breakdown, nil
+}
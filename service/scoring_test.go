@@ -0,0 +1,161 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/repository/memory"
+)
+
+// testWeights are uneven so that each factor's share of a score is visible
+var testWeights = ScoreWeights{Price: 0.5, Time: 0.3, Location: 0.2, Rating: 0.4}
+
+// scoringCandidate builds a candidate departing 30 minutes after the offer with a 1 km detour
+// of the 4 km allowed, so that with a 2 hour window its time and location scores are both 0.75
+func scoringCandidate(fare float64) *MatchCandidate {
+	departure := time.Now().Add(time.Hour)
+	return &MatchCandidate{
+		Offer: &model.RideOffer{
+			DriverID:        uuid.New(),
+			StartLocation:   centralStation,
+			EndLocation:     zuidStation,
+			DepartureTime:   departure,
+			AllowedDetourKm: 4,
+		},
+		Request: &model.RideRequest{
+			PassengerID:   uuid.New(),
+			StartLocation: centralStation,
+			EndLocation:   museumSquare,
+			DepartureTime: departure.Add(30 * time.Minute),
+			MaxPrice:      10,
+		},
+		DetourKm: 1,
+		Fare:     fare,
+	}
+}
+
+func TestDefaultScorer(t *testing.T) {
+	cases := []struct {
+		name      string
+		fare      float64
+		wantPrice float64
+		wantScore float64
+		weights   ScoreWeights
+	}{
+		// (0.5*1 + 0.3*0.75 + 0.2*0.75) / 1.0; the rating weight is dropped
+		{name: "within budget", fare: 8, wantPrice: 1, wantScore: 0.875, weights: testWeights},
+		// (0.5*0 + 0.3*0.75 + 0.2*0.75) / 1.0
+		{name: "over budget", fare: 12, wantPrice: 0, wantScore: 0.375, weights: testWeights},
+		// Only the ratios between weights matter
+		{name: "scaled weights", fare: 8, wantPrice: 1, wantScore: 0.875, weights: ScoreWeights{Price: 5, Time: 3, Location: 2}},
+		{name: "no weights", fare: 8, wantPrice: 1, wantScore: 0, weights: ScoreWeights{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			breakdown, err := NewDefaultScorer(tc.weights, 2*time.Hour).Score(scoringCandidate(tc.fare))
+			if err != nil {
+				t.Fatalf("Score: %v", err)
+			}
+			if breakdown.PriceScore != tc.wantPrice || !closeTo(breakdown.TimeScore, 0.75) || !closeTo(breakdown.LocationScore, 0.75) {
+				t.Errorf("component scores = %.3f, %.3f, %.3f; want %.3f, 0.75, 0.75",
+					breakdown.PriceScore, breakdown.TimeScore, breakdown.LocationScore, tc.wantPrice)
+			}
+			if breakdown.RatingScore != nil {
+				t.Errorf("RatingScore = %v, want none", *breakdown.RatingScore)
+			}
+			if !closeTo(breakdown.Score, tc.wantScore) {
+				t.Errorf("Score = %.4f, want %.4f", breakdown.Score, tc.wantScore)
+			}
+		})
+	}
+}
+
+func TestRatingScorer(t *testing.T) {
+	cases := []struct {
+		name          string
+		rating        float32
+		preferences   *model.PassengerPreferences
+		wantRating    float64
+		wantScore     float64
+		wantRejection bool
+	}{
+		// (0.5*1 + 0.3*0.75 + 0.2*0.75 + 0.4*0.8) / 1.4
+		{name: "rated driver", rating: 4, wantRating: 0.8, wantScore: 1.195 / 1.4},
+		// Drivers nobody has rated yet count as average
+		{name: "unrated driver", rating: 0, wantRating: 0.5, wantScore: 1.075 / 1.4},
+		// The time weight counts twice: (0.5*1 + 0.6*0.75 + 0.2*0.75 + 0.4*0.8) / 1.7
+		{name: "time priority", rating: 4, preferences: &model.PassengerPreferences{Priority: model.PriorityTime},
+			wantRating: 0.8, wantScore: 1.42 / 1.7},
+		// The rating weight counts twice: (0.5*1 + 0.3*0.75 + 0.2*0.75 + 0.8*0.8) / 1.8
+		{name: "rating priority", rating: 4, preferences: &model.PassengerPreferences{Priority: model.PriorityRating},
+			wantRating: 0.8, wantScore: 1.515 / 1.8},
+		{name: "meets the minimum rating", rating: 4, preferences: &model.PassengerPreferences{MinDriverRating: 4},
+			wantRating: 0.8, wantScore: 1.195 / 1.4},
+		{name: "below the minimum rating", rating: 3.5, preferences: &model.PassengerPreferences{MinDriverRating: 4},
+			wantRating: 0.7, wantRejection: true},
+		{name: "unrated with a minimum rating", rating: 0, preferences: &model.PassengerPreferences{MinDriverRating: 1},
+			wantRating: 0.5, wantRejection: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := memory.NewUserRepository()
+			candidate := scoringCandidate(8)
+			profile := &model.DriverProfile{UserID: candidate.Offer.DriverID, NumSeats: 3, AverageRating: tc.rating}
+			if err := userRepo.CreateDriverProfile(profile); err != nil {
+				t.Fatalf("CreateDriverProfile: %v", err)
+			}
+			if tc.preferences != nil {
+				tc.preferences.UserID = candidate.Request.PassengerID
+				if err := userRepo.UpdatePassengerPreferences(tc.preferences); err != nil {
+					t.Fatalf("UpdatePassengerPreferences: %v", err)
+				}
+			}
+
+			breakdown, err := NewRatingScorer(userRepo, testWeights, 2*time.Hour).Score(candidate)
+			if err != nil {
+				t.Fatalf("Score: %v", err)
+			}
+			if breakdown.RatingScore == nil || !closeTo(*breakdown.RatingScore, tc.wantRating) {
+				t.Errorf("RatingScore = %v, want %.2f", breakdown.RatingScore, tc.wantRating)
+			}
+			if tc.wantRejection {
+				if breakdown.Rejection == "" || breakdown.Score != 0 {
+					t.Errorf("Score = %.4f, Rejection = %q; want a rejection scoring 0", breakdown.Score, breakdown.Rejection)
+				}
+				return
+			}
+			if breakdown.Rejection != "" || !closeTo(breakdown.Score, tc.wantScore) {
+				t.Errorf("Score = %.4f, Rejection = %q; want %.4f", breakdown.Score, breakdown.Rejection, tc.wantScore)
+			}
+		})
+	}
+}
+
+func TestNewMatchScorer(t *testing.T) {
+	userRepo := memory.NewUserRepository()
+	for name, want := range map[string]string{
+		"":            "*service.DefaultScorer",
+		ScorerDefault: "*service.DefaultScorer",
+		ScorerRating:  "*service.RatingScorer",
+	} {
+		scorer, err := NewMatchScorer(name, userRepo, testWeights, time.Hour)
+		if err != nil {
+			t.Fatalf("NewMatchScorer(%q): %v", name, err)
+		}
+		if got := fmt.Sprintf("%T", scorer); got != want {
+			t.Errorf("NewMatchScorer(%q) = %s, want %s", name, got, want)
+		}
+	}
+	if _, err := NewMatchScorer("nearest", userRepo, testWeights, time.Hour); err == nil {
+		t.Error("NewMatchScorer accepted an unknown scorer")
+	}
+}
+
+// closeTo reports whether two scores are equal but for rounding
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/infrastructure/events"
+)
+
+// maxClockSkew is how far in the future a driver's device clock may run
+const maxClockSkew = time.Minute
+
+// TrackingOptions configures how GPS points of trips are taken and kept
+type TrackingOptions struct {
+	// MaxPointsPerMinute caps the points one ride offer records per minute; 0 disables the limit
+	MaxPointsPerMinute int
+	// MaxBatchSize caps the points in one upload
+	MaxBatchSize int
+	// Retention is how long points are kept after they were recorded; 0 keeps them forever
+	Retention time.Duration
+}
+
+// ErrTripNotActive is returned when locations are reported for a ride that is not in progress
+var ErrTripNotActive = errors.New("the ride is not in progress")
+
+// ErrRateLimited is returned when a driver reports locations faster than allowed
+var ErrRateLimited = errors.New("too many location updates, slow down")
+
+// ErrTripNotFound is returned when the ride offer of a trip does not exist
+var ErrTripNotFound = errors.New("ride offer not found")
+
+// ErrNotTripDriver is returned when someone other than its driver reports locations for a trip
+var ErrNotTripDriver = errors.New("unauthorized: user is not the driver for this ride offer")
+
+// ErrNotOnTrip is returned when someone who is neither its driver nor a confirmed passenger follows a trip
+var ErrNotOnTrip = errors.New("unauthorized: user is neither the driver nor a confirmed passenger of this ride")
+
+// TripTrace is where a trip is and, when asked for, where it has been
+type TripTrace struct {
+	RideOfferID uuid.UUID        `json:"ride_offer_id"`
+	Status      model.RideStatus `json:"status"`
+	// Latest is the most recently recorded point; it is nil until the driver reports one
+	Latest *model.TripLocation `json:"latest"`
+	// Locations is the breadcrumb trail in recording order
+	Locations []model.TripLocation `json:"locations,omitempty"`
+}
+
+// FindActiveTrip retrieves a ride offer of the driver that is in progress and can be tracked
+func (s *RideService) FindActiveTrip(offerID uuid.UUID, driverID uuid.UUID) (*model.RideOffer, error) {
+	offer, err := s.rideRepo.FindRideOfferByID(offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil {
+		return nil, ErrTripNotFound
+	}
+	if offer.DriverID != driverID {
+		return nil, ErrNotTripDriver
+	}
+	if offer.Status != model.StatusInProgress {
+		return nil, ErrTripNotActive
+	}
+	return offer, nil
+}
+
+// RecordTripLocations stores GPS points the driver reported for a ride in progress.
+// Points without a recording time are taken to be recorded now; points may arrive out of order,
+// for example after the driver's phone lost its connection for a while.
+func (s *RideService) RecordTripLocations(offerID uuid.UUID, driverID uuid.UUID, locations []model.TripLocation) ([]model.TripLocation, error) {
+	if len(locations) == 0 {
+		return nil, errors.New("no locations given")
+	}
+	if s.tracking.MaxBatchSize > 0 && len(locations) > s.tracking.MaxBatchSize {
+		return nil, fmt.Errorf("at most %d locations can be sent at once", s.tracking.MaxBatchSize)
+	}
+
+	now := time.Now()
+	for i := range locations {
+		location := &locations[i]
+		if location.Latitude < -90 || location.Latitude > 90 || location.Longitude < -180 || location.Longitude > 180 {
+			return nil, errors.New("invalid coordinates")
+		}
+		if location.RecordedAt.IsZero() {
+			location.RecordedAt = now
+		}
+		if location.RecordedAt.After(now.Add(maxClockSkew)) {
+			return nil, errors.New("recorded time is in the future")
+		}
+		location.ID = uuid.Nil
+		location.RideOfferID = offerID
+	}
+
+	if _, err := s.FindActiveTrip(offerID, driverID); err != nil {
+		return nil, err
+	}
+	if !s.trackingLimiter.Allow(offerID.String(), len(locations)) {
+		return nil, ErrRateLimited
+	}
+
+	sort.SliceStable(locations, func(i, j int) bool {
+		return locations[i].RecordedAt.Before(locations[j].RecordedAt)
+	})
+	if err := s.rideRepo.CreateTripLocations(locations); err != nil {
+		return nil, err
+	}
+
+	// Tell the passengers where the car is; the points are saved even if this fails
+	if err := s.publishDriverLocation(offerID, locations[len(locations)-1]); err != nil {
+		log.Printf("Failed to publish the location of ride offer %s: %v", offerID, err)
+	}
+	return locations, nil
+}
+
+// GetTripLocation retrieves where a ride offer's car was last seen, for its driver or a passenger
+// on it. It returns ErrTripNotFound if the offer does not exist and ErrNotOnTrip if the user is neither.
+func (s *RideService) GetTripLocation(offerID uuid.UUID, userID uuid.UUID) (*TripTrace, error) {
+	offer, err := s.tripForViewer(offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := s.rideRepo.FindLatestTripLocation(offer.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TripTrace{RideOfferID: offer.ID, Status: offer.Status, Latest: latest}, nil
+}
+
+// GetTripTrace retrieves the points of a ride offer's trip recorded after the given time, for its
+// driver or a passenger on it. It returns ErrTripNotFound if the offer does not exist and
+// ErrNotOnTrip if the user is neither.
+func (s *RideService) GetTripTrace(offerID uuid.UUID, userID uuid.UUID, recordedAfter time.Time) (*TripTrace, error) {
+	offer, err := s.tripForViewer(offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	locations, err := s.rideRepo.FindTripLocations(offer.ID, recordedAfter)
+	if err != nil {
+		return nil, err
+	}
+	trace := &TripTrace{RideOfferID: offer.ID, Status: offer.Status, Locations: locations}
+	if len(locations) > 0 {
+		trace.Latest = &locations[len(locations)-1]
+	} else if trace.Latest, err = s.rideRepo.FindLatestTripLocation(offer.ID); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// PurgeTripLocations removes the GPS points older than the retention period
+func (s *RideService) PurgeTripLocations(ctx context.Context) error {
+	if s.tracking.Retention <= 0 {
+		return nil
+	}
+	deleted, err := s.rideRepo.DeleteTripLocationsBefore(time.Now().Add(-s.tracking.Retention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d trip locations past retention", deleted)
+	}
+	return nil
+}
+
+// publishDriverLocation sends the latest point of a ride in progress to the passengers on it
+func (s *RideService) publishDriverLocation(offerID uuid.UUID, latest model.TripLocation) error {
+	if s.publisher == nil {
+		return nil
+	}
+	matches, err := s.rideRepo.FindRideMatchesByOfferID(offerID)
+	if err != nil {
+		return err
+	}
+
+	var located []events.Event
+	for _, match := range matches {
+		if match.Status != model.StatusInProgress {
+			continue
+		}
+		request, err := s.rideRepo.FindRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return err
+		}
+		if request == nil {
+			continue
+		}
+		located = append(located, events.Event{
+			Type:   events.DriverLocation,
+			UserID: request.PassengerID,
+			RideID: request.ID,
+			Data:   latest,
+		})
+	}
+	s.publish(located)
+	return nil
+}
+
+// tripForViewer retrieves a ride offer if the user may follow its trip: its driver, or a passenger
+// whose match on it was confirmed. It returns ErrTripNotFound or ErrNotOnTrip otherwise.
+func (s *RideService) tripForViewer(offerID uuid.UUID, userID uuid.UUID) (*model.RideOffer, error) {
+	offer, err := s.rideRepo.FindRideOfferByID(offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil {
+		return nil, ErrTripNotFound
+	}
+	if offer.DriverID == userID {
+		return offer, nil
+	}
+
+	matches, err := s.rideRepo.FindRideMatchesByOfferID(offer.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		if match.Status != model.StatusConfirmed && match.Status != model.StatusInProgress && match.Status != model.StatusCompleted {
+			continue
+		}
+		request, err := s.rideRepo.FindRideRequestByID(match.RideRequestID)
+		if err != nil {
+			return nil, err
+		}
+		if request != nil && request.PassengerID == userID {
+			return offer, nil
+		}
+	}
+	return nil, ErrNotOnTrip
+}
@@ -179,3 +179,37 @@ func (s *UserService) UpdateDriverProfile(
 
 	return profile, nil
 }
+
+// GetPassengerPreferences retrieves a passenger's match preferences.
+// Passengers who never set any get the zero preferences, which ask nothing of their rides.
+func (s *UserService) GetPassengerPreferences(userID uuid.UUID) (*model.PassengerPreferences, error) {
+	preferences, err := s.userRepo.GetPassengerPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	if preferences == nil {
+		preferences = &model.PassengerPreferences{UserID: userID}
+	}
+	return preferences, nil
+}
+
+// UpdatePassengerPreferences sets a passenger's match preferences
+func (s *UserService) UpdatePassengerPreferences(
+	userID uuid.UUID,
+	minDriverRating float32,
+	priority model.MatchPriority,
+) (*model.PassengerPreferences, error) {
+	preferences, err := s.GetPassengerPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences.MinDriverRating = minDriverRating
+	preferences.Priority = priority
+
+	if err := s.userRepo.UpdatePassengerPreferences(preferences); err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
@@ -0,0 +1,532 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/domain/repository"
+	"github.com/yourusername/ride-sharing-app/infrastructure/eventbus"
+	"github.com/yourusername/ride-sharing-app/infrastructure/webhook"
+)
+
+const (
+	// defaultWebhookBatchSize is how many due deliveries are read at once when no batch size is configured
+	defaultWebhookBatchSize = 50
+	// defaultWebhookMaxAttempts is how many times a delivery is tried when no limit is configured
+	defaultWebhookMaxAttempts = 8
+	// defaultWebhookBackoff is the delay before the first retry when none is configured
+	defaultWebhookBackoff = 30 * time.Second
+	// minWebhookSecretLength is the shortest secret a webhook can be given
+	minWebhookSecretLength = 16
+	// defaultDeliveryLimit and maxDeliveryLimit bound how many deliveries are listed at once
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// ErrWebhookNotFound is returned when a webhook subscription does not exist
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrDeliveryNotFound is returned when a webhook delivery does not exist
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// ErrDeliveryPending is returned when a delivery that is still being tried is replayed
+var ErrDeliveryPending = errors.New("webhook delivery is still pending")
+
+// WebhookOptions configures how events are delivered to webhooks
+type WebhookOptions struct {
+	// BatchSize is how many due deliveries are read at once
+	BatchSize int
+	// MaxAttempts is how many times a delivery is tried before it is given up as dead
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles with every retry
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries; 0 leaves it uncapped
+	MaxBackoff time.Duration
+}
+
+// WebhookInput holds the settings of a new webhook subscription
+type WebhookInput struct {
+	Name       string
+	URL        string
+	EventTypes []model.DomainEventType
+	// UserIDs limits the webhook to the rides of these users; empty covers every ride
+	UserIDs []uuid.UUID
+	// Secret keys the delivery signatures; a random one is generated when it is empty
+	Secret string
+}
+
+// WebhookChanges lists the settings of a webhook subscription to change; nil fields are left as they are
+type WebhookChanges struct {
+	Name       *string
+	URL        *string
+	EventTypes *[]model.DomainEventType
+	UserIDs    *[]uuid.UUID
+	Secret     *string
+	Active     *bool
+}
+
+// WebhookDeliveryLog is a delivery together with every attempt at posting it, oldest first
+type WebhookDeliveryLog struct {
+	Delivery model.WebhookDelivery  `json:"delivery"`
+	Attempts []model.WebhookAttempt `json:"attempts"`
+}
+
+// WebhookService manages partner webhooks and delivers domain events to them
+type WebhookService struct {
+	webhookRepo repository.WebhookRepository
+	rideRepo    repository.RideRepository
+	sender      *webhook.Sender
+	options     WebhookOptions
+}
+
+// NewWebhookService creates a new WebhookService
+func NewWebhookService(
+	webhookRepo repository.WebhookRepository,
+	rideRepo repository.RideRepository,
+	sender *webhook.Sender,
+	options WebhookOptions,
+) *WebhookService {
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultWebhookBatchSize
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaultWebhookBackoff
+	}
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		rideRepo:    rideRepo,
+		sender:      sender,
+		options:     options,
+	}
+}
+
+// CreateWebhook subscribes a URL to domain events. The subscription is returned with its secret,
+// which is not shown again.
+func (s *WebhookService) CreateWebhook(input WebhookInput) (*model.WebhookSubscription, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, errors.New("webhook name is required")
+	}
+	if err := validateWebhookURL(input.URL); err != nil {
+		return nil, err
+	}
+	if err := validateEventTypes(input.EventTypes); err != nil {
+		return nil, err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	} else if len(secret) < minWebhookSecretLength {
+		return nil, fmt.Errorf("webhook secret must be at least %d characters", minWebhookSecretLength)
+	}
+
+	subscription := &model.WebhookSubscription{
+		Name:       strings.TrimSpace(input.Name),
+		URL:        input.URL,
+		Secret:     secret,
+		EventTypes: model.EventTypeList(input.EventTypes),
+		UserIDs:    model.UserIDList(input.UserIDs),
+		Active:     true,
+	}
+	if err := s.webhookRepo.CreateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// GetWebhooks retrieves every webhook subscription
+func (s *WebhookService) GetWebhooks() ([]model.WebhookSubscription, error) {
+	return s.webhookRepo.FindSubscriptions()
+}
+
+// GetWebhook retrieves a webhook subscription by ID
+func (s *WebhookService) GetWebhook(id uuid.UUID) (*model.WebhookSubscription, error) {
+	return s.webhookRepo.FindSubscriptionByID(id)
+}
+
+// UpdateWebhook changes the settings of a webhook subscription. Changes apply to the deliveries
+// attempted from then on, including the retries of earlier events.
+func (s *WebhookService) UpdateWebhook(id uuid.UUID, changes WebhookChanges) (*model.WebhookSubscription, error) {
+	if changes == (WebhookChanges{}) {
+		return nil, ErrNoChanges
+	}
+
+	subscription, err := s.webhookRepo.FindSubscriptionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	if changes.Name != nil {
+		if strings.TrimSpace(*changes.Name) == "" {
+			return nil, errors.New("webhook name is required")
+		}
+		subscription.Name = strings.TrimSpace(*changes.Name)
+	}
+	if changes.URL != nil {
+		if err := validateWebhookURL(*changes.URL); err != nil {
+			return nil, err
+		}
+		subscription.URL = *changes.URL
+	}
+	if changes.EventTypes != nil {
+		if err := validateEventTypes(*changes.EventTypes); err != nil {
+			return nil, err
+		}
+		subscription.EventTypes = model.EventTypeList(*changes.EventTypes)
+	}
+	if changes.UserIDs != nil {
+		subscription.UserIDs = model.UserIDList(*changes.UserIDs)
+	}
+	if changes.Secret != nil {
+		if len(*changes.Secret) < minWebhookSecretLength {
+			return nil, fmt.Errorf("webhook secret must be at least %d characters", minWebhookSecretLength)
+		}
+		subscription.Secret = *changes.Secret
+	}
+	if changes.Active != nil {
+		subscription.Active = *changes.Active
+	}
+
+	if err := s.webhookRepo.UpdateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// DeleteWebhook removes a webhook subscription along with its deliveries and their log
+func (s *WebhookService) DeleteWebhook(id uuid.UUID) error {
+	subscription, err := s.webhookRepo.FindSubscriptionByID(id)
+	if err != nil {
+		return err
+	}
+	if subscription == nil {
+		return ErrWebhookNotFound
+	}
+	return s.webhookRepo.DeleteSubscription(id)
+}
+
+// GetWebhookDeliveries retrieves the latest deliveries of a webhook, newest first, optionally only
+// those with the given status
+func (s *WebhookService) GetWebhookDeliveries(id uuid.UUID, status model.WebhookDeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	subscription, err := s.webhookRepo.FindSubscriptionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	if limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+	return s.webhookRepo.FindDeliveriesBySubscriptionID(id, status, limit)
+}
+
+// GetWebhookDelivery retrieves a delivery with the log of its attempts
+func (s *WebhookService) GetWebhookDelivery(id uuid.UUID) (*WebhookDeliveryLog, error) {
+	delivery, err := s.webhookRepo.FindDeliveryByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, ErrDeliveryNotFound
+	}
+
+	attempts, err := s.webhookRepo.FindAttemptsByDeliveryID(id)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookDeliveryLog{Delivery: *delivery, Attempts: attempts}, nil
+}
+
+// ReplayWebhookDelivery sends a dead or delivered event to its webhook again. The delivery is
+// queued with a fresh set of attempts and keeps its ID, so that the receiver can tell it is a repeat.
+func (s *WebhookService) ReplayWebhookDelivery(id uuid.UUID) (*model.WebhookDelivery, error) {
+	delivery, err := s.webhookRepo.FindDeliveryByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, ErrDeliveryNotFound
+	}
+	if delivery.Status == model.WebhookPending {
+		return nil, ErrDeliveryPending
+	}
+
+	delivery.Status = model.WebhookPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.LastError = ""
+	delivery.DeliveredAt = nil
+	if err := s.webhookRepo.UpdateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// HandleEvent queues a domain event for every active webhook that subscribes to its type and covers
+// the users of its ride. It is handed events by the outbox relay, which hands an event over again
+// when handling it failed; an event is only queued once per webhook.
+func (s *WebhookService) HandleEvent(ctx context.Context, message eventbus.Message) error {
+	subscriptions, err := s.webhookRepo.FindActiveSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	var users []uuid.UUID
+	usersFound := false
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if !subscription.Subscribes(model.DomainEventType(message.Type)) {
+			continue
+		}
+		if len(subscription.UserIDs) > 0 {
+			if !usersFound {
+				if users, err = s.eventUsers(message); err != nil {
+					return err
+				}
+				usersFound = true
+			}
+			if !subscription.Covers(users) {
+				continue
+			}
+		}
+
+		existing, err := s.webhookRepo.FindDeliveryByEvent(subscription.ID, message.ID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+
+		if body == nil {
+			if body, err = json.Marshal(message); err != nil {
+				return err
+			}
+		}
+		delivery := &model.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        message.ID,
+			EventType:      model.DomainEventType(message.Type),
+			Body:           model.EventPayload(body),
+			Status:         model.WebhookPending,
+			NextAttemptAt:  time.Now(),
+		}
+		if err := s.webhookRepo.CreateDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverWebhooks posts the deliveries that are due, until none are left. A delivery that fails is
+// retried later, waiting twice as long after every failure, and is given up as dead after the last
+// attempt. Deliveries are at least once and not ordered: receivers should skip the event IDs they
+// have already seen.
+func (s *WebhookService) DeliverWebhooks(ctx context.Context) error {
+	for {
+		deliveries, err := s.webhookRepo.FindDueDeliveries(time.Now(), s.options.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range deliveries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.attempt(ctx, &deliveries[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < s.options.BatchSize {
+			return nil
+		}
+	}
+}
+
+// attempt posts a delivery once and records the outcome in the delivery and its log
+func (s *WebhookService) attempt(ctx context.Context, delivery *model.WebhookDelivery) error {
+	subscription, err := s.webhookRepo.FindSubscriptionByID(delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	if subscription == nil {
+		// The webhook was deleted, and its deliveries with it, after the delivery was read
+		return nil
+	}
+	if !subscription.Active {
+		// The webhook was paused after the delivery was read; the delivery stays pending and is
+		// sent once the webhook is resumed
+		return nil
+	}
+
+	result, sendErr := s.sender.Send(ctx, webhook.Delivery{
+		ID:        delivery.ID,
+		EventType: string(delivery.EventType),
+		URL:       subscription.URL,
+		Secret:    subscription.Secret,
+		Body:      delivery.Body,
+	})
+	if sendErr != nil && ctx.Err() != nil {
+		// Shutting down is not the webhook's fault; the attempt is made again on the next start
+		return ctx.Err()
+	}
+
+	attempt := &model.WebhookAttempt{
+		DeliveryID:   delivery.ID,
+		StatusCode:   result.StatusCode,
+		ResponseBody: result.Body,
+		DurationMs:   result.Duration.Milliseconds(),
+	}
+	now := time.Now()
+	delivery.Attempts++
+	if sendErr == nil {
+		delivery.Status = model.WebhookDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		attempt.Error = sendErr.Error()
+		delivery.LastError = sendErr.Error()
+		if delivery.Attempts >= s.options.MaxAttempts {
+			delivery.Status = model.WebhookDead
+		} else {
+			delivery.NextAttemptAt = now.Add(s.retryDelay(delivery.Attempts))
+		}
+	}
+
+	if err := s.webhookRepo.CreateAttempt(attempt); err != nil {
+		return err
+	}
+	return s.webhookRepo.UpdateDelivery(delivery)
+}
+
+// retryDelay returns how long to wait before retrying a delivery that failed the given number of times
+func (s *WebhookService) retryDelay(failures int) time.Duration {
+	delay := s.options.Backoff
+	for i := 1; i < failures && (s.options.MaxBackoff <= 0 || delay < s.options.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if s.options.MaxBackoff > 0 && delay > s.options.MaxBackoff {
+		delay = s.options.MaxBackoff
+	}
+	return delay
+}
+
+// eventParties is the part of an event payload naming the users of a ride, directly for ride offers
+// and requests or through the offer and request of a match
+type eventParties struct {
+	DriverID      uuid.UUID `json:"driver_id"`
+	PassengerID   uuid.UUID `json:"passenger_id"`
+	RideOfferID   uuid.UUID `json:"ride_offer_id"`
+	RideRequestID uuid.UUID `json:"ride_request_id"`
+}
+
+// eventUsers returns the drivers and passengers an event is about. Events about a ride offer are
+// also about the passengers who confirmed a seat on it.
+func (s *WebhookService) eventUsers(message eventbus.Message) ([]uuid.UUID, error) {
+	var parties eventParties
+	if err := json.Unmarshal(message.Payload, &parties); err != nil {
+		return nil, err
+	}
+
+	var users []uuid.UUID
+	if strings.HasPrefix(message.Type, "ride_offer.") {
+		matches, err := s.rideRepo.FindRideMatchesByOfferID(message.AggregateID)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if match.Status != model.StatusConfirmed && match.Status != model.StatusInProgress && match.Status != model.StatusCompleted {
+				continue
+			}
+			request, err := s.rideRepo.FindRideRequestByID(match.RideRequestID)
+			if err != nil {
+				return nil, err
+			}
+			if request != nil {
+				users = append(users, request.PassengerID)
+			}
+		}
+	}
+
+	if parties.DriverID == uuid.Nil && parties.RideOfferID != uuid.Nil {
+		offer, err := s.rideRepo.FindRideOfferByID(parties.RideOfferID)
+		if err != nil {
+			return nil, err
+		}
+		if offer != nil {
+			parties.DriverID = offer.DriverID
+		}
+	}
+	if parties.PassengerID == uuid.Nil && parties.RideRequestID != uuid.Nil {
+		request, err := s.rideRepo.FindRideRequestByID(parties.RideRequestID)
+		if err != nil {
+			return nil, err
+		}
+		if request != nil {
+			parties.PassengerID = request.PassengerID
+		}
+	}
+
+	for _, userID := range []uuid.UUID{parties.DriverID, parties.PassengerID} {
+		if userID != uuid.Nil {
+			users = append(users, userID)
+		}
+	}
+	return users, nil
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute http or https URL
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("webhook URL must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// validateEventTypes checks that a webhook subscribes to at least one event and only to known ones
+func validateEventTypes(eventTypes []model.DomainEventType) error {
+	if len(eventTypes) == 0 {
+		return errors.New("webhook must subscribe to at least one event type")
+	}
+	for _, eventType := range eventTypes {
+		if !model.IsDomainEventType(eventType) {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+// generateWebhookSecret returns a random secret for signing deliveries
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
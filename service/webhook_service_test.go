@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/ride-sharing-app/domain/model"
+	"github.com/yourusername/ride-sharing-app/infrastructure/webhook"
+	"github.com/yourusername/ride-sharing-app/repository/memory"
+)
+
+func TestWebhookRetryDelay(t *testing.T) {
+	cases := []struct {
+		name       string
+		maxBackoff time.Duration
+		failures   int
+		want       time.Duration
+	}{
+		{name: "first retry", maxBackoff: 10 * time.Minute, failures: 1, want: time.Minute},
+		{name: "doubles", maxBackoff: 10 * time.Minute, failures: 2, want: 2 * time.Minute},
+		{name: "doubles again", maxBackoff: 10 * time.Minute, failures: 4, want: 8 * time.Minute},
+		{name: "capped", maxBackoff: 10 * time.Minute, failures: 5, want: 10 * time.Minute},
+		{name: "stays capped", maxBackoff: 10 * time.Minute, failures: 200, want: 10 * time.Minute},
+		{name: "cap below the backoff", maxBackoff: 30 * time.Second, failures: 1, want: 30 * time.Second},
+		{name: "uncapped", maxBackoff: 0, failures: 11, want: 1024 * time.Minute},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := NewWebhookService(nil, nil, nil, WebhookOptions{Backoff: time.Minute, MaxBackoff: tc.maxBackoff})
+			if got := service.retryDelay(tc.failures); got != tc.want {
+				t.Errorf("retryDelay(%d) = %s, want %s", tc.failures, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeliverWebhooksHoldsPausedWebhooks(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer server.Close()
+
+	webhookRepo := memory.NewWebhookRepository()
+	service := NewWebhookService(webhookRepo, memory.NewRideRepository(), webhook.NewSender(time.Second), WebhookOptions{MaxAttempts: 1})
+	subscription, err := service.CreateWebhook(WebhookInput{
+		Name:       "Partner",
+		URL:        server.URL,
+		EventTypes: []model.DomainEventType{model.EventMatchConfirmed},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	delivery := &model.WebhookDelivery{
+		SubscriptionID: subscription.ID,
+		EventID:        uuid.New(),
+		EventType:      model.EventMatchConfirmed,
+		Body:           model.EventPayload(`{}`),
+		Status:         model.WebhookPending,
+		NextAttemptAt:  time.Now(),
+	}
+	if err := webhookRepo.CreateDelivery(delivery); err != nil {
+		t.Fatalf("CreateDelivery: %v", err)
+	}
+
+	paused, resumed := false, true
+	if _, err := service.UpdateWebhook(subscription.ID, WebhookChanges{Active: &paused}); err != nil {
+		t.Fatalf("UpdateWebhook(pause): %v", err)
+	}
+	if err := service.DeliverWebhooks(context.Background()); err != nil {
+		t.Fatalf("DeliverWebhooks: %v", err)
+	}
+	held, err := webhookRepo.FindDeliveryByID(delivery.ID)
+	if err != nil || held.Status != model.WebhookPending || held.Attempts != 0 || atomic.LoadInt32(&received) != 0 {
+		t.Fatalf("delivery to a paused webhook = %+v, %v, want it pending and unsent", held, err)
+	}
+
+	if _, err := service.UpdateWebhook(subscription.ID, WebhookChanges{Active: &resumed}); err != nil {
+		t.Fatalf("UpdateWebhook(resume): %v", err)
+	}
+	if err := service.DeliverWebhooks(context.Background()); err != nil {
+		t.Fatalf("DeliverWebhooks: %v", err)
+	}
+	sent, err := webhookRepo.FindDeliveryByID(delivery.ID)
+	if err != nil || sent.Status != model.WebhookDelivered || atomic.LoadInt32(&received) != 1 {
+		t.Fatalf("delivery after resuming = %+v, %v, want it delivered once", sent, err)
+	}
+}